@@ -0,0 +1,69 @@
+package psi
+
+import "bytes"
+
+const logStripANSIEnv = "PSI_LOG_STRIP_ANSI"
+
+// WithLogStripANSI makes the init remove ANSI escape sequences (colours,
+// cursor movement, window titles) from the child's output before it is
+// forwarded, written to the log files or kept for the termination message,
+// for programs that colour their output without a terminal to show it.
+// Overridden by PSI_LOG_STRIP_ANSI.
+func WithLogStripANSI() Option {
+	return func(c *config) {
+		c.logStripANSI = true
+	}
+}
+
+// stripANSI is a lineFilter removing escape sequences: CSI (ESC [ ... final
+// byte), OSC and the other string controls (ESC ] ... BEL or ESC \), and
+// two- or three-byte escapes such as ESC ( B.
+func stripANSI(line []byte) []byte {
+	i := bytes.IndexByte(line, 0x1b)
+	if i < 0 {
+		return line
+	}
+	out := append([]byte(nil), line[:i]...)
+	for i < len(line) {
+		c := line[i]
+		if c != 0x1b {
+			out = append(out, c)
+			i++
+			continue
+		}
+		i++
+		if i == len(line) {
+			break
+		}
+		switch c := line[i]; {
+		case c == '[':
+			// Parameter and intermediate bytes up to a final byte.
+			i++
+			for i < len(line) && (line[i] < 0x40 || line[i] > 0x7e) {
+				i++
+			}
+			i++
+		case c == ']' || c == 'P' || c == 'X' || c == '^' || c == '_':
+			// A string terminated by BEL or ST (ESC \).
+			i++
+			for i < len(line) {
+				if line[i] == 0x07 {
+					i++
+					break
+				}
+				if line[i] == 0x1b && i+1 < len(line) && line[i+1] == '\\' {
+					i += 2
+					break
+				}
+				i++
+			}
+		default:
+			// Intermediate bytes, then a final byte.
+			for i < len(line) && line[i] >= 0x20 && line[i] <= 0x2f {
+				i++
+			}
+			i++
+		}
+	}
+	return out
+}
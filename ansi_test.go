@@ -0,0 +1,44 @@
+//go:build !windows
+
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStripANSI(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":                                    "plain",
+		"\x1b[31mred\x1b[0m":                       "red",
+		"\x1b[1;38;5;208mbold orange\x1b[m text":   "bold orange text",
+		"\x1b]0;title\x07after":                    "after",
+		"\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\": "link",
+		"\x1b(Bcharset":                            "charset",
+		"\x1bcreset":                               "reset",
+		"\x1b[2K\rprogress 50%":                    "\rprogress 50%",
+		"unterminated \x1b[31":                     "unterminated ",
+		"trailing \x1b":                            "trailing ",
+	} {
+		if got := string(stripANSI([]byte(in))); got != want {
+			t.Errorf("stripANSI(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSupervisorStripsANSI(t *testing.T) {
+	dir := t.TempDir()
+	cmd := helperCommand("init-color", logStripANSIEnv+"=1", logDirEnv+"="+dir)
+	out, err := cmd.Output()
+	if exit := exitStatus(err); exit != 1 {
+		t.Fatalf("expected exit code 1, got %d", exit)
+	}
+	if string(out) != "green\n" {
+		t.Fatalf("stdout = %q", out)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "stdout.log"))
+	if err != nil || string(b) != "green\n" {
+		t.Fatalf("stdout.log = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,83 @@
+package psi
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+const autoTuneEnv = "PSI_AUTOTUNE"
+
+// memLimitRatio is the share of the container memory limit handed to
+// GOMEMLIMIT, leaving headroom for non-heap memory.
+const memLimitRatio = 0.9
+
+// WithAutoTune calls AutoTuneRuntime in the process running submain before
+// submain starts. Overridden by PSI_AUTOTUNE.
+func WithAutoTune() Option {
+	return func(c *config) {
+		c.autoTune = true
+	}
+}
+
+// AutoTuneRuntime sizes GOMAXPROCS from the cgroup CPU quota and GOMEMLIMIT
+// from the cgroup memory limit (90% of it) of the current process. Values
+// set explicitly through the GOMAXPROCS or GOMEMLIMIT environment variables
+// are left alone. It returns the values it applied, zero for those left
+// unchanged.
+func AutoTuneRuntime() (maxProcs int, memLimit int64) {
+	quotaProcs, memMax := cgroupLimits()
+	if quotaProcs > 0 && os.Getenv("GOMAXPROCS") == "" && quotaProcs < runtime.NumCPU() {
+		runtime.GOMAXPROCS(quotaProcs)
+		maxProcs = quotaProcs
+	}
+	if memMax > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		memLimit = int64(float64(memMax) * memLimitRatio)
+		debug.SetMemoryLimit(memLimit)
+	}
+	return maxProcs, memLimit
+}
+
+// parseCPUMax converts a cgroup v2 cpu.max value ("max 100000" or
+// "150000 100000") into a CPU count, rounding up. Zero means unlimited.
+func parseCPUMax(s string) int {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || fields[0] == "max" {
+		return 0
+	}
+	period := 100000.0
+	if len(fields) > 1 {
+		if p, err := strconv.ParseFloat(fields[1], 64); err == nil && p > 0 {
+			period = p
+		}
+	}
+	return cpuQuotaProcs(fields[0], period)
+}
+
+// cpuQuotaProcs converts a CFS quota and period into a CPU count, rounding
+// up to at least one. Negative or invalid quotas mean unlimited (zero).
+func cpuQuotaProcs(quota string, period float64) int {
+	q, err := strconv.ParseFloat(strings.TrimSpace(quota), 64)
+	if err != nil || q <= 0 || period <= 0 {
+		return 0
+	}
+	return max(1, int(math.Ceil(q/period)))
+}
+
+// parseMemoryMax parses a cgroup memory limit ("max" or bytes). Zero means
+// unlimited; cgroup v1's "no limit" sentinel (close to MaxInt64) counts as
+// unlimited too.
+func parseMemoryMax(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "max" {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 || n >= math.MaxInt64/2 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,39 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupLimits reads the CPU quota (as a CPU count) and memory limit of the
+// current process' cgroup, preferring cgroup v2 and falling back to the v1
+// cpu and memory controllers. Zero means unlimited or unknown.
+func cgroupLimits() (procs int, memMax int64) {
+	if mount, err := cgroup2Mount(); err == nil {
+		if self, err := ownCgroup2Path(); err == nil {
+			dir := filepath.Join(mount, self)
+			if b, err := os.ReadFile(filepath.Join(dir, "cpu.max")); err == nil {
+				procs = parseCPUMax(string(b))
+			}
+			if b, err := os.ReadFile(filepath.Join(dir, "memory.max")); err == nil {
+				memMax = parseMemoryMax(string(b))
+			}
+			if procs > 0 || memMax > 0 {
+				return procs, memMax
+			}
+		}
+	}
+	quota, errQ := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period, errP := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if errQ == nil && errP == nil {
+		if p, err := strconv.ParseFloat(strings.TrimSpace(string(period)), 64); err == nil {
+			procs = cpuQuotaProcs(string(quota), p)
+		}
+	}
+	if b, err := os.ReadFile("/sys/fs/cgroup/memory/memory.limit_in_bytes"); err == nil {
+		memMax = parseMemoryMax(string(b))
+	}
+	return procs, memMax
+}
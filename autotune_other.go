@@ -0,0 +1,5 @@
+//go:build !linux
+
+package psi
+
+func cgroupLimits() (procs int, memMax int64) { return 0, 0 }
@@ -0,0 +1,35 @@
+package psi
+
+import "testing"
+
+func TestParseCPUMax(t *testing.T) {
+	cases := map[string]int{
+		"max 100000":    0,
+		"":              0,
+		"100000 100000": 1,
+		"150000 100000": 2,
+		"50000 100000":  1,
+		"400000":        4,
+		"bogus 100000":  0,
+	}
+	for input, want := range cases {
+		if got := parseCPUMax(input); got != want {
+			t.Fatalf("parseCPUMax(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestParseMemoryMax(t *testing.T) {
+	cases := map[string]int64{
+		"max":                 0,
+		"536870912\n":         536870912,
+		"9223372036854771712": 0,
+		"-1":                  0,
+		"junk":                0,
+	}
+	for input, want := range cases {
+		if got := parseMemoryMax(input); got != want {
+			t.Fatalf("parseMemoryMax(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package psi
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// modulePath is psi's module path, to find its version in the build info.
+const modulePath = "pkt.systems/psi"
+
+// bannerSettings are the build settings the banner shows; -ldflags is left
+// out as it may carry values set with -X.
+var bannerSettings = []string{"GOOS", "GOARCH", "CGO_ENABLED", "-tags", "vcs.revision", "vcs.time", "vcs.modified"}
+
+// logBanner logs the init's version, build and effective configuration as
+// a single record when it starts, so that any excerpt of its log says what
+// ran and how.
+func (s *supervisor) logBanner() {
+	kv := []any{"version", "(devel)", "go", runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v := moduleVersion(info); v != "" {
+			kv[1] = v
+		}
+		if info.Main.Path != "" && info.Main.Path != modulePath {
+			kv = append(kv, "main", info.Main.Path+"@"+info.Main.Version)
+		}
+		for _, key := range bannerSettings {
+			for _, st := range info.Settings {
+				if st.Key == key {
+					kv = append(kv, st.Key, st.Value)
+				}
+			}
+		}
+	}
+	stopSignal := "forwarded"
+	if s.cfg.stopSignal != 0 {
+		stopSignal = signalName(s.cfg.stopSignal)
+	}
+	kv = append(kv,
+		"mode", s.cfg.superviseMode(),
+		"pid", os.Getpid(),
+		"stop_timeout", s.stopTimeout.String(),
+		"stop_signal", stopSignal,
+		"umask", s.cfg.umaskString(),
+		"subreaper", s.cfg.subreaper && os.Getpid() != 1,
+	)
+	logFields(LogInfo, "starting", kv...)
+}
+
+// moduleVersion returns psi's version from info, "" if it is unknown.
+func moduleVersion(info *debug.BuildInfo) string {
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// superviseMode names the reason the init supervises: it is PID 1, a child
+// subreaper, or told to supervise anyway.
+func (c *config) superviseMode() string {
+	switch {
+	case os.Getpid() == 1:
+		return "pid1"
+	case c.subreaper:
+		return "subreaper"
+	}
+	return "supervised"
+}
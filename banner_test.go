@@ -0,0 +1,67 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestFormatFields(t *testing.T) {
+	got := formatFields([]any{"version", "v1.2.0", "tags", "netgo osusergo", "pid", 1, "empty", "", "subreaper", false})
+	want := `version=v1.2.0 tags="netgo osusergo" pid=1 empty="" subreaper=false`
+	if got != want {
+		t.Fatalf("formatFields = %s, want %s", got, want)
+	}
+}
+
+func TestJSONLogFields(t *testing.T) {
+	var out strings.Builder
+	jl := &jsonLog{w: &out}
+	jl.fields(LogInfo, "starting", []any{"version", "v1.2.0", "pid", 7, "subreaper", true})
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &rec); err != nil {
+		t.Fatalf("%q: %v", out.String(), err)
+	}
+	if rec["msg"] != "starting" || rec["level"] != "info" || rec["version"] != "v1.2.0" || rec["pid"] != 7.0 || rec["subreaper"] != true {
+		t.Fatalf("record = %v", rec)
+	}
+	if !strings.HasPrefix(out.String(), `{"time":`) {
+		t.Fatalf("fields before the time: %s", out.String())
+	}
+}
+
+func TestModuleVersion(t *testing.T) {
+	info := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/app", Version: "v0.3.0"},
+		Deps: []*debug.Module{{Path: modulePath, Version: "v1.4.0"}},
+	}
+	if v := moduleVersion(info); v != "v1.4.0" {
+		t.Fatalf("dependency version = %q", v)
+	}
+	info.Deps[0].Replace = &debug.Module{Path: "../psi", Version: "v1.4.1"}
+	if v := moduleVersion(info); v != "v1.4.1" {
+		t.Fatalf("replaced version = %q", v)
+	}
+	info.Main.Path, info.Deps = modulePath, nil
+	if v := moduleVersion(info); v != "v0.3.0" {
+		t.Fatalf("main module version = %q", v)
+	}
+}
+
+func TestSupervisorLogsBanner(t *testing.T) {
+	cmd := helperCommand("init-stderr", logFormatEnv+"=json", stopSignalEnv+"=SIGINT", umaskEnv+"=0027")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	cmd.Run()
+	first, _, _ := strings.Cut(stderr.String(), "\n")
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(first), &rec); err != nil {
+		t.Fatalf("%q: %v", first, err)
+	}
+	if rec["msg"] != "starting" || rec["stop_signal"] != "SIGINT" || rec["stop_timeout"] != "30s" || rec["umask"] != "0027" || rec["go"] == nil || rec["mode"] == nil {
+		t.Fatalf("banner = %v", rec)
+	}
+}
@@ -0,0 +1,277 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+const (
+	capDropEnv = "PSI_CAP_DROP"
+	capKeepEnv = "PSI_CAP_KEEP"
+)
+
+// capabilityNames maps capability names (without the CAP_ prefix) to their
+// numbers.
+var capabilityNames = map[string]uint{
+	"CHOWN":              0,
+	"DAC_OVERRIDE":       1,
+	"DAC_READ_SEARCH":    2,
+	"FOWNER":             3,
+	"FSETID":             4,
+	"KILL":               5,
+	"SETGID":             6,
+	"SETUID":             7,
+	"SETPCAP":            8,
+	"LINUX_IMMUTABLE":    9,
+	"NET_BIND_SERVICE":   10,
+	"NET_BROADCAST":      11,
+	"NET_ADMIN":          12,
+	"NET_RAW":            13,
+	"IPC_LOCK":           14,
+	"IPC_OWNER":          15,
+	"SYS_MODULE":         16,
+	"SYS_RAWIO":          17,
+	"SYS_CHROOT":         18,
+	"SYS_PTRACE":         19,
+	"SYS_PACCT":          20,
+	"SYS_ADMIN":          21,
+	"SYS_BOOT":           22,
+	"SYS_NICE":           23,
+	"SYS_RESOURCE":       24,
+	"SYS_TIME":           25,
+	"SYS_TTY_CONFIG":     26,
+	"MKNOD":              27,
+	"LEASE":              28,
+	"AUDIT_WRITE":        29,
+	"AUDIT_CONTROL":      30,
+	"SETFCAP":            31,
+	"MAC_OVERRIDE":       32,
+	"MAC_ADMIN":          33,
+	"SYSLOG":             34,
+	"WAKE_ALARM":         35,
+	"BLOCK_SUSPEND":      36,
+	"AUDIT_READ":         37,
+	"PERFMON":            38,
+	"BPF":                39,
+	"CHECKPOINT_RESTORE": 40,
+}
+
+// WithCapabilities restricts the child's capabilities. drop lists
+// capabilities removed from the child's bounding and inheritable sets ("ALL"
+// removes every capability); keep lists capabilities exempt from drop, which
+// are also raised as ambient capabilities when the child runs as a non-root
+// user (see WithUser). Names may carry the CAP_ prefix and are
+// case-insensitive. Overridden by PSI_CAP_DROP and PSI_CAP_KEEP.
+func WithCapabilities(drop, keep []string) Option {
+	return func(c *config) {
+		c.caps.drop = append([]string(nil), drop...)
+		c.caps.keep = append([]string(nil), keep...)
+	}
+}
+
+// capConfig is the child's capability policy, as capability names.
+type capConfig struct {
+	drop []string
+	keep []string
+}
+
+// loadEnv applies the PSI_CAP_DROP and PSI_CAP_KEEP overrides.
+func (c *capConfig) loadEnv() {
+	for key, dst := range map[string]*[]string{capDropEnv: &c.drop, capKeepEnv: &c.keep} {
+		val := strings.TrimSpace(os.Getenv(key))
+		if val == "" {
+			continue
+		}
+		names := strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' })
+		if _, err := parseCapabilities(names); err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", key, val, err)
+			continue
+		}
+		*dst = names
+	}
+}
+
+func (c *capConfig) enabled() bool {
+	return len(c.drop) > 0 || len(c.keep) > 0
+}
+
+// parseCapabilities converts capability names to a bit mask. "ALL" sets
+// every bit.
+func parseCapabilities(names []string) (uint64, error) {
+	var mask uint64
+	for _, name := range names {
+		name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "CAP_")
+		if name == "" {
+			continue
+		}
+		if name == "ALL" {
+			mask = ^uint64(0)
+			continue
+		}
+		n, ok := capabilityNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown capability %q", name)
+		}
+		mask |= 1 << n
+	}
+	return mask, nil
+}
+
+// resolve returns the mask of capabilities to drop and the list of
+// capabilities to keep.
+func (c *capConfig) resolve() (drop uint64, keep []uintptr, err error) {
+	if drop, err = parseCapabilities(c.drop); err != nil {
+		return 0, nil, err
+	}
+	keepMask, err := parseCapabilities(c.keep)
+	if err != nil {
+		return 0, nil, err
+	}
+	if keepMask == ^uint64(0) {
+		return 0, nil, fmt.Errorf("cannot keep ALL capabilities")
+	}
+	for n := range uint(64) {
+		if keepMask&(1<<n) != 0 {
+			keep = append(keep, uintptr(n))
+		}
+	}
+	return drop &^ keepMask, keep, nil
+}
+
+// startChildProcess runs start, which forks the child, on a thread prepared
+// with the child's CPU affinity, CPU and I/O priorities, UTS and mount
+// namespaces, capability restrictions, no_new_privs flag, Landlock ruleset
+// and seccomp filter, and the umask of an external program; the forked child
+// inherits them. A restricted thread cannot be restored, so it stays locked
+// and the runtime discards it once start returns. Lowered priorities cannot
+// always be raised again, nor a thread's filesystem attributes shared again.
+func (c *config) startChildProcess(attr *syscall.SysProcAttr, start func() error) error {
+	forkUmask := threadUmask && c.forkUmask()
+	if !c.restricted() && !c.sched.enabled() && !forkUmask {
+		unpin := c.pinThread()
+		defer unpin()
+		return start()
+	}
+	drop, keep, err := c.caps.resolve()
+	if err != nil {
+		return err
+	}
+	filter, err := c.seccompFilter()
+	if err != nil {
+		return err
+	}
+	if runsAsUser(attr) {
+		setAmbientCaps(attr, keep)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		c.pinThread()
+		c.sched.applyThread()
+		if forkUmask {
+			if err := c.setForkUmask(); err != nil {
+				errc <- err
+				return
+			}
+		}
+		if err := c.restrictThread(drop, filter); err != nil {
+			errc <- err
+			return
+		}
+		errc <- start()
+	}()
+	return <-errc
+}
+
+// restricted reports whether the child's thread needs restrictions that
+// cannot be undone.
+func (c *config) restricted() bool {
+	return c.unshareUTS || c.isolation.enabled || c.caps.enabled() || c.noNewPrivs ||
+		c.landlock.enabled() || c.seccompProfile != ""
+}
+
+// restrictThread unshares the UTS and mount namespaces, drops the
+// capabilities in drop, sets no_new_privs, applies the Landlock ruleset and
+// installs filter on the calling thread as configured. The seccomp filter goes last so it
+// cannot block the other steps.
+func (c *config) restrictThread(drop uint64, filter []bpfInsn) error {
+	if c.unshareUTS {
+		if err := unshareUTS(c.hostname); err != nil {
+			return err
+		}
+	}
+	if c.isolation.enabled {
+		if err := c.isolation.restrictThread(); err != nil {
+			return err
+		}
+	}
+	if c.caps.enabled() {
+		if err := restrictThreadCaps(drop); err != nil {
+			return fmt.Errorf("dropping capabilities: %w", err)
+		}
+	}
+	if c.noNewPrivs {
+		if err := setNoNewPrivs(); err != nil {
+			return fmt.Errorf("PR_SET_NO_NEW_PRIVS: %w", err)
+		}
+	}
+	if c.landlock.enabled() {
+		if err := c.landlock.restrictThread(); err != nil {
+			return err
+		}
+	}
+	if filter != nil {
+		if err := installSeccomp(filter); err != nil {
+			return fmt.Errorf("installing seccomp filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// restrictExec applies the mount isolation, capability restrictions,
+// no_new_privs, Landlock, the seccomp filter and the configured user to the current thread right before
+// it execs the program.
+func (c *config) restrictExec() {
+	runtime.LockOSThread()
+	if !c.restricted() {
+		c.dropPrivileges()
+		return
+	}
+	drop, keep, err := c.caps.resolve()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	filter, err := c.seccompFilter()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := c.restrictThread(drop, nil); err != nil {
+		fatalf("%v", err)
+	}
+	cred, err := c.credential()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	nonRoot := cred != nil && cred.Uid != 0
+	if nonRoot && len(keep) > 0 {
+		if err := setKeepCaps(); err != nil {
+			fatalf("PR_SET_KEEPCAPS: %v", err)
+		}
+	}
+	c.dropPrivileges()
+	if nonRoot && len(keep) > 0 {
+		if err := raiseAmbientCaps(keep); err != nil {
+			fatalf("raising ambient capabilities: %v", err)
+		}
+	}
+	if filter != nil {
+		// After the credential switch, which the filter may forbid.
+		if err := installSeccomp(filter); err != nil {
+			fatalf("installing seccomp filter: %v", err)
+		}
+	}
+}
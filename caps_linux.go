@@ -0,0 +1,71 @@
+package psi
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setAmbientCaps makes the child raise caps as ambient capabilities after
+// switching to its non-root credentials.
+func setAmbientCaps(attr *syscall.SysProcAttr, caps []uintptr) {
+	attr.AmbientCaps = caps
+}
+
+// restrictThreadCaps removes the capabilities in drop from the calling
+// thread's bounding and inheritable sets. Capabilities already missing from
+// the bounding set are skipped, so dropping needs CAP_SETPCAP only when there
+// is something left to drop.
+func restrictThreadCaps(drop uint64) error {
+	for n := range uintptr(64) {
+		if drop&(1<<n) == 0 {
+			continue
+		}
+		in, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, n, 0, 0, 0)
+		if err != nil {
+			// EINVAL: beyond the kernel's last capability.
+			break
+		}
+		if in == 1 {
+			if err := unix.Prctl(unix.PR_CAPBSET_DROP, n, 0, 0, 0); err != nil {
+				return err
+			}
+		}
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+	data[0].Inheritable &^= uint32(drop)
+	data[1].Inheritable &^= uint32(drop >> 32)
+	return unix.Capset(&hdr, &data[0])
+}
+
+// setKeepCaps preserves the thread's permitted capabilities across setuid.
+func setKeepCaps() error {
+	return unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0)
+}
+
+// raiseAmbientCaps makes caps permitted, inheritable and ambient on the
+// calling thread so they survive exec as a non-root user.
+func raiseAmbientCaps(caps []uintptr) error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+	for _, c := range caps {
+		data[c/32].Permitted |= 1 << (c % 32)
+		data[c/32].Inheritable |= 1 << (c % 32)
+	}
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return err
+	}
+	for _, c := range caps {
+		if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, c, 0, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
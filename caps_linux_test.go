@@ -0,0 +1,92 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartChildProcessDropsCapabilities(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to drop bounding capabilities")
+	}
+	cfg := &config{caps: capConfig{drop: []string{"ALL"}, keep: []string{"NET_BIND_SERVICE"}}}
+	cmd := exec.Command("sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	status, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/status")
+	if err != nil {
+		t.Fatalf("read status: %v", err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if rest, ok := strings.CutPrefix(line, "CapBnd:"); ok {
+			if got := strings.TrimSpace(rest); got != "0000000000000400" {
+				t.Fatalf("CapBnd = %s; want only NET_BIND_SERVICE", got)
+			}
+			return
+		}
+	}
+	t.Fatal("no CapBnd in child status")
+}
+
+func TestStartChildProcessNoNewPrivs(t *testing.T) {
+	cfg := &config{noNewPrivs: true}
+	cmd := exec.Command("grep", "NoNewPrivs", "/proc/self/status")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	var out strings.Builder
+	cmd.Stdout = &out
+	if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if got := strings.Join(strings.Fields(out.String()), " "); got != "NoNewPrivs: 1" {
+		t.Fatalf("child status: %q", got)
+	}
+	self, err := os.ReadFile("/proc/thread-self/status")
+	if err == nil && strings.Contains(string(self), "NoNewPrivs:\t1") {
+		t.Fatal("no_new_privs leaked into the caller's thread")
+	}
+}
+
+func TestStartChildProcessSeccomp(t *testing.T) {
+	if seccompSyscalls == nil {
+		t.Skip("no syscall table for this platform")
+	}
+	dir := t.TempDir()
+	profile := filepath.Join(dir, "seccomp.json")
+	err := os.WriteFile(profile, []byte(`{
+		"defaultAction": "SCMP_ACT_ALLOW",
+		"syscalls": [{"names": ["mkdir", "mkdirat"], "action": "SCMP_ACT_ERRNO"}]
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{noNewPrivs: true, seccompProfile: profile}
+	target := filepath.Join(dir, "denied")
+	cmd := exec.Command("mkdir", target)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected mkdir to fail under the seccomp filter")
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Fatal("directory was created despite the filter")
+	}
+	if err := os.Mkdir(filepath.Join(dir, "allowed"), 0o755); err != nil {
+		t.Fatalf("the filter leaked into the caller: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package psi
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errCapsUnsupported = errors.New("capabilities are only supported on Linux")
+
+func restrictThreadCaps(uint64) error { return errCapsUnsupported }
+
+func setKeepCaps() error { return errCapsUnsupported }
+
+func raiseAmbientCaps([]uintptr) error { return errCapsUnsupported }
+
+func setAmbientCaps(*syscall.SysProcAttr, []uintptr) {}
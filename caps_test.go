@@ -0,0 +1,48 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	mask, err := parseCapabilities([]string{"CAP_NET_ADMIN", "chown", " sys_admin "})
+	if err != nil {
+		t.Fatalf("parseCapabilities: %v", err)
+	}
+	if want := uint64(1<<12 | 1<<0 | 1<<21); mask != want {
+		t.Fatalf("mask = %#x; want %#x", mask, want)
+	}
+	if mask, err := parseCapabilities([]string{"all"}); err != nil || mask != ^uint64(0) {
+		t.Fatalf("ALL = %#x, %v", mask, err)
+	}
+	if _, err := parseCapabilities([]string{"NET_FLY"}); err == nil {
+		t.Fatal("expected unknown capability to fail")
+	}
+}
+
+func TestCapConfigResolve(t *testing.T) {
+	c := capConfig{drop: []string{"ALL"}, keep: []string{"NET_BIND_SERVICE", "CAP_KILL"}}
+	drop, keep, err := c.resolve()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if drop&(1<<10) != 0 || drop&(1<<5) != 0 || drop&(1<<0) == 0 {
+		t.Fatalf("unexpected drop mask %#x", drop)
+	}
+	if !slices.Equal(keep, []uintptr{5, 10}) {
+		t.Fatalf("keep = %v", keep)
+	}
+	if _, _, err := (&capConfig{keep: []string{"ALL"}}).resolve(); err == nil {
+		t.Fatal("expected keeping ALL to fail")
+	}
+}
+
+func TestCapEnv(t *testing.T) {
+	t.Setenv(capDropEnv, "ALL")
+	t.Setenv(capKeepEnv, "NET_BIND_SERVICE, NET_RAW")
+	cfg := newConfig(WithCapabilities([]string{"SYS_ADMIN"}, nil))
+	if !slices.Equal(cfg.caps.drop, []string{"ALL"}) || !slices.Equal(cfg.caps.keep, []string{"NET_BIND_SERVICE", "NET_RAW"}) {
+		t.Fatalf("unexpected caps %+v", cfg.caps)
+	}
+}
@@ -0,0 +1,143 @@
+package psi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// childCgroupName is the cgroup v2 directory, below the init's own cgroup,
+// that holds the managed child's process tree.
+const childCgroupName = "psi-child"
+
+// childCgroup is a cgroup v2 sub-cgroup confining the managed child and
+// everything it spawns, including daemons that left its process group.
+type childCgroup struct {
+	path string
+	fd   int
+}
+
+// setupChildCgroup creates (or reuses) the child sub-cgroup next to the
+// init's own cgroup on the unified (v2) hierarchy.
+func setupChildCgroup() (*childCgroup, error) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		return nil, err
+	}
+	self, err := ownCgroup2Path()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(mount, self, childCgroupName)
+	if err := os.Mkdir(path, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &childCgroup{path: path, fd: fd}, nil
+}
+
+// attach makes cmd start directly inside the cgroup (CLONE_INTO_CGROUP).
+func (c *childCgroup) attach(cmd *exec.Cmd) {
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = c.fd
+}
+
+// kill SIGKILLs every process in the cgroup via cgroup.kill (Linux 5.14+).
+func (c *childCgroup) kill() error {
+	return os.WriteFile(filepath.Join(c.path, "cgroup.kill"), []byte("1"), 0)
+}
+
+// freezeKill freezes the cgroup so nothing in it can fork, SIGKILLs every
+// member and thaws it again, guaranteeing no straggler survives. Without
+// cgroup.kill the members listed in cgroup.procs are killed one by one.
+func (c *childCgroup) freezeKill() error {
+	if err := c.setFrozen(true); err != nil {
+		return err
+	}
+	defer c.setFrozen(false)
+	c.waitFrozen(100 * time.Millisecond)
+	if err := c.kill(); err == nil {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Join(c.path, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	for _, field := range strings.Fields(string(b)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			_ = unix.Kill(pid, unix.SIGKILL)
+		}
+	}
+	return nil
+}
+
+func (c *childCgroup) setFrozen(frozen bool) error {
+	val := "0"
+	if frozen {
+		val = "1"
+	}
+	return os.WriteFile(filepath.Join(c.path, "cgroup.freeze"), []byte(val), 0)
+}
+
+// waitFrozen polls cgroup.events until the cgroup reports "frozen 1" or the
+// timeout expires.
+func (c *childCgroup) waitFrozen(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b, err := os.ReadFile(filepath.Join(c.path, "cgroup.events"))
+		if err != nil || strings.Contains(string(b), "frozen 1") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// cgroup2Mount returns the mount point of the unified cgroup hierarchy, which
+// is /sys/fs/cgroup on pure v2 hosts and e.g. /sys/fs/cgroup/unified on
+// hybrid ones.
+func cgroup2Mount() (string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		pre, post, ok := strings.Cut(sc.Text(), " - ")
+		if !ok || !strings.HasPrefix(post, "cgroup2 ") {
+			continue
+		}
+		if fields := strings.Fields(pre); len(fields) >= 5 {
+			return fields[4], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// ownCgroup2Path returns the init's cgroup v2 path from /proc/self/cgroup.
+func ownCgroup2Path() (string, error) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
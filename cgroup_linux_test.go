@@ -0,0 +1,23 @@
+package psi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCgroup2Discovery(t *testing.T) {
+	mount, err := cgroup2Mount()
+	if err != nil {
+		t.Skipf("no cgroup v2 hierarchy: %v", err)
+	}
+	if !strings.HasPrefix(mount, "/") {
+		t.Fatalf("unexpected cgroup2 mount point %q", mount)
+	}
+	path, err := ownCgroup2Path()
+	if err != nil {
+		t.Fatalf("ownCgroup2Path: %v", err)
+	}
+	if !strings.HasPrefix(path, "/") {
+		t.Fatalf("unexpected cgroup path %q", path)
+	}
+}
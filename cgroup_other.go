@@ -0,0 +1,20 @@
+//go:build !linux
+
+package psi
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type childCgroup struct{}
+
+func setupChildCgroup() (*childCgroup, error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
+
+func (*childCgroup) attach(*exec.Cmd) {}
+
+func (*childCgroup) kill() error { return errors.New("cgroups are only supported on Linux") }
+
+func (*childCgroup) freezeKill() error { return errors.New("cgroups are only supported on Linux") }
@@ -0,0 +1,64 @@
+package psi
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	chdirEnv  = "PSI_CHDIR"
+	chrootEnv = "PSI_CHROOT"
+)
+
+// WithDir sets the child's working directory, relative to its root when
+// WithChroot is used as well. Overridden by PSI_CHDIR.
+func WithDir(dir string) Option {
+	return func(c *config) {
+		c.chdir = dir
+	}
+}
+
+// WithChroot confines the child to the subtree at root. The program (or,
+// for Run, this binary at the same path) must exist inside it. Other path
+// settings such as WithLandlock and WithIsolation refer to the filesystem
+// outside the chroot. Needs CAP_SYS_CHROOT. Overridden by PSI_CHROOT.
+func WithChroot(root string) Option {
+	return func(c *config) {
+		c.chroot = root
+	}
+}
+
+// loadRootEnv applies the PSI_CHDIR and PSI_CHROOT overrides.
+func (c *config) loadRootEnv() {
+	if val := strings.TrimSpace(os.Getenv(chdirEnv)); val != "" {
+		c.chdir = val
+	}
+	if val := strings.TrimSpace(os.Getenv(chrootEnv)); val != "" {
+		c.chroot = val
+	}
+}
+
+// applyRoot sets the chroot and working directory on the child command.
+func (c *config) applyRoot(dir *string, attr *syscall.SysProcAttr) {
+	setChroot(attr, c.chroot)
+	*dir = c.chdir
+}
+
+// enterRoot chroots and changes directory in the current process. It is used
+// when there is no separate child, i.e. right before exec or submain.
+func (c *config) enterRoot() {
+	if c.chroot != "" {
+		if err := chroot(c.chroot); err != nil {
+			fatalf("chroot %s: %v", c.chroot, err)
+		}
+		if c.chdir == "" {
+			c.chdir = "/"
+		}
+	}
+	if c.chdir != "" {
+		if err := os.Chdir(c.chdir); err != nil {
+			fatalf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestRootEnv(t *testing.T) {
+	t.Setenv(chdirEnv, "/srv/app")
+	t.Setenv(chrootEnv, "")
+	cfg := newConfig(WithDir("/tmp"), WithChroot("/jail"))
+	var dir string
+	attr := &syscall.SysProcAttr{}
+	cfg.applyRoot(&dir, attr)
+	if dir != "/srv/app" || attr.Chroot != "/jail" {
+		t.Fatalf("dir=%q chroot=%q", dir, attr.Chroot)
+	}
+}
@@ -0,0 +1,32 @@
+package psi
+
+import "syscall"
+
+const cleanExitOnStopEnv = "PSI_CLEAN_EXIT_ON_STOP"
+
+// WithCleanExitOnStop makes the init exit 0 instead of 128+N when it was asked
+// to terminate and the child then died of (or exited with the shell-style code
+// of) a stop signal the init sent it: a container stopped on request is not
+// an error. A child that had to be killed with SIGKILL still exits with
+// ExitStopTimeout.
+// Overridden by PSI_CLEAN_EXIT_ON_STOP.
+func WithCleanExitOnStop() Option {
+	return func(c *config) {
+		c.cleanExitOnStop = true
+	}
+}
+
+// cleanStopExitCode maps the final child's exit code to 0 when it reflects a
+// stop signal sent during shutdown and clean exits on stop are enabled.
+func (s *supervisor) cleanStopExitCode(code int) int {
+	if !s.cfg.cleanExitOnStop || !s.esc.started() {
+		return code
+	}
+	for _, sig := range s.stopSignals {
+		if sig != syscall.SIGKILL && code == 128+int(sig) {
+			s.cfg.debugf(1, "child stopped by %s on request; exiting 0", signalName(sig))
+			return 0
+		}
+	}
+	return code
+}
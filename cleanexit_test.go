@@ -0,0 +1,68 @@
+//go:build !windows
+
+package psi
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCleanStopExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		clean   bool
+		stopped bool
+		sent    []syscall.Signal
+		code    int
+		want    int
+	}{
+		{"disabled", false, true, []syscall.Signal{syscall.SIGTERM}, 143, 143},
+		{"not stopping", true, false, nil, 143, 143},
+		{"stop signal", true, true, []syscall.Signal{syscall.SIGTERM}, 143, 0},
+		{"later step", true, true, []syscall.Signal{syscall.SIGTERM, syscall.SIGINT}, 130, 0},
+		{"other signal", true, true, []syscall.Signal{syscall.SIGTERM}, 130, 130},
+		{"forced kill", true, true, []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL}, 137, 137},
+		{"failure", true, true, []syscall.Signal{syscall.SIGTERM}, 1, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []Option
+			if tc.clean {
+				opts = append(opts, WithCleanExitOnStop())
+			}
+			s := newSupervisor(newConfig(opts...))
+			if tc.stopped {
+				s.esc.advance()
+			}
+			s.stopSignals = tc.sent
+			if got := s.cleanStopExitCode(tc.code); got != tc.want {
+				t.Fatalf("cleanStopExitCode(%d) = %d, want %d", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCleanExitOnStopEnv(t *testing.T) {
+	t.Setenv(cleanExitOnStopEnv, "1")
+	if c := newConfig(); !c.cleanExitOnStop {
+		t.Fatal("PSI_CLEAN_EXIT_ON_STOP=1 not applied")
+	}
+}
+
+func TestSupervisorCleanExitOnStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-sleep", helperCountEnv+"="+countFile, cleanExitOnStopEnv+"=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, countFile, "running\n")
+	time.Sleep(100 * time.Millisecond)
+	cmd.Process.Signal(syscall.SIGTERM)
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultControlSocket is used when neither -socket nor PSI_CONTROL_SOCKET
+// is given.
+const defaultControlSocket = "/run/psi.sock"
+
+// ctl runs "psi ctl": it sends a command to the control socket of a running
+// psi (see psi.WithControlSocket), prints the reply and returns the exit
+// code.
+func ctl(args []string) int {
+	fs := flag.NewFlagSet("psi ctl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: psi ctl [-socket PATH] [-timeout D] status | signal SIGNAL | stop | restart | log-level LEVEL\n")
+		fs.PrintDefaults()
+	}
+	socket := fs.String("socket", "", "control socket `path` (default $PSI_CONTROL_SOCKET or "+defaultControlSocket+")")
+	timeout := fs.Duration("timeout", 10*time.Second, "give up after this long")
+	_ = fs.Parse(args)
+	line, err := ctlRequest(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "psi ctl: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if *socket == "" {
+		*socket = controlSocket()
+	}
+	reply, err := ctlSend(*socket, line, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "psi ctl: %v\n", err)
+		return 1
+	}
+	if !reply.OK {
+		fmt.Fprintf(os.Stderr, "psi ctl: %s\n", reply.Error)
+		return 1
+	}
+	if len(reply.Status) > 0 {
+		var out bytes.Buffer
+		if json.Indent(&out, reply.Status, "", "  ") == nil {
+			fmt.Println(out.String())
+		}
+	}
+	return 0
+}
+
+// controlSocket returns the socket path from the environment, or the
+// default.
+func controlSocket() string {
+	if path := strings.TrimSpace(os.Getenv("PSI_CONTROL_SOCKET")); path != "" {
+		return path
+	}
+	return defaultControlSocket
+}
+
+// ctlRequest turns the command line into a line of the control protocol.
+func ctlRequest(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("missing command")
+	}
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "signal", "send-signal":
+		cmd = "send-signal"
+	case "log-level", "set-log-level":
+		cmd = "set-log-level"
+	case "status", "stop", "restart":
+		if len(rest) > 0 {
+			return "", fmt.Errorf("%s takes no arguments", cmd)
+		}
+		return cmd, nil
+	default:
+		return "", fmt.Errorf("unknown command %q", cmd)
+	}
+	if len(rest) != 1 {
+		return "", fmt.Errorf("%s takes one argument", args[0])
+	}
+	return cmd + " " + rest[0], nil
+}
+
+// ctlReply is the reply of the control socket.
+type ctlReply struct {
+	OK     bool            `json:"ok"`
+	Error  string          `json:"error"`
+	Status json.RawMessage `json:"status"`
+}
+
+// ctlSend sends line to the control socket at path and reads the reply.
+func ctlSend(path, line string, timeout time.Duration) (ctlReply, error) {
+	var reply ctlReply
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return reply, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		return reply, err
+	}
+	b, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return reply, fmt.Errorf("reading the reply: %w", err)
+	}
+	if err := json.Unmarshal(b, &reply); err != nil {
+		return reply, fmt.Errorf("invalid reply: %w", err)
+	}
+	return reply, nil
+}
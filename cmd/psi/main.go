@@ -0,0 +1,182 @@
+// Command psi is a standalone PID1 init for containers whose application is
+// not written in Go (or not built with pkt.systems/psi). It accepts
+// tini-style flags so it can replace tini in existing images:
+//
+//	ENTRYPOINT ["/psi", "-g", "--", "/app/server", "--flag"]
+//
+// Flags:
+//
+//	-g          kill the child's process group (always on in psi; accepted for compatibility)
+//	-s          register as a child subreaper and supervise when not PID 1
+//	-p SIGNAL   signal psi receives when its parent dies (PR_SET_PDEATHSIG)
+//	-v, -vv     log lifecycle events, and additionally every forwarded signal;
+//	            counted like tini's, so -v -v is -vv
+//	--version   print version and exit
+//
+// "psi ctl" talks to the control socket of a running psi (PSI_CONTROL_SOCKET,
+// see psi.WithControlSocket), e.g. from kubectl exec:
+//
+//	psi ctl status              print the child's status as JSON
+//	psi ctl signal USR1         send a signal to the child's process group
+//	psi ctl restart             stop the child and start a new one
+//	psi ctl stop                shut down as on SIGTERM
+//	psi ctl log-level debug     change psi's log level
+//
+// The socket is $PSI_CONTROL_SOCKET, /run/psi.sock by default, or -socket
+// PATH.
+//
+// "psi doctor" checks the environment psi would run in (see psi.SelfCheck),
+// e.g. in the CI of a base image, printing a line per check and exiting 1 if
+// any failed:
+//
+//	RUN ["/psi", "doctor"]
+//
+// To run a program named ctl or doctor, write "psi -- ctl".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"pkt.systems/psi"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(ctl(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(doctor())
+	}
+	fs := flag.NewFlagSet("psi", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [-g] [-s] [-p SIGNAL] [-v...] [--version] [--] PROGRAM [ARGS...]\n       %s ctl COMMAND [ARG]\n       %s doctor\n", fs.Name(), fs.Name(), fs.Name())
+		fs.PrintDefaults()
+	}
+	cl, _ := parseArgs(fs, os.Args[1:])
+
+	if cl.showVersion {
+		fmt.Println("psi", version())
+		return
+	}
+	args := cl.args
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var opts []psi.Option
+	if cl.pdeath != "" {
+		sig, err := psi.ParseSignal(cl.pdeath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "psi: -p: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, psi.WithParentDeathSignal(sig))
+	}
+	if cl.verbosity > 0 {
+		opts = append(opts, psi.WithVerbosity(cl.verbosity))
+	}
+	if cl.subreaper {
+		opts = append(opts, psi.WithSubreaper())
+	}
+	psi.Exec(args[0], args[1:], opts...)
+}
+
+// cmdline is the parsed command line of the init.
+type cmdline struct {
+	subreaper   bool
+	pdeath      string
+	verbosity   int
+	showVersion bool
+	// args are the program and its arguments.
+	args []string
+}
+
+// parseArgs defines the init's flags on fs and parses args, the command line
+// without the program name.
+func parseArgs(fs *flag.FlagSet, args []string) (cmdline, error) {
+	var cl cmdline
+	_ = fs.Bool("g", false, "kill the child's process group (always on; accepted for tini compatibility)")
+	fs.BoolVar(&cl.subreaper, "s", false, "register as a child subreaper and supervise when not PID 1")
+	fs.StringVar(&cl.pdeath, "p", "", "parent-death `signal`, e.g. SIGKILL")
+	fs.Var((*countFlag)(&cl.verbosity), "v", "verbose: log lifecycle events; twice (-vv) also log every forwarded signal")
+	fs.BoolVar(&cl.showVersion, "version", false, "print version and exit")
+	err := fs.Parse(splitVerbose(args))
+	cl.args = fs.Args()
+	return cl, err
+}
+
+// splitVerbose rewrites the combined flags -vv, -vvv and so on among the
+// init's flags as repeated -v, as getopt would read them for tini. The
+// program and its arguments are left alone.
+func splitVerbose(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || a == "-" || !strings.HasPrefix(a, "-") {
+			return append(out, args[i:]...)
+		}
+		name := strings.TrimLeft(a, "-")
+		switch {
+		case name == "p" && i+1 < len(args):
+			out = append(out, a, args[i+1])
+			i++
+		case len(name) > 1 && strings.Trim(name, "v") == "":
+			for range name {
+				out = append(out, "-v")
+			}
+		default:
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// countFlag is a boolean flag that counts how often it is given.
+type countFlag int
+
+func (n *countFlag) String() string {
+	if n == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*n))
+}
+
+func (n *countFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on {
+		*n++
+	}
+	return nil
+}
+
+func (n *countFlag) IsBoolFlag() bool { return true }
+
+// doctor runs "psi doctor": it prints the self-check report and returns 1
+// if any check failed.
+func doctor() int {
+	checks := psi.SelfCheck()
+	for _, c := range checks {
+		fmt.Println(c)
+	}
+	if psi.SelfCheckFailed(checks) {
+		return 1
+	}
+	return 0
+}
+
+// version reports the module version psi was built from.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
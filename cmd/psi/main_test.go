@@ -0,0 +1,46 @@
+//go:build !windows
+
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args      string
+		verbosity int
+		pdeath    string
+		program   []string
+	}{
+		{"/app", 0, "", []string{"/app"}},
+		{"-v /app", 1, "", []string{"/app"}},
+		{"-vv /app", 2, "", []string{"/app"}},
+		{"-v -v /app", 2, "", []string{"/app"}},
+		{"-vvv /app", 3, "", []string{"/app"}},
+		{"-v -vv -- /app", 3, "", []string{"/app"}},
+		{"-g -s -p SIGKILL -vv -- /app -vvv", 2, "SIGKILL", []string{"/app", "-vvv"}},
+		{"-p -vv -v /app", 1, "-vv", []string{"/app"}},
+		{"/app -v", 0, "", []string{"/app", "-v"}},
+	} {
+		fs := flag.NewFlagSet("psi", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cl, err := parseArgs(fs, strings.Fields(tc.args))
+		if err != nil {
+			t.Errorf("parseArgs(%q): %v", tc.args, err)
+			continue
+		}
+		if cl.verbosity != tc.verbosity || cl.pdeath != tc.pdeath || !slices.Equal(cl.args, tc.program) {
+			t.Errorf("parseArgs(%q) = %+v", tc.args, cl)
+		}
+	}
+	fs := flag.NewFlagSet("psi", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := parseArgs(fs, []string{"-vx", "/app"}); err == nil {
+		t.Error("parseArgs accepted -vx")
+	}
+}
@@ -0,0 +1,48 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Exec runs the external program path with args under the same PID1
+// machinery as Run (signal forwarding, zombie reaping, stop timeout,
+// restarts, sidecars) instead of re-executing itself to run a Go submain.
+// When not PID 1 the program simply replaces the current process. Exec never
+// returns.
+//
+//	func main() { psi.Exec("/app/server", os.Args[1:], psi.WithStopSignal(syscall.SIGQUIT)) }
+func Exec(path string, args []string, opts ...Option) {
+	cfg := newConfig(opts...)
+	cfg.command = append([]string{path}, args...)
+	if !cfg.supervise() {
+		cfg.prepareUnsupervised()
+		cfg.pinThread()
+		cfg.enterRoot()
+		cfg.restrictExec()
+		cfg.setUmask()
+		cfg.scrubEnv()
+		execProgram(cfg.expandArgv(cfg.command, os.Environ()))
+		// execProgram never returns.
+	}
+	runAsInit(cfg)
+	// runAsInit never returns.
+}
+
+// Command is shorthand for Exec(path, args) without options.
+func Command(path string, args ...string) {
+	Exec(path, args)
+}
+
+// execProgram replaces the current process with argv[0], resolved via PATH,
+// exiting with ExitNotFound or ExitCannotExecute if that fails.
+func execProgram(argv []string) {
+	bin, err := exec.LookPath(argv[0])
+	if err != nil {
+		exitStartFailure(err)
+	}
+	err = syscall.Exec(bin, argv, os.Environ())
+	exitStartFailure(fmt.Errorf("exec %s: %w", bin, err))
+}
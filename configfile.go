@@ -0,0 +1,482 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	configEnv = "PSI_CONFIG"
+	// defaultConfigFile is read when it exists and no file is configured.
+	defaultConfigFile = "/etc/psi/psi.yaml"
+)
+
+// WithConfigFile reads settings from a YAML file. Each key names a PSI_*
+// environment variable in lower case and takes the same syntax as that
+// variable; lists are joined with commas and nested mappings extend the
+// name, so nofile under rlimit sets PSI_RLIMIT_NOFILE. A sidecars list
+// declares sidecars like WithSidecar, an init_tasks list tasks like
+// WithInitTask and a cron list jobs like WithCronJob:
+//
+//	stop_timeout: 45s
+//	stop_chain: [SIGTERM:20s, SIGINT:5s, SIGKILL]
+//	restart: on-failure
+//	rlimit:
+//	  nofile: 65536
+//	sysctl: [net.core.somaxconn=4096]
+//	sidecars:
+//	  - name: proxy
+//	    path: /usr/bin/envoy
+//	    args: [-c, /etc/envoy.yaml]
+//	    env: [LOG_LEVEL=info]
+//	    stop_signal: SIGINT
+//	    stop_timeout: 10s
+//	    after_ready: true
+//	init_tasks:
+//	  - name: migrate
+//	    path: /app/migrate
+//	    args: [up]
+//	    timeout: 5m
+//	  - name: warm-cache
+//	    path: /app/warm
+//	    optional: true
+//	cron:
+//	  - name: purge
+//	    schedule: "*/15 * * * *"
+//	    path: /app/purge-cache
+//	    timeout: 5m
+//
+// File values override options; environment variables override file values.
+// Without this option /etc/psi/psi.yaml is read if it exists. Overridden by
+// PSI_CONFIG. A file that is invalid, or names a variable psi does not know,
+// is fatal, as is a configured file that is missing.
+func WithConfigFile(path string) Option {
+	return func(c *config) {
+		c.configFile = path
+	}
+}
+
+// loadConfigFile reads the configuration file and exports its settings as
+// PSI_* variables unless they are already set, for loadEnv to apply.
+func (c *config) loadConfigFile() {
+	path, explicit := c.configFile, c.configFile != ""
+	if val := strings.TrimSpace(os.Getenv(configEnv)); val != "" {
+		path, explicit = val, true
+	}
+	if path == "" {
+		path = defaultConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		fatalf("config: %v", err)
+	}
+	doc, err := parseConfigFile(string(data))
+	if err != nil {
+		fatalf("config %s: %v", path, err)
+	}
+	for _, kv := range doc.vars {
+		key, val, _ := strings.Cut(kv, "=")
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, val)
+		}
+	}
+	c.sidecars = append(c.sidecars, doc.sidecars...)
+	c.initTasks = append(c.initTasks, doc.initTasks...)
+	c.cronJobs = append(c.cronJobs, doc.cronJobs...)
+}
+
+// configDoc is the content of a configuration file.
+type configDoc struct {
+	// vars are the PSI_* variables set, sorted by name.
+	vars      []string
+	sidecars  []Sidecar
+	initTasks []InitTask
+	cronJobs  []CronJob
+}
+
+// parseConfigFile converts a configuration file to PSI_* variables and
+// declarations.
+func parseConfigFile(data string) (*configDoc, error) {
+	parsed, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := parsed.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("top level must be a mapping")
+	}
+	doc := &configDoc{}
+	for key, v := range root {
+		switch key {
+		case "sidecars":
+			err = configItems(key, v, func(m map[string]any) error {
+				sc, err := parseConfigSidecar(m)
+				doc.sidecars = append(doc.sidecars, sc)
+				return err
+			})
+		case "init_tasks":
+			err = configItems(key, v, func(m map[string]any) error {
+				t, err := parseConfigInitTask(m)
+				doc.initTasks = append(doc.initTasks, t)
+				return err
+			})
+		case "cron":
+			err = configItems(key, v, func(m map[string]any) error {
+				job, err := parseConfigCronJob(m)
+				doc.cronJobs = append(doc.cronJobs, job)
+				return err
+			})
+		default:
+			doc.vars, err = flattenConfig(doc.vars, key, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(doc.vars)
+	return doc, nil
+}
+
+// flattenConfig appends the variables for key, whose nested keys are joined
+// with dots.
+func flattenConfig(vars []string, key string, v any) ([]string, error) {
+	name := psiEnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+	switch name {
+	case configEnv, childEnvKey:
+		return nil, fmt.Errorf("%s cannot be set in the config file", strings.ToLower(key))
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, sub := range v {
+			var err error
+			if vars, err = flattenConfig(vars, key+"."+k, sub); err != nil {
+				return nil, err
+			}
+		}
+		return vars, nil
+	case []any:
+		if !knownConfigVar(name) {
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		items, err := configStrings(key, v)
+		if err != nil {
+			return nil, err
+		}
+		return append(vars, name+"="+strings.Join(items, ",")), nil
+	default:
+		if !knownConfigVar(name) {
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		return append(vars, name+"="+v.(string)), nil
+	}
+}
+
+// knownConfigVar reports whether name is a PSI_* variable that configures
+// psi, as opposed to a misspelling or one psi sets for its children.
+func knownConfigVar(name string) bool {
+	if resource, ok := strings.CutPrefix(name, rlimitEnvPrefix); ok {
+		return resource != "" && name != rlimitGateEnv
+	}
+	return configVars[name]
+}
+
+// configVars are the variables a config file may set, besides
+// PSI_RLIMIT_<RESOURCE>.
+var configVars = map[string]bool{
+	autoTuneEnv:          true,
+	capDropEnv:           true,
+	capKeepEnv:           true,
+	cgroupEnv:            true,
+	cgroupFreezeEnv:      true,
+	chdirEnv:             true,
+	childNameEnv:         true,
+	childOOMScoreAdjEnv:  true,
+	chrootEnv:            true,
+	cleanExitOnStopEnv:   true,
+	controlSocketEnv:     true,
+	cpusetEnv:            true,
+	crashBufferEnv:       true,
+	crashLoopLimitEnv:    true,
+	diagFileEnv:          true,
+	diagHeapEnv:          true,
+	diagSignalEnv:        true,
+	dumpTreeOnKillEnv:    true,
+	envAllowEnv:          true,
+	envDirEnv:            true,
+	envFileEnv:           true,
+	expandArgsEnv:        true,
+	fluentAddrEnv:        true,
+	fluentBufferEnv:      true,
+	fluentTagEnv:         true,
+	forceSecondEnv:       true,
+	forceSuperviseEnv:    true,
+	gidEnv:               true,
+	healthAddrEnv:        true,
+	hostnameEnv:          true,
+	hupActionEnv:         true,
+	ignoreSignalsEnv:     true,
+	ioniceClassEnv:       true,
+	ioniceLevelEnv:       true,
+	isolationEnv:         true,
+	isolationRWEnv:       true,
+	journaldEnv:          true,
+	landlockROEnv:        true,
+	landlockRWEnv:        true,
+	listenEnv:            true,
+	livenessDelayEnv:     true,
+	livenessExecEnv:      true,
+	livenessHTTPEnv:      true,
+	livenessIntervalEnv:  true,
+	livenessTCPEnv:       true,
+	livenessThresholdEnv: true,
+	livenessTimeoutEnv:   true,
+	logDirEnv:            true,
+	logFilesOnlyEnv:      true,
+	logFormatEnv:         true,
+	logLevelEnv:          true,
+	logMaxAgeEnv:         true,
+	logMaxFilesEnv:       true,
+	logMaxSizeEnv:        true,
+	logPrefixEnv:         true,
+	logRateEnv:           true,
+	logStripANSIEnv:      true,
+	logTimestampsEnv:     true,
+	logWrapJSONEnv:       true,
+	maxRestartsEnv:       true,
+	metricsAddrEnv:       true,
+	minUptimeEnv:         true,
+	mountProcEnv:         true,
+	niceEnv:              true,
+	noNewPrivsEnv:        true,
+	oomScoreAdjEnv:       true,
+	pdeathSignalEnv:      true,
+	pidFileEnv:           true,
+	postStopEnv:          true,
+	postStopTimeoutEnv:   true,
+	pprofAddrEnv:         true,
+	preStartEnv:          true,
+	preStartTimeoutEnv:   true,
+	preStopCmdEnv:        true,
+	preStopSleepEnv:      true,
+	preStopTimeoutEnv:    true,
+	processTitleEnv:      true,
+	ptyEnv:               true,
+	readyNotifyEnv:       true,
+	redactEnv:            true,
+	reexecPathEnv:        true,
+	restartDelayEnv:      true,
+	restartEnv:           true,
+	restartMaxDelayEnv:   true,
+	restartRequestsEnv:   true,
+	schedPolicyEnv:       true,
+	schedPriorityEnv:     true,
+	seccompProfileEnv:    true,
+	setupDevEnv:          true,
+	signalAuditEnv:       true,
+	signalMapEnv:         true,
+	startTimeoutEnv:      true,
+	stateFileEnv:         true,
+	stdinEnv:             true,
+	stopChainEnv:         true,
+	stopExtendMaxEnv:     true,
+	stopSignalEnv:        true,
+	stopTimeoutEnv:       true,
+	subreaperEnv:         true,
+	sysctlEnv:            true,
+	terminationLogEnv:    true,
+	uidEnv:               true,
+	umaskEnv:             true,
+	unsharePIDEnv:        true,
+	unshareUTSEnv:        true,
+	upgradeSignalEnv:     true,
+	userEnv:              true,
+	verbosityEnv:         true,
+	watchdogEnv:          true,
+	watchFilesEnv:        true,
+	watchSignalEnv:       true,
+}
+
+// configStrings converts a list of scalars.
+func configStrings(key string, v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list", key)
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: list items must be scalars", key)
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+// configItems calls parse for each mapping in the list v.
+func configItems(key string, v any, parse func(map[string]any) error) error {
+	list, ok := v.([]any)
+	if !ok {
+		return fmt.Errorf("%s: expected a list", key)
+	}
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s[%d]: expected a mapping", key, i)
+		}
+		if err := parse(m); err != nil {
+			return fmt.Errorf("%s[%d]: %w", key, i, err)
+		}
+	}
+	return nil
+}
+
+func parseConfigSidecar(m map[string]any) (Sidecar, error) {
+	var sc Sidecar
+	for key, v := range m {
+		if key == "args" || key == "env" {
+			items, err := configStrings(key, v)
+			if err != nil {
+				return sc, err
+			}
+			if key == "args" {
+				sc.Args = items
+			} else {
+				sc.Env = items
+			}
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return sc, fmt.Errorf("%s: expected a scalar", key)
+		}
+		switch key {
+		case "name":
+			sc.Name = s
+		case "path":
+			sc.Path = s
+		case "stop_signal":
+			sig, err := ParseSignal(s)
+			if err != nil {
+				return sc, fmt.Errorf("stop_signal: %w", err)
+			}
+			sc.StopSignal = sig
+		case "after_ready":
+			b, err := parseBool(s)
+			if err != nil {
+				return sc, fmt.Errorf("after_ready: %w", err)
+			}
+			sc.AfterReady = b
+		case "stop_timeout":
+			d, err := parseDuration(s)
+			if err != nil {
+				return sc, fmt.Errorf("stop_timeout: %w", err)
+			}
+			sc.StopTimeout = d
+		default:
+			return sc, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if sc.Path == "" {
+		return sc, fmt.Errorf("path is required")
+	}
+	return sc, nil
+}
+
+func parseConfigInitTask(m map[string]any) (InitTask, error) {
+	var t InitTask
+	for key, v := range m {
+		if key == "args" || key == "env" {
+			items, err := configStrings(key, v)
+			if err != nil {
+				return t, err
+			}
+			if key == "args" {
+				t.Args = items
+			} else {
+				t.Env = items
+			}
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return t, fmt.Errorf("%s: expected a scalar", key)
+		}
+		switch key {
+		case "name":
+			t.Name = s
+		case "path":
+			t.Path = s
+		case "timeout":
+			d, err := parseDuration(s)
+			if err != nil {
+				return t, fmt.Errorf("timeout: %w", err)
+			}
+			t.Timeout = d
+		case "optional":
+			b, err := parseBool(s)
+			if err != nil {
+				return t, fmt.Errorf("optional: %w", err)
+			}
+			t.Optional = b
+		default:
+			return t, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if t.Path == "" {
+		return t, fmt.Errorf("path is required")
+	}
+	return t, nil
+}
+
+func parseConfigCronJob(m map[string]any) (CronJob, error) {
+	var job CronJob
+	for key, v := range m {
+		if key == "args" || key == "env" {
+			items, err := configStrings(key, v)
+			if err != nil {
+				return job, err
+			}
+			if key == "args" {
+				job.Args = items
+			} else {
+				job.Env = items
+			}
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return job, fmt.Errorf("%s: expected a scalar", key)
+		}
+		switch key {
+		case "name":
+			job.Name = s
+		case "schedule":
+			if _, err := parseCronSchedule(s); err != nil {
+				return job, err
+			}
+			job.Schedule = s
+		case "path":
+			job.Path = s
+		case "timeout":
+			d, err := parseDuration(s)
+			if err != nil {
+				return job, fmt.Errorf("timeout: %w", err)
+			}
+			job.Timeout = d
+		default:
+			return job, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if job.Path == "" || job.Schedule == "" {
+		return job, fmt.Errorf("path and schedule are required")
+	}
+	return job, nil
+}
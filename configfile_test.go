@@ -0,0 +1,146 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	doc, err := parseConfigFile(`
+stop_timeout: 45s
+stop_chain: [SIGTERM:20s, SIGKILL]
+restart: on-failure
+rlimit:
+  nofile: 65536
+  core: unlimited
+sidecars:
+  - name: proxy
+    path: /usr/bin/envoy
+    args: [-c, /etc/envoy.yaml]
+    env: [LOG_LEVEL=info]
+    stop_signal: SIGINT
+    stop_timeout: 10
+    after_ready: yes
+init_tasks:
+  - name: migrate
+    path: /app/migrate
+    args: [up]
+    timeout: 5m
+  - path: /app/warm
+    optional: "true"
+cron:
+  - name: purge
+    schedule: "*/15 * * * *"
+    path: /app/purge
+    args: [--all]
+    timeout: 1m
+`)
+	if err != nil {
+		t.Fatalf("parseConfigFile: %v", err)
+	}
+	wantVars := []string{
+		"PSI_RESTART=on-failure",
+		"PSI_RLIMIT_CORE=unlimited",
+		"PSI_RLIMIT_NOFILE=65536",
+		"PSI_STOP_CHAIN=SIGTERM:20s,SIGKILL",
+		"PSI_STOP_TIMEOUT=45s",
+	}
+	if !slices.Equal(doc.vars, wantVars) {
+		t.Fatalf("vars = %q, want %q", doc.vars, wantVars)
+	}
+	if len(doc.sidecars) != 1 {
+		t.Fatalf("sidecars = %+v", doc.sidecars)
+	}
+	sc := doc.sidecars[0]
+	if sc.Name != "proxy" || sc.Path != "/usr/bin/envoy" || !slices.Equal(sc.Args, []string{"-c", "/etc/envoy.yaml"}) ||
+		!slices.Equal(sc.Env, []string{"LOG_LEVEL=info"}) || sc.StopSignal != syscall.SIGINT || sc.StopTimeout != 10*time.Second || !sc.AfterReady {
+		t.Fatalf("sidecar = %+v", sc)
+	}
+	if len(doc.initTasks) != 2 {
+		t.Fatalf("init tasks = %+v", doc.initTasks)
+	}
+	if task := doc.initTasks[0]; task.Name != "migrate" || task.Path != "/app/migrate" || !slices.Equal(task.Args, []string{"up"}) ||
+		task.Timeout != 5*time.Minute || task.Optional {
+		t.Fatalf("init task = %+v", task)
+	}
+	if task := doc.initTasks[1]; task.Path != "/app/warm" || !task.Optional {
+		t.Fatalf("init task = %+v", task)
+	}
+	if len(doc.cronJobs) != 1 {
+		t.Fatalf("cron jobs = %+v", doc.cronJobs)
+	}
+	if job := doc.cronJobs[0]; job.Name != "purge" || job.Schedule != "*/15 * * * *" || job.Path != "/app/purge" ||
+		!slices.Equal(job.Args, []string{"--all"}) || job.Timeout != time.Minute {
+		t.Fatalf("cron job = %+v", job)
+	}
+	for _, bad := range []string{
+		"- a",
+		"config: /other.yaml",
+		"child: 1",
+		"sidecars: [a]",
+		"sidecars:\n  - name: x",
+		"sidecars:\n  - path: /x\n    bogus: 1",
+		"sidecars:\n  - path: /x\n    stop_signal: NOPE",
+		"init_tasks:\n  - name: x",
+		"init_tasks:\n  - path: /x\n    optional: maybe",
+		"cron:\n  - path: /x",
+		"cron:\n  - path: /x\n    schedule: \"61 * * * *\"",
+		"list:\n  - [a]",
+		"stop_timout: 45s",
+		"rlimit:\n  gate: 3",
+		"ready_fd: 3",
+		"restart:\n  dleay: 1s",
+	} {
+		if _, err := parseConfigFile(bad); err == nil {
+			t.Errorf("parseConfigFile(%q) should fail", bad)
+		}
+	}
+}
+
+func TestConfigFileEnvOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "psi.yaml")
+	data := "stop_signal: SIGINT\nverbosity: 2\nsidecars:\n  - path: /bin/true\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configEnv, path)
+	t.Setenv(stopSignalEnv, "SIGQUIT")
+	// Restored after the test; the file sets it.
+	t.Setenv("PSI_VERBOSITY", "")
+	os.Unsetenv("PSI_VERBOSITY")
+
+	cfg := newConfig(WithStopSignal(syscall.SIGUSR1))
+	if cfg.stopSignal != syscall.SIGQUIT {
+		t.Fatalf("stopSignal = %v; the environment must override the file", cfg.stopSignal)
+	}
+	if got := os.Getenv("PSI_VERBOSITY"); got != "2" {
+		t.Fatalf("PSI_VERBOSITY = %q, want the file's value", got)
+	}
+	if len(cfg.sidecars) != 1 || cfg.sidecars[0].Path != "/bin/true" {
+		t.Fatalf("sidecars = %+v", cfg.sidecars)
+	}
+}
+
+func TestConfigFileUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "psi.yaml")
+	if err := os.WriteFile(path, []byte("stop_timout: 45s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd := helperCommand("init-control", configEnv+"="+path)
+	cmd.Stderr = &stderr
+	if exit := exitStatus(cmd.Run()); exit != ExitInternalError {
+		t.Fatalf("exit code = %d, want %d", exit, ExitInternalError)
+	}
+	if msg := stderr.String(); !strings.Contains(msg, path) || !strings.Contains(msg, `"stop_timout"`) {
+		t.Fatalf("stderr = %q, want the file and the key", msg)
+	}
+}
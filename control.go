@@ -0,0 +1,290 @@
+package psi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const controlSocketEnv = "PSI_CONTROL_SOCKET"
+
+// controlTimeout bounds how long a command waits for the supervisor loop,
+// which does not take commands while it runs hooks or waits to restart.
+const controlTimeout = 5 * time.Second
+
+// WithControlSocket makes the init listen on a unix socket at path (e.g.
+// "/run/psi.sock", mode 0600) for commands from operators, e.g. from
+// kubectl exec. A client sends one command per line and gets one JSON line
+// back, {"ok":true,...} or {"ok":false,"error":"..."}. A command is either
+// words or a JSON object:
+//
+//	status                    {"command":"status"}
+//	send-signal SIGHUP        {"command":"send-signal","signal":"SIGHUP"}
+//	stop                      {"command":"stop"}
+//	restart                   {"command":"restart"}
+//	set-log-level debug       {"command":"set-log-level","level":"debug"}
+//
+// status answers with the /status document (see WithHealthAddr) under
+// "status"; send-signal sends a signal to the child's process group as is;
+// stop shuts down as on SIGTERM; restart stops the child through the same
+// escalation as stop (see WithStopChain) and starts a new generation
+// regardless of the restart policy; set-log-level changes the init's log
+// level (see WithLogLevel). Overridden by PSI_CONTROL_SOCKET.
+func WithControlSocket(path string) Option {
+	return func(c *config) {
+		c.controlSocket = path
+	}
+}
+
+// loadControlEnv applies the PSI_CONTROL_SOCKET override.
+func (c *config) loadControlEnv() {
+	if val := strings.TrimSpace(os.Getenv(controlSocketEnv)); val != "" {
+		c.controlSocket = val
+	}
+}
+
+// controlRequest is a command received on the control socket.
+type controlRequest struct {
+	Command string `json:"command"`
+	Signal  string `json:"signal,omitempty"`
+	Level   string `json:"level,omitempty"`
+}
+
+// controlReply answers a controlRequest.
+type controlReply struct {
+	OK     bool          `json:"ok"`
+	Error  string        `json:"error,omitempty"`
+	Status *statusReport `json:"status,omitempty"`
+}
+
+// parseControlRequest parses a line of the control protocol.
+func parseControlRequest(line string) (controlRequest, error) {
+	var req controlRequest
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			return req, fmt.Errorf("invalid request: %v", err)
+		}
+		return req, nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return req, errors.New("empty request")
+	}
+	req.Command = fields[0]
+	switch {
+	case len(fields) == 2 && req.Command == "send-signal":
+		req.Signal = fields[1]
+	case len(fields) == 2 && req.Command == "set-log-level":
+		req.Level = fields[1]
+	case len(fields) > 1:
+		return req, fmt.Errorf("unexpected arguments to %q", req.Command)
+	}
+	return req, nil
+}
+
+// controlCall hands a command that changes the child's state to the
+// supervisor loop, which sends the outcome on result.
+type controlCall struct {
+	req    controlRequest
+	sig    syscall.Signal
+	result chan error
+}
+
+// serveControl opens the control socket if configured. Failing to listen is
+// logged and otherwise ignored.
+func (s *supervisor) serveControl() {
+	path := s.cfg.controlSocket
+	if path == "" {
+		return
+	}
+	// A socket left behind by a previous run would make listening fail.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		log.Printf("psi: control socket disabled: %v", err)
+		return
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		log.Printf("psi: control socket disabled: %v", err)
+		ln.Close()
+		return
+	}
+	s.control = ln
+	s.cfg.debugf(1, "listening for control commands on %s", path)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveControlConn(conn)
+		}
+	}()
+}
+
+// stopControl closes the control socket, removing its file.
+func (s *supervisor) stopControl() {
+	if s.control != nil {
+		s.control.Close()
+	}
+}
+
+// serveControlConn answers the commands of a control connection until the
+// client closes it.
+func (s *supervisor) serveControlConn(conn net.Conn) {
+	defer conn.Close()
+	pid, uid := peerCredentials(conn)
+	sc := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		if err := enc.Encode(s.controlLine(sc.Text(), pid, uid)); err != nil {
+			return
+		}
+	}
+}
+
+// controlLine runs the command on line, sent by the peer pid and uid (0
+// and -1 when unknown).
+func (s *supervisor) controlLine(line string, pid, uid int) controlReply {
+	req, err := parseControlRequest(line)
+	if err == nil {
+		var st *statusReport
+		st, err = s.runControl(req)
+		s.audit.control(req, pid, uid, err)
+		if err == nil {
+			return controlReply{OK: true, Status: st}
+		}
+	}
+	return controlReply{Error: err.Error()}
+}
+
+// runControl runs req, in the supervisor loop if it affects the child.
+func (s *supervisor) runControl(req controlRequest) (*statusReport, error) {
+	call := controlCall{req: req, result: make(chan error, 1)}
+	switch req.Command {
+	case "status":
+		r := s.status.report(time.Now())
+		return &r, nil
+	case "set-log-level":
+		l, err := parseLogLevel(req.Level)
+		if err != nil {
+			return nil, err
+		}
+		initLog.level.Store(int32(l))
+		log.Printf("psi: log level set to %s over the control socket", l)
+		return nil, nil
+	case "send-signal":
+		sig, err := ParseSignal(req.Signal)
+		if err != nil {
+			return nil, err
+		}
+		call.sig = sig
+	case "stop", "restart":
+	default:
+		return nil, fmt.Errorf("unknown command %q", req.Command)
+	}
+	select {
+	case s.controls <- call:
+	case <-time.After(controlTimeout):
+		return nil, errors.New("supervisor busy; try again")
+	}
+	return nil, <-call.result
+}
+
+// handleControl runs a command taken by the supervisor loop.
+func (s *supervisor) handleControl(call controlCall) {
+	call.result <- s.applyControl(call)
+}
+
+func (s *supervisor) applyControl(call controlCall) error {
+	switch {
+	case call.req.Command == "stop":
+		if s.esc.started() {
+			return errors.New("already stopping")
+		}
+		log.Printf("psi: stop requested over the control socket")
+		s.beginStop(syscall.SIGTERM)
+		return nil
+	case s.esc.started():
+		return errors.New("shutting down")
+	case !s.status.alive():
+		return errors.New("child is not running")
+	case call.req.Command == "restart":
+		return s.restartChild()
+	}
+	s.signalAll(call.sig)
+	return nil
+}
+
+// restartChild stops the child so that a new generation starts once it has
+// exited, whatever the restart policy. The child is stopped through the same
+// escalation chain as on shutdown (see WithStopChain), each step's timer
+// moving on to the next signal until SIGKILL.
+func (s *supervisor) restartChild() error {
+	switch {
+	case s.esc.started():
+		return errors.New("shutting down")
+	case s.retiring != nil:
+		return errors.New("upgrade in progress")
+	case s.restartRequested || s.unhealthy:
+		return errors.New("child is already being stopped")
+	}
+	log.Printf("psi: restart requested; stopping child (pid %d)", s.childPID)
+	s.probeDue, s.watchdogDue = nil, nil
+	s.restartRequested = true
+	s.restartEsc = newEscalation(s.cfg.resolveStopChain(s.stopTimeout))
+	s.advanceRestart()
+	return nil
+}
+
+// advanceRestart sends the next signal of the restart escalation.
+func (s *supervisor) advanceRestart() {
+	step, ok := s.restartEsc.advance()
+	if !ok {
+		return
+	}
+	if step.Signal == 0 {
+		step.Signal = s.cfg.forwardSignal(syscall.SIGTERM)
+	}
+	if step.Signal == syscall.SIGKILL {
+		log.Printf("psi: child (pid %d) did not stop within %s; killing", s.childPID, s.cfg.killAfter(s.stopTimeout))
+	}
+	if s.restartEsc.timer != nil {
+		tracef("kill timer armed for %s (pid %d)", step.Wait, s.childPID)
+	}
+	s.signalChild(step.Signal)
+}
+
+// restartKill returns the channel that fires when the current step of a
+// restart's escalation has waited long enough.
+func (s *supervisor) restartKill() <-chan time.Time {
+	if s.restartEsc == nil {
+		return nil
+	}
+	return s.restartEsc.C()
+}
+
+// takeRestart reports whether the child that just exited was stopped by a
+// restart command, clearing the request.
+func (s *supervisor) takeRestart() bool {
+	requested := s.restartRequested
+	if s.restartEsc != nil && s.restartEsc.timer != nil {
+		s.restartEsc.timer.Stop()
+	}
+	s.restartRequested, s.restartEsc = false, nil
+	return requested && !s.esc.started()
+}
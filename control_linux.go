@@ -0,0 +1,28 @@
+package psi
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentials returns the PID and UID of the process at the other end
+// of a unix socket connection, 0 and -1 if they cannot be had.
+func peerCredentials(conn net.Conn) (pid, uid int) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, -1
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, -1
+	}
+	var cred *unix.Ucred
+	var cerr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, cerr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil || cerr != nil {
+		return 0, -1
+	}
+	return int(cred.Pid), int(cred.Uid)
+}
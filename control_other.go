@@ -0,0 +1,10 @@
+//go:build !linux
+
+package psi
+
+import "net"
+
+// peerCredentials is only implemented on Linux.
+func peerCredentials(net.Conn) (pid, uid int) {
+	return 0, -1
+}
@@ -0,0 +1,125 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseControlRequest(t *testing.T) {
+	for line, want := range map[string]controlRequest{
+		"status":              {Command: "status"},
+		"  send-signal HUP ":  {Command: "send-signal", Signal: "HUP"},
+		"set-log-level debug": {Command: "set-log-level", Level: "debug"},
+		`{"command":"send-signal","signal":"SIGUSR1"}`: {Command: "send-signal", Signal: "SIGUSR1"},
+	} {
+		if got, err := parseControlRequest(line); err != nil || got != want {
+			t.Errorf("parseControlRequest(%q) = %+v, %v", line, got, err)
+		}
+	}
+	for _, line := range []string{"", "stop now", "{bad"} {
+		if _, err := parseControlRequest(line); err == nil {
+			t.Errorf("parseControlRequest(%q) accepted", line)
+		}
+	}
+}
+
+func TestSupervisorControlSocket(t *testing.T) {
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "count")
+	sock := filepath.Join(dir, "psi.sock")
+	cmd := helperCommand("init-control", helperCountEnv+"="+countFile, controlSocketEnv+"="+sock)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "start\n")
+	if fi, err := os.Stat(sock); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("control socket: %v, %v", fi, err)
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	replies := bufio.NewScanner(conn)
+	send := func(line string) controlReply {
+		t.Helper()
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		var r controlReply
+		if !replies.Scan() || json.Unmarshal(replies.Bytes(), &r) != nil {
+			t.Fatalf("%s: no reply: %v", line, replies.Err())
+		}
+		return r
+	}
+
+	if r := send("status"); !r.OK || r.Status == nil || !r.Status.Running || r.Status.PID <= 0 {
+		t.Fatalf("status = %+v", r)
+	}
+	if r := send("send-signal HUP"); !r.OK {
+		t.Fatalf("send-signal = %+v", r)
+	}
+	waitForContent(t, countFile, "start\nhup\n")
+	if r := send(`{"command":"restart"}`); !r.OK {
+		t.Fatalf("restart = %+v", r)
+	}
+	waitForContent(t, countFile, "start\nhup\nterm\nstart\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for r := send("status"); r.Status.Restarts != 1 || !r.Status.Running; r = send("status") {
+		if time.Now().After(deadline) {
+			t.Fatalf("status after restart = %+v", r.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if r := send("set-log-level loud"); r.OK || r.Error == "" {
+		t.Fatalf("invalid level accepted: %+v", r)
+	}
+	if r := send("set-log-level warn"); !r.OK {
+		t.Fatalf("set-log-level = %+v", r)
+	}
+	if r := send("reboot"); r.OK {
+		t.Fatalf("unknown command accepted: %+v", r)
+	}
+	if r := send("stop"); !r.OK {
+		t.Fatalf("stop = %+v", r)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatalf("control socket left behind: %v", err)
+	}
+}
+
+func TestSupervisorControlRestartStopChain(t *testing.T) {
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "count")
+	sock := filepath.Join(dir, "psi.sock")
+	cmd := helperCommand("init-control-chain", helperCountEnv+"="+countFile, controlSocketEnv+"="+sock)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "start\n")
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("restart\n")); err != nil {
+		t.Fatal(err)
+	}
+	var r controlReply
+	if replies := bufio.NewScanner(conn); !replies.Scan() || json.Unmarshal(replies.Bytes(), &r) != nil || !r.OK {
+		t.Fatalf("restart = %+v", r)
+	}
+	waitForContent(t, countFile, "start\nusr1\nint\nstart\n")
+}
@@ -0,0 +1,81 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const cpusetEnv = "PSI_CPUSET"
+
+// WithCPUAffinity pins the child to the given CPUs. The mask is set with
+// sched_setaffinity on the thread that forks the child, so the child starts
+// with it before exec and everything it spawns inherits it. Overridden by
+// PSI_CPUSET, which takes a cpuset list such as "0-3,8".
+func WithCPUAffinity(cpus ...int) Option {
+	return func(c *config) {
+		c.cpus = append([]int(nil), cpus...)
+	}
+}
+
+// parseCPUList parses a cpuset(7) list such as "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(hi))
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, fmt.Errorf("empty CPU list")
+	}
+	return cpus, nil
+}
+
+// loadCPUSetEnv applies the PSI_CPUSET override.
+func (c *config) loadCPUSetEnv() {
+	val := strings.TrimSpace(os.Getenv(cpusetEnv))
+	if val == "" {
+		return
+	}
+	cpus, err := parseCPUList(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", cpusetEnv, val, err)
+		return
+	}
+	c.cpus = cpus
+}
+
+// pinThread locks the calling goroutine to its OS thread and restricts that
+// thread to the configured CPUs, so a child forked or exec'd from it inherits
+// the mask. The returned function restores the previous mask and unlocks the
+// thread. Without configured CPUs it does nothing.
+func (c *config) pinThread() func() {
+	if len(c.cpus) == 0 {
+		return func() {}
+	}
+	restore, err := setThreadAffinity(c.cpus)
+	if err != nil {
+		log.Printf("psi: failed to set child CPU affinity: %v", err)
+		return func() {}
+	}
+	return restore
+}
@@ -0,0 +1,33 @@
+package psi
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// setThreadAffinity pins the current OS thread to cpus. The goroutine stays
+// locked to the thread until the returned function restores the old mask.
+func setThreadAffinity(cpus []int) (func(), error) {
+	runtime.LockOSThread()
+	var old, set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &old); err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	if err := unix.SchedSetaffinity(0, &set); err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	return func() {
+		if err := unix.SchedSetaffinity(0, &old); err != nil {
+			// Leave the goroutine locked so the pinned thread is discarded
+			// instead of being reused by the scheduler.
+			return
+		}
+		runtime.UnlockOSThread()
+	}, nil
+}
@@ -0,0 +1,45 @@
+package psi
+
+import (
+	"os/exec"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPinThreadChildAffinity(t *testing.T) {
+	var avail unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &avail); err != nil {
+		t.Fatalf("sched_getaffinity: %v", err)
+	}
+	cpu := -1
+	for i := 0; i < 1024; i++ {
+		if avail.IsSet(i) {
+			cpu = i
+			break
+		}
+	}
+	cfg := &config{cpus: []int{cpu}}
+	cmd := exec.Command("sleep", "10")
+	unpin := cfg.pinThread()
+	err := cmd.Start()
+	unpin()
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	var got unix.CPUSet
+	if err := unix.SchedGetaffinity(cmd.Process.Pid, &got); err != nil {
+		t.Fatalf("sched_getaffinity(child): %v", err)
+	}
+	if got.Count() != 1 || !got.IsSet(cpu) {
+		t.Fatalf("expected child pinned to CPU %d, got %d CPUs", cpu, got.Count())
+	}
+	var self unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &self); err != nil || self != avail {
+		t.Fatalf("expected the init's affinity to be restored")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func setThreadAffinity([]int) (func(), error) {
+	return nil, errors.New("CPU affinity is only supported on Linux")
+}
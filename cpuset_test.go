@@ -0,0 +1,34 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cases := map[string][]int{
+		"0":          {0},
+		"0-3":        {0, 1, 2, 3},
+		"1,4-5, 9":   {1, 4, 5, 9},
+		" 2 - 3 ,,7": {2, 3, 7},
+	}
+	for input, want := range cases {
+		got, err := parseCPUList(input)
+		if err != nil || !slices.Equal(got, want) {
+			t.Fatalf("parseCPUList(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "a", "3-1", "-1", "1-"} {
+		if _, err := parseCPUList(input); err == nil {
+			t.Fatalf("parseCPUList(%q) should fail", input)
+		}
+	}
+}
+
+func TestCPUSetEnv(t *testing.T) {
+	t.Setenv(cpusetEnv, "2-3")
+	cfg := newConfig(WithCPUAffinity(0))
+	if !slices.Equal(cfg.cpus, []int{2, 3}) {
+		t.Fatalf("expected env cpuset to win, got %v", cfg.cpus)
+	}
+}
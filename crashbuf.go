@@ -0,0 +1,131 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const crashBufferEnv = "PSI_CRASH_BUFFER"
+
+// defaultCrashBuffer is the crash buffer's size unless one is set.
+const defaultCrashBuffer = 64 << 10
+
+// WithCrashBuffer makes the init keep the last size bytes of the child's
+// stderr (64 KiB if size is 0) in a ring buffer. When a generation exits
+// abnormally, the kept output, typically a panic with its stack traces, is
+// logged, carried in ExitSummary.Stderr and used for the termination message
+// (see WithTerminationLog), so it survives a logging pipeline that lost it.
+// Overridden by PSI_CRASH_BUFFER, set to a size such as 64KiB or 1M, to 1
+// for the default or to off.
+func WithCrashBuffer(size int) Option {
+	return func(c *config) {
+		if size <= 0 {
+			size = defaultCrashBuffer
+		}
+		c.crashBuffer = size
+	}
+}
+
+// loadCrashBufferEnv applies the PSI_CRASH_BUFFER override.
+func (c *config) loadCrashBufferEnv() {
+	val := strings.TrimSpace(os.Getenv(crashBufferEnv))
+	if val == "" {
+		return
+	}
+	if b, err := parseBool(val); err == nil {
+		c.crashBuffer = 0
+		if b {
+			c.crashBuffer = defaultCrashBuffer
+		}
+		return
+	}
+	n, err := parseSize(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", crashBufferEnv, val, err)
+		return
+	}
+	c.crashBuffer = int(n)
+}
+
+// ringBuffer keeps the last bytes written to it.
+type ringBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, size)}
+}
+
+func (b *ringBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	if n >= len(b.buf) {
+		copy(b.buf, p[n-len(b.buf):])
+		b.pos, b.full = 0, true
+		return n, nil
+	}
+	if b.pos+n >= len(b.buf) {
+		b.full = true
+	}
+	c := copy(b.buf[b.pos:], p)
+	copy(b.buf, p[c:])
+	b.pos = (b.pos + n) % len(b.buf)
+	return n, nil
+}
+
+// bytes returns the kept output, oldest first. Once the buffer has wrapped,
+// the partial first line is dropped.
+func (b *ringBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]byte(nil), b.buf[:b.pos]...)
+	}
+	out := append(append([]byte(nil), b.buf[b.pos:]...), b.buf[:b.pos]...)
+	if i := strings.IndexByte(string(out), '\n'); i >= 0 && i < len(out)-1 {
+		out = out[i+1:]
+	}
+	return out
+}
+
+// reset empties the buffer for a new generation.
+func (b *ringBuffer) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pos, b.full = 0, false
+}
+
+// crashed reports whether the generation summarized by sum ended
+// abnormally: it failed on its own, or had to be killed after ignoring a
+// stop request.
+func (s *supervisor) crashed(sum *ExitSummary) bool {
+	if s.esc.started() {
+		return sum.Signal == syscall.SIGKILL
+	}
+	return sum.ExitCode != 0
+}
+
+// captureCrash fills sum.Stderr with the crash buffer if the generation
+// crashed, and logs it.
+func (s *supervisor) captureCrash(sum *ExitSummary) {
+	if s.crashBuffer == nil || !s.crashed(sum) {
+		return
+	}
+	s.output.flush(time.Second)
+	out := s.crashBuffer.bytes()
+	if len(out) == 0 {
+		return
+	}
+	sum.Stderr = string(out)
+	logMessage(LogError, fmt.Sprintf("child (pid %d) stderr before it exited (last %d bytes):\n%s",
+		sum.PID, len(out), strings.TrimSuffix(sum.Stderr, "\n")))
+}
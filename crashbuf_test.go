@@ -0,0 +1,93 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	b := newRingBuffer(16)
+	fmt.Fprint(b, "hello\n")
+	if got := string(b.bytes()); got != "hello\n" {
+		t.Fatalf("bytes = %q", got)
+	}
+	fmt.Fprint(b, "first\nsecond\nthird\n")
+	// Wrapped: the cut-off first line is dropped.
+	if got := string(b.bytes()); got != "second\nthird\n" {
+		t.Fatalf("bytes = %q", got)
+	}
+	fmt.Fprint(b, strings.Repeat("z", 20)+"\nend")
+	if got := string(b.bytes()); got != "end" {
+		t.Fatalf("bytes = %q", got)
+	}
+	b.reset()
+	fmt.Fprint(b, "0123456789abcdef")
+	if got := string(b.bytes()); got != "0123456789abcdef" {
+		t.Fatalf("bytes after reset = %q", got)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"65536": 65536, "64K": 64 << 10, "64KiB": 64 << 10, "1M": 1 << 20, " 2 mib ": 2 << 20, "1G": 1 << 30} {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "K", "-1", "0", "12Q", "4G"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) succeeded", in)
+		}
+	}
+}
+
+func TestCrashBufferEnv(t *testing.T) {
+	for val, want := range map[string]int{"1": defaultCrashBuffer, "256K": 256 << 10, "off": 0, "bogus": 1024} {
+		t.Setenv(crashBufferEnv, val)
+		if c := newConfig(WithCrashBuffer(1024)); c.crashBuffer != want {
+			t.Errorf("%s=%q: crashBuffer = %d, want %d", crashBufferEnv, val, c.crashBuffer, want)
+		}
+	}
+	t.Setenv(crashBufferEnv, "")
+	if c := newConfig(WithCrashBuffer(0)); c.crashBuffer != defaultCrashBuffer {
+		t.Errorf("WithCrashBuffer(0) = %d", c.crashBuffer)
+	}
+}
+
+func TestCrashed(t *testing.T) {
+	s := newSupervisor(newConfig())
+	if s.crashed(&ExitSummary{}) || !s.crashed(&ExitSummary{ExitCode: 2}) {
+		t.Fatal("exit codes misjudged")
+	}
+	s.esc.advance()
+	if s.crashed(&ExitSummary{ExitCode: 143, Signal: syscall.SIGTERM}) {
+		t.Fatal("a requested stop counted as a crash")
+	}
+	if !s.crashed(&ExitSummary{ExitCode: 137, Signal: syscall.SIGKILL}) {
+		t.Fatal("a forced kill did not count as a crash")
+	}
+}
+
+func TestSupervisorCrashBuffer(t *testing.T) {
+	path := t.TempDir() + "/termination-log"
+	cmd := helperCommand("init-stderr", crashBufferEnv+"=1", terminationLogEnv+"="+path)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	if want := "stderr before it exited (last 21 bytes):\nstarting\npanic: boom\n"; !strings.Contains(stderr.String(), want) {
+		t.Fatalf("crash output not logged, want %q in:\n%s", want, stderr.String())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "last stderr lines:\nstarting\npanic: boom\n"; !strings.HasSuffix(string(b), want) {
+		t.Fatalf("termination message lacks %q:\n%s", want, b)
+	}
+}
@@ -0,0 +1,320 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CronJob is a command the init runs periodically while it supervises the
+// child, e.g. to purge a cache or renew certificates, so no separate cron is
+// needed. Like hooks, jobs run with the init's credentials and environment
+// (without PSI_* variables) as children reaped by the init.
+type CronJob struct {
+	// Name identifies the job in logs.
+	Name string
+	// Schedule is a cron expression in local time: five fields (minute,
+	// hour, day of month, month, day of week) accepting *, lists, ranges,
+	// steps and month or day names, e.g. "*/15 * * * *" or "0 3 * * MON-FRI";
+	// @yearly, @monthly, @weekly, @daily or @hourly; or "@every DURATION",
+	// e.g. "@every 90s".
+	Schedule string
+	// Path is the executable to run; Args are its arguments (without argv[0]).
+	Path string
+	Args []string
+	// Env is appended to the environment.
+	Env []string
+	// Timeout bounds a run; it is killed once exceeded. Zero means no limit.
+	Timeout time.Duration
+}
+
+// WithCronJob adds a periodic job. A run that is due while the previous run
+// of the same job is still going is skipped. Jobs are scheduled once the init
+// tasks and pre-start hook have completed; on shutdown scheduling stops and
+// running jobs are sent SIGTERM and killed after PSI_STOP_TIMEOUT, before the
+// post-stop hook runs. A job with an invalid schedule is logged and ignored.
+func WithCronJob(job CronJob) Option {
+	return func(c *config) {
+		job.Args = append([]string(nil), job.Args...)
+		job.Env = append([]string(nil), job.Env...)
+		c.cronJobs = append(c.cronJobs, job)
+	}
+}
+
+// cronSchedule is a parsed schedule. Each field is a bit set of the values
+// it matches; every is set instead for @every schedules.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a day field starting with *: when both day
+	// fields are restricted, a day matching either is due.
+	domAny, dowAny bool
+	every          time.Duration
+}
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	cronDays   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+func parseCronSchedule(s string) (*cronSchedule, error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: want a positive duration", s)
+		}
+		return &cronSchedule{every: d}, nil
+	}
+	if expr, ok := cronShorthands[strings.ToLower(s)]; ok {
+		s = expr
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want 5 fields", s)
+	}
+	var sched cronSchedule
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+		names    []string
+		nameBase int
+	}{
+		{&sched.minute, 0, 59, nil, 0},
+		{&sched.hour, 0, 23, nil, 0},
+		{&sched.dom, 1, 31, nil, 0},
+		{&sched.month, 1, 12, cronMonths, 1},
+		{&sched.dow, 0, 7, cronDays, 0},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.min, f.max, f.names, f.nameBase); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", s, err)
+		}
+	}
+	if sched.dow&(1<<7) != 0 {
+		// 7 is Sunday too.
+		sched.dow |= 1
+	}
+	// As in Vixie cron, a day field starting with * (e.g. */2) counts as
+	// unrestricted: the two fields must then both match, not either.
+	sched.domAny, sched.dowAny = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	return &sched, nil
+}
+
+// parseCronField parses a comma-separated list of *, values and ranges with
+// optional steps into a bit set.
+func parseCronField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(loStr, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(hiStr, min, max, names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + nameBase, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, min, max)
+	}
+	return n, nil
+}
+
+// next returns the first time after t the schedule is due, or the zero
+// time if it never is within five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Add(c.every)
+	}
+	// Step the clock in t's zone: truncating absolute time is off by the
+	// zone's offset where it is not whole hours (e.g. Asia/Kolkata).
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cron runs the cron jobs.
+type cron struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startCron schedules the configured cron jobs.
+func (s *supervisor) startCron() {
+	if len(s.cfg.cronJobs) == 0 {
+		return
+	}
+	s.cron = &cron{stop: make(chan struct{})}
+	for _, job := range s.cfg.cronJobs {
+		sched, err := parseCronSchedule(job.Schedule)
+		if err != nil {
+			log.Printf("psi: cron job %q: %v; ignoring", job.Name, err)
+			continue
+		}
+		s.cron.wg.Add(1)
+		go s.runCronJob(job, sched)
+	}
+}
+
+// runCronJob runs job on its schedule until the cron is stopped.
+func (s *supervisor) runCronJob(job CronJob, sched *cronSchedule) {
+	defer s.cron.wg.Done()
+	name := "cron job"
+	if job.Name != "" {
+		name += " " + job.Name
+	}
+	// finished yields once the current run is over; nil when idle.
+	var finished chan struct{}
+	var stopRun chan struct{}
+	for {
+		next := sched.next(time.Now())
+		if next.IsZero() {
+			log.Printf("psi: %s is never due", name)
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.cron.stop:
+			timer.Stop()
+			if finished != nil {
+				close(stopRun)
+				<-finished
+			}
+			return
+		case <-timer.C:
+		}
+		if finished != nil {
+			select {
+			case <-finished:
+				finished = nil
+			default:
+				log.Printf("psi: %s is still running; skipping the run due at %s", name, next.Format(time.RFC3339))
+				continue
+			}
+		}
+		finished, stopRun = make(chan struct{}), make(chan struct{})
+		go func(finished, stopRun chan struct{}) {
+			defer close(finished)
+			s.cronRun(name, job, stopRun)
+		}(finished, stopRun)
+	}
+}
+
+// cronRun runs job once. Closing stop sends it SIGTERM and kills it after
+// the stop timeout.
+func (s *supervisor) cronRun(name string, job CronJob, stop <-chan struct{}) {
+	proc, done, err := s.reaper.start(s.hookCommand(Hook{Path: job.Path, Args: job.Args, Env: job.Env}))
+	if err != nil {
+		log.Printf("psi: %s: %v", name, err)
+		return
+	}
+	defer proc.close()
+	start := time.Now()
+	s.cfg.debugf(1, "running %s %s (pid %d)", name, job.Path, proc.pid)
+	var timeout, kill <-chan time.Time
+	if job.Timeout > 0 {
+		timeout = time.After(job.Timeout)
+	}
+	for {
+		select {
+		case code := <-done:
+			if code != 0 {
+				log.Printf("psi: %s exited with code %d after %s", name, code, time.Since(start).Round(time.Millisecond))
+			} else {
+				s.cfg.debugf(1, "%s completed in %s", name, time.Since(start).Round(time.Millisecond))
+			}
+			return
+		case <-timeout:
+			timeout = nil
+			log.Printf("psi: %s did not finish within %s; killing", name, job.Timeout)
+			_ = proc.signalGroup(syscall.SIGKILL)
+		case <-stop:
+			stop = nil
+			_ = proc.signalGroup(syscall.SIGTERM)
+			kill = time.After(s.stopTimeout)
+		case <-kill:
+			kill = nil
+			_ = proc.signalGroup(syscall.SIGKILL)
+		}
+	}
+}
+
+// stopCron stops scheduling and waits for running jobs to stop.
+func (s *supervisor) stopCron() {
+	if s.cron == nil {
+		return
+	}
+	close(s.cron.stop)
+	s.cron.wg.Wait()
+	s.cron = nil
+}
@@ -0,0 +1,79 @@
+package psi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, good := range []string{"* * * * *", "*/15 0-6 1,15 JAN-mar mon-fri", "0 3 * * 7", "@daily", "@HOURLY", "@every 90s", "5/10 * * * *"} {
+		if _, err := parseCronSchedule(good); err != nil {
+			t.Errorf("parseCronSchedule(%q): %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "@every", "@every -1s", "@fortnightly"} {
+		if _, err := parseCronSchedule(bad); err == nil {
+			t.Errorf("parseCronSchedule(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2024-01-10 is a Wednesday.
+	from := time.Date(2024, time.January, 10, 10, 7, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 15, 0, 0, time.UTC)},
+		{"5/10 * * * *", time.Date(2024, 1, 10, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * SUN", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 MAR *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match.
+		{"0 0 20 * FRI", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		// A stepped * counts as unrestricted, so both fields must match:
+		// an odd-numbered Friday, not any odd day or any Friday.
+		{"0 0 */2 * FRI", time.Date(2024, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */2 * *", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	} {
+		sched, err := parseCronSchedule(tc.schedule)
+		if err != nil {
+			t.Fatalf("parseCronSchedule(%q): %v", tc.schedule, err)
+		}
+		if got := sched.next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %s, want %s", tc.schedule, got, tc.want)
+		}
+	}
+	sched, _ := parseCronSchedule("0 0 31 2 *")
+	if got := sched.next(from); !got.IsZero() {
+		t.Errorf("February 31st is due at %s", got)
+	}
+}
+
+func TestCronScheduleNextHalfHourZone(t *testing.T) {
+	// Asia/Kolkata is UTC+5:30.
+	ist := time.FixedZone("IST", 5*3600+1800)
+	from := time.Date(2024, time.January, 10, 10, 7, 30, 0, ist)
+	for _, tc := range []struct {
+		schedule string
+		want     time.Time
+	}{
+		{"0 3 * * *", time.Date(2024, 1, 11, 3, 0, 0, 0, ist)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, ist)},
+		{"30 * * * *", time.Date(2024, 1, 10, 10, 30, 0, 0, ist)},
+	} {
+		sched, err := parseCronSchedule(tc.schedule)
+		if err != nil {
+			t.Fatalf("parseCronSchedule(%q): %v", tc.schedule, err)
+		}
+		if got := sched.next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %s, want %s", tc.schedule, got, tc.want)
+		}
+	}
+}
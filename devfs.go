@@ -0,0 +1,52 @@
+package psi
+
+import "log"
+
+const setupDevEnv = "PSI_SETUP_DEV"
+
+// WithSetupDev creates the basic device nodes (/dev/null, /dev/zero,
+// /dev/full, /dev/random, /dev/urandom) and the /dev/fd and /dev/std*
+// symlinks during init startup when they are missing, as on scratch images
+// run without a populated /dev. Nodes are created with mknod, or
+// bind-mounted from a private devtmpfs when mknod is not permitted. Linux
+// only. Overridden by PSI_SETUP_DEV.
+func WithSetupDev() Option {
+	return func(c *config) {
+		c.setupDev = true
+	}
+}
+
+// devNode is a character device psi provisions in /dev.
+type devNode struct {
+	name         string
+	major, minor uint32
+}
+
+var devNodes = []devNode{
+	{"null", 1, 3},
+	{"zero", 1, 5},
+	{"full", 1, 7},
+	{"random", 1, 8},
+	{"urandom", 1, 9},
+}
+
+var devLinks = [][2]string{
+	{"fd", "/proc/self/fd"},
+	{"stdin", "/proc/self/fd/0"},
+	{"stdout", "/proc/self/fd/1"},
+	{"stderr", "/proc/self/fd/2"},
+}
+
+// applySetupDev provisions /dev when enabled.
+func (c *config) applySetupDev() {
+	if !c.setupDev {
+		return
+	}
+	created, err := setupDev("/dev")
+	if err != nil {
+		log.Printf("psi: cannot set up /dev: %v", err)
+	}
+	if len(created) > 0 {
+		c.debugf(1, "created %v in /dev", created)
+	}
+}
@@ -0,0 +1,90 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// setupDev creates the missing nodes and links of devNodes and devLinks in
+// dir and returns the names it created.
+func setupDev(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var created, needBind []string
+	var errs []error
+	for _, n := range devNodes {
+		path := filepath.Join(dir, n.name)
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		}
+		err := unix.Mknod(path, unix.S_IFCHR|0o666, int(unix.Mkdev(n.major, n.minor)))
+		switch {
+		case err == nil:
+			// mknod honours the umask.
+			if err := os.Chmod(path, 0o666); err != nil {
+				errs = append(errs, err)
+			}
+			created = append(created, n.name)
+		case errors.Is(err, unix.EPERM):
+			needBind = append(needBind, n.name)
+		default:
+			errs = append(errs, fmt.Errorf("mknod %s: %w", path, err))
+		}
+	}
+	if len(needBind) > 0 {
+		bound, err := bindDevNodes(dir, needBind)
+		created = append(created, bound...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, l := range devLinks {
+		path := filepath.Join(dir, l[0])
+		if _, err := os.Lstat(path); err == nil {
+			continue
+		}
+		if err := os.Symlink(l[1], path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		created = append(created, l[0])
+	}
+	return created, errors.Join(errs...)
+}
+
+// bindDevNodes bind-mounts the named nodes from a temporary devtmpfs mount
+// onto empty files in dir, for when mknod is not permitted.
+func bindDevNodes(dir string, names []string) ([]string, error) {
+	tmp, err := os.MkdirTemp(dir, ".psi-devtmpfs")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	if err := unix.Mount("devtmpfs", tmp, "devtmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, ""); err != nil {
+		return nil, fmt.Errorf("mknod not permitted and devtmpfs unavailable: %w", err)
+	}
+	defer unix.Unmount(tmp, unix.MNT_DETACH)
+	var bound []string
+	var errs []error
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o666)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f.Close()
+		if err := unix.Mount(filepath.Join(tmp, name), path, "", unix.MS_BIND, ""); err != nil {
+			os.Remove(path)
+			errs = append(errs, fmt.Errorf("bind %s: %w", path, err))
+			continue
+		}
+		bound = append(bound, name)
+	}
+	return bound, errors.Join(errs...)
+}
@@ -0,0 +1,36 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetupDev(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to create device nodes")
+	}
+	dir := filepath.Join(t.TempDir(), "dev")
+	created, err := setupDev(dir)
+	if err != nil {
+		t.Fatalf("setupDev: %v", err)
+	}
+	if len(created) != len(devNodes)+len(devLinks) {
+		t.Fatalf("created %v", created)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "null"))
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 || fi.Mode().Perm() != 0o666 {
+		t.Fatalf("/dev/null: %v, %v", fi.Mode(), err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "null"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open null: %v", err)
+	}
+	f.Close()
+	if target, err := os.Readlink(filepath.Join(dir, "fd")); err != nil || target != "/proc/self/fd" {
+		t.Fatalf("fd link: %q, %v", target, err)
+	}
+	if created, err := setupDev(dir); err != nil || len(created) != 0 {
+		t.Fatalf("second setupDev = %v, %v; want no-op", created, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func setupDev(string) ([]string, error) {
+	return nil, errors.New("/dev provisioning is only supported on Linux")
+}
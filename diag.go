@@ -0,0 +1,111 @@
+package psi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	diagSignalEnv = "PSI_DIAG_SIGNAL"
+	diagFileEnv   = "PSI_DIAG_FILE"
+	diagHeapEnv   = "PSI_DIAG_HEAP"
+)
+
+// WithDiagSignal makes a Go submain dump the stacks of all its goroutines,
+// in the format of an unrecovered panic, each time it receives sig (e.g.
+// SIGUSR1, which the init forwards as is), without stopping: the way to see
+// where a submain hangs during shutdown in an image without a shell or
+// debugger. Dumps go to stderr unless WithDiagFile is set. The init's
+// POST /debug/child/stacks endpoint sends sig by default. Overridden by
+// PSI_DIAG_SIGNAL.
+func WithDiagSignal(sig syscall.Signal) Option {
+	return func(c *config) {
+		c.diagSignal = sig
+	}
+}
+
+// WithDiagFile appends the dumps of WithDiagSignal to the file at path
+// instead of writing them to stderr. Overridden by PSI_DIAG_FILE.
+func WithDiagFile(path string) Option {
+	return func(c *config) {
+		c.diagFile = path
+	}
+}
+
+// WithDiagHeap adds a heap profile, in pprof's text form, to each dump of
+// WithDiagSignal. Overridden by PSI_DIAG_HEAP.
+func WithDiagHeap() Option {
+	return func(c *config) {
+		c.diagHeap = true
+	}
+}
+
+// loadDiagEnv applies the PSI_DIAG_* overrides.
+func (c *config) loadDiagEnv() {
+	if val := strings.TrimSpace(os.Getenv(diagSignalEnv)); val != "" {
+		sig, err := ParseSignal(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", diagSignalEnv, val, err)
+		} else {
+			c.diagSignal = sig
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(diagFileEnv)); val != "" {
+		c.diagFile = val
+	}
+	envBool(diagHeapEnv, &c.diagHeap)
+}
+
+// startDiag installs the diagnostic dump handler in the process about to run
+// submain.
+func (c *config) startDiag() {
+	if c.diagSignal == 0 {
+		return
+	}
+	Notify(context.Background(), func(os.Signal) {
+		if err := c.writeDiag(); err != nil {
+			log.Printf("psi: diagnostic dump: %v", err)
+		}
+	}, c.diagSignal)
+}
+
+// writeDiag writes one dump to the configured destination.
+func (c *config) writeDiag() error {
+	if c.diagFile == "" {
+		return c.dumpDiag(os.Stderr)
+	}
+	f, err := os.OpenFile(c.diagFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := c.dumpDiag(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dumpDiag writes a header, all goroutine stacks and, if enabled, the heap
+// profile to w.
+func (c *config) dumpDiag(w io.Writer) error {
+	fmt.Fprintf(w, "=== psi diagnostic dump of pid %d at %s (%s) ===\n",
+		os.Getpid(), time.Now().UTC().Format(time.RFC3339Nano), signalName(c.diagSignal))
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		return err
+	}
+	if c.diagHeap {
+		fmt.Fprintln(w, "=== heap profile ===")
+		if err := pprof.Lookup("heap").WriteTo(w, 1); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "=== end of psi diagnostic dump ===")
+	return err
+}
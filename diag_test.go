@@ -0,0 +1,88 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDiagEnv(t *testing.T) {
+	t.Setenv(diagSignalEnv, "usr2")
+	t.Setenv(diagFileEnv, "/tmp/dump")
+	t.Setenv(diagHeapEnv, "1")
+	c := newConfig(WithDiagSignal(syscall.SIGUSR1))
+	if c.diagSignal != syscall.SIGUSR2 || c.diagFile != "/tmp/dump" || !c.diagHeap {
+		t.Fatalf("diag = %v %q %t", c.diagSignal, c.diagFile, c.diagHeap)
+	}
+	if sig := c.stackDumpSignal(); sig != syscall.SIGUSR2 {
+		t.Fatalf("stackDumpSignal = %v, want the diag signal", sig)
+	}
+	t.Setenv(diagSignalEnv, "bogus")
+	if c := newConfig(WithDiagSignal(syscall.SIGUSR1)); c.diagSignal != syscall.SIGUSR1 {
+		t.Fatalf("invalid override applied: %v", c.diagSignal)
+	}
+}
+
+func TestDumpDiag(t *testing.T) {
+	c := newConfig(WithDiagSignal(syscall.SIGUSR1), WithDiagHeap())
+	var buf bytes.Buffer
+	if err := c.dumpDiag(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		fmt.Sprintf("psi diagnostic dump of pid %d", os.Getpid()),
+		"(SIGUSR1)",
+		"goroutine ",
+		"TestDumpDiag",
+		"=== heap profile ===",
+		"heap profile: ",
+		"=== end of psi diagnostic dump ===",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump lacks %q", want)
+		}
+	}
+}
+
+func TestRunChildDiagSignal(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "dump")
+	cmd := helperCommand("run-child", childEnvKey+"="+childEnvVal,
+		diagSignalEnv+"=SIGUSR2", diagFileEnv+"="+dump)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	time.Sleep(100 * time.Millisecond)
+	if err := cmd.Process.Signal(syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(dump)
+		if bytes.Contains(b, []byte("end of psi diagnostic dump")) {
+			if !bytes.Contains(b, []byte("TestHelperProcess")) {
+				t.Fatalf("dump lacks the submain's stack:\n%s", b)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no dump in %s: %q", dump, b)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// The dump must not have stopped the submain.
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 99 {
+		t.Fatalf("expected exit code 99 after cancellation, got %d", exit)
+	}
+}
@@ -0,0 +1,178 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const envFileEnv = "PSI_ENV_FILE"
+
+// WithEnvFile adds variables from dotenv files to the child's environment.
+// The files are read whenever a child starts; variables already present in
+// the environment win over file values. Overridden by PSI_ENV_FILE
+// (comma-separated paths).
+func WithEnvFile(paths ...string) Option {
+	return func(c *config) {
+		c.envFiles = append([]string(nil), paths...)
+	}
+}
+
+// loadEnvFileEnv applies the PSI_ENV_FILE override.
+func (c *config) loadEnvFileEnv() {
+	if paths := splitPaths(os.Getenv(envFileEnv)); len(paths) > 0 {
+		c.envFiles = paths
+	}
+}
+
+// parseDotenv parses dotenv syntax: KEY=value lines with optional "export"
+// prefixes, # comments, single quotes (literal), double quotes (with \n, \t,
+// \" and \\ escapes) and quoted values spanning several lines.
+func parseDotenv(data string) ([]string, error) {
+	var env []string
+	rest := data
+	line := 0
+	for rest != "" {
+		var cur string
+		cur, rest, _ = strings.Cut(rest, "\n")
+		line++
+		cur = strings.TrimSpace(cur)
+		if cur == "" || strings.HasPrefix(cur, "#") {
+			continue
+		}
+		cur = strings.TrimPrefix(cur, "export ")
+		key, val, ok := strings.Cut(cur, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=value", line)
+		}
+		val = strings.TrimLeft(val, " \t")
+		if val != "" && (val[0] == '"' || val[0] == '\'') {
+			// Quoted values may continue on the following lines.
+			quote := val[0]
+			body := val[1:] + "\n" + rest
+			end := closingQuote(body, quote)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated %c quote", line, quote)
+			}
+			consumed := body[:end]
+			line += strings.Count(consumed, "\n")
+			rest = body[end+1:]
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				trailer := strings.TrimSpace(rest[:i])
+				if trailer != "" && !strings.HasPrefix(trailer, "#") {
+					return nil, fmt.Errorf("line %d: unexpected text after quoted value", line)
+				}
+				rest = rest[i+1:]
+			} else {
+				rest = ""
+			}
+			if quote == '"' {
+				consumed = unescapeDotenv(consumed)
+			}
+			val = consumed
+		} else {
+			if i := strings.Index(val, " #"); i >= 0 {
+				val = val[:i]
+			}
+			val = strings.TrimSpace(val)
+		}
+		env = append(env, key+"="+val)
+	}
+	return env, nil
+}
+
+// closingQuote returns the index of the quote ending a value in s, skipping
+// backslash-escaped double quotes.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\"`, `"`, `\\`, `\`)
+
+func unescapeDotenv(s string) string {
+	return dotenvEscapes.Replace(s)
+}
+
+// readEnvFiles parses the configured dotenv files in order.
+func (c *config) readEnvFiles() ([]string, error) {
+	var env []string
+	for _, path := range c.envFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		vars, err := parseDotenv(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		env = append(env, vars...)
+	}
+	return env, nil
+}
+
+// mergeEnv appends the variables in extra whose keys are not yet set in env.
+func mergeEnv(env, extra []string) []string {
+	set := make(map[string]bool, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		set[key] = true
+	}
+	for _, kv := range extra {
+		key, _, _ := strings.Cut(kv, "=")
+		if !set[key] {
+			set[key] = true
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// extraEnv reads the variables from the configured env files and env
+// directories, in that order.
+func (c *config) extraEnv() ([]string, error) {
+	env, err := c.readEnvFiles()
+	if err != nil {
+		return nil, fmt.Errorf("env file: %w", err)
+	}
+	dirEnv, err := c.readEnvDirs()
+	if err != nil {
+		return nil, fmt.Errorf("env dir: %w", err)
+	}
+	return append(env, dirEnv...), nil
+}
+
+// childExtraEnv adds the env file and env directory variables to a child's
+// environment.
+func (c *config) childExtraEnv(env []string) ([]string, error) {
+	extra, err := c.extraEnv()
+	if err != nil {
+		return nil, err
+	}
+	return mergeEnv(env, extra), nil
+}
+
+// setExtraEnv adds the env file and env directory variables to the current
+// process's environment. It is used when there is no separate child.
+func (c *config) setExtraEnv() {
+	extra, err := c.extraEnv()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	for _, kv := range extra {
+		key, val, _ := strings.Cut(kv, "=")
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, val)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	data := `# comment
+PLAIN=value
+export EXPORTED=yes
+SPACED = padded value   # trailing comment
+EMPTY=
+HASH=a#b
+SINGLE='literal \n $HOME'
+DOUBLE="tab\there \"quoted\""
+MULTI="line one
+line two"
+CERT='-----BEGIN-----
+abc
+-----END-----' # done
+LAST=1`
+	got, err := parseDotenv(data)
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+	want := []string{
+		"PLAIN=value",
+		"EXPORTED=yes",
+		"SPACED=padded value",
+		"EMPTY=",
+		"HASH=a#b",
+		`SINGLE=literal \n $HOME`,
+		"DOUBLE=tab\there \"quoted\"",
+		"MULTI=line one\nline two",
+		"CERT=-----BEGIN-----\nabc\n-----END-----",
+		"LAST=1",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+	for _, bad := range []string{"NOVALUE", "A B=1", `Q="open`, `Q="x" junk`} {
+		if _, err := parseDotenv(bad); err == nil {
+			t.Fatalf("parseDotenv(%q) should fail", bad)
+		}
+	}
+}
+
+func TestChildEnvFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A=file\nB=file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envFileEnv, path)
+	cfg := newConfig()
+	env, err := cfg.childExtraEnv([]string{"A=env"})
+	if err != nil {
+		t.Fatalf("childExtraEnv: %v", err)
+	}
+	if !slices.Equal(env, []string{"A=env", "B=file"}) {
+		t.Fatalf("env = %v; existing variables must win", env)
+	}
+	cfg.envFiles = []string{filepath.Join(t.TempDir(), "missing")}
+	if _, err := cfg.childExtraEnv(nil); err == nil {
+		t.Fatal("expected a missing env file to fail")
+	}
+}
@@ -0,0 +1,67 @@
+package psi
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	envAllowEnv = "PSI_ENV_ALLOW"
+	// psiEnvPrefix is the namespace of psi's own environment variables.
+	psiEnvPrefix = "PSI_"
+)
+
+// WithEnvAllow lets PSI_* variables matching the given patterns (path.Match
+// syntax, e.g. "PSI_APP_*") through to the child. All other PSI_* variables
+// configure psi only and are removed from the environment of the child,
+// sidecars and submain. Overridden by PSI_ENV_ALLOW (comma-separated).
+func WithEnvAllow(patterns ...string) Option {
+	return func(c *config) {
+		c.envAllow = append([]string(nil), patterns...)
+	}
+}
+
+// loadEnvAllowEnv applies the PSI_ENV_ALLOW override.
+func (c *config) loadEnvAllowEnv() {
+	if patterns := splitPaths(os.Getenv(envAllowEnv)); len(patterns) > 0 {
+		c.envAllow = patterns
+	}
+}
+
+// keepEnv reports whether the variable key may be passed on to the child.
+func (c *config) keepEnv(key string) bool {
+	if !strings.HasPrefix(key, psiEnvPrefix) || key == readyFDEnv {
+		return true
+	}
+	for _, pattern := range c.envAllow {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// childEnv returns env without the psi variables not allowed through.
+func (c *config) childEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if c.keepEnv(key) {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// scrubEnv removes the psi variables not allowed through from the current
+// process's environment. It runs once the configuration has been read, right
+// before submain or exec.
+func (c *config) scrubEnv() {
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !c.keepEnv(key) {
+			os.Unsetenv(key)
+		}
+	}
+}
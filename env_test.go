@@ -0,0 +1,31 @@
+package psi
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestChildEnv(t *testing.T) {
+	cfg := &config{envAllow: []string{"PSI_APP_*", "PSI_TOKEN"}}
+	env := []string{"PATH=/bin", "PSI_CHILD=1", "PSI_STOP_TIMEOUT=5s", "PSI_APP_MODE=x", "PSI_TOKEN=t", "PSIX=1"}
+	got := cfg.childEnv(env)
+	want := []string{"PATH=/bin", "PSI_APP_MODE=x", "PSI_TOKEN=t", "PSIX=1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("childEnv = %v; want %v", got, want)
+	}
+}
+
+func TestScrubEnv(t *testing.T) {
+	t.Setenv(envAllowEnv, "PSI_KEEP_*")
+	t.Setenv("PSI_KEEP_ME", "1")
+	t.Setenv("PSI_DROP_ME", "1")
+	cfg := newConfig()
+	cfg.scrubEnv()
+	if _, ok := os.LookupEnv("PSI_DROP_ME"); ok {
+		t.Fatal("PSI_DROP_ME survived scrubbing")
+	}
+	if _, ok := os.LookupEnv("PSI_KEEP_ME"); !ok {
+		t.Fatal("allowed PSI_KEEP_ME was scrubbed")
+	}
+}
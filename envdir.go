@@ -0,0 +1,74 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const envDirEnv = "PSI_ENV_DIR"
+
+// WithEnvDir adds one variable per regular file in each directory to the
+// child's environment: the file name is the key and the contents, minus one
+// trailing newline, the value. This matches how Kubernetes projects secrets
+// and ConfigMaps into volumes. Hidden entries (such as Kubernetes' ..data)
+// and names that are not valid variable names are skipped; variables already
+// present in the environment win. The directories are read whenever a child
+// starts. Overridden by PSI_ENV_DIR (comma-separated paths).
+func WithEnvDir(dirs ...string) Option {
+	return func(c *config) {
+		c.envDirs = append([]string(nil), dirs...)
+	}
+}
+
+// loadEnvDirEnv applies the PSI_ENV_DIR override.
+func (c *config) loadEnvDirEnv() {
+	if dirs := splitPaths(os.Getenv(envDirEnv)); len(dirs) > 0 {
+		c.envDirs = dirs
+	}
+}
+
+// readEnvDirs maps the files of the configured directories to variables.
+func (c *config) readEnvDirs() ([]string, error) {
+	var env []string
+	for _, dir := range c.envDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if !isEnvName(name) {
+				c.debugf(1, "env dir %s: skipping %q, not a valid variable name", dir, name)
+				continue
+			}
+			path := filepath.Join(dir, name)
+			// Stat follows the symlinks Kubernetes uses for atomic updates.
+			if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, name+"="+strings.TrimSuffix(string(data), "\n"))
+		}
+	}
+	return env, nil
+}
+
+// isEnvName reports whether s is a portable environment variable name.
+func isEnvName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadEnvDirs(t *testing.T) {
+	// Mimic a Kubernetes projected volume: files are symlinks into ..data.
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2026_01_01")
+	if err := os.Mkdir(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"DB_PASSWORD": "s3cret\n",
+		"TOKEN":       "abc",
+		"tls.crt":     "cert",
+		"MULTI":       "a\nb\n\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(data, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "SUBDIR"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(envDirEnv, dir)
+	cfg := newConfig()
+	env, err := cfg.childExtraEnv([]string{"TOKEN=env"})
+	if err != nil {
+		t.Fatalf("childExtraEnv: %v", err)
+	}
+	want := []string{"TOKEN=env", "DB_PASSWORD=s3cret", "MULTI=a\nb\n"}
+	if !slices.Equal(env, want) {
+		t.Fatalf("env = %q, want %q", env, want)
+	}
+
+	cfg.envDirs = []string{filepath.Join(dir, "missing")}
+	if _, err := cfg.childExtraEnv(nil); err == nil {
+		t.Fatal("expected a missing env dir to fail")
+	}
+}
+
+func TestIsEnvName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"A": true, "_x1": true, "DB_HOST": true,
+		"": false, "1A": false, "tls.crt": false, "a-b": false,
+	} {
+		if got := isEnvName(name); got != want {
+			t.Errorf("isEnvName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package psi
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// EventType identifies a supervisor lifecycle event.
+type EventType string
+
+const (
+	// EventChildStarted: a child generation was started (PID).
+	EventChildStarted EventType = "child_started"
+	// EventChildReady: the child PID became ready (see WithReadyNotify).
+	EventChildReady EventType = "child_ready"
+	// EventSignalReceived: the init received Signal.
+	EventSignalReceived EventType = "signal_received"
+	// EventSignalForwarded: Signal was sent to the process group PID.
+	EventSignalForwarded EventType = "signal_forwarded"
+	// EventStopTimerArmed: a shutdown step was taken; the next one follows
+	// after Timeout.
+	EventStopTimerArmed EventType = "stop_timer_armed"
+	// EventForcedKill: the child PID is being killed with SIGKILL.
+	EventForcedKill EventType = "forced_kill"
+	// EventChildExited: the child PID exited with ExitCode, killed by Signal
+	// if not 0; Exit summarizes its run.
+	EventChildExited EventType = "child_exited"
+	// EventReaped: the init reaped PID, a child or an adopted orphan (then
+	// Orphan is set), which exited with ExitCode.
+	EventReaped EventType = "reaped"
+)
+
+// eventBuffer is the capacity of a channel returned by Events.
+const eventBuffer = 256
+
+// Event describes something the supervisor did or observed. Fields not
+// relevant to Type are zero.
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	PID      int
+	Signal   syscall.Signal
+	ExitCode int
+	Timeout  time.Duration
+	Exit     *ExitSummary
+	Orphan   bool
+}
+
+// bus delivers events to the subscribers of the current process.
+var bus struct {
+	mu       sync.Mutex
+	chans    []chan Event
+	handlers []func(Event)
+	// calls serializes the handler calls, made without holding mu.
+	calls sync.Mutex
+}
+
+// Events subscribes to the supervisor's lifecycle events. They are only
+// produced in the init and are not forwarded to the child: subscribe from
+// code running in the init, e.g. started with WithSupervisor. In the child,
+// including submain, the channel never receives an event. The channel is
+// buffered; events are dropped while it is full.
+func Events() <-chan Event {
+	ch := make(chan Event, eventBuffer)
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.chans = append(bus.chans, ch)
+	return ch
+}
+
+// WithEventHandler calls fn in the init for every lifecycle event (see
+// Events); it is never called in the child. Calls are serialized, and fn
+// must not block: the supervisor and the reaper wait for it.
+func WithEventHandler(fn func(Event)) Option {
+	return func(c *config) {
+		c.eventHandlers = append(c.eventHandlers, fn)
+	}
+}
+
+// subscribeEvents registers the configured event handlers.
+func (c *config) subscribeEvents() {
+	addEventHandler(c.eventHandlers...)
+}
+
+// addEventHandler registers handlers for the events to come.
+func addEventHandler(fns ...func(Event)) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.handlers = append(bus.handlers, fns...)
+}
+
+// emit publishes e to the subscribers, stamping its time.
+func emit(e Event) {
+	// The lists are only appended to, so the slices taken here stay valid.
+	bus.mu.Lock()
+	chans, handlers := bus.chans, bus.handlers
+	bus.mu.Unlock()
+	if len(chans) == 0 && len(handlers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	bus.calls.Lock()
+	for _, fn := range handlers {
+		fn(e)
+	}
+	bus.calls.Unlock()
+	for _, ch := range chans {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	t.Cleanup(func() {
+		bus.mu.Lock()
+		bus.chans, bus.handlers = nil, nil
+		bus.mu.Unlock()
+	})
+	var handled []EventType
+	newConfig(WithEventHandler(func(e Event) { handled = append(handled, e.Type) })).subscribeEvents()
+	ch := Events()
+	emit(Event{Type: EventSignalReceived, Signal: syscall.SIGTERM})
+	e := <-ch
+	if e.Type != EventSignalReceived || e.Signal != syscall.SIGTERM || e.Time.IsZero() {
+		t.Fatalf("event = %+v", e)
+	}
+	if len(handled) != 1 || handled[0] != EventSignalReceived {
+		t.Fatalf("handler saw %v", handled)
+	}
+	// A full subscriber does not block the supervisor.
+	for i := 0; i < eventBuffer+10; i++ {
+		emit(Event{Type: EventReaped, PID: i})
+	}
+	if len(ch) != eventBuffer || len(handled) != eventBuffer+11 {
+		t.Fatalf("buffered %d events, handled %d", len(ch), len(handled))
+	}
+}
+
+func TestEventHandlerSubscribes(t *testing.T) {
+	t.Cleanup(func() {
+		bus.mu.Lock()
+		bus.chans, bus.handlers = nil, nil
+		bus.mu.Unlock()
+	})
+	// Handlers run without the bus locked, so they may subscribe.
+	var sub <-chan Event
+	addEventHandler(func(e Event) {
+		if sub == nil {
+			sub = Events()
+		}
+	})
+	emit(Event{Type: EventChildStarted, PID: 1})
+	emit(Event{Type: EventChildReady, PID: 1})
+	if e := <-sub; e.Type != EventChildReady {
+		t.Fatalf("event = %+v", e)
+	}
+}
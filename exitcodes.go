@@ -0,0 +1,80 @@
+package psi
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Exit codes of the init for failures of its own, as opposed to the child's
+// exit code, which it otherwise passes on (128+N if the child was killed by
+// signal N). They follow the conventions of timeout(1) and of container
+// runtimes, so orchestration can tell an application failure from an
+// infrastructure one. A child exiting with one of these codes itself is
+// passed on as well: the termination log (see WithTerminationLog) and the
+// child_exited event tell the two apart.
+const (
+	// ExitCrashLoop is the init's exit code when restarts are abandoned
+	// because the child keeps exiting before PSI_MIN_UPTIME.
+	ExitCrashLoop = 120
+	// ExitStartTimeout is the exit code recorded for a child that failed to
+	// start within PSI_START_TIMEOUT. The restart policy sees it like any
+	// other failure; when the child is not restarted the init exits with it.
+	ExitStartTimeout = 121
+	// ExitLivenessFailed is the exit code recorded for a child stopped
+	// because its liveness probe kept failing or it missed its watchdog
+	// deadline. The restart policy sees it like any other failure; when the
+	// child is not restarted the init exits with it.
+	ExitLivenessFailed = 122
+	// ExitPreStartFailed is the init's exit code when the pre-start hook or a
+	// required init task fails or times out; the child is never started.
+	ExitPreStartFailed = 123
+	// ExitStopTimeout is the init's exit code when a stop step's wait
+	// expired and the SIGKILL that followed killed the child, rather than
+	// 137. A child killed at once because the stop signal, or the first
+	// step of the stop chain, is SIGKILL still exits 137.
+	ExitStopTimeout = 124
+	// ExitInternalError is the init's exit code when psi itself failed: an
+	// invalid configuration file, a setup step (credentials, chroot,
+	// capabilities, ...) that could not be carried out, a sidecar or a
+	// child that could not be started for other reasons than those below.
+	ExitInternalError = 125
+	// ExitCannotExecute is the init's exit code when the child's program
+	// was found but could not be executed: permission denied, not an
+	// executable format, a directory.
+	ExitCannotExecute = 126
+	// ExitNotFound is the init's exit code when the child's program does
+	// not exist.
+	ExitNotFound = 127
+)
+
+// startFailureCode returns the exit code for a child that could not be
+// started because of err.
+func startFailureCode(err error) int {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return ExitNotFound
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.ENOEXEC), errors.Is(err, syscall.EISDIR):
+		return ExitCannotExecute
+	}
+	return ExitInternalError
+}
+
+// stopTimeoutExitCode replaces the exit code of a child killed by the
+// SIGKILL sent once a stop step's wait expired with ExitStopTimeout.
+func (s *supervisor) stopTimeoutExitCode(code int) int {
+	if !s.stopTimedOut || code != 128+int(syscall.SIGKILL) {
+		return code
+	}
+	s.cfg.debugf(1, "child killed after the stop timeout; exiting %d", ExitStopTimeout)
+	return ExitStopTimeout
+}
+
+// exitStartFailure logs why the program could not be started and exits with
+// the code startFailureCode picks.
+func exitStartFailure(err error) {
+	logMessage(LogError, err.Error())
+	os.Exit(startFailureCode(err))
+}
@@ -0,0 +1,101 @@
+//go:build !windows
+
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartFailureCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{&exec.Error{Name: "app", Err: exec.ErrNotFound}, ExitNotFound},
+		{&os.PathError{Op: "fork/exec", Path: "/app", Err: syscall.ENOENT}, ExitNotFound},
+		{&os.PathError{Op: "fork/exec", Path: "/app", Err: syscall.EACCES}, ExitCannotExecute},
+		{fmt.Errorf("exec /app: %w", syscall.ENOEXEC), ExitCannotExecute},
+		{&exec.Error{Name: "/etc", Err: syscall.EISDIR}, ExitCannotExecute},
+		{errors.New("pipe: too many open files"), ExitInternalError},
+	} {
+		if got := startFailureCode(tc.err); got != tc.want {
+			t.Errorf("startFailureCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestStopTimeoutExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		timedOut bool
+		code     int
+		want     int
+	}{
+		{"not stopping", false, 137, 137},
+		{"stop signal", false, 143, 143},
+		{"killed by someone else", false, 137, 137},
+		{"SIGKILL as the stop signal", false, 137, 137},
+		{"forced kill", true, 137, ExitStopTimeout},
+		{"exited in time", true, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSupervisor(newConfig())
+			s.stopTimedOut = tc.timedOut
+			if got := s.stopTimeoutExitCode(tc.code); got != tc.want {
+				t.Fatalf("stopTimeoutExitCode(%d) = %d, want %d", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupervisorStartFailureExitCode(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		program string
+		exit    int
+		reason  string
+	}{
+		{filepath.Join(dir, "missing"), ExitNotFound, "reason: the child's program was not found\n"},
+		{script, ExitCannotExecute, "reason: the child's program could not be executed\n"},
+		{dir, ExitCannotExecute, "reason: the child's program could not be executed\n"},
+	} {
+		termLog := filepath.Join(t.TempDir(), "termination-log")
+		cmd := helperCommand("init-program", "GO_HELPER_PROGRAM="+tc.program, terminationLogEnv+"="+termLog)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		if exit := exitStatus(cmd.Run()); exit != tc.exit {
+			t.Errorf("%s: expected exit code %d, got %d (stderr=%q)", tc.program, tc.exit, exit, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), "failed to start child") {
+			t.Errorf("%s: stderr = %q", tc.program, stderr.String())
+		}
+		if b, _ := os.ReadFile(termLog); !strings.Contains(string(b), tc.reason) {
+			t.Errorf("%s: termination log = %q, want %q", tc.program, b, tc.reason)
+		}
+	}
+}
+
+func TestSupervisorKillStopSignalExitCode(t *testing.T) {
+	// The stop signal itself kills the child: no wait expired.
+	countFile := filepath.Join(t.TempDir(), "count")
+	cmd := helperCommand("init-stubborn", helperCountEnv+"="+countFile, stopSignalEnv+"=KILL")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, countFile, "running\n")
+	cmd.Process.Signal(syscall.SIGTERM)
+	if exit := exitStatus(cmd.Wait()); exit != 128+int(syscall.SIGKILL) {
+		t.Fatalf("expected exit code %d, got %d", 128+int(syscall.SIGKILL), exit)
+	}
+}
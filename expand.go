@@ -0,0 +1,84 @@
+package psi
+
+import "strings"
+
+const expandArgsEnv = "PSI_EXPAND_ARGS"
+
+// WithArgExpansion expands ${VAR}, ${VAR:-default} (default when unset or
+// empty) and ${VAR-default} (default when unset) in the program path and
+// arguments of the child in command mode and of sidecars, using the
+// environment they are started with. "$${" yields a literal "${". This gives
+// exec-form Docker ENTRYPOINTs variable references without a shell.
+// Overridden by PSI_EXPAND_ARGS.
+func WithArgExpansion() Option {
+	return func(c *config) {
+		c.expandArgs = true
+	}
+}
+
+// expandArgv expands args against env when expansion is enabled.
+func (c *config) expandArgv(args, env []string) []string {
+	if !c.expandArgs {
+		return args
+	}
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		key, val, _ := strings.Cut(kv, "=")
+		vars[key] = val
+	}
+	lookup := func(key string) (string, bool) {
+		val, ok := vars[key]
+		return val, ok
+	}
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = expandVars(arg, lookup)
+	}
+	return out
+}
+
+// expandVars replaces ${...} references in s. Malformed references are left
+// as they are.
+func expandVars(s string, lookup func(string) (string, bool)) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		if i > 0 && s[i-1] == '$' {
+			// "$${" escapes a literal "${".
+			b.WriteString(s[:i])
+			b.WriteString("{")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		b.WriteString(expandRef(s[i+2:i+end], lookup))
+		s = s[i+end+1:]
+	}
+}
+
+// expandRef resolves the inside of a ${...} reference.
+func expandRef(ref string, lookup func(string) (string, bool)) string {
+	if name, def, ok := strings.Cut(ref, ":-"); ok {
+		if val, _ := lookup(name); val != "" {
+			return val
+		}
+		return def
+	}
+	if name, def, ok := strings.Cut(ref, "-"); ok {
+		if val, set := lookup(name); set {
+			return val
+		}
+		return def
+	}
+	val, _ := lookup(ref)
+	return val
+}
@@ -0,0 +1,29 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandArgv(t *testing.T) {
+	env := []string{"HOST=db", "PORT=5432", "EMPTY="}
+	args := []string{
+		"--dsn=${HOST}:${PORT}",
+		"${EMPTY:-fallback}",
+		"${EMPTY-kept}",
+		"${UNSET-dflt}",
+		"${UNSET}",
+		"$${HOST}",
+		"${unterminated",
+		"$HOST",
+	}
+	want := []string{"--dsn=db:5432", "fallback", "", "dflt", "", "${HOST}", "${unterminated", "$HOST"}
+	cfg := &config{expandArgs: true}
+	if got := cfg.expandArgv(args, env); !slices.Equal(got, want) {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+	cfg.expandArgs = false
+	if got := cfg.expandArgv(args, env); !slices.Equal(got, args) {
+		t.Fatalf("expansion must be opt-in, got %q", got)
+	}
+}
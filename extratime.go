@@ -0,0 +1,180 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	stopExtendMaxEnv = "PSI_STOP_EXTEND_MAX"
+	// extendTimeoutKey is the sd_notify message asking for more time.
+	extendTimeoutKey = "EXTEND_TIMEOUT_USEC"
+)
+
+// WithStopExtendMax lets the child push back the shutdown's forced kill (or
+// next stop step, see WithStopChain) with RequestExtraTime, by up to d in
+// total over the whole shutdown. Requests beyond d are cut short. Without it
+// such requests are ignored. Overridden by PSI_STOP_EXTEND_MAX.
+func WithStopExtendMax(d time.Duration) Option {
+	return func(c *config) {
+		c.stopExtendMax = d
+	}
+}
+
+// RequestExtraTime asks the init for d more time to stop, e.g. while a
+// long checkpoint is being written after the stop signal: the current stop
+// step then ends no sooner than d from now, within the maximum the init
+// allows (see WithStopExtendMax). It sends the sd_notify
+// EXTEND_TIMEOUT_USEC message, so the child should repeat it before d
+// runs out if it needs longer. In a submain, ShutdownDeadline and the
+// context of the OnShutdown hooks move along with the extension. It fails
+// when the process has no NOTIFY_SOCKET to ask through, as when the init
+// runs without WithStopExtendMax or psi does not supervise.
+func RequestExtraTime(d time.Duration) error {
+	addr := os.Getenv(notifySocketEnv)
+	if addr == "" {
+		return errors.New("psi: cannot request extra time: " + notifySocketEnv + " is not set")
+	}
+	if d <= 0 {
+		return nil
+	}
+	if err := sdNotify(addr, fmt.Sprintf("%s=%d", extendTimeoutKey, d.Microseconds())); err != nil {
+		return err
+	}
+	stopClock.extend(d)
+	return nil
+}
+
+// stopClock is, in the child running submain, when the init will kill it.
+var stopClock shutdownClock
+
+// shutdownClock tracks the child's shutdown deadline as RequestExtraTime
+// moves it, mirroring what the init grants.
+type shutdownClock struct {
+	mu sync.Mutex
+	// deadline is zero until the child is asked to stop.
+	deadline time.Time
+	// max and extended are the init's maximum extension and how much of it
+	// was used.
+	max      time.Duration
+	extended time.Duration
+	// timer expires the context of the shutdown hooks.
+	timer *time.Timer
+}
+
+// start sets the deadline when the child is first asked to stop.
+func (c *shutdownClock) start(deadline time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadline.IsZero() {
+		c.deadline = deadline
+	}
+}
+
+// get returns the deadline, false before the child was asked to stop.
+func (c *shutdownClock) get() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+// extend moves the deadline to d from now if that is later, within what is
+// left of the maximum extension, as the init does.
+func (c *shutdownClock) extend(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadline.IsZero() {
+		return
+	}
+	extra := min(time.Now().Add(d).Sub(c.deadline), c.max-c.extended)
+	if extra <= 0 {
+		return
+	}
+	c.extended += extra
+	c.deadline = c.deadline.Add(extra)
+	if c.timer != nil {
+		c.timer.Reset(time.Until(c.deadline))
+	}
+}
+
+// context returns a context expiring at the deadline, moving along with it,
+// or at deadline if the child was not asked to stop.
+func (c *shutdownClock) context(deadline time.Time) (context.Context, context.CancelFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deadline.IsZero() {
+		return context.WithDeadline(context.Background(), deadline)
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	timer := time.AfterFunc(time.Until(c.deadline), func() { cancel(context.DeadlineExceeded) })
+	c.timer = timer
+	return &clockContext{Context: ctx, clock: c}, func() {
+		c.mu.Lock()
+		c.timer = nil
+		c.mu.Unlock()
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// clockContext is a context expiring at a shutdownClock's moving deadline.
+type clockContext struct {
+	context.Context
+	clock *shutdownClock
+}
+
+func (ctx *clockContext) Deadline() (time.Time, bool) {
+	return ctx.clock.get()
+}
+
+func (ctx *clockContext) Err() error {
+	if ctx.Context.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx.Context)
+}
+
+// parseExtendTimeout parses an EXTEND_TIMEOUT_USEC value.
+func parseExtendTimeout(val string) (time.Duration, bool) {
+	usec, err := strconv.ParseUint(val, 10, 63)
+	if err != nil || usec > uint64(time.Duration(1<<63-1)/time.Microsecond) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// extendStop moves the end of the current stop step to d from now if that
+// is later, within what is left of the maximum extension.
+func (s *supervisor) extendStop(d time.Duration) {
+	if !s.esc.started() || s.esc.timer == nil || s.cfg.stopExtendMax <= 0 {
+		s.cfg.debugf(1, "ignoring the child's request for %s more to stop", d)
+		return
+	}
+	now := time.Now()
+	extra := now.Add(d).Sub(s.esc.deadline)
+	if extra <= 0 {
+		return
+	}
+	if left := s.cfg.stopExtendMax - s.stopExtended; extra > left {
+		extra = left
+	}
+	if extra <= 0 {
+		log.Printf("psi: child (pid %d) asked for %s more to stop; the %s maximum is used up", s.childPID, d, s.cfg.stopExtendMax)
+		return
+	}
+	s.stopExtended += extra
+	s.esc.extend(extra)
+	wait := s.esc.deadline.Sub(now)
+	log.Printf("psi: child (pid %d) asked for more time to stop; waiting %s more", s.childPID, wait.Round(time.Millisecond))
+	tracef("stop timer armed for %s", wait)
+	emit(Event{Type: EventStopTimerArmed, PID: s.childPID, Timeout: wait})
+	if s.systemd != nil && s.systemd.addr != "" {
+		s.systemd.notify(fmt.Sprintf("%s=%d", extendTimeoutKey, wait.Microseconds()))
+	}
+}
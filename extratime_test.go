@@ -0,0 +1,89 @@
+//go:build !windows
+
+package psi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseExtendTimeout(t *testing.T) {
+	if d, ok := parseExtendTimeout("1500000"); !ok || d != 1500*time.Millisecond {
+		t.Fatalf("parseExtendTimeout = %v, %v", d, ok)
+	}
+	for _, val := range []string{"", "-1", "1s", "99999999999999999999"} {
+		if _, ok := parseExtendTimeout(val); ok {
+			t.Errorf("parseExtendTimeout(%q) accepted", val)
+		}
+	}
+}
+
+func TestEscalationExtend(t *testing.T) {
+	e := newEscalation([]StopStep{{Wait: 50 * time.Millisecond}, {Signal: syscall.SIGKILL}})
+	e.advance()
+	start := time.Now()
+	e.extend(150 * time.Millisecond)
+	<-e.C()
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("extended timer fired after %s", elapsed)
+	}
+}
+
+func TestSupervisorRequestExtraTime(t *testing.T) {
+	for _, tc := range []struct {
+		max  string
+		exit int
+		want string
+	}{
+		{"5s", 0, "started\nextended\ncheckpointed\n"},
+		{"100ms", ExitStopTimeout, "started\n"},
+		// Without a maximum there is no NOTIFY_SOCKET to ask through.
+		{"", 2, "started\n"},
+	} {
+		countFile := filepath.Join(t.TempDir(), "count")
+		cmd := helperCommand("init-extra-time", helperCountEnv+"="+countFile, stopTimeoutEnv+"=300ms", stopExtendMaxEnv+"="+tc.max)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		waitForContent(t, countFile, "started\n")
+		cmd.Process.Signal(syscall.SIGTERM)
+		if exit := exitStatus(cmd.Wait()); exit != tc.exit {
+			t.Errorf("max %q: expected exit code %d, got %d", tc.max, tc.exit, exit)
+		}
+		if b, _ := os.ReadFile(countFile); string(b) != tc.want {
+			t.Errorf("max %q: got %q, want %q", tc.max, b, tc.want)
+		}
+	}
+}
+
+func TestShutdownClock(t *testing.T) {
+	c := shutdownClock{max: time.Second}
+	c.extend(time.Minute)
+	if _, ok := c.get(); ok {
+		t.Fatal("deadline set before the stop")
+	}
+	start := time.Now()
+	c.start(start.Add(100 * time.Millisecond))
+	ctx, cancel := c.context(time.Time{})
+	defer cancel()
+	c.extend(300 * time.Millisecond)
+	if deadline, _ := ctx.Deadline(); deadline.Sub(start) < 300*time.Millisecond {
+		t.Fatalf("deadline %s after the start, want 300ms", deadline.Sub(start))
+	}
+	<-ctx.Done()
+	if elapsed := time.Since(start); elapsed < 280*time.Millisecond {
+		t.Fatalf("context expired after %s", elapsed)
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("Err() = %v", ctx.Err())
+	}
+	// The maximum caps the total extension.
+	c.extend(time.Minute)
+	if deadline, _ := c.get(); !deadline.Equal(start.Add(1100 * time.Millisecond)) {
+		t.Fatalf("deadline %s after the start, want 1.1s", deadline.Sub(start))
+	}
+}
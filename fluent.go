@@ -0,0 +1,355 @@
+package psi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fluentAddrEnv   = "PSI_FLUENT_ADDR"
+	fluentTagEnv    = "PSI_FLUENT_TAG"
+	fluentBufferEnv = "PSI_FLUENT_BUFFER"
+)
+
+const (
+	defaultFluentTag    = "psi"
+	defaultFluentBuffer = 8 << 20
+	fluentDialTimeout   = 5 * time.Second
+	fluentWriteTimeout  = 10 * time.Second
+	fluentMinBackoff    = 250 * time.Millisecond
+	fluentMaxBackoff    = 30 * time.Second
+	// fluentFlushTimeout bounds the wait for the buffered records to be sent
+	// when the init exits.
+	fluentFlushTimeout = 5 * time.Second
+)
+
+// WithFluentForward makes the init ship the child's and its sidecars'
+// output to a Fluentd or Fluent Bit forward input at addr ("host:port", or
+// "unix:/path" for a Unix socket) over the Forward protocol, a record per
+// line tagged tag ("psi" if empty) with the fields log, stream and app (the
+// process's name, see WithChildName). Records are buffered (see
+// WithFluentBuffer) and sent in batches; while the aggregator is unreachable
+// they are kept and the connection retried with backoff. The output is still
+// forwarded to the init's own stdout and stderr. Overridden by
+// PSI_FLUENT_ADDR and PSI_FLUENT_TAG.
+func WithFluentForward(addr, tag string) Option {
+	return func(c *config) {
+		c.fluentAddr, c.fluentTag = addr, tag
+	}
+}
+
+// WithFluentBuffer sets how many bytes of records WithFluentForward keeps
+// while the aggregator is unreachable (8 MiB if 0); the oldest are dropped
+// beyond that. Overridden by PSI_FLUENT_BUFFER (e.g. 32M).
+func WithFluentBuffer(size int) Option {
+	return func(c *config) {
+		c.fluentBuffer = size
+	}
+}
+
+// loadFluentEnv applies the PSI_FLUENT_* overrides.
+func (c *config) loadFluentEnv() {
+	if val := strings.TrimSpace(os.Getenv(fluentAddrEnv)); val != "" {
+		c.fluentAddr = val
+	}
+	if val := strings.TrimSpace(os.Getenv(fluentTagEnv)); val != "" {
+		c.fluentTag = val
+	}
+	if val := strings.TrimSpace(os.Getenv(fluentBufferEnv)); val != "" {
+		n, err := parseSize(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", fluentBufferEnv, val, err)
+		} else {
+			c.fluentBuffer = int(n)
+		}
+	}
+}
+
+// fluentForwarder buffers records and sends them to the aggregator from a
+// goroutine started with the first record.
+type fluentForwarder struct {
+	network, addr string
+	tag           string
+	maxBuffer     int
+
+	start sync.Once
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu sync.Mutex
+	// pending are the encoded entries not yet sent, size their length.
+	pending [][]byte
+	size    int
+	dropped bool
+
+	// conn and failing belong to the sending goroutine.
+	conn    net.Conn
+	failing bool
+}
+
+// newFluentForwarder returns the forwarder if Fluent forwarding is
+// configured, nil otherwise.
+func (c *config) newFluentForwarder() *fluentForwarder {
+	if c.fluentAddr == "" {
+		return nil
+	}
+	f := &fluentForwarder{
+		network:   "tcp",
+		addr:      c.fluentAddr,
+		tag:       c.fluentTag,
+		maxBuffer: c.fluentBuffer,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if path, ok := strings.CutPrefix(f.addr, "unix:"); ok {
+		f.network, f.addr = "unix", path
+	}
+	if f.tag == "" {
+		f.tag = defaultFluentTag
+	}
+	if f.maxBuffer <= 0 {
+		f.maxBuffer = defaultFluentBuffer
+	}
+	return f
+}
+
+// attachFluent adds line sinks shipping the output of the process called
+// name.
+func (s *supervisor) attachFluent(o *childOutput, name string) {
+	if s.fluent == nil {
+		return
+	}
+	o.stdout.lineSinks = append(o.stdout.lineSinks, &fluentWriter{f: s.fluent, name: name, stream: "stdout"})
+	o.stderr.lineSinks = append(o.stderr.lineSinks, &fluentWriter{f: s.fluent, name: name, stream: "stderr"})
+}
+
+// stopFluent sends the buffered records, waiting at most
+// fluentFlushTimeout.
+func (s *supervisor) stopFluent() {
+	if s.fluent != nil {
+		s.fluent.close(fluentFlushTimeout)
+	}
+}
+
+// fluentWriter is a line sink turning each line of a stream into a record.
+type fluentWriter struct {
+	f      *fluentForwarder
+	name   string
+	stream string
+}
+
+func (w *fluentWriter) Write(p []byte) (int, error) {
+	w.f.post(fluentEntry(time.Now(), map[string]string{
+		"log":    string(bytes.TrimSuffix(p, []byte("\n"))),
+		"stream": w.stream,
+		"app":    w.name,
+	}))
+	return len(p), nil
+}
+
+// post queues an encoded entry, dropping the oldest ones to stay within
+// the buffer.
+func (f *fluentForwarder) post(entry []byte) {
+	f.start.Do(func() { go f.run() })
+	f.mu.Lock()
+	f.pending = append(f.pending, entry)
+	f.size += len(entry)
+	f.trim()
+	f.mu.Unlock()
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// trim drops the oldest entries beyond the buffer. f.mu must be held.
+func (f *fluentForwarder) trim() {
+	n := 0
+	for f.size > f.maxBuffer && n < len(f.pending) {
+		f.size -= len(f.pending[n])
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	f.pending = append(f.pending[:0], f.pending[n:]...)
+	if !f.dropped {
+		f.dropped = true
+		log.Printf("psi: fluent forward buffer full; dropping the oldest records")
+	}
+}
+
+// run sends the queued entries as they come, retrying with backoff, until
+// stopped, when it makes a last attempt.
+func (f *fluentForwarder) run() {
+	defer close(f.done)
+	defer func() {
+		if f.conn != nil {
+			f.conn.Close()
+		}
+	}()
+	for {
+		select {
+		case <-f.wake:
+		case <-f.stop:
+			f.send()
+			return
+		}
+		for backoff := fluentMinBackoff; !f.send(); backoff = min(2*backoff, fluentMaxBackoff) {
+			select {
+			case <-time.After(backoff):
+			case <-f.stop:
+				f.send()
+				return
+			}
+		}
+	}
+}
+
+// send sends the queued entries in one Forward mode message, putting them
+// back on failure. It reports whether the queue was flushed.
+func (f *fluentForwarder) send() bool {
+	f.mu.Lock()
+	batch := f.pending
+	f.pending, f.size = nil, 0
+	f.mu.Unlock()
+	if len(batch) == 0 {
+		return true
+	}
+	err := f.write(fluentMessage(f.tag, batch))
+	if err == nil {
+		if f.failing {
+			f.failing = false
+			log.Printf("psi: fluent forward to %s resumed", f.addr)
+		}
+		f.mu.Lock()
+		f.dropped = false
+		f.mu.Unlock()
+		return true
+	}
+	if !f.failing {
+		f.failing = true
+		log.Printf("psi: fluent forward to %s: %v; retrying", f.addr, err)
+	}
+	f.mu.Lock()
+	for _, e := range batch {
+		f.size += len(e)
+	}
+	f.pending = append(batch, f.pending...)
+	f.trim()
+	f.mu.Unlock()
+	return false
+}
+
+// write writes msg, connecting first if need be. A failed connection is
+// closed so the next write reconnects.
+func (f *fluentForwarder) write(msg []byte) error {
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.addr, fluentDialTimeout)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(fluentWriteTimeout))
+	if _, err := f.conn.Write(msg); err != nil {
+		f.conn.Close()
+		f.conn = nil
+		return err
+	}
+	return nil
+}
+
+// close stops the forwarder after a last attempt at sending the queued
+// entries, waiting at most timeout.
+func (f *fluentForwarder) close(timeout time.Duration) {
+	f.start.Do(func() { close(f.done) })
+	select {
+	case <-f.stop:
+		return
+	default:
+	}
+	close(f.stop)
+	select {
+	case <-f.done:
+	case <-time.After(timeout):
+	}
+}
+
+// fluentMessage encodes a Forward mode message: [tag, [entry...]].
+func fluentMessage(tag string, entries [][]byte) []byte {
+	n := len(tag) + 16
+	for _, e := range entries {
+		n += len(e)
+	}
+	buf := appendMsgpackArray(make([]byte, 0, n), 2)
+	buf = appendMsgpackString(buf, tag)
+	buf = appendMsgpackArray(buf, len(entries))
+	for _, e := range entries {
+		buf = append(buf, e...)
+	}
+	return buf
+}
+
+// fluentEntry encodes an entry, [time, record], with the time as an
+// EventTime.
+func fluentEntry(t time.Time, record map[string]string) []byte {
+	buf := appendMsgpackArray(nil, 2)
+	buf = append(buf, 0xd7, 0x00)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(t.Unix()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(t.Nanosecond()))
+	buf = appendMsgpackMap(buf, len(record))
+	for k, v := range record {
+		buf = appendMsgpackString(buf, k)
+		buf = appendMsgpackString(buf, v)
+	}
+	return buf
+}
+
+// appendMsgpackString appends s as a MessagePack str.
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= 0xff:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= 0xffff:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+// appendMsgpackArray appends the header of a MessagePack array of n
+// elements.
+func appendMsgpackArray(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n))
+	}
+}
+
+// appendMsgpackMap appends the header of a MessagePack map of n pairs.
+func appendMsgpackMap(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(n))
+	}
+}
@@ -0,0 +1,212 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		buf  []byte
+		want string
+	}{
+		{appendMsgpackString(nil, "abc"), "\xa3abc"},
+		{appendMsgpackString(nil, strings.Repeat("x", 40))[:2], "\xd9\x28"},
+		{appendMsgpackString(nil, strings.Repeat("x", 300))[:3], "\xda\x01\x2c"},
+		{appendMsgpackArray(nil, 2), "\x92"},
+		{appendMsgpackArray(nil, 20), "\xdc\x00\x14"},
+		{appendMsgpackMap(nil, 3), "\x83"},
+	} {
+		if string(tc.buf) != tc.want {
+			t.Errorf("got %q, want %q", tc.buf, tc.want)
+		}
+	}
+}
+
+func TestFluentForwarderConfig(t *testing.T) {
+	if f := newConfig().newFluentForwarder(); f != nil {
+		t.Fatal("forwarder without an address")
+	}
+	t.Setenv(fluentAddrEnv, "unix:/run/fluent.sock")
+	t.Setenv(fluentBufferEnv, "1M")
+	f := newConfig(WithFluentForward("localhost:24224", "")).newFluentForwarder()
+	if f.network != "unix" || f.addr != "/run/fluent.sock" || f.tag != defaultFluentTag || f.maxBuffer != 1<<20 {
+		t.Fatalf("forwarder = %+v", f)
+	}
+}
+
+func TestFluentForwarderBuffersUntilReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := &supervisor{cfg: newConfig(WithFluentForward(addr, "app.logs"))}
+	s.fluent = s.cfg.newFluentForwarder()
+	var out childOutput
+	s.attachFluent(&out, "web")
+	out.stdout.lineSinks[0].Write([]byte("first\n"))
+	time.Sleep(100 * time.Millisecond)
+
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		t.Skipf("port taken meanwhile: %v", err)
+	}
+	defer ln.Close()
+	out.stderr.lineSinks[0].Write([]byte("second\n"))
+
+	got := make(chan []fluentTestRecord, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		var recs []fluentTestRecord
+		for len(recs) < 2 {
+			msg, err := decodeMsgpack(r)
+			if err != nil {
+				break
+			}
+			m := msg.([]any)
+			if m[0] != "app.logs" {
+				t.Errorf("tag = %v", m[0])
+			}
+			for _, e := range m[1].([]any) {
+				entry := e.([]any)
+				rec := entry[1].(map[string]any)
+				recs = append(recs, fluentTestRecord{entry[0].(time.Time), rec["log"].(string), rec["stream"].(string), rec["app"].(string)})
+			}
+		}
+		got <- recs
+	}()
+	var recs []fluentTestRecord
+	select {
+	case recs = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no records received")
+	}
+	s.stopFluent()
+	if len(recs) != 2 {
+		t.Fatalf("records = %+v", recs)
+	}
+	for i, want := range []fluentTestRecord{{log: "first", stream: "stdout", app: "web"}, {log: "second", stream: "stderr", app: "web"}} {
+		if r := recs[i]; r.log != want.log || r.stream != want.stream || r.app != want.app || time.Since(r.time) > time.Minute {
+			t.Errorf("record %d = %+v, want %+v", i, r, want)
+		}
+	}
+}
+
+func TestFluentForwarderDropsOldest(t *testing.T) {
+	f := newConfig(WithFluentForward("127.0.0.1:1", ""), WithFluentBuffer(10)).newFluentForwarder()
+	f.mu.Lock()
+	f.pending = [][]byte{[]byte("12345"), []byte("67890"), []byte("abc")}
+	f.size = 13
+	f.trim()
+	f.mu.Unlock()
+	if len(f.pending) != 2 || string(f.pending[0]) != "67890" || f.size != 8 {
+		t.Fatalf("pending = %q, size %d", f.pending, f.size)
+	}
+	f.close(time.Second)
+}
+
+type fluentTestRecord struct {
+	time             time.Time
+	log, stream, app string
+}
+
+// decodeMsgpack decodes the subset of MessagePack the forwarder writes.
+func decodeMsgpack(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+	length := func(size int) (int, error) {
+		buf, err := readN(size)
+		if err != nil {
+			return 0, err
+		}
+		switch size {
+		case 1:
+			return int(buf[0]), nil
+		case 2:
+			return int(binary.BigEndian.Uint16(buf)), nil
+		}
+		return int(binary.BigEndian.Uint32(buf)), nil
+	}
+	str := func(n int, err error) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		buf, err := readN(n)
+		return string(buf), err
+	}
+	array := func(n int, err error) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		a := make([]any, n)
+		for i := range a {
+			if a[i], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	mapping := func(n int, err error) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]any{}
+		for range n {
+			k, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[k.(string)], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	switch {
+	case b&0xe0 == 0xa0:
+		return str(int(b&0x1f), nil)
+	case b&0xf0 == 0x90:
+		return array(int(b&0x0f), nil)
+	case b&0xf0 == 0x80:
+		return mapping(int(b&0x0f), nil)
+	case b == 0xd9:
+		return str(length(1))
+	case b == 0xda:
+		return str(length(2))
+	case b == 0xdb:
+		return str(length(4))
+	case b == 0xdc:
+		return array(length(2))
+	case b == 0xdd:
+		return array(length(4))
+	case b == 0xd7:
+		buf, err := readN(9)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(buf[1:])), int64(binary.BigEndian.Uint32(buf[5:]))), nil
+	}
+	return nil, io.ErrUnexpectedEOF
+}
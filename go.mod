@@ -3,6 +3,7 @@ module pkt.systems/psi
 go 1.25.1
 
 require (
+	golang.org/x/sys v0.37.0
 	pkt.systems/emrun v0.5.0
 	pkt.systems/logport v0.15.0
 )
@@ -10,6 +11,5 @@ require (
 require (
 	go.opentelemetry.io/otel v1.38.0 // indirect
 	go.opentelemetry.io/otel/trace v1.38.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
 	golang.org/x/term v0.36.0 // indirect
 )
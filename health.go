@@ -0,0 +1,182 @@
+package psi
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const healthAddrEnv = "PSI_HEALTH_ADDR"
+
+// WithHealthAddr makes the init serve HTTP on addr (e.g. ":9097") with
+// /healthz (200 while the child runs), /readyz (200 once the running child is
+// ready, see WithReadyNotify) and /status (JSON with the child's PID, uptime,
+// restart count, generation, sd_notify STATUS and last exit). During an
+// upgrade (see WithUpgradeSignal) they describe the previous generation until
+// its replacement is ready, and /status adds the replacement's PID. Serving
+// only happens while supervising.
+// Overridden by PSI_HEALTH_ADDR.
+func WithHealthAddr(addr string) Option {
+	return func(c *config) {
+		c.healthAddr = addr
+	}
+}
+
+// loadHealthEnv applies the PSI_HEALTH_ADDR override.
+func (c *config) loadHealthEnv() {
+	if val := strings.TrimSpace(os.Getenv(healthAddrEnv)); val != "" {
+		c.healthAddr = val
+	}
+}
+
+// childStatus is the supervisor's view of the child, shared with the health
+// endpoint.
+type childStatus struct {
+	mu       sync.Mutex
+	pid      int
+	running  bool
+	ready    bool
+	started  time.Time
+	restarts int
+	// generation counts child generations started, including upgrades.
+	generation int
+	// upgradingPID is the replacement child while an upgrade is pending.
+	upgradingPID int
+	exited       bool
+	lastExit     int
+	exitTime     time.Time
+	summary      ExitSummary
+	// text is the child's latest sd_notify STATUS.
+	text string
+}
+
+// statusReport is the /status document.
+type statusReport struct {
+	PID           int        `json:"pid"`
+	Running       bool       `json:"running"`
+	Ready         bool       `json:"ready"`
+	Started       *time.Time `json:"started,omitempty"`
+	UptimeSeconds float64    `json:"uptime_seconds"`
+	Restarts      int        `json:"restarts"`
+	Generation    int        `json:"generation"`
+	UpgradingPID  int        `json:"upgrading_pid,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	LastExitCode  *int       `json:"last_exit_code,omitempty"`
+	LastExitTime  *time.Time `json:"last_exit_time,omitempty"`
+}
+
+func (st *childStatus) childStarted(pid, restarts, generation int, at time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pid, st.running, st.ready, st.started, st.restarts = pid, true, false, at, restarts
+	st.generation, st.upgradingPID, st.text = generation, 0, ""
+}
+
+// upgrading records the replacement child of a pending upgrade, 0 when none.
+func (st *childStatus) upgrading(pid int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.upgradingPID = pid
+}
+
+func (st *childStatus) setText(text string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.text = text
+}
+
+func (st *childStatus) childReady() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.ready = st.running
+}
+
+func (st *childStatus) childExited(sum ExitSummary) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.running, st.ready = false, false
+	st.exited, st.lastExit, st.exitTime, st.summary = true, sum.ExitCode, sum.Exited, sum
+}
+
+func (st *childStatus) lastExitSummary() (ExitSummary, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.summary, st.exited
+}
+
+// alive reports whether the child is running.
+func (st *childStatus) alive() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.running
+}
+
+func (st *childStatus) report(now time.Time) statusReport {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	r := statusReport{
+		PID:          st.pid,
+		Running:      st.running,
+		Ready:        st.ready,
+		Restarts:     st.restarts,
+		Generation:   st.generation,
+		UpgradingPID: st.upgradingPID,
+		Status:       st.text,
+	}
+	if !st.started.IsZero() {
+		started := st.started
+		r.Started = &started
+	}
+	if st.running {
+		r.UptimeSeconds = now.Sub(st.started).Seconds()
+	}
+	if st.exited {
+		code, at := st.lastExit, st.exitTime
+		r.LastExitCode, r.LastExitTime = &code, &at
+	}
+	return r
+}
+
+// handler serves the health endpoints.
+func (st *childStatus) handler() http.Handler {
+	mux := http.NewServeMux()
+	probe := func(ok func(statusReport) bool, state string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			if ok(st.report(time.Now())) {
+				w.Write([]byte(state + "\n"))
+				return
+			}
+			http.Error(w, "not "+state, http.StatusServiceUnavailable)
+		}
+	}
+	mux.Handle("GET /healthz", probe(func(r statusReport) bool { return r.Running }, "alive"))
+	mux.Handle("GET /readyz", probe(func(r statusReport) bool { return r.Ready }, "ready"))
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(st.report(time.Now()))
+	})
+	return mux
+}
+
+// serveHealth starts the health endpoint if configured. Failing to listen is
+// logged and otherwise ignored.
+func (s *supervisor) serveHealth() {
+	if s.cfg.healthAddr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", s.cfg.healthAddr)
+	if err != nil {
+		log.Printf("psi: health endpoint disabled: %v", err)
+		return
+	}
+	s.cfg.debugf(1, "serving health endpoint on %s", ln.Addr())
+	srv := &http.Server{Handler: s.status.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+}
@@ -0,0 +1,84 @@
+package psi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var st childStatus
+	srv := httptest.NewServer(st.handler())
+	defer srv.Close()
+	get := func(path string) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	status := func() statusReport {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var r statusReport
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if get("/healthz") != http.StatusServiceUnavailable || get("/readyz") != http.StatusServiceUnavailable {
+		t.Fatal("healthy before the child started")
+	}
+	st.childStarted(42, 0, 1, time.Now().Add(-time.Minute))
+	if get("/healthz") != http.StatusOK || get("/readyz") != http.StatusServiceUnavailable {
+		t.Fatal("started child must be alive but not ready")
+	}
+	st.childReady()
+	if get("/readyz") != http.StatusOK {
+		t.Fatal("ready child not reported ready")
+	}
+	if r := status(); r.PID != 42 || !r.Running || !r.Ready || r.UptimeSeconds < 60 || r.LastExitCode != nil {
+		t.Fatalf("status = %+v", r)
+	}
+	st.childExited(ExitSummary{PID: 42, ExitCode: 3, Exited: time.Now()})
+	if get("/healthz") != http.StatusServiceUnavailable || get("/readyz") != http.StatusServiceUnavailable {
+		t.Fatal("exited child still healthy")
+	}
+	st.childReady()
+	r := status()
+	if r.Running || r.Ready || r.LastExitCode == nil || *r.LastExitCode != 3 || r.UptimeSeconds != 0 {
+		t.Fatalf("status after exit = %+v", r)
+	}
+	st.childStarted(43, 1, 2, time.Now())
+	if r := status(); r.PID != 43 || r.Restarts != 1 || r.Generation != 2 || *r.LastExitCode != 3 {
+		t.Fatalf("status after restart = %+v", r)
+	}
+	st.childReady()
+	st.upgrading(44)
+	if r := status(); r.PID != 43 || !r.Ready || r.UpgradingPID != 44 {
+		t.Fatalf("status during an upgrade = %+v", r)
+	}
+	st.childStarted(44, 1, 3, time.Now())
+	if r := status(); r.PID != 44 || r.Generation != 3 || r.UpgradingPID != 0 {
+		t.Fatalf("status after an upgrade = %+v", r)
+	}
+	if get("/nope") != http.StatusNotFound {
+		t.Fatal("unknown path served")
+	}
+}
+
+func TestHealthAddrEnv(t *testing.T) {
+	t.Setenv(healthAddrEnv, ":9097")
+	if cfg := newConfig(WithHealthAddr(":1")); cfg.healthAddr != ":9097" {
+		t.Fatalf("healthAddr = %q", cfg.healthAddr)
+	}
+}
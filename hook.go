@@ -0,0 +1,145 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	preStartEnv        = "PSI_PRE_START"
+	preStartTimeoutEnv = "PSI_PRE_START_TIMEOUT"
+	postStopEnv        = "PSI_POST_STOP"
+	postStopTimeoutEnv = "PSI_POST_STOP_TIMEOUT"
+)
+
+// Hook is a command the init runs at a point of the child's lifecycle. Like
+// sidecars, hooks run with the init's credentials and environment (without
+// PSI_* variables), and their output goes to the init's.
+type Hook struct {
+	// Path is the executable to run; Args are its arguments (without argv[0]).
+	Path string
+	Args []string
+	// Env is appended to the environment.
+	Env []string
+	// Timeout bounds the hook's run time; it is killed once exceeded. Zero
+	// means no limit.
+	Timeout time.Duration
+}
+
+// WithPreStart runs h once before the first child generation is started,
+// after the sidecars, e.g. to migrate a database. If it fails, times out or
+// is interrupted by a terminate signal, the child is not started and the init
+// exits with ExitPreStartFailed. Overridden by PSI_PRE_START (a command line
+// split on spaces) and PSI_PRE_START_TIMEOUT.
+func WithPreStart(h Hook) Option {
+	return func(c *config) {
+		c.preStart = h.clone()
+	}
+}
+
+// WithPostStop runs h once after the last child generation has exited and
+// before the sidecars are stopped, e.g. to flush or upload data. Its failure
+// is logged; the init still exits with the child's exit code. Overridden by
+// PSI_POST_STOP and PSI_POST_STOP_TIMEOUT.
+func WithPostStop(h Hook) Option {
+	return func(c *config) {
+		c.postStop = h.clone()
+	}
+}
+
+func (h Hook) clone() Hook {
+	h.Args = append([]string(nil), h.Args...)
+	h.Env = append([]string(nil), h.Env...)
+	return h
+}
+
+// loadHookEnv applies the PSI_PRE_START and PSI_POST_STOP overrides.
+func (c *config) loadHookEnv() {
+	for _, v := range []struct {
+		cmd, timeout string
+		hook         *Hook
+	}{
+		{preStartEnv, preStartTimeoutEnv, &c.preStart},
+		{postStopEnv, postStopTimeoutEnv, &c.postStop},
+	} {
+		if argv := strings.Fields(os.Getenv(v.cmd)); len(argv) > 0 {
+			*v.hook = Hook{Path: argv[0], Args: argv[1:], Timeout: v.hook.Timeout}
+		}
+		envDuration(v.timeout, &v.hook.Timeout)
+	}
+}
+
+// hookCommand builds the command running h in its own process group.
+func (s *supervisor) hookCommand(h Hook) *exec.Cmd {
+	env := append(s.cfg.childEnv(os.Environ()), h.Env...)
+	argv := s.cfg.expandArgv(append([]string{h.Path}, h.Args...), env)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.SysProcAttr = newSysProcAttr(nil)
+	return cmd
+}
+
+// runHook runs h to completion and returns its exit code. A timeout kills
+// it. When interruptible, a terminate signal received meanwhile sends h the
+// stop signal, kills it after the stop timeout and reports interrupted;
+// other signals are not forwarded while a hook runs.
+func (s *supervisor) runHook(name string, h Hook, interruptible bool) (code int, interrupted bool) {
+	proc, done, err := s.reaper.start(s.hookCommand(h))
+	if err != nil {
+		log.Printf("psi: %s hook: %v", name, err)
+		return 127, false
+	}
+	defer proc.close()
+	s.cfg.debugf(1, "running %s hook %s (pid %d)", name, h.Path, proc.pid)
+	var timeout, kill <-chan time.Time
+	if h.Timeout > 0 {
+		timeout = time.After(h.Timeout)
+	}
+	for {
+		select {
+		case code := <-done:
+			if code != 0 && !interrupted {
+				log.Printf("psi: %s hook %s exited with code %d", name, h.Path, code)
+			}
+			return code, interrupted
+		case <-timeout:
+			timeout = nil
+			log.Printf("psi: %s hook %s did not finish within %s; killing", name, h.Path, h.Timeout)
+			_ = proc.signalGroup(syscall.SIGKILL)
+		case <-kill:
+			kill = nil
+			_ = proc.signalGroup(syscall.SIGKILL)
+		case received := <-s.sigs:
+			sig, ok := toSyscallSignal(received)
+			if !interruptible || interrupted || !ok || s.cfg.isIgnored(sig) || !s.cfg.isTerminate(s.cfg.translateSignal(sig)) {
+				continue
+			}
+			interrupted = true
+			log.Printf("psi: interrupted; stopping %s hook %s", name, h.Path)
+			_ = proc.signalGroup(s.cfg.forwardSignal(s.cfg.translateSignal(sig)))
+			kill = time.After(s.stopTimeout)
+		}
+	}
+}
+
+// preStartHook runs the pre-start hook, if any, and reports whether the
+// child may be started.
+func (s *supervisor) preStartHook() bool {
+	if s.cfg.preStart.Path == "" {
+		return true
+	}
+	code, interrupted := s.runHook("pre-start", s.cfg.preStart, true)
+	return code == 0 && !interrupted
+}
+
+// postStopHook runs the post-stop hook, if any.
+func (s *supervisor) postStopHook() {
+	if s.cfg.postStop.Path != "" {
+		s.runHook("post-stop", s.cfg.postStop, false)
+	}
+}
@@ -0,0 +1,19 @@
+package psi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHookEnv(t *testing.T) {
+	t.Setenv(preStartEnv, "/app/migrate  up --all")
+	t.Setenv(postStopTimeoutEnv, "45")
+	c := newConfig(WithPreStart(Hook{Path: "/bin/true", Timeout: time.Minute}), WithPostStop(Hook{Path: "/app/flush"}))
+	if want := (Hook{Path: "/app/migrate", Args: []string{"up", "--all"}, Timeout: time.Minute}); !reflect.DeepEqual(c.preStart, want) {
+		t.Fatalf("preStart = %+v, want %+v", c.preStart, want)
+	}
+	if c.postStop.Path != "/app/flush" || c.postStop.Timeout != 45*time.Second {
+		t.Fatalf("postStop = %+v", c.postStop)
+	}
+}
@@ -0,0 +1,50 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	hostnameEnv   = "PSI_HOSTNAME"
+	unshareUTSEnv = "PSI_UNSHARE_UTS"
+)
+
+// WithHostname sets the hostname before the child starts. Without
+// WithUTSNamespace the init sets it in its own UTS namespace, i.e. for the
+// whole container. Needs CAP_SYS_ADMIN; Linux only. Overridden by
+// PSI_HOSTNAME.
+func WithHostname(name string) Option {
+	return func(c *config) {
+		c.hostname = name
+	}
+}
+
+// WithUTSNamespace gives the child its own UTS namespace, so the hostname
+// from WithHostname applies to the child only. Overridden by
+// PSI_UNSHARE_UTS.
+func WithUTSNamespace() Option {
+	return func(c *config) {
+		c.unshareUTS = true
+	}
+}
+
+// loadHostnameEnv applies the PSI_HOSTNAME and PSI_UNSHARE_UTS overrides.
+func (c *config) loadHostnameEnv() {
+	if val := strings.TrimSpace(os.Getenv(hostnameEnv)); val != "" {
+		c.hostname = val
+	}
+	envBool(unshareUTSEnv, &c.unshareUTS)
+}
+
+// applyInitHostname sets the hostname in the init's UTS namespace unless the
+// child gets a namespace of its own.
+func (c *config) applyInitHostname() {
+	if c.hostname == "" || c.unshareUTS {
+		return
+	}
+	if err := setHostname(c.hostname); err != nil {
+		log.Printf("psi: failed to set hostname %q: %v", c.hostname, err)
+	}
+}
@@ -0,0 +1,26 @@
+package psi
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+func setHostname(name string) error {
+	return unix.Sethostname([]byte(name))
+}
+
+// unshareUTS moves the calling thread into a new UTS namespace, which the
+// child inherits, and sets its hostname there.
+func unshareUTS(hostname string) error {
+	if err := unix.Unshare(unix.CLONE_NEWUTS); err != nil {
+		return fmt.Errorf("unshare UTS namespace: %w", err)
+	}
+	if hostname == "" {
+		return nil
+	}
+	if err := setHostname(hostname); err != nil {
+		return fmt.Errorf("set hostname %q: %w", hostname, err)
+	}
+	return nil
+}
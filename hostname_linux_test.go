@@ -0,0 +1,33 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStartChildProcessUTSNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to create a UTS namespace")
+	}
+	before, _ := os.Hostname()
+	cfg := &config{hostname: "psi-test-host", unshareUTS: true}
+	cmd := exec.Command("cat", "/proc/sys/kernel/hostname")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	var out strings.Builder
+	cmd.Stdout = &out
+	if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "psi-test-host" {
+		t.Fatalf("child hostname = %q", got)
+	}
+	if after, _ := os.Hostname(); after != before {
+		t.Fatalf("hostname leaked into the caller: %q", after)
+	}
+}
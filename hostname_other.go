@@ -0,0 +1,11 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+var errUTSUnsupported = errors.New("hostname settings are only supported on Linux")
+
+func setHostname(string) error { return errUTSUnsupported }
+
+func unshareUTS(string) error { return errUTSUnsupported }
@@ -0,0 +1,15 @@
+package psi
+
+import "testing"
+
+func TestHostnameEnv(t *testing.T) {
+	t.Setenv(hostnameEnv, "myapp")
+	t.Setenv(unshareUTSEnv, "1")
+	cfg := newConfig(WithHostname("other"))
+	if cfg.hostname != "myapp" || !cfg.unshareUTS {
+		t.Fatalf("hostname=%q unshareUTS=%v", cfg.hostname, cfg.unshareUTS)
+	}
+	if !cfg.restricted() {
+		t.Fatal("a UTS namespace must be set up on the child's thread")
+	}
+}
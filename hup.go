@@ -0,0 +1,68 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const hupActionEnv = "PSI_HUP_ACTION"
+
+// HupAction decides what the init does with SIGHUP.
+type HupAction string
+
+const (
+	// HupTerminate treats SIGHUP like SIGTERM: it is forwarded (as the stop
+	// signal, if one is set) and starts the shutdown sequence (default).
+	HupTerminate HupAction = "terminate"
+	// HupReload forwards SIGHUP to the child and sidecars as is without
+	// starting shutdown, for daemons that reload their configuration on it.
+	// A Go submain's context is not cancelled by it.
+	HupReload HupAction = "reload"
+	// HupIgnore swallows SIGHUP in the init.
+	HupIgnore HupAction = "ignore"
+)
+
+func parseHupAction(s string) (HupAction, error) {
+	switch a := HupAction(strings.ToLower(strings.TrimSpace(s))); a {
+	case HupTerminate, HupReload, HupIgnore:
+		return a, nil
+	case "":
+		return HupTerminate, nil
+	default:
+		return "", fmt.Errorf("unknown SIGHUP action %q", s)
+	}
+}
+
+// WithHupAction sets what the init does with SIGHUP. Overridden by
+// PSI_HUP_ACTION.
+func WithHupAction(action HupAction) Option {
+	return func(c *config) {
+		c.hupAction = action
+	}
+}
+
+// loadHupEnv applies the PSI_HUP_ACTION override.
+func (c *config) loadHupEnv() {
+	val := strings.TrimSpace(os.Getenv(hupActionEnv))
+	if val == "" {
+		return
+	}
+	a, err := parseHupAction(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", hupActionEnv, val, err)
+		return
+	}
+	c.hupAction = a
+}
+
+// isTerminate reports whether sig, once translated, starts the shutdown
+// sequence.
+func (c *config) isTerminate(sig syscall.Signal) bool {
+	if sig == syscall.SIGHUP && c.hupAction == HupReload {
+		return false
+	}
+	return isTerminateSignal(sig)
+}
@@ -0,0 +1,41 @@
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseHupAction(t *testing.T) {
+	for in, want := range map[string]HupAction{"": HupTerminate, "terminate": HupTerminate, "Reload": HupReload, " ignore ": HupIgnore} {
+		got, err := parseHupAction(in)
+		if err != nil || got != want {
+			t.Fatalf("parseHupAction(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := parseHupAction("restart"); err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+}
+
+func TestHupAction(t *testing.T) {
+	c := newConfig(WithStopSignal(syscall.SIGQUIT))
+	if !c.isTerminate(syscall.SIGHUP) || c.forwardSignal(syscall.SIGHUP) != syscall.SIGQUIT || c.isIgnored(syscall.SIGHUP) {
+		t.Fatal("SIGHUP must terminate by default")
+	}
+	c = newConfig(WithStopSignal(syscall.SIGQUIT), WithHupAction(HupReload))
+	if c.isTerminate(syscall.SIGHUP) || c.forwardSignal(syscall.SIGHUP) != syscall.SIGHUP || c.isIgnored(syscall.SIGHUP) {
+		t.Fatal("reload must forward SIGHUP as is without terminating")
+	}
+	if !c.isTerminate(syscall.SIGTERM) {
+		t.Fatal("reload must not affect SIGTERM")
+	}
+	t.Setenv(hupActionEnv, "ignore")
+	c = newConfig(WithHupAction(HupReload))
+	if !c.isIgnored(syscall.SIGHUP) {
+		t.Fatal("PSI_HUP_ACTION=ignore must override the option")
+	}
+	t.Setenv(hupActionEnv, "bogus")
+	if c = newConfig(WithHupAction(HupReload)); c.hupAction != HupReload {
+		t.Fatalf("invalid PSI_HUP_ACTION must be ignored, got %q", c.hupAction)
+	}
+}
@@ -0,0 +1,60 @@
+package psi
+
+import (
+	"log"
+	"time"
+)
+
+// InitTask is a one-shot command the init runs to completion before the
+// first child generation, like a Kubernetes init container: database
+// migrations, cache warm-up, rendering configuration files. Like hooks,
+// tasks run with the init's credentials and environment (without PSI_*
+// variables).
+type InitTask struct {
+	// Name identifies the task in logs.
+	Name string
+	// Path is the executable to run; Args are its arguments (without argv[0]).
+	Path string
+	Args []string
+	// Env is appended to the environment.
+	Env []string
+	// Timeout bounds the task's run time; it is killed once exceeded. Zero
+	// means no limit.
+	Timeout time.Duration
+	// Optional tasks may fail without keeping the child from starting.
+	Optional bool
+}
+
+// WithInitTask adds a task run before the child. Tasks run one at a time in
+// declaration order after the sidecars have started and before the
+// pre-start hook. If a task that is not Optional fails or times out, or a
+// terminate signal interrupts a task, the child is not started and the init
+// exits with ExitPreStartFailed.
+func WithInitTask(t InitTask) Option {
+	return func(c *config) {
+		t.Args = append([]string(nil), t.Args...)
+		t.Env = append([]string(nil), t.Env...)
+		c.initTasks = append(c.initTasks, t)
+	}
+}
+
+// runInitTasks runs the init tasks and reports whether the child may be
+// started.
+func (s *supervisor) runInitTasks() bool {
+	for _, t := range s.cfg.initTasks {
+		name := "init task"
+		if t.Name != "" {
+			name += " " + t.Name
+		}
+		code, interrupted := s.runHook(name, Hook{Path: t.Path, Args: t.Args, Env: t.Env, Timeout: t.Timeout}, true)
+		switch {
+		case interrupted:
+			return false
+		case code != 0 && t.Optional:
+			log.Printf("psi: %s is optional; continuing", name)
+		case code != 0:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// restrictThread moves the calling thread into a new mount namespace, which
+// the child inherits, and makes it read-only apart from the writable paths.
+func (i *isolationConfig) restrictThread() error {
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		return fmt.Errorf("unshare mount namespace: %w", err)
+	}
+	// Keep our changes from propagating back to the host namespace.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+	if err := remountReadOnly(); err != nil {
+		return err
+	}
+	for _, p := range i.writable() {
+		if err := unix.Mount(p, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", p, err)
+		}
+		// The new bind mount copied the read-only flag; clear it.
+		if err := unix.Mount("", p, "", unix.MS_BIND|unix.MS_REMOUNT, ""); err != nil {
+			return fmt.Errorf("remount %s read-write: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// remountReadOnly makes / and all mounts below it read-only, falling back to
+// the root mount alone on kernels without mount_setattr (before 5.12).
+func remountReadOnly() error {
+	err := unix.MountSetattr(-1, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if errors.Is(err, unix.ENOSYS) {
+		err = unix.Mount("", "/", "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, "")
+	}
+	if err != nil {
+		return fmt.Errorf("remount / read-only: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestStartChildProcessIsolation(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to create a mount namespace")
+	}
+	rw := t.TempDir()
+	ro := t.TempDir()
+	cfg := &config{isolation: isolationConfig{enabled: true, rw: []string{rw}}}
+	run := func(path string) error {
+		cmd := exec.Command("touch", path)
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+		return cmd.Wait()
+	}
+	if err := run(filepath.Join(rw, "ok")); err != nil {
+		t.Fatalf("writing below a writable path failed: %v", err)
+	}
+	if err := run(filepath.Join(ro, "no")); err == nil {
+		t.Fatal("writing to the read-only root succeeded")
+	}
+	if err := os.WriteFile(filepath.Join(ro, "caller"), nil, 0o644); err != nil {
+		t.Fatalf("the read-only root leaked into the caller: %v", err)
+	}
+}
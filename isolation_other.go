@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func (i *isolationConfig) restrictThread() error {
+	return errors.New("mount isolation is only supported on Linux")
+}
@@ -0,0 +1,18 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsolationEnv(t *testing.T) {
+	t.Setenv(isolationEnv, "1")
+	t.Setenv(isolationRWEnv, "/data, /tmp")
+	cfg := newConfig()
+	if !cfg.isolation.enabled {
+		t.Fatal("expected isolation to be enabled")
+	}
+	if got := cfg.isolation.writable(); !slices.Equal(got, []string{"/dev", "/data", "/tmp"}) {
+		t.Fatalf("writable = %v", got)
+	}
+}
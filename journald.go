@@ -0,0 +1,184 @@
+package psi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const journaldEnv = "PSI_JOURNALD"
+
+// journalSocket is where journald listens for its native protocol.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Journal priorities, as in syslog.
+const (
+	journalErr     = 3
+	journalWarning = 4
+	journalInfo    = 6
+	journalDebug   = 7
+)
+
+// WithJournald makes the init send the child's and its sidecars' output,
+// a journal entry per line, and its lifecycle events straight to journald
+// over its native protocol, when /run/systemd/journal/socket exists. Entries
+// carry PRIORITY (6 for stdout, 3 for stderr), SYSLOG_IDENTIFIER and UNIT
+// (the process's name, see WithChildName) and PSI_STREAM; events carry
+// PSI_EVENT and, where they apply, PSI_PID, PSI_SIGNAL and PSI_EXIT_CODE.
+// The output is still forwarded to the init's own stdout and stderr.
+// Overridden by PSI_JOURNALD.
+func WithJournald() Option {
+	return func(c *config) {
+		c.journald = true
+	}
+}
+
+// journal sends entries to journald. The socket is dialed on the first
+// entry; send errors are logged once and otherwise dropped so the child
+// never blocks on them.
+type journal struct {
+	addr string
+	// unit names the child in event entries.
+	unit string
+
+	mu     sync.Mutex
+	conn   *net.UnixConn
+	failed bool
+}
+
+// newJournal returns the journal if journald forwarding is enabled and
+// journald is running, nil otherwise.
+func (c *config) newJournal() *journal {
+	if !c.journald {
+		return nil
+	}
+	if _, err := os.Stat(journalSocket); err != nil {
+		log.Printf("psi: journald forwarding disabled: %v", err)
+		return nil
+	}
+	return &journal{addr: journalSocket, unit: c.mainChildName()}
+}
+
+// attachJournal adds line sinks sending the output of the process called
+// name to the journal.
+func (s *supervisor) attachJournal(o *childOutput, name string) {
+	if s.journal == nil {
+		return
+	}
+	o.stdout.lineSinks = append(o.stdout.lineSinks, &journalWriter{j: s.journal, name: name, stream: "stdout", priority: journalInfo})
+	o.stderr.lineSinks = append(o.stderr.lineSinks, &journalWriter{j: s.journal, name: name, stream: "stderr", priority: journalErr})
+}
+
+// startJournal sends the lifecycle events to the journal.
+func (s *supervisor) startJournal() {
+	if s.journal != nil {
+		addEventHandler(s.journal.event)
+		s.cfg.debugf(1, "forwarding to journald at %s", s.journal.addr)
+	}
+}
+
+// event sends e as an entry.
+func (j *journal) event(e Event) {
+	level, msg, ok := eventMessage(e)
+	if !ok {
+		return
+	}
+	priority := journalInfo
+	switch {
+	case level <= LogDebug:
+		priority = journalDebug
+	case level == LogWarn:
+		priority = journalWarning
+	case level >= LogError:
+		priority = journalErr
+	}
+	fields := []string{
+		"MESSAGE", msg,
+		"PRIORITY", strconv.Itoa(priority),
+		"SYSLOG_IDENTIFIER", "psi",
+		"UNIT", j.unit,
+		"PSI_EVENT", string(e.Type),
+	}
+	if e.PID > 0 {
+		fields = append(fields, "PSI_PID", strconv.Itoa(e.PID))
+	}
+	if e.Signal != 0 {
+		fields = append(fields, "PSI_SIGNAL", signalName(e.Signal))
+	}
+	if e.Exit != nil {
+		fields = append(fields, "PSI_EXIT_CODE", strconv.Itoa(e.ExitCode))
+	}
+	j.send(fields...)
+}
+
+// send sends an entry of key, value pairs.
+func (j *journal) send(fields ...string) {
+	var buf []byte
+	for i := 0; i+1 < len(fields); i += 2 {
+		buf = appendJournalField(buf, fields[i], fields[i+1])
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		if j.failed {
+			return
+		}
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.addr, Net: "unixgram"})
+		if err != nil {
+			j.fail(err)
+			return
+		}
+		j.conn = conn
+	}
+	if _, err := j.conn.Write(buf); err != nil {
+		j.fail(err)
+	}
+}
+
+// fail logs err if it is the first.
+func (j *journal) fail(err error) {
+	if !j.failed {
+		j.failed = true
+		log.Printf("psi: journald: %v", err)
+	}
+}
+
+// appendJournalField appends a field in the native protocol's format:
+// KEY=value, or, for values spanning lines, the key, a newline, the value's
+// length as a little-endian uint64 and the value.
+func appendJournalField(buf []byte, key, value string) []byte {
+	buf = append(buf, key...)
+	if !strings.Contains(value, "\n") {
+		buf = append(buf, '=')
+		buf = append(buf, value...)
+		return append(buf, '\n')
+	}
+	buf = append(buf, '\n')
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// journalWriter is a line sink sending each line of a stream as an entry.
+type journalWriter struct {
+	j        *journal
+	name     string
+	stream   string
+	priority int
+}
+
+func (w *journalWriter) Write(p []byte) (int, error) {
+	w.j.send(
+		"MESSAGE", string(bytes.TrimSuffix(p, []byte("\n"))),
+		"PRIORITY", strconv.Itoa(w.priority),
+		"SYSLOG_IDENTIFIER", w.name,
+		"UNIT", w.name,
+		"PSI_STREAM", w.stream,
+	)
+	return len(p), nil
+}
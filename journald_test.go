@@ -0,0 +1,117 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAppendJournalField(t *testing.T) {
+	buf := appendJournalField(nil, "MESSAGE", "hello")
+	buf = appendJournalField(buf, "MESSAGE", "two\nlines")
+	want := []byte("MESSAGE=hello\nMESSAGE\n")
+	want = binary.LittleEndian.AppendUint64(want, 9)
+	want = append(want, "two\nlines\n"...)
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("got %q, want %q", buf, want)
+	}
+}
+
+func TestNewJournalWithoutJournald(t *testing.T) {
+	defer func(old string) { journalSocket = old }(journalSocket)
+	journalSocket = filepath.Join(t.TempDir(), "missing")
+	if j := newConfig(WithJournald()).newJournal(); j != nil {
+		t.Fatal("journal enabled without a socket")
+	}
+	if j := newConfig().newJournal(); j != nil {
+		t.Fatal("journal enabled without WithJournald")
+	}
+}
+
+func TestJournalForwarding(t *testing.T) {
+	defer func(old string) { journalSocket = old }(journalSocket)
+	journalSocket = filepath.Join(t.TempDir(), "socket")
+	ln, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	t.Setenv(journaldEnv, "1")
+	s := &supervisor{cfg: newConfig(WithChildName("web"))}
+	if s.journal = s.cfg.newJournal(); s.journal == nil {
+		t.Fatal("journal not enabled")
+	}
+	var out childOutput
+	s.attachJournal(&out, "web")
+	cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	out.stdout.drop, out.stderr.drop = true, true
+	closeOutput, err := out.attach(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	closeOutput()
+	cmd.Wait()
+	out.flush(2 * time.Second)
+	s.journal.event(Event{Type: EventSignalForwarded, PID: 42, Signal: syscall.SIGTERM})
+
+	want := map[string]map[string]string{
+		"out":              {"PRIORITY": "6", "SYSLOG_IDENTIFIER": "web", "UNIT": "web", "PSI_STREAM": "stdout"},
+		"err":              {"PRIORITY": "3", "SYSLOG_IDENTIFIER": "web", "PSI_STREAM": "stderr"},
+		"signal forwarded": {"PRIORITY": "6", "UNIT": "web", "PSI_EVENT": "signal_forwarded", "PSI_PID": "42", "PSI_SIGNAL": "SIGTERM"},
+	}
+	ln.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1<<16)
+	for len(want) > 0 {
+		n, err := ln.Read(buf)
+		if err != nil {
+			t.Fatalf("entries missing for %v: %v", want, err)
+		}
+		fields := parseJournalEntry(t, buf[:n])
+		w, ok := want[fields["MESSAGE"]]
+		if !ok {
+			continue
+		}
+		for k, v := range w {
+			if fields[k] != v {
+				t.Errorf("%q: %s=%q, want %q", fields["MESSAGE"], k, fields[k], v)
+			}
+		}
+		delete(want, fields["MESSAGE"])
+	}
+}
+
+// parseJournalEntry decodes a native protocol datagram.
+func parseJournalEntry(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if i < 0 {
+			t.Fatalf("malformed entry %q", b)
+		}
+		key := string(b[:i])
+		if b[i] == '=' {
+			end := bytes.IndexByte(b, '\n')
+			fields[key] = string(b[i+1 : end])
+			b = b[end+1:]
+			continue
+		}
+		n := int(binary.LittleEndian.Uint64(b[i+1:]))
+		fields[key] = string(b[i+9 : i+9+n])
+		b = b[i+9+n+1:]
+	}
+	return fields
+}
@@ -0,0 +1,57 @@
+package psi
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	landlockROEnv = "PSI_LANDLOCK_RO"
+	landlockRWEnv = "PSI_LANDLOCK_RW"
+)
+
+// WithLandlock confines the child's filesystem access with a Landlock
+// ruleset (Linux 5.13+): paths in ro (and everything beneath them) may be
+// read and executed, paths in rw may also be written, and everything else is
+// off limits. The child needs access to its own binary and libraries, so ro
+// usually includes "/" or "/usr". Restricting needs no_new_privs
+// (WithNoNewPrivs) or CAP_SYS_ADMIN. On kernels without Landlock the child
+// runs unconfined and a warning is logged. Overridden by PSI_LANDLOCK_RO and
+// PSI_LANDLOCK_RW (comma-separated paths).
+func WithLandlock(ro, rw []string) Option {
+	return func(c *config) {
+		c.landlock.ro = append([]string(nil), ro...)
+		c.landlock.rw = append([]string(nil), rw...)
+	}
+}
+
+// landlockConfig lists the paths the child may access.
+type landlockConfig struct {
+	ro []string
+	rw []string
+}
+
+// loadEnv applies the PSI_LANDLOCK_RO and PSI_LANDLOCK_RW overrides.
+func (l *landlockConfig) loadEnv() {
+	if paths := splitPaths(os.Getenv(landlockROEnv)); len(paths) > 0 {
+		l.ro = paths
+	}
+	if paths := splitPaths(os.Getenv(landlockRWEnv)); len(paths) > 0 {
+		l.rw = paths
+	}
+}
+
+func (l *landlockConfig) enabled() bool {
+	return len(l.ro) > 0 || len(l.rw) > 0
+}
+
+// splitPaths splits a comma-separated path list, dropping empty entries.
+func splitPaths(s string) []string {
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
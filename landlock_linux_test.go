@@ -0,0 +1,37 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestStartChildProcessLandlock(t *testing.T) {
+	if landlockABI() == 0 {
+		t.Skip("Landlock is not available")
+	}
+	rw := t.TempDir()
+	denied := t.TempDir()
+	cfg := &config{noNewPrivs: true, landlock: landlockConfig{ro: []string{"/"}, rw: []string{rw}}}
+	run := func(path string) error {
+		cmd := exec.Command("touch", path)
+		// Opening /dev/null for writing would happen on the restricted thread.
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		if err := cfg.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+		return cmd.Wait()
+	}
+	if err := run(filepath.Join(rw, "ok")); err != nil {
+		t.Fatalf("writing below an rw path failed: %v", err)
+	}
+	if err := run(filepath.Join(denied, "no")); err == nil {
+		t.Fatal("writing outside the rw paths succeeded")
+	}
+	if err := os.WriteFile(filepath.Join(denied, "caller"), nil, 0o644); err != nil {
+		t.Fatalf("the ruleset leaked into the caller: %v", err)
+	}
+}
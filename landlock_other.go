@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func (l *landlockConfig) restrictThread() error {
+	return errors.New("Landlock is only supported on Linux")
+}
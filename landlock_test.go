@@ -0,0 +1,21 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLandlockEnv(t *testing.T) {
+	t.Setenv(landlockROEnv, "/usr, /etc,,")
+	t.Setenv(landlockRWEnv, "")
+	cfg := newConfig(WithLandlock([]string{"/"}, []string{"/data"}))
+	if !slices.Equal(cfg.landlock.ro, []string{"/usr", "/etc"}) {
+		t.Fatalf("ro = %v", cfg.landlock.ro)
+	}
+	if !slices.Equal(cfg.landlock.rw, []string{"/data"}) {
+		t.Fatalf("expected the option's rw paths to stay, got %v", cfg.landlock.rw)
+	}
+	if (&landlockConfig{}).enabled() {
+		t.Fatal("empty Landlock config reported as enabled")
+	}
+}
@@ -0,0 +1,273 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const listenEnv = "PSI_LISTEN"
+
+// Socket activation variables (sd_listen_fds(3)).
+const (
+	listenFDsEnv     = "LISTEN_FDS"
+	listenPIDEnv     = "LISTEN_PID"
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+	// listenFDStart is the first passed descriptor.
+	listenFDStart = 3
+	// listenPIDFixEnv asks the child to set LISTEN_PID to its own PID, which
+	// the init cannot know before the fork.
+	listenPIDFixEnv = "PSI_LISTEN_PID_FIX"
+	// childExecVal marks this binary re-exec'd as a trampoline that fixes
+	// LISTEN_PID and waits for its resource limits before exec'ing an
+	// external program.
+	childExecVal = "exec"
+	// listenFDPrefix prefixes the per-name variables holding each socket's
+	// descriptor number, e.g. LISTEN_FD_HTTP=3.
+	listenFDPrefix = "LISTEN_FD_"
+)
+
+// WithListen makes the init bind listening sockets and pass them to every
+// child generation, so the child can use privileged ports without
+// privileges and restarts never hit EADDRINUSE. Each spec is
+// "[name=]network:address" with network tcp, tcp4, tcp6 or unix, e.g.
+// "http=tcp::8080" or "unix:/run/app.sock". The child receives them like
+// socket-activated sockets: descriptors 3 and up with LISTEN_FDS,
+// LISTEN_FDNAMES and LISTEN_PID, plus LISTEN_FD_<NAME> naming each
+// descriptor. Go submains get them with Listener. The default name is the
+// port for TCP and the file name for unix sockets. Sockets are only bound
+// while supervising. Overridden by PSI_LISTEN (comma-separated).
+func WithListen(specs ...string) Option {
+	return func(c *config) {
+		c.listen = append([]string(nil), specs...)
+	}
+}
+
+// loadListenEnv applies the PSI_LISTEN override.
+func (c *config) loadListenEnv() {
+	val := strings.TrimSpace(os.Getenv(listenEnv))
+	if val == "" {
+		return
+	}
+	specs := splitPaths(val)
+	for _, spec := range specs {
+		if _, _, _, err := parseListenSpec(spec); err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", listenEnv, val, err)
+			return
+		}
+	}
+	c.listen = specs
+}
+
+// parseListenSpec splits "[name=]network:address".
+func parseListenSpec(spec string) (name, network, addr string, err error) {
+	name, rest, ok := strings.Cut(spec, "=")
+	if !ok {
+		name, rest = "", spec
+	}
+	network, addr, ok = strings.Cut(rest, ":")
+	if !ok || addr == "" {
+		return "", "", "", fmt.Errorf("listener %q: want [name=]network:address", spec)
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", "", "", fmt.Errorf("listener %q: %w", spec, err)
+		}
+		if name == "" {
+			name = port
+		}
+	case "unix":
+		if name == "" {
+			name = filepath.Base(addr)
+		}
+	default:
+		return "", "", "", fmt.Errorf("listener %q: unsupported network %q", spec, network)
+	}
+	if name == "" || strings.ContainsAny(name, ": \t\n") {
+		return "", "", "", fmt.Errorf("listener %q: invalid name %q", spec, name)
+	}
+	return name, network, addr, nil
+}
+
+// bindListeners binds the configured sockets. A stale unix socket file is
+// replaced, and the new one is owned by the child's user.
+func (c *config) bindListeners() ([]listenFD, error) {
+	var fds []listenFD
+	for _, spec := range c.listen {
+		name, network, addr, err := parseListenSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		if network == "unix" {
+			if err := os.Remove(addr); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if network == "unix" {
+			ln.(*net.UnixListener).SetUnlinkOnClose(false)
+			if err := c.chownToChild(addr); err != nil {
+				ln.Close()
+				return nil, err
+			}
+		}
+		f, err := ln.(interface{ File() (*os.File, error) }).File()
+		ln.Close()
+		if err != nil {
+			return nil, err
+		}
+		c.debugf(1, "listening on %s %s as %q", network, addr, name)
+		fds = append(fds, listenFD{file: f, name: name})
+	}
+	return fds, nil
+}
+
+// chownToChild gives path to the child's user, if one is configured.
+func (c *config) chownToChild(path string) error {
+	cred, err := c.credential()
+	if err != nil || cred == nil {
+		return err
+	}
+	return os.Lchown(path, int(cred.Uid), int(cred.Gid))
+}
+
+// Listener returns the listening socket passed to the current process
+// under name, by the init (see WithListen) or by a service manager. It fails
+// when no such socket was passed, e.g. when not running under the init, so
+// callers can fall back to net.Listen.
+func Listener(name string) (net.Listener, error) {
+	if os.Getenv(listenPIDEnv) != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("psi: no listener %q: no sockets were passed", name)
+	}
+	n, _ := strconv.Atoi(os.Getenv(listenFDsEnv))
+	for i, fdName := range strings.Split(os.Getenv(listenFDNamesEnv), ":") {
+		if fdName != name || i >= n {
+			continue
+		}
+		fd := listenFDStart + i
+		closeOnExec(fd)
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("psi: listener %q: %w", name, err)
+		}
+		return ln, nil
+	}
+	return nil, fmt.Errorf("psi: no listener %q was passed", name)
+}
+
+func init() {
+	if os.Getenv(childEnvKey) == childExecVal {
+		// Trampoline: the argv to run follows our own argv[0].
+		os.Unsetenv(childEnvKey)
+		fixListenPID()
+		awaitRlimitGate()
+		execProgram(os.Args[1:])
+	}
+}
+
+// fixListenPID points LISTEN_PID at the current process if the init asked
+// for it.
+func fixListenPID() {
+	if os.Getenv(listenPIDFixEnv) == "" {
+		return
+	}
+	os.Unsetenv(listenPIDFixEnv)
+	os.Setenv(listenPIDEnv, strconv.Itoa(os.Getpid()))
+}
+
+// listenFD is a socket passed on to the child under a name.
+type listenFD struct {
+	file *os.File
+	name string
+}
+
+// inheritListenFDs takes over the sockets a service manager passed to the
+// init for socket activation. They are removed from the environment and
+// marked close-on-exec so only the child receives them.
+func inheritListenFDs() []listenFD {
+	if os.Getenv(listenPIDEnv) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv(listenFDsEnv))
+	names := strings.Split(os.Getenv(listenFDNamesEnv), ":")
+	for _, key := range []string{listenFDsEnv, listenPIDEnv, listenFDNamesEnv} {
+		os.Unsetenv(key)
+	}
+	if err != nil || n <= 0 {
+		return nil
+	}
+	fds := make([]listenFD, n)
+	for i := range fds {
+		fd := listenFDStart + i
+		closeOnExec(fd)
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		fds[i] = listenFD{file: os.NewFile(uintptr(fd), name), name: name}
+	}
+	return fds
+}
+
+// envKeyName upper-cases name and replaces characters not valid in
+// variable names with underscores.
+func envKeyName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// prepareListenFDs passes the listen sockets to cmd as descriptors 3 and up
+// with LISTEN_FDS, LISTEN_FDNAMES and LISTEN_FD_<NAME>. An external program is started through
+// this binary as a trampoline that sets LISTEN_PID once it has its PID; a Go
+// submain sets it in Run.
+func (s *supervisor) prepareListenFDs(cmd *exec.Cmd) error {
+	if len(s.listenFDs) == 0 {
+		return nil
+	}
+	names := make([]string, len(s.listenFDs))
+	files := make([]*os.File, len(s.listenFDs))
+	for i, l := range s.listenFDs {
+		files[i], names[i] = l.file, l.name
+	}
+	cmd.ExtraFiles = append(files, cmd.ExtraFiles...)
+	env := setEnv(cmd.Env, listenFDsEnv, strconv.Itoa(len(files)))
+	env = setEnv(env, listenFDNamesEnv, strings.Join(names, ":"))
+	for i, name := range names {
+		env = setEnv(env, listenFDPrefix+envKeyName(name), strconv.Itoa(listenFDStart+i))
+	}
+	cmd.Env = setEnv(env, listenPIDFixEnv, "1")
+	s.cfg.viaTrampoline(cmd)
+	return nil
+}
+
+// viaTrampoline has cmd, in command mode, start this binary as a trampoline
+// that execs the external program, unless it already does.
+func (c *config) viaTrampoline(cmd *exec.Cmd) {
+	if len(c.command) == 0 || slices.Contains(cmd.Env, childEnvKey+"="+childExecVal) {
+		return
+	}
+	cmd.Path = c.selfExe()
+	cmd.Args = append([]string{os.Args[0]}, cmd.Args...)
+	cmd.Env = setEnv(cmd.Env, childEnvKey, childExecVal)
+}
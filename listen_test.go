@@ -0,0 +1,97 @@
+package psi
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFixListenPID(t *testing.T) {
+	t.Setenv(listenPIDEnv, "1")
+	fixListenPID()
+	if got := os.Getenv(listenPIDEnv); got != "1" {
+		t.Fatalf("LISTEN_PID changed to %q without a request", got)
+	}
+	t.Setenv(listenPIDFixEnv, "1")
+	fixListenPID()
+	if got := os.Getenv(listenPIDEnv); got != strconv.Itoa(os.Getpid()) {
+		t.Fatalf("LISTEN_PID = %q, want our PID", got)
+	}
+	if _, ok := os.LookupEnv(listenPIDFixEnv); ok {
+		t.Fatalf("%s left set", listenPIDFixEnv)
+	}
+}
+
+func TestInheritListenFDsForOtherProcess(t *testing.T) {
+	t.Setenv(listenPIDEnv, "1")
+	t.Setenv(listenFDsEnv, "2")
+	if fds := inheritListenFDs(); fds != nil {
+		t.Fatalf("took over sockets meant for another process: %v", fds)
+	}
+	if os.Getenv(listenFDsEnv) != "2" {
+		t.Fatal("environment of another process's sockets modified")
+	}
+}
+
+func TestParseListenSpec(t *testing.T) {
+	for _, tt := range []struct{ spec, name, network, addr string }{
+		{"tcp::8080", "8080", "tcp", ":8080"},
+		{"http=tcp4:127.0.0.1:80", "http", "tcp4", "127.0.0.1:80"},
+		{"tcp6:[::1]:443", "443", "tcp6", "[::1]:443"},
+		{"unix:/run/app.sock", "app.sock", "unix", "/run/app.sock"},
+		{"admin=unix:/run/admin.sock", "admin", "unix", "/run/admin.sock"},
+	} {
+		name, network, addr, err := parseListenSpec(tt.spec)
+		if err != nil || name != tt.name || network != tt.network || addr != tt.addr {
+			t.Errorf("parseListenSpec(%q) = %q, %q, %q, %v", tt.spec, name, network, addr, err)
+		}
+	}
+	for _, bad := range []string{"", "tcp", "tcp:", "tcp:8080", "udp::53", "a:b=tcp::80"} {
+		if _, _, _, err := parseListenSpec(bad); err == nil {
+			t.Errorf("parseListenSpec(%q) should fail", bad)
+		}
+	}
+}
+
+func TestEnvKeyName(t *testing.T) {
+	if got := envKeyName("app.sock-2"); got != "APP_SOCK_2" {
+		t.Fatalf("envKeyName = %q", got)
+	}
+}
+
+func TestBindListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	// A stale socket file from a previous run must not prevent binding.
+	if err := os.WriteFile(sock, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newConfig(WithListen("http=tcp:127.0.0.1:0", "unix:"+sock))
+	fds, err := cfg.bindListeners()
+	if err != nil {
+		t.Fatalf("bindListeners: %v", err)
+	}
+	if len(fds) != 2 || fds[0].name != "http" || fds[1].name != "app.sock" {
+		t.Fatalf("fds = %+v", fds)
+	}
+	for _, l := range fds {
+		ln, err := net.FileListener(l.file)
+		if err != nil {
+			t.Fatalf("%s: %v", l.name, err)
+		}
+		conn, err := net.Dial(ln.Addr().Network(), ln.Addr().String())
+		if err != nil {
+			t.Fatalf("%s: dial: %v", l.name, err)
+		}
+		conn.Close()
+		ln.Close()
+		l.file.Close()
+	}
+}
+
+func TestListenerNotPassed(t *testing.T) {
+	if _, err := Listener("http"); err == nil {
+		t.Fatal("Listener succeeded without passed sockets")
+	}
+}
@@ -0,0 +1,186 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logDirEnv       = "PSI_LOG_DIR"
+	logMaxSizeEnv   = "PSI_LOG_MAX_SIZE"
+	logMaxFilesEnv  = "PSI_LOG_MAX_FILES"
+	logMaxAgeEnv    = "PSI_LOG_MAX_AGE"
+	logFilesOnlyEnv = "PSI_LOG_FILES_ONLY"
+)
+
+const (
+	defaultLogMaxSize  = 10 << 20
+	defaultLogMaxFiles = 5
+)
+
+// WithLogDir makes the init write the child's stdout and stderr to
+// stdout.log and stderr.log in dir, rotated per WithLogRotation, as well as
+// to its own stdout and stderr (see WithLogFilesOnly), for environments
+// without a log collector. Overridden by PSI_LOG_DIR.
+func WithLogDir(dir string) Option {
+	return func(c *config) {
+		c.logDir = dir
+	}
+}
+
+// WithLogRotation sets when the log files of WithLogDir are rotated: before
+// a write would take one past maxSize bytes (10 MiB if 0), and on the first
+// write after it has been open for maxAge (never if 0). A rotated file is renamed with the suffix
+// .1, shifting older ones up, and only the newest maxFiles (5 if 0) are
+// kept. Overridden by PSI_LOG_MAX_SIZE (e.g. 50M), PSI_LOG_MAX_FILES and
+// PSI_LOG_MAX_AGE (e.g. 24h).
+func WithLogRotation(maxSize int64, maxFiles int, maxAge time.Duration) Option {
+	return func(c *config) {
+		c.logMaxSize, c.logMaxFiles, c.logMaxAge = maxSize, maxFiles, maxAge
+	}
+}
+
+// WithLogFilesOnly makes the child's output go to the files of WithLogDir
+// only, not to the init's stdout and stderr. Overridden by
+// PSI_LOG_FILES_ONLY.
+func WithLogFilesOnly() Option {
+	return func(c *config) {
+		c.logFilesOnly = true
+	}
+}
+
+// loadLogFilesEnv applies the PSI_LOG_DIR, PSI_LOG_MAX_* and
+// PSI_LOG_FILES_ONLY overrides.
+func (c *config) loadLogFilesEnv() {
+	if val := strings.TrimSpace(os.Getenv(logDirEnv)); val != "" {
+		c.logDir = val
+	}
+	if val := strings.TrimSpace(os.Getenv(logMaxSizeEnv)); val != "" {
+		n, err := parseSize(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", logMaxSizeEnv, val, err)
+		} else {
+			c.logMaxSize = n
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(logMaxFilesEnv)); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			log.Printf("psi: invalid %s=%q; ignoring", logMaxFilesEnv, val)
+		} else {
+			c.logMaxFiles = n
+		}
+	}
+	envDuration(logMaxAgeEnv, &c.logMaxAge)
+	envBool(logFilesOnlyEnv, &c.logFilesOnly)
+}
+
+// attachLogFiles adds the log files to the child's output.
+func (s *supervisor) attachLogFiles() {
+	if s.cfg.logDir == "" {
+		return
+	}
+	maxSize, maxFiles := s.cfg.logMaxSize, s.cfg.logMaxFiles
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultLogMaxFiles
+	}
+	for _, f := range []struct {
+		stream *outputStream
+		name   string
+	}{{&s.output.stdout, "stdout.log"}, {&s.output.stderr, "stderr.log"}} {
+		rf := &rotatingFile{
+			path:     filepath.Join(s.cfg.logDir, f.name),
+			maxSize:  maxSize,
+			maxFiles: maxFiles,
+			maxAge:   s.cfg.logMaxAge,
+		}
+		f.stream.sinks = append(f.stream.sinks, rf)
+		f.stream.drop = s.cfg.logFilesOnly
+	}
+}
+
+// rotatingFile is a log file rotated by size and age. It is opened, and its
+// directory created, on the first write. Write errors are logged once and
+// otherwise dropped so the child never blocks on them.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	maxAge   time.Duration
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	opened  time.Time
+	failing bool
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.write(p); err != nil {
+		if !r.failing {
+			log.Printf("psi: log file %s: %v", r.path, err)
+		}
+		r.failing = true
+	} else {
+		r.failing = false
+	}
+	return len(p), nil
+}
+
+func (r *rotatingFile) write(p []byte) error {
+	if r.f != nil && r.size > 0 && (r.size+int64(len(p)) > r.maxSize ||
+		r.maxAge > 0 && time.Since(r.opened) >= r.maxAge) {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return err
+}
+
+// open opens the file for appending, creating it and its directory.
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, st.Size(), time.Now()
+	return nil
+}
+
+// rotate closes the file and shifts it and the older ones up a suffix,
+// removing the oldest.
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	return os.Rename(r.path, r.path+".1")
+}
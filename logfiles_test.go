@@ -0,0 +1,97 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "stdout.log")
+	r := &rotatingFile{path: path, maxSize: 10, maxFiles: 2}
+	for i := range 4 {
+		fmt.Fprintf(r, "line %d\n", i)
+	}
+	if got := readFile(t, path); got != "line 3\n" {
+		t.Fatalf("current = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "line 2\n" {
+		t.Fatalf(".1 = %q", got)
+	}
+	if got := readFile(t, path+".2"); got != "line 1\n" {
+		t.Fatalf(".2 = %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf(".3 kept: %v", err)
+	}
+	// A write larger than maxSize still lands, in a file of its own.
+	fmt.Fprint(r, strings.Repeat("x", 20))
+	if got := readFile(t, path); got != strings.Repeat("x", 20) {
+		t.Fatalf("current = %q", got)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stderr.log")
+	r := &rotatingFile{path: path, maxSize: 1 << 20, maxFiles: 1, maxAge: 50 * time.Millisecond}
+	fmt.Fprint(r, "old\n")
+	fmt.Fprint(r, "still\n")
+	time.Sleep(60 * time.Millisecond)
+	fmt.Fprint(r, "new\n")
+	if got := readFile(t, path); got != "new\n" {
+		t.Fatalf("current = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "old\nstill\n" {
+		t.Fatalf(".1 = %q", got)
+	}
+}
+
+func TestLogFilesEnv(t *testing.T) {
+	t.Setenv(logDirEnv, "/var/log/app")
+	t.Setenv(logMaxSizeEnv, "50M")
+	t.Setenv(logMaxFilesEnv, "3")
+	t.Setenv(logMaxAgeEnv, "24h")
+	t.Setenv(logFilesOnlyEnv, "1")
+	c := newConfig(WithLogDir("/x"), WithLogRotation(1, 1, time.Second))
+	if c.logDir != "/var/log/app" || c.logMaxSize != 50<<20 || c.logMaxFiles != 3 || c.logMaxAge != 24*time.Hour || !c.logFilesOnly {
+		t.Fatalf("log files = %q %d %d %s %t", c.logDir, c.logMaxSize, c.logMaxFiles, c.logMaxAge, c.logFilesOnly)
+	}
+	t.Setenv(logMaxFilesEnv, "0")
+	t.Setenv(logMaxSizeEnv, "big")
+	if c := newConfig(WithLogRotation(1, 1, 0)); c.logMaxFiles != 1 || c.logMaxSize != 1 {
+		t.Fatalf("invalid overrides applied: %d %d", c.logMaxFiles, c.logMaxSize)
+	}
+}
+
+func TestSupervisorLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	cmd := helperCommand("init-stderr", logDirEnv+"="+dir, logFilesOnlyEnv+"=1")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	if got := readFile(t, filepath.Join(dir, "stderr.log")); got != "starting\npanic: boom\n" {
+		t.Fatalf("stderr.log = %q", got)
+	}
+	if strings.Contains(stderr.String(), "panic: boom") {
+		t.Fatalf("child stderr forwarded despite %s:\n%s", logFilesOnlyEnv, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stdout.log")); !os.IsNotExist(err) {
+		t.Fatalf("stdout.log created without output: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package psi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logFormatEnv = "PSI_LOG_FORMAT"
+
+// LogFormat selects how the init writes its log.
+type LogFormat string
+
+const (
+	// LogText writes the init's messages as plain "psi: ..." lines.
+	LogText LogFormat = "text"
+	// LogJSON writes one JSON object per line: every message becomes a
+	// record with "time", "level" and "msg", and every lifecycle event (see
+	// Events) a record with "event" and, as relevant, "pid", "signal",
+	// "exit_code", "timeout" and the child's exit summary (see ExitSummary).
+	LogJSON LogFormat = "json"
+)
+
+// WithLogFormat selects the init's log format; the default is LogText.
+// LogJSON suits log pipelines, e.g. to alert on "event":"forced_kill". Only
+// the init's own log is affected, not the child's output (see
+// WithLogWrapJSON). Overridden by PSI_LOG_FORMAT (text or json).
+func WithLogFormat(f LogFormat) Option {
+	return func(c *config) {
+		c.logFormat = f
+	}
+}
+
+// loadLogFormatEnv applies the PSI_LOG_FORMAT override.
+func (c *config) loadLogFormatEnv() {
+	val := strings.ToLower(strings.TrimSpace(os.Getenv(logFormatEnv)))
+	switch LogFormat(val) {
+	case "":
+	case LogText, LogJSON:
+		c.logFormat = LogFormat(val)
+	default:
+		log.Printf("psi: invalid %s=%q: want text or json; ignoring", logFormatEnv, val)
+	}
+}
+
+// setupLogging switches the init's log to the configured level and format.
+func (c *config) setupLogging() {
+	initLog.level.Store(int32(c.initLogLevel()))
+	initLog.redact = c.newRedactor()
+	switch {
+	case c.logger != nil:
+		initLog.sink = loggerLog{c.logger}
+	case c.logFormat == LogJSON:
+		initLog.sink = &jsonLog{w: os.Stderr}
+	default:
+		initLog.sink = textLog{log.New(log.Writer(), log.Prefix(), log.Flags())}
+	}
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(sinkWriter{})
+	c.eventHandlers = append(c.eventHandlers, logEvent)
+}
+
+// logEvent logs a lifecycle event.
+func logEvent(e Event) {
+	if level, msg, ok := eventMessage(e); ok && logEnabled(level) {
+		initLog.sink.event(level, msg, e)
+	}
+}
+
+// jsonRecord is a line of the JSON log.
+type jsonRecord struct {
+	Time     string    `json:"time"`
+	Level    string    `json:"level"`
+	Msg      string    `json:"msg"`
+	Event    EventType `json:"event,omitempty"`
+	PID      int       `json:"pid,omitempty"`
+	Signal   string    `json:"signal,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Timeout  string    `json:"timeout,omitempty"`
+	Orphan   bool      `json:"orphan,omitempty"`
+	// The child's exit summary.
+	UptimeSeconds  float64 `json:"uptime_seconds,omitempty"`
+	UserCPUSeconds float64 `json:"user_cpu_seconds,omitempty"`
+	SysCPUSeconds  float64 `json:"sys_cpu_seconds,omitempty"`
+	MaxRSSBytes    int64   `json:"max_rss_bytes,omitempty"`
+	OrphansReaped  int     `json:"orphans_reaped,omitempty"`
+}
+
+// jsonLog writes the init's messages and lifecycle events as JSON records.
+type jsonLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (jl *jsonLog) message(level LogLevel, msg string) {
+	jl.write(jsonRecord{Time: time.Now().Format(time.RFC3339Nano), Level: level.String(), Msg: msg})
+}
+
+// fields writes a record with the key/value pairs after "msg".
+func (jl *jsonLog) fields(level LogLevel, msg string, kv []any) {
+	line, err := json.Marshal(jsonRecord{Time: time.Now().Format(time.RFC3339Nano), Level: level.String(), Msg: msg})
+	if err != nil {
+		return
+	}
+	line = line[:len(line)-1]
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, _ := json.Marshal(fmt.Sprint(kv[i]))
+		val, err := json.Marshal(kv[i+1])
+		if err != nil {
+			continue
+		}
+		line = append(append(append(append(line, ','), key...), ':'), val...)
+	}
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.w.Write(append(line, '}', '\n'))
+}
+
+func (jl *jsonLog) event(level LogLevel, msg string, e Event) {
+	rec := jsonRecord{
+		Time:  e.Time.Format(time.RFC3339Nano),
+		Level: level.String(),
+		Msg:   msg,
+		Event: e.Type,
+		PID:   e.PID,
+	}
+	if e.Signal != 0 {
+		rec.Signal = signalName(e.Signal)
+	}
+	if e.Timeout > 0 {
+		rec.Timeout = e.Timeout.String()
+	}
+	if e.Type == EventChildExited || e.Type == EventReaped {
+		code := e.ExitCode
+		rec.ExitCode = &code
+	}
+	rec.Orphan = e.Orphan
+	if sum := e.Exit; sum != nil {
+		rec.UptimeSeconds = sum.Uptime.Seconds()
+		rec.UserCPUSeconds, rec.SysCPUSeconds = sum.UserCPU.Seconds(), sum.SystemCPU.Seconds()
+		rec.MaxRSSBytes, rec.OrphansReaped = sum.MaxRSS, sum.OrphansReaped
+	}
+	jl.write(rec)
+}
+
+// eventMessage returns the level and message e is logged with, or false
+// if it is not worth logging.
+func eventMessage(e Event) (level LogLevel, msg string, ok bool) {
+	if e.Signal == sigPreempt {
+		// Go's runtime preempts goroutines with SIGURG.
+		return 0, "", false
+	}
+	level = LogInfo
+	if e.Type == EventForcedKill {
+		level = LogWarn
+	}
+	if e.Exit != nil {
+		return level, e.Exit.String(), true
+	}
+	return level, strings.ReplaceAll(string(e.Type), "_", " "), true
+}
+
+func (jl *jsonLog) write(rec jsonRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
+	jl.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLogFormatEnv(t *testing.T) {
+	if c := newConfig(); c.logFormat != "" {
+		t.Fatalf("default format = %q", c.logFormat)
+	}
+	t.Setenv(logFormatEnv, " JSON ")
+	if c := newConfig(WithLogFormat(LogText)); c.logFormat != LogJSON {
+		t.Fatalf("PSI_LOG_FORMAT=json must override the option, got %q", c.logFormat)
+	}
+	t.Setenv(logFormatEnv, "xml")
+	if c := newConfig(WithLogFormat(LogJSON)); c.logFormat != LogJSON {
+		t.Fatalf("invalid PSI_LOG_FORMAT must be ignored, got %q", c.logFormat)
+	}
+}
+
+func TestJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	jl := &jsonLog{w: &buf}
+	useLog(t, jl, LogInfo)
+	logMessage(LogWarn, "child (pid 42) exited")
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	logEvent(Event{Type: EventForcedKill, Time: at, PID: 42, Signal: syscall.SIGKILL})
+	logEvent(Event{Type: EventSignalReceived, Time: at, Signal: syscall.SIGURG})
+	logEvent(Event{Type: EventChildExited, Time: at, PID: 42})
+	logEvent(Event{Type: EventStopTimerArmed, Time: at, PID: 42, Timeout: 30 * time.Second})
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d records:\n%s", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["msg"] != "child (pid 42) exited" || rec["level"] != "warn" || rec["time"] == "" {
+		t.Fatalf("message record = %s", lines[0])
+	}
+	for i, want := range []string{
+		`{"time":"2026-01-02T03:04:05Z","level":"warn","msg":"forced kill","event":"forced_kill","pid":42,"signal":"SIGKILL"}`,
+		`{"time":"2026-01-02T03:04:05Z","level":"info","msg":"child exited","event":"child_exited","pid":42,"exit_code":0}`,
+		`{"time":"2026-01-02T03:04:05Z","level":"info","msg":"stop timer armed","event":"stop_timer_armed","pid":42,"timeout":"30s"}`,
+	} {
+		if lines[i+1] != want {
+			t.Fatalf("record %d = %s, want %s", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestSupervisorJSONLog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-events", helperCountEnv+"="+countFile, logFormatEnv+"=json")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile+".child", "running\n")
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		var rec jsonRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("not a JSON record: %q", line)
+		}
+		if rec.Event != "" {
+			events = append(events, string(rec.Event)+" "+rec.Signal)
+		}
+	}
+	want := "child_started |child_ready |signal_received SIGTERM|stop_timer_armed |signal_forwarded SIGTERM|reaped |child_exited "
+	if got := strings.Join(events, "|"); got != want {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
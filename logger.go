@@ -0,0 +1,75 @@
+package psi
+
+// Logger receives the init's log messages. Its methods take key/value pairs
+// after the message, so logport loggers (logport.ForLoggingMinimalSubset)
+// and thin wrappers around slog.Logger satisfy it.
+type Logger interface {
+	Debug(msg string, keyvals ...any)
+	Info(msg string, keyvals ...any)
+	Warn(msg string, keyvals ...any)
+	Error(msg string, keyvals ...any)
+}
+
+// WithLogger sends the init's messages and lifecycle events (see Events) to
+// l instead of the standard logger, so they share the application's sink,
+// level filtering and format: messages are passed at their level (see
+// WithLogLevel, which defaults to LogDebug here) and events carry "event",
+// "pid", "signal", "exit_code", "timeout" and the exit summary (see
+// ExitSummary) as relevant. It takes
+// precedence over WithLogFormat and PSI_LOG_FORMAT. Only the init uses l,
+// not the child.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// loggerLog passes the init's messages and lifecycle events to a Logger.
+type loggerLog struct {
+	l Logger
+}
+
+func (ll loggerLog) message(level LogLevel, msg string) {
+	ll.write(level, msg)
+}
+
+func (ll loggerLog) fields(level LogLevel, msg string, kv []any) {
+	ll.write(level, msg, kv...)
+}
+
+func (ll loggerLog) write(level LogLevel, msg string, keyvals ...any) {
+	switch level {
+	case LogDebug:
+		ll.l.Debug(msg, keyvals...)
+	case LogInfo:
+		ll.l.Info(msg, keyvals...)
+	case LogWarn:
+		ll.l.Warn(msg, keyvals...)
+	default:
+		ll.l.Error(msg, keyvals...)
+	}
+}
+
+func (ll loggerLog) event(level LogLevel, msg string, e Event) {
+	kv := []any{"event", string(e.Type)}
+	if e.PID != 0 {
+		kv = append(kv, "pid", e.PID)
+	}
+	if e.Signal != 0 {
+		kv = append(kv, "signal", signalName(e.Signal))
+	}
+	if e.Type == EventChildExited || e.Type == EventReaped {
+		kv = append(kv, "exit_code", e.ExitCode)
+	}
+	if e.Orphan {
+		kv = append(kv, "orphan", true)
+	}
+	if e.Timeout > 0 {
+		kv = append(kv, "timeout", e.Timeout.String())
+	}
+	if sum := e.Exit; sum != nil {
+		kv = append(kv, "uptime", sum.Uptime, "user_cpu", sum.UserCPU, "sys_cpu", sum.SystemCPU,
+			"max_rss_bytes", sum.MaxRSS, "orphans_reaped", sum.OrphansReaped)
+	}
+	ll.write(level, msg, kv...)
+}
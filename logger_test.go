@@ -0,0 +1,65 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"pkt.systems/logport/adapters/psl"
+)
+
+// logport loggers are Loggers.
+var _ Logger = psl.New(io.Discard)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) record(level, msg string, keyvals []any) {
+	r.lines = append(r.lines, strings.TrimSpace(level+" "+msg+" "+fmt.Sprintln(keyvals...)))
+}
+
+func (r *recordingLogger) Debug(msg string, keyvals ...any) { r.record("debug", msg, keyvals) }
+func (r *recordingLogger) Info(msg string, keyvals ...any)  { r.record("info", msg, keyvals) }
+func (r *recordingLogger) Warn(msg string, keyvals ...any)  { r.record("warn", msg, keyvals) }
+func (r *recordingLogger) Error(msg string, keyvals ...any) { r.record("error", msg, keyvals) }
+
+func TestLogger(t *testing.T) {
+	rl := &recordingLogger{}
+	useLog(t, loggerLog{rl}, LogDebug)
+	c := newConfig(WithVerbosity(1))
+	log.New(sinkWriter{}, "", 0).Printf("psi: child (pid %d) exited", 42)
+	c.debugf(1, "restarting in %s", time.Second)
+	c.debugf(2, "tracing")
+	logEvent(Event{Type: EventForcedKill, PID: 42, Signal: syscall.SIGKILL})
+	logEvent(Event{Type: EventSignalForwarded, PID: 42, Signal: syscall.SIGURG})
+	logEvent(Event{Type: EventReaped, PID: 43, ExitCode: 1})
+	want := []string{
+		"warn child (pid 42) exited",
+		"info restarting in 1s",
+		"debug tracing",
+		"warn forced kill event forced_kill pid 42 signal SIGKILL",
+		"info reaped event reaped pid 43 exit_code 1",
+	}
+	if strings.Join(rl.lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("logged:\n%s\nwant:\n%s", strings.Join(rl.lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	if l := newConfig(WithLogger(&recordingLogger{})).initLogLevel(); l != LogDebug {
+		t.Fatalf("a Logger must receive debug messages by default, level = %v", l)
+	}
+	if l := newConfig(WithLogger(&recordingLogger{}), WithLogLevel(LogWarn)).initLogLevel(); l != LogWarn {
+		t.Fatalf("level = %v, want warn", l)
+	}
+	if l := newConfig().initLogLevel(); l != LogInfo {
+		t.Fatalf("default level = %v, want info", l)
+	}
+}
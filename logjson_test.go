@@ -0,0 +1,60 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineJSON(t *testing.T) {
+	wrap := lineJSON("web", "stderr")
+	var rec outputRecord
+	if err := json.Unmarshal(wrap([]byte(`plain "text" <b>`)), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Stream != "stderr" || rec.App != "web" || rec.Msg != `plain "text" <b>` {
+		t.Fatalf("record = %+v", rec)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, rec.TS); err != nil {
+		t.Fatalf("ts %q: %v", rec.TS, err)
+	}
+	for _, line := range []string{`{"level":"info","msg":"hi"}`, ` {"a":1} `} {
+		if got := string(wrap([]byte(line))); got != line {
+			t.Errorf("JSON line rewritten: %q", got)
+		}
+	}
+	for _, line := range []string{`{not json}`, `[1,2]`, `{`} {
+		if got := wrap([]byte(line)); !strings.HasPrefix(string(got), `{"ts":`) {
+			t.Errorf("%q passed through: %q", line, got)
+		}
+	}
+}
+
+func TestSupervisorWrapsLinesInJSON(t *testing.T) {
+	cmd := helperCommand("init-prefixed", logWrapJSONEnv+"=1", logTimestampsEnv+"=1")
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	want := map[string]outputRecord{
+		"out":      {Stream: "stdout", App: "app"},
+		"proxy up": {Stream: "stdout", App: "proxy"},
+		"err":      {Stream: "stderr", App: "app"},
+	}
+	for _, line := range strings.Split(stdout.String()+stderr.String(), "\n") {
+		var rec outputRecord
+		if json.Unmarshal([]byte(line), &rec) != nil {
+			continue
+		}
+		if w, ok := want[rec.Msg]; ok && w.Stream == rec.Stream && w.App == rec.App {
+			delete(want, rec.Msg)
+		}
+	}
+	if len(want) > 0 {
+		t.Fatalf("records missing for %v in:\n%s%s", want, stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,194 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const logLevelEnv = "PSI_LOG_LEVEL"
+
+// LogLevel is the minimum severity of the init's log messages.
+type LogLevel int
+
+const (
+	// LogDebug adds the verbose messages regardless of WithVerbosity and
+	// traces every signal received (by number), wait4 result and timer.
+	LogDebug LogLevel = iota - 1
+	// LogInfo logs the lifecycle events (in the JSON format or to a
+	// Logger) and the verbose messages enabled by WithVerbosity.
+	LogInfo
+	// LogWarn logs the init's messages about anything unusual, e.g. a
+	// child restart, an invalid setting or a forced kill.
+	LogWarn
+	// LogError only logs the errors that make the init exit.
+	LogError
+	// LogOff silences the init.
+	LogOff
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l LogLevel) String() string {
+	if i := int(l - LogDebug); i >= 0 && i < len(logLevelNames) {
+		return logLevelNames[i]
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// parseLogLevel parses a level name; "warning" is accepted for warn.
+func parseLogLevel(s string) (LogLevel, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warning" {
+		s = "warn"
+	}
+	for i, name := range logLevelNames {
+		if s == name {
+			return LogDebug + LogLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// WithLogLevel sets the minimum level of the init's messages; the default is
+// LogInfo, or LogDebug with WithLogger so that the Logger's own level
+// applies. Overridden by PSI_LOG_LEVEL (debug, info, warn, error or off).
+func WithLogLevel(l LogLevel) Option {
+	return func(c *config) {
+		c.logLevel = &l
+	}
+}
+
+// loadLogLevelEnv applies the PSI_LOG_LEVEL override.
+func (c *config) loadLogLevelEnv() {
+	val := strings.TrimSpace(os.Getenv(logLevelEnv))
+	if val == "" {
+		return
+	}
+	l, err := parseLogLevel(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", logLevelEnv, val, err)
+		return
+	}
+	c.logLevel = &l
+}
+
+// initLogLevel returns the configured level or the default.
+func (c *config) initLogLevel() LogLevel {
+	switch {
+	case c.logLevel != nil:
+		return *c.logLevel
+	case c.logger != nil:
+		return LogDebug
+	}
+	return LogInfo
+}
+
+// logSink writes the init's messages and lifecycle events in a format.
+type logSink interface {
+	message(level LogLevel, msg string)
+	fields(level LogLevel, msg string, kv []any)
+	event(level LogLevel, msg string, e Event)
+}
+
+// initLog is the init's log, set up by setupLogging.
+var initLog struct {
+	level atomic.Int32
+	sink  logSink
+	// redact masks secrets in the messages.
+	redact *redactor
+}
+
+// logEnabled reports whether messages at level are logged.
+func logEnabled(level LogLevel) bool {
+	return level < LogOff && level >= LogLevel(initLog.level.Load())
+}
+
+// logMessage logs msg at level. Before setupLogging (and in the child) it
+// goes to the standard logger.
+func logMessage(level LogLevel, msg string) {
+	if !logEnabled(level) {
+		return
+	}
+	msg = initLog.redact.string(msg)
+	if initLog.sink == nil {
+		log.Print("psi: " + msg)
+		return
+	}
+	initLog.sink.message(level, msg)
+}
+
+// logFields logs msg at level with key/value pairs, e.g. as "key=value"
+// after the text message or as fields of the JSON record.
+func logFields(level LogLevel, msg string, kv ...any) {
+	if !logEnabled(level) {
+		return
+	}
+	if initLog.sink == nil {
+		log.Print("psi: " + msg + " " + formatFields(kv))
+		return
+	}
+	initLog.sink.fields(level, msg, kv)
+}
+
+// formatFields formats key/value pairs as "key=value ...", quoting values
+// that need it.
+func formatFields(kv []any) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		val := fmt.Sprint(kv[i+1])
+		if val == "" || strings.ContainsAny(val, " \t\"=") {
+			val = strconv.Quote(val)
+		}
+		fmt.Fprintf(&b, "%v=%s", kv[i], val)
+	}
+	return b.String()
+}
+
+// tracef logs a debug trace line.
+func tracef(format string, args ...any) {
+	if logEnabled(LogDebug) {
+		logMessage(LogDebug, fmt.Sprintf(format, args...))
+	}
+}
+
+// fatalf logs an error that ends psi and exits with ExitInternalError.
+func fatalf(format string, args ...any) {
+	logMessage(LogError, fmt.Sprintf(format, args...))
+	os.Exit(ExitInternalError)
+}
+
+// sinkWriter passes the standard logger's messages to the init's log as
+// warnings.
+type sinkWriter struct{}
+
+func (sinkWriter) Write(p []byte) (int, error) {
+	logMessage(LogWarn, strings.TrimPrefix(strings.TrimSuffix(string(p), "\n"), "psi: "))
+	return len(p), nil
+}
+
+// textLog writes "psi: ..." lines like the standard logger; of the lifecycle
+// events it only logs the child's exit summary.
+type textLog struct {
+	l *log.Logger
+}
+
+func (t textLog) message(_ LogLevel, msg string) {
+	t.l.Print("psi: " + msg)
+}
+
+func (t textLog) fields(_ LogLevel, msg string, kv []any) {
+	t.l.Print("psi: " + msg + " " + formatFields(kv))
+}
+
+func (t textLog) event(_ LogLevel, _ string, e Event) {
+	if e.Exit != nil {
+		t.l.Print("psi: " + e.Exit.String())
+	}
+}
@@ -0,0 +1,104 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// useLog makes sink the init's log at level for the duration of the test.
+func useLog(t *testing.T, sink logSink, level LogLevel) {
+	t.Helper()
+	prevSink, prevLevel := initLog.sink, initLog.level.Load()
+	initLog.sink = sink
+	initLog.level.Store(int32(level))
+	t.Cleanup(func() {
+		initLog.sink = prevSink
+		initLog.level.Store(prevLevel)
+	})
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for in, want := range map[string]LogLevel{"debug": LogDebug, " INFO ": LogInfo, "warn": LogWarn, "warning": LogWarn, "error": LogError, "off": LogOff} {
+		got, err := parseLogLevel(in)
+		if err != nil || got != want {
+			t.Fatalf("parseLogLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+		if strings.TrimSpace(strings.ToLower(in)) != "warning" && got.String() != strings.TrimSpace(strings.ToLower(in)) {
+			t.Fatalf("%v.String() = %q", got, got.String())
+		}
+	}
+	if _, err := parseLogLevel("trace"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
+
+func TestLogLevelEnv(t *testing.T) {
+	if c := newConfig(); c.logLevel != nil {
+		t.Fatalf("default level = %v", *c.logLevel)
+	}
+	t.Setenv(logLevelEnv, "error")
+	if c := newConfig(WithLogLevel(LogDebug)); c.logLevel == nil || *c.logLevel != LogError {
+		t.Fatal("PSI_LOG_LEVEL must override the option")
+	}
+	t.Setenv(logLevelEnv, "loud")
+	if c := newConfig(WithLogLevel(LogDebug)); *c.logLevel != LogDebug {
+		t.Fatalf("invalid PSI_LOG_LEVEL must be ignored, got %v", *c.logLevel)
+	}
+}
+
+func TestLogLevelFilter(t *testing.T) {
+	var buf strings.Builder
+	for _, tc := range []struct {
+		level LogLevel
+		want  string
+	}{
+		{LogDebug, "psi: trace\npsi: verbose\npsi: warning\npsi: error\n"},
+		{LogInfo, "psi: verbose\npsi: warning\npsi: error\n"},
+		{LogWarn, "psi: warning\npsi: error\n"},
+		{LogError, "psi: error\n"},
+		{LogOff, ""},
+	} {
+		buf.Reset()
+		useLog(t, textLog{log.New(&buf, "", 0)}, tc.level)
+		c := newConfig(WithVerbosity(1))
+		tracef("trace")
+		c.debugf(1, "verbose")
+		log.New(sinkWriter{}, "", 0).Print("psi: warning")
+		logMessage(LogError, "error")
+		if buf.String() != tc.want {
+			t.Fatalf("level %v logged %q, want %q", tc.level, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestSupervisorDebugLog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-events", helperCountEnv+"="+countFile, logLevelEnv+"=debug")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile+".child", "running\n")
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	for _, want := range []string{"psi: received signal 15 (SIGTERM)", "psi: stop timer armed for 30s", "psi: wait4: pid "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("debug log lacks %q:\n%s", want, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	logPrefixEnv = "PSI_LOG_PREFIX"
+	childNameEnv = "PSI_CHILD_NAME"
+)
+
+// WithLogPrefix sets whether each line of output the init forwards is
+// prefixed with the process's name and stream, as in
+// "[app/stderr] listening on :8080", so the child and sidecars sharing the
+// container's stdout and stderr can be told apart. By default lines are
+// prefixed when sidecars are declared. Lines are forwarded whole; one
+// longer than 64 KiB is split. Overridden by PSI_LOG_PREFIX (1, 0 or auto).
+func WithLogPrefix(on bool) Option {
+	return func(c *config) {
+		c.logPrefix = &on
+	}
+}
+
+// WithChildName names the child in line prefixes (see WithLogPrefix). The
+// default is the base name of the program: the external program, or this
+// binary for a Go submain. Overridden by PSI_CHILD_NAME.
+func WithChildName(name string) Option {
+	return func(c *config) {
+		c.childName = name
+	}
+}
+
+// loadLogPrefixEnv applies the PSI_LOG_PREFIX and PSI_CHILD_NAME overrides.
+func (c *config) loadLogPrefixEnv() {
+	if val := strings.TrimSpace(os.Getenv(logPrefixEnv)); val != "" {
+		if strings.EqualFold(val, "auto") {
+			c.logPrefix = nil
+		} else if on, err := parseBool(val); err != nil {
+			log.Printf("psi: invalid %s=%q: want 1, 0 or auto; ignoring", logPrefixEnv, val)
+		} else {
+			c.logPrefix = &on
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(childNameEnv)); val != "" {
+		c.childName = val
+	}
+}
+
+// prefixLines reports whether forwarded lines are prefixed.
+func (c *config) prefixLines() bool {
+	if c.logPrefix != nil {
+		return *c.logPrefix
+	}
+	return len(c.sidecars) > 0
+}
+
+// mainChildName returns the child's name for line prefixes.
+func (c *config) mainChildName() string {
+	if c.childName != "" {
+		return c.childName
+	}
+	argv := c.command
+	if len(argv) == 0 {
+		argv = os.Args
+	}
+	return filepath.Base(argv[0])
+}
+
+// linePrefix returns a filter that prefixes each line with "[name/stream] ".
+func linePrefix(name, stream string) lineFilter {
+	prefix := "[" + name + "/" + stream + "] "
+	return func(line []byte) []byte {
+		return append([]byte(prefix), line...)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var out bytes.Buffer
+	l := &lineWriter{dst: &out, filters: []lineFilter{linePrefix("app", "stdout")}}
+	fmt.Fprint(l, "one\ntw")
+	fmt.Fprint(l, "o\n\nthr")
+	if got := out.String(); got != "[app/stdout] one\n[app/stdout] two\n[app/stdout] \n" {
+		t.Fatalf("output = %q", got)
+	}
+	l.flush()
+	if got := out.String(); !strings.HasSuffix(got, "[app/stdout] thr\n") {
+		t.Fatalf("flushed output = %q", got)
+	}
+	out.Reset()
+	fmt.Fprint(l, strings.Repeat("x", maxLineLength+10)+"\n")
+	want := "[app/stdout] " + strings.Repeat("x", maxLineLength) + "\n[app/stdout] xxxxxxxxxx\n"
+	if got := out.String(); got != want {
+		t.Fatalf("long line split into %d bytes, want %d", len(got), len(want))
+	}
+	out.Reset()
+	fmt.Fprint(l, strings.Repeat("y", maxLineLength+5))
+	if got := out.Len(); got != len("[app/stdout] \n")+maxLineLength {
+		t.Fatalf("unterminated long line wrote %d bytes", got)
+	}
+	l.flush()
+	if got := out.String(); !strings.HasSuffix(got, "\n[app/stdout] yyyyy\n") {
+		t.Fatalf("rest of the long line = %q", got[len(got)-30:])
+	}
+}
+
+func TestLogPrefixConfig(t *testing.T) {
+	if newConfig().prefixLines() {
+		t.Fatal("lines prefixed without sidecars")
+	}
+	if !newConfig(WithSidecar(Sidecar{Name: "proxy", Path: "/bin/true"})).prefixLines() {
+		t.Fatal("lines not prefixed with sidecars")
+	}
+	t.Setenv(logPrefixEnv, "1")
+	t.Setenv(childNameEnv, "web")
+	c := newConfig(WithChildName("app"))
+	if !c.prefixLines() || c.mainChildName() != "web" {
+		t.Fatalf("prefix=%t name=%q", c.prefixLines(), c.mainChildName())
+	}
+	t.Setenv(logPrefixEnv, "auto")
+	t.Setenv(childNameEnv, "")
+	if c := newConfig(WithLogPrefix(true)); c.prefixLines() {
+		t.Fatal("auto did not reset the option")
+	}
+	c = newConfig()
+	c.command = []string{"/usr/bin/nginx", "-g", "daemon off;"}
+	if name := c.mainChildName(); name != "nginx" {
+		t.Fatalf("mainChildName = %q", name)
+	}
+}
+
+func TestSupervisorPrefixesLines(t *testing.T) {
+	cmd := helperCommand("init-prefixed")
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", exit, stderr.String())
+	}
+	for _, want := range []string{"[proxy/stdout] proxy up\n", "[app/stdout] out\n", "[app/stdout] partial\n"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("stdout lacks %q:\n%s", want, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "[app/stderr] err\n") {
+		t.Errorf("stderr lacks the prefixed line:\n%s", stderr.String())
+	}
+}
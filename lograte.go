@@ -0,0 +1,115 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const logRateEnv = "PSI_LOG_RATE"
+
+// WithLogRate limits the lines of output the init forwards for each process,
+// the child or a sidecar, to rate per second on average, in bursts of up to
+// burst lines (rate rounded up, at least 1, if 0). Lines beyond that are
+// dropped and a "psi: suppressed N lines" line is forwarded in their stead
+// ahead of the next line let through, or when the process's output ends, so
+// a crash-looping child cannot flood the log. The sinks (WithLogDir,
+// WithJournald, ...) still get every line. Overridden by PSI_LOG_RATE, e.g.
+// "100/s", "6000/m", "100/s,500" with a burst of 500, or "off".
+func WithLogRate(rate float64, burst int) Option {
+	return func(c *config) {
+		c.logRate, c.logBurst = rate, burst
+	}
+}
+
+// loadLogRateEnv applies the PSI_LOG_RATE override.
+func (c *config) loadLogRateEnv() {
+	val := strings.TrimSpace(os.Getenv(logRateEnv))
+	if val == "" {
+		return
+	}
+	rate, burst, err := parseLogRate(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", logRateEnv, val, err)
+		return
+	}
+	c.logRate, c.logBurst = rate, burst
+}
+
+// parseLogRate parses "N[/s|/m|/h][,BURST]" into lines per second and the
+// burst, or "off" or "0" into 0.
+func parseLogRate(s string) (rate float64, burst int, err error) {
+	if s == "off" || s == "0" {
+		return 0, 0, nil
+	}
+	s, b, hasBurst := strings.Cut(s, ",")
+	if hasBurst {
+		if burst, err = strconv.Atoi(strings.TrimSpace(b)); err != nil || burst <= 0 {
+			return 0, 0, fmt.Errorf("invalid burst %q", b)
+		}
+	}
+	num, unit, _ := strings.Cut(strings.TrimSpace(s), "/")
+	rate, err = strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+		return 0, 0, fmt.Errorf("invalid rate %q", num)
+	}
+	switch strings.TrimSpace(unit) {
+	case "", "s":
+	case "m":
+		rate /= 60
+	case "h":
+		rate /= 3600
+	default:
+		return 0, 0, errors.New("unit must be s, m or h")
+	}
+	return rate, burst, nil
+}
+
+// rateLimiter is a token bucket holding up to burst lines, refilled at rate
+// lines per second. It is shared by the streams of a process, each of which
+// counts the lines it suppressed itself.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a full bucket, nil if rate is not positive.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token for a line at now and reports whether it may go out.
+func (r *rateLimiter) allow(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.last.IsZero() {
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// suppressedLine is the line standing in for n suppressed ones.
+func suppressedLine(n int) []byte {
+	return fmt.Appendf(nil, "psi: suppressed %d lines", n)
+}
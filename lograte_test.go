@@ -0,0 +1,88 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogRate(t *testing.T) {
+	for in, want := range map[string]struct {
+		rate  float64
+		burst int
+	}{
+		"100":       {100, 0},
+		"100/s":     {100, 0},
+		"6000/m":    {100, 0},
+		"3600/h,10": {1, 10},
+		"0.5/s, 3":  {0.5, 3},
+		"off":       {0, 0},
+	} {
+		rate, burst, err := parseLogRate(in)
+		if err != nil || rate != want.rate || burst != want.burst {
+			t.Errorf("parseLogRate(%q) = %v, %d, %v", in, rate, burst, err)
+		}
+	}
+	for _, bad := range []string{"fast", "-1/s", "10/d", "10/s,0", "10/s,x"} {
+		if _, _, err := parseLogRate(bad); err == nil {
+			t.Errorf("parseLogRate(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(0, 10) != nil {
+		t.Fatal("limiter without a rate")
+	}
+	r := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := range 3 {
+		if !r.allow(now) {
+			t.Fatalf("line %d of the burst suppressed", i)
+		}
+	}
+	if r.allow(now) {
+		t.Fatal("line over the burst let through")
+	}
+	if !r.allow(now.Add(time.Second / 2)) {
+		t.Fatal("line suppressed after the refill")
+	}
+	if r.allow(now.Add(time.Second / 2)) {
+		t.Fatal("refill counted twice")
+	}
+	if r := newRateLimiter(0.5, 0); r.burst != 1 {
+		t.Fatalf("default burst = %v", r.burst)
+	}
+}
+
+func TestLineWriterRateLimit(t *testing.T) {
+	var out strings.Builder
+	l := &lineWriter{dst: &out, filters: []lineFilter{linePrefix("app", "stdout")}, limit: newRateLimiter(0.001, 2)}
+	for i := range 5 {
+		fmt.Fprintf(l, "line %d\n", i)
+	}
+	l.flush()
+	want := "[app/stdout] line 0\n[app/stdout] line 1\n[app/stdout] psi: suppressed 3 lines\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestSupervisorRateLimitsOutput(t *testing.T) {
+	cmd := helperCommand("init-flood", logRateEnv+"=1/s,5")
+	out, err := cmd.Output()
+	if exit := exitStatus(err); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	var suppressed int
+	if _, err := fmt.Sscanf(lines[len(lines)-1], "psi: suppressed %d lines", &suppressed); err != nil {
+		t.Fatalf("no suppression marker at the end of %q", out)
+	}
+	if len(lines) < 6 || lines[4] != "line 4" || len(lines)-1+suppressed != 200 {
+		t.Fatalf("%d lines forwarded, %d suppressed: %q", len(lines)-1, suppressed, out)
+	}
+}
@@ -0,0 +1,26 @@
+package psi
+
+import "time"
+
+const logTimestampsEnv = "PSI_LOG_TIMESTAMPS"
+
+// lineTimeFormat is RFC 3339 in UTC with milliseconds.
+const lineTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// WithLogTimestamps makes the init start each line of output it forwards
+// with the time the line was complete, in RFC 3339 UTC with milliseconds, as
+// in "2024-05-01T12:00:00.123Z listening on :8080", for programs that log
+// without timestamps. It goes before the prefix of WithLogPrefix. Overridden
+// by PSI_LOG_TIMESTAMPS.
+func WithLogTimestamps() Option {
+	return func(c *config) {
+		c.logTimestamps = true
+	}
+}
+
+// lineTimestamp is a lineFilter prepending the current time.
+func lineTimestamp(line []byte) []byte {
+	out := time.Now().UTC().AppendFormat(make([]byte, 0, len(lineTimeFormat)+1+len(line)), lineTimeFormat)
+	out = append(out, ' ')
+	return append(out, line...)
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package psi
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineTimestamp(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	got := string(lineTimestamp([]byte("hello")))
+	stamp, rest, ok := strings.Cut(got, " ")
+	if !ok || rest != "hello" {
+		t.Fatalf("line = %q", got)
+	}
+	ts, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil || !strings.HasSuffix(stamp, "Z") || len(stamp) != len("2006-01-02T15:04:05.000Z") {
+		t.Fatalf("timestamp %q: %v", stamp, err)
+	}
+	if ts.Before(before) || ts.After(time.Now()) {
+		t.Fatalf("timestamp %s not between %s and now", ts, before)
+	}
+}
+
+func TestLogTimestampsEnv(t *testing.T) {
+	t.Setenv(logTimestampsEnv, "1")
+	if !newConfig().logTimestamps {
+		t.Fatalf("%s=1 ignored", logTimestampsEnv)
+	}
+}
+
+func TestSupervisorTimestampsLines(t *testing.T) {
+	cmd := helperCommand("init-prefixed", logTimestampsEnv+"=1")
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	line := regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z \[app/stdout\] out$`)
+	if !line.MatchString(stdout.String()) {
+		t.Fatalf("no timestamped line in:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,167 @@
+package psi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsAddrEnv = "PSI_METRICS_ADDR"
+
+// stopDurationBuckets are the upper bounds, in seconds, of the
+// psi_stop_duration_seconds histogram.
+var stopDurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// WithMetricsAddr makes the init serve Prometheus metrics on addr (e.g.
+// ":9098") at /metrics:
+//
+//	psi_restarts_total                 child restarts
+//	psi_reaped_zombies_total           processes reaped, children and adopted orphans
+//	psi_orphans_reaped_total           adopted orphans reaped
+//	psi_adopted_orphans                live adopted orphans (Linux, from /proc)
+//	psi_signals_forwarded_total        signals sent to the child, by signal
+//	psi_forced_kills_total             children killed with SIGKILL
+//	psi_child_uptime_seconds           the running child's uptime
+//	psi_stop_duration_seconds          histogram of the time from the first stop
+//	                                   signal to the child's exit
+//
+// Serving only happens while supervising. Overridden by PSI_METRICS_ADDR.
+func WithMetricsAddr(addr string) Option {
+	return func(c *config) {
+		c.metricsAddr = addr
+	}
+}
+
+// loadMetricsEnv applies the PSI_METRICS_ADDR override.
+func (c *config) loadMetricsEnv() {
+	if val := strings.TrimSpace(os.Getenv(metricsAddrEnv)); val != "" {
+		c.metricsAddr = val
+	}
+}
+
+// metrics accumulates the lifecycle events served as Prometheus metrics.
+type metrics struct {
+	mu          sync.Mutex
+	reaped      uint64
+	orphans     uint64
+	forcedKills uint64
+	forwarded   map[string]uint64
+	// stopStart is when the current child was first sent a stop signal.
+	stopStart time.Time
+	// stopCounts holds the cumulative histogram counts, one per bucket.
+	stopCounts []uint64
+	stopSum    float64
+	stopCount  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		forwarded:  make(map[string]uint64),
+		stopCounts: make([]uint64, len(stopDurationBuckets)),
+	}
+}
+
+// event updates the metrics from a lifecycle event.
+func (m *metrics) event(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e.Type {
+	case EventReaped:
+		m.reaped++
+		if e.Orphan {
+			m.orphans++
+		}
+	case EventForcedKill:
+		m.forcedKills++
+	case EventSignalForwarded:
+		m.forwarded[signalName(e.Signal)]++
+	case EventStopTimerArmed:
+		if m.stopStart.IsZero() {
+			m.stopStart = e.Time
+		}
+	case EventChildExited:
+		if m.stopStart.IsZero() {
+			return
+		}
+		d := e.Time.Sub(m.stopStart).Seconds()
+		m.stopStart = time.Time{}
+		for i, le := range stopDurationBuckets {
+			if d <= le {
+				m.stopCounts[i]++
+			}
+		}
+		m.stopSum += d
+		m.stopCount++
+	}
+}
+
+// write renders the metrics in the Prometheus text format.
+// adopted is the live orphan count, negative if unknown.
+func (m *metrics) write(w io.Writer, st statusReport, adopted int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP psi_restarts_total Child restarts.\n# TYPE psi_restarts_total counter\npsi_restarts_total %d\n", st.Restarts)
+	fmt.Fprintf(w, "# HELP psi_reaped_zombies_total Processes reaped by the init.\n# TYPE psi_reaped_zombies_total counter\npsi_reaped_zombies_total %d\n", m.reaped)
+	fmt.Fprintf(w, "# HELP psi_orphans_reaped_total Adopted orphans reaped by the init.\n# TYPE psi_orphans_reaped_total counter\npsi_orphans_reaped_total %d\n", m.orphans)
+	if adopted >= 0 {
+		fmt.Fprintf(w, "# HELP psi_adopted_orphans Live orphans adopted by the init.\n# TYPE psi_adopted_orphans gauge\npsi_adopted_orphans %d\n", adopted)
+	}
+	fmt.Fprint(w, "# HELP psi_signals_forwarded_total Signals sent to the child's process group.\n# TYPE psi_signals_forwarded_total counter\n")
+	names := make([]string, 0, len(m.forwarded))
+	for name := range m.forwarded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "psi_signals_forwarded_total{signal=%q} %d\n", name, m.forwarded[name])
+	}
+	fmt.Fprintf(w, "# HELP psi_forced_kills_total Children killed with SIGKILL.\n# TYPE psi_forced_kills_total counter\npsi_forced_kills_total %d\n", m.forcedKills)
+	fmt.Fprintf(w, "# HELP psi_child_uptime_seconds Uptime of the running child.\n# TYPE psi_child_uptime_seconds gauge\npsi_child_uptime_seconds %g\n", st.UptimeSeconds)
+	fmt.Fprint(w, "# HELP psi_stop_duration_seconds Time from the first stop signal to the child's exit.\n# TYPE psi_stop_duration_seconds histogram\n")
+	for i, le := range stopDurationBuckets {
+		fmt.Fprintf(w, "psi_stop_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.stopCounts[i])
+	}
+	fmt.Fprintf(w, "psi_stop_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.stopCount)
+	fmt.Fprintf(w, "psi_stop_duration_seconds_sum %g\npsi_stop_duration_seconds_count %d\n", m.stopSum, m.stopCount)
+}
+
+// handler serves /metrics; r, if not nil, provides the adopted orphans.
+func (m *metrics) handler(st *childStatus, r *reaper) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		adopted := -1
+		if r != nil {
+			if n, err := r.adoptedOrphans(); err == nil {
+				adopted = n
+			}
+		}
+		m.write(w, st.report(time.Now()), adopted)
+	})
+	return mux
+}
+
+// serveMetrics starts the metrics endpoint if configured. Failing to listen
+// is logged and otherwise ignored.
+func (s *supervisor) serveMetrics() {
+	if s.cfg.metricsAddr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", s.cfg.metricsAddr)
+	if err != nil {
+		log.Printf("psi: metrics endpoint disabled: %v", err)
+		return
+	}
+	m := newMetrics()
+	addEventHandler(m.event)
+	s.cfg.debugf(1, "serving metrics on %s", ln.Addr())
+	srv := &http.Server{Handler: m.handler(&s.status, s.reaper), ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+}
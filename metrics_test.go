@@ -0,0 +1,64 @@
+package psi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	var st childStatus
+	st.childStarted(42, 2, 3, time.Now().Add(-time.Minute))
+	at := time.Now()
+	for _, e := range []Event{
+		{Type: EventChildExited, Time: at, PID: 41},
+		{Type: EventSignalForwarded, Time: at, PID: 42, Signal: syscall.SIGHUP},
+		{Type: EventStopTimerArmed, Time: at, PID: 42, Timeout: 2 * time.Second},
+		{Type: EventSignalForwarded, Time: at, PID: 42, Signal: syscall.SIGTERM},
+		{Type: EventStopTimerArmed, Time: at.Add(2 * time.Second), PID: 42},
+		{Type: EventForcedKill, Time: at.Add(2 * time.Second), PID: 42, Signal: syscall.SIGKILL},
+		{Type: EventSignalForwarded, Time: at.Add(2 * time.Second), PID: 42, Signal: syscall.SIGKILL},
+		{Type: EventReaped, Time: at.Add(3 * time.Second), PID: 42, ExitCode: 137},
+		{Type: EventReaped, Time: at.Add(3 * time.Second), PID: 50, Orphan: true},
+		{Type: EventChildExited, Time: at.Add(3 * time.Second), PID: 42, ExitCode: 137},
+	} {
+		m.event(e)
+	}
+	srv := httptest.NewServer(m.handler(&st, newReaper()))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		"psi_restarts_total 2\n",
+		"psi_reaped_zombies_total 2\n",
+		"psi_orphans_reaped_total 1\n",
+		"psi_signals_forwarded_total{signal=\"SIGHUP\"} 1\npsi_signals_forwarded_total{signal=\"SIGKILL\"} 1\npsi_signals_forwarded_total{signal=\"SIGTERM\"} 1\n",
+		"psi_forced_kills_total 1\n",
+		"psi_child_uptime_seconds 6",
+		"psi_stop_duration_seconds_bucket{le=\"2.5\"} 0\npsi_stop_duration_seconds_bucket{le=\"5\"} 1\n",
+		"psi_stop_duration_seconds_bucket{le=\"+Inf\"} 1\npsi_stop_duration_seconds_sum 3\npsi_stop_duration_seconds_count 1\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsAddrEnv(t *testing.T) {
+	t.Setenv(metricsAddrEnv, ":9098")
+	if c := newConfig(WithMetricsAddr(":1")); c.metricsAddr != ":9098" {
+		t.Fatalf("metricsAddr = %q", c.metricsAddr)
+	}
+}
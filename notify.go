@@ -0,0 +1,169 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	watchdogEnv = "PSI_WATCHDOG"
+	// notifySocketEnv and watchdogUsecEnv are the sd_notify protocol's
+	// variables.
+	notifySocketEnv = "NOTIFY_SOCKET"
+	watchdogUsecEnv = "WATCHDOG_USEC"
+)
+
+// WithWatchdog requires the child to send sd_notify WATCHDOG=1 keepalives
+// (see Watchdog) at least every d once it has started; the child finds d in
+// WATCHDOG_USEC. A child that misses the deadline or sends WATCHDOG=trigger
+// is stopped like one failing its liveness probe (see WithLivenessProbe).
+// Overridden by PSI_WATCHDOG.
+func WithWatchdog(d time.Duration) Option {
+	return func(c *config) {
+		c.watchdog = d
+	}
+}
+
+// Watchdog sends a keepalive to the init's sd_notify socket. It does nothing
+// when the child has no NOTIFY_SOCKET.
+func Watchdog() error {
+	return sdNotify(os.Getenv(notifySocketEnv), "WATCHDOG=1")
+}
+
+// sdNotify sends state to the sd_notify socket addr ("@name" for the abstract
+// namespace). An empty addr is a no-op.
+func sdNotify(addr, state string) error {
+	if addr == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("psi: sd_notify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("psi: sd_notify: %w", err)
+	}
+	return nil
+}
+
+// notifyMsg is a datagram received on the init's sd_notify socket from pid.
+type notifyMsg struct {
+	pid   int
+	state string
+}
+
+// startNotify opens the sd_notify socket when the child may use it.
+func (s *supervisor) startNotify() {
+	if !s.cfg.readyNotify && s.cfg.watchdog <= 0 && s.cfg.stopExtendMax <= 0 && !s.cfg.restartRequests {
+		return
+	}
+	addr, msgs, err := listenNotify()
+	if err != nil {
+		log.Printf("psi: sd_notify socket unavailable: %v", err)
+		return
+	}
+	s.notifyAddr, s.notifyMsgs = addr, msgs
+	s.cfg.debugf(1, "listening for sd_notify messages on %s", addr)
+}
+
+// prepareNotify points the child at the sd_notify socket.
+func (s *supervisor) prepareNotify(cmd *exec.Cmd) {
+	if s.notifyAddr == "" {
+		return
+	}
+	cmd.Env = setEnv(cmd.Env, notifySocketEnv, s.notifyAddr)
+	if s.cfg.watchdog > 0 {
+		cmd.Env = setEnv(cmd.Env, watchdogUsecEnv, fmt.Sprint(s.cfg.watchdog.Microseconds()))
+	}
+}
+
+// handleNotify applies an sd_notify message from the child.
+func (s *supervisor) handleNotify(msg notifyMsg) {
+	if !s.fromChild(msg.pid) {
+		s.cfg.debugf(1, "ignoring sd_notify message from pid %d", msg.pid)
+		return
+	}
+	for _, line := range strings.Split(msg.state, "\n") {
+		key, val, _ := strings.Cut(line, "=")
+		switch key {
+		case "READY":
+			if val == "1" {
+				s.childReady()
+			}
+		case "STATUS":
+			s.status.setText(val)
+			s.saveState()
+			s.forwardStatus(val)
+			s.cfg.debugf(1, "child status: %s", val)
+		case "WATCHDOG":
+			switch val {
+			case "1":
+				s.beginWatchdog()
+			case "trigger":
+				s.stopUnhealthy("triggered its watchdog")
+			}
+		case extendTimeoutKey:
+			if d, ok := parseExtendTimeout(val); ok {
+				s.extendStop(d)
+			}
+		case restartRequestKey:
+			if val == "1" && s.cfg.restartRequests {
+				if err := s.restartChild(); err != nil {
+					log.Printf("psi: ignoring the child's restart request: %v", err)
+				}
+			}
+		case "STOPPING", "RELOADING":
+			if val == "1" {
+				s.cfg.debugf(1, "child is %s", strings.ToLower(key))
+			}
+		}
+	}
+}
+
+// fromChild reports whether pid belongs to the child's process group.
+func (s *supervisor) fromChild(pid int) bool {
+	if pid <= 0 || s.child == nil {
+		return false
+	}
+	if pid == s.childPID {
+		return true
+	}
+	pgid, err := processGroup(pid)
+	return err == nil && pgid == s.childPID
+}
+
+// beginWatchdog arms (or re-arms, on a keepalive) the child's watchdog.
+func (s *supervisor) beginWatchdog() {
+	if s.cfg.watchdog > 0 && !s.unhealthy {
+		s.watchdogDue = time.After(s.cfg.watchdog)
+		tracef("watchdog timer armed for %s", s.cfg.watchdog)
+	}
+}
+
+// watchdogExpired handles a missed watchdog deadline. A child that is still
+// starting gets another period.
+func (s *supervisor) watchdogExpired() {
+	s.watchdogDue = nil
+	if s.starting || (s.cfg.readyNotify && !s.ready) {
+		s.beginWatchdog()
+		return
+	}
+	s.stopUnhealthy(fmt.Sprintf("sent no watchdog keepalive within %s", s.cfg.watchdog))
+}
+
+// setEnv returns env with key set to val, replacing any previous value.
+func setEnv(env []string, key, val string) []string {
+	out := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if k, _, _ := strings.Cut(kv, "="); k != key {
+			out = append(out, kv)
+		}
+	}
+	return append(out, key+"="+val)
+}
@@ -0,0 +1,62 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// listenNotify opens an sd_notify socket in the abstract namespace, which
+// the child reaches regardless of chroot, mount isolation or user, and
+// delivers its messages with the sender's PID.
+func listenNotify() (string, <-chan notifyMsg, error) {
+	addr := fmt.Sprintf("@psi/notify/%d/%x", os.Getpid(), rand.Uint64())
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return "", nil, err
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return "", nil, err
+	}
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PASSCRED, 1)
+	}); err != nil || serr != nil {
+		conn.Close()
+		return "", nil, fmt.Errorf("SO_PASSCRED: %w", errors.Join(err, serr))
+	}
+	msgs := make(chan notifyMsg, 16)
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		oob := make([]byte, unix.CmsgSpace(unix.SizeofUcred))
+		for {
+			n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+			if err != nil {
+				return
+			}
+			msgs <- notifyMsg{pid: senderPID(oob[:oobn]), state: string(buf[:n])}
+		}
+	}()
+	return addr, msgs, nil
+}
+
+// senderPID extracts the sender's PID from SCM_CREDENTIALS, or returns 0.
+func senderPID(oob []byte) int {
+	cmsgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for i := range cmsgs {
+		if cred, err := unix.ParseUnixCredentials(&cmsgs[i]); err == nil {
+			return int(cred.Pid)
+		}
+	}
+	return 0
+}
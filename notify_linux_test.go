@@ -0,0 +1,28 @@
+package psi
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNotifySocketRoundTrip(t *testing.T) {
+	addr, msgs, err := listenNotify()
+	if err != nil {
+		t.Fatalf("listenNotify: %v", err)
+	}
+	if addr[0] != '@' {
+		t.Fatalf("expected an abstract socket, got %q", addr)
+	}
+	if err := sdNotify(addr, "READY=1"); err != nil {
+		t.Fatalf("sdNotify: %v", err)
+	}
+	select {
+	case msg := <-msgs:
+		if msg.state != "READY=1" || msg.pid != os.Getpid() {
+			t.Fatalf("got %+v, want READY=1 from pid %d", msg, os.Getpid())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func listenNotify() (string, <-chan notifyMsg, error) {
+	return "", nil, errors.New("the sd_notify socket is only supported on Linux")
+}
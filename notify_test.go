@@ -0,0 +1,37 @@
+package psi
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSetEnv(t *testing.T) {
+	got := setEnv([]string{"A=1", "NOTIFY_SOCKET=/run/systemd/notify", "B=2"}, notifySocketEnv, "@psi")
+	if want := []string{"A=1", "B=2", "NOTIFY_SOCKET=@psi"}; !slices.Equal(got, want) {
+		t.Fatalf("setEnv = %q, want %q", got, want)
+	}
+}
+
+func TestHandleNotify(t *testing.T) {
+	s := newSupervisor(newConfig(WithReadyNotify(), WithWatchdog(time.Hour)))
+	s.child = &procHandle{pid: os.Getpid(), pidfd: -1}
+	s.childPID = os.Getpid()
+	s.status.childStarted(s.childPID, 0, 1, time.Now())
+
+	s.handleNotify(notifyMsg{pid: 1, state: "READY=1"})
+	if s.ready {
+		t.Fatal("accepted READY=1 from outside the child's process group")
+	}
+	s.handleNotify(notifyMsg{pid: os.Getpid(), state: "STATUS=warming up\nREADY=1\nWATCHDOG=1"})
+	if !s.ready {
+		t.Fatal("READY=1 from the child not applied")
+	}
+	if r := s.status.report(time.Now()); r.Status != "warming up" || !r.Ready {
+		t.Fatalf("status = %+v", r)
+	}
+	if s.watchdogDue == nil {
+		t.Fatal("WATCHDOG=1 did not arm the watchdog")
+	}
+}
@@ -0,0 +1,116 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	oomScoreAdjEnv      = "PSI_OOM_SCORE_ADJ"
+	childOOMScoreAdjEnv = "PSI_CHILD_OOM_SCORE_ADJ"
+)
+
+// WithOOMScoreAdj sets the init's own oom_score_adj (e.g. -1000 so the OOM
+// killer never picks the supervisor) and the child's. Every process the init
+// starts would otherwise inherit the init's value, so the child, and when the
+// init value is set the sidecars, hooks, init tasks, cron jobs and exec
+// probes too, start with their own value before they can run anything: the
+// value the init started with unless the child's is configured. Values range
+// from -1000 to 1000; lowering them requires CAP_SYS_RESOURCE. Overridden by
+// PSI_OOM_SCORE_ADJ and PSI_CHILD_OOM_SCORE_ADJ.
+func WithOOMScoreAdj(initAdj, childAdj int) Option {
+	return func(c *config) {
+		c.oomScoreAdj = &initAdj
+		c.childOOMScoreAdj = &childAdj
+	}
+}
+
+// parseOOMScoreAdj parses and range-checks an oom_score_adj value.
+func parseOOMScoreAdj(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if n < -1000 || n > 1000 {
+		return 0, fmt.Errorf("%d out of range [-1000, 1000]", n)
+	}
+	return n, nil
+}
+
+// envOOMScoreAdj sets *dst from an oom_score_adj environment variable.
+func envOOMScoreAdj(key string, dst **int) {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return
+	}
+	n, err := parseOOMScoreAdj(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", key, val, err)
+		return
+	}
+	*dst = &n
+}
+
+// applyInitOOMScoreAdj adjusts the init's own OOM score, remembering the
+// original value for the processes it starts, and for the child unless one
+// was configured.
+func (c *config) applyInitOOMScoreAdj() {
+	if c.oomScoreAdj == nil {
+		return
+	}
+	if n, err := readOOMScoreAdj(); err == nil {
+		c.spawnOOMScoreAdj = &n
+		if c.childOOMScoreAdj == nil {
+			c.childOOMScoreAdj = &n
+		}
+	}
+	if err := writeOOMScoreAdj(*c.oomScoreAdj); err != nil {
+		log.Printf("psi: failed to set init oom_score_adj: %v", err)
+	}
+}
+
+// forkWithOOMScoreAdj runs fork, which starts a process, with the init's
+// oom_score_adj set to adj, so that the process starts out with it rather
+// than patched after the fact, when it may have started processes of its
+// own; nil leaves the init's value alone. The value belongs to the whole
+// init, so forks are serialized and the init's value restored afterwards.
+func (r *reaper) forkWithOOMScoreAdj(adj *int, fork func() error) error {
+	r.forkMu.Lock()
+	defer r.forkMu.Unlock()
+	if adj == nil {
+		return fork()
+	}
+	old, err := readOOMScoreAdj()
+	if err != nil {
+		log.Printf("psi: cannot read oom_score_adj: %v", err)
+		return fork()
+	}
+	if old == *adj {
+		return fork()
+	}
+	if err := writeOOMScoreAdj(*adj); err != nil {
+		log.Printf("psi: failed to set oom_score_adj %d for a new process: %v", *adj, err)
+		return fork()
+	}
+	defer func() {
+		if err := writeOOMScoreAdj(old); err != nil {
+			log.Printf("psi: failed to restore init oom_score_adj: %v", err)
+		}
+	}()
+	return fork()
+}
+
+func readOOMScoreAdj() (int, error) {
+	b, err := os.ReadFile("/proc/self/oom_score_adj")
+	if err != nil {
+		return 0, err
+	}
+	return parseOOMScoreAdj(string(b))
+}
+
+func writeOOMScoreAdj(adj int) error {
+	return os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(adj)), 0)
+}
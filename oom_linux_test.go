@@ -0,0 +1,59 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestForkWithOOMScoreAdj(t *testing.T) {
+	old, err := readOOMScoreAdj()
+	if err != nil {
+		t.Skip(err)
+	}
+	adj := old + 100
+	var out []byte
+	err = newReaper().forkWithOOMScoreAdj(&adj, func() (err error) {
+		out, err = exec.Command("cat", "/proc/self/oom_score_adj").Output()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != strconv.Itoa(adj) {
+		t.Fatalf("process started with oom_score_adj %s, want %d", got, adj)
+	}
+	if now, _ := readOOMScoreAdj(); now != old {
+		if err := writeOOMScoreAdj(old); err != nil {
+			t.Skipf("cannot restore oom_score_adj without CAP_SYS_RESOURCE: %v", err)
+		}
+		t.Fatalf("oom_score_adj not restored: %d, want %d", now, old)
+	}
+}
+
+func TestSupervisorOOMScoreAdj(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("lowering oom_score_adj needs CAP_SYS_RESOURCE")
+	}
+	old, err := readOOMScoreAdj()
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "count")
+	script := filepath.Join(dir, "report")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat /proc/self/oom_score_adj >> \"$"+helperCountEnv+"\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The pre-start hook keeps the value the init started with; the child
+	// gets its own.
+	cmd := helperCommand("init-program", "GO_HELPER_PROGRAM="+script, helperCountEnv+"="+countFile,
+		oomScoreAdjEnv+"=-900", childOOMScoreAdjEnv+"=300", preStartEnv+"="+script)
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	waitForContent(t, countFile, strconv.Itoa(old)+"\n300\n")
+}
@@ -0,0 +1,29 @@
+package psi
+
+import "testing"
+
+func TestParseOOMScoreAdj(t *testing.T) {
+	for input, want := range map[string]int{"-1000": -1000, " 0\n": 0, "500": 500} {
+		got, err := parseOOMScoreAdj(input)
+		if err != nil || got != want {
+			t.Fatalf("parseOOMScoreAdj(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"-1001", "1001", "high"} {
+		if _, err := parseOOMScoreAdj(input); err == nil {
+			t.Fatalf("parseOOMScoreAdj(%q) should fail", input)
+		}
+	}
+}
+
+func TestOOMScoreAdjEnv(t *testing.T) {
+	t.Setenv(oomScoreAdjEnv, "-999")
+	t.Setenv(childOOMScoreAdjEnv, "")
+	cfg := newConfig(WithOOMScoreAdj(-1000, 100))
+	if cfg.oomScoreAdj == nil || *cfg.oomScoreAdj != -999 {
+		t.Fatalf("expected env init value -999, got %v", cfg.oomScoreAdj)
+	}
+	if cfg.childOOMScoreAdj == nil || *cfg.childOOMScoreAdj != 100 {
+		t.Fatalf("expected option child value 100, got %v", cfg.childOOMScoreAdj)
+	}
+}
@@ -0,0 +1,682 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	stopSignalEnv     = "PSI_STOP_SIGNAL"
+	ignoreSignalsEnv  = "PSI_IGNORE_SIGNALS"
+	signalMapEnv      = "PSI_SIGNAL_MAP"
+	forceSecondEnv    = "PSI_FORCE_ON_SECOND_SIGNAL"
+	pdeathSignalEnv   = "PSI_PARENT_DEATH_SIGNAL"
+	verbosityEnv      = "PSI_VERBOSITY"
+	unsharePIDEnv     = "PSI_UNSHARE_PID"
+	subreaperEnv      = "PSI_SUBREAPER"
+	forceSuperviseEnv = "PSI_FORCE_SUPERVISE"
+	cgroupEnv         = "PSI_CGROUP"
+	cgroupFreezeEnv   = "PSI_CGROUP_FREEZE"
+	noNewPrivsEnv     = "PSI_NO_NEW_PRIVS"
+	// unsharedEnv marks a process already re-exec'd into its own PID
+	// namespace so it does not unshare again.
+	unsharedEnv = "PSI_UNSHARED"
+)
+
+// Option customizes how Run supervises submain. Options are applied in both
+// the init and the child process, so they must be deterministic. Environment
+// variables (PSI_*) take precedence over options, allowing operators to tune
+// a built image without recompiling.
+type Option func(*config)
+
+// config is the effective configuration resolved from defaults, options and
+// the environment.
+type config struct {
+	// stopSignal is forwarded to the child's process group instead of the
+	// received signal when the init gets a terminate-like signal.
+	// Zero means forward the received signal as-is.
+	stopSignal syscall.Signal
+	// stopChain is the shutdown escalation sequence; empty means forward,
+	// wait PSI_STOP_TIMEOUT, then SIGKILL.
+	stopChain []StopStep
+	// ignored signals are swallowed by the init and never forwarded.
+	ignored map[syscall.Signal]bool
+	// signalMap translates received signals before they are interpreted
+	// and forwarded.
+	signalMap map[syscall.Signal]syscall.Signal
+	// forceOnSecond SIGKILLs the child's process group when a second
+	// terminate-like signal arrives during shutdown.
+	forceOnSecond bool
+	// cleanExitOnStop exits 0 when the child dies of a stop signal sent
+	// because the init was asked to terminate.
+	cleanExitOnStop bool
+	// terminationLog is where the termination message is written; empty
+	// for none.
+	terminationLog string
+	// crashBuffer is the size of the ring buffer keeping the child's last
+	// stderr output, 0 if disabled.
+	crashBuffer int
+	// logDir receives the child's output in rotated files.
+	logDir       string
+	logMaxSize   int64
+	logMaxFiles  int
+	logMaxAge    time.Duration
+	logFilesOnly bool
+	// logPrefix prefixes forwarded lines with the process's name and
+	// stream; nil means only when there are sidecars. childName names the
+	// child in them.
+	logPrefix *bool
+	childName string
+	// logTimestamps starts forwarded lines with the time.
+	logTimestamps bool
+	// logWrapJSON wraps forwarded lines in JSON records.
+	logWrapJSON bool
+	// logStripANSI removes escape sequences from the child's output.
+	logStripANSI bool
+	// redactNames and redactPatterns select the secrets masked in the
+	// output and the init's log.
+	redactNames    []string
+	redactPatterns []*regexp.Regexp
+	// pty runs the child on a pseudo-terminal.
+	pty bool
+	// stdin is what the child reads on stdin.
+	stdin StdinMode
+	// logRate and logBurst rate limit the forwarded lines per process; 0
+	// for no limit.
+	logRate  float64
+	logBurst int
+	// journald sends the child's output and the lifecycle events to
+	// journald.
+	journald bool
+	// fluentAddr receives the child's output over the Fluent Forward
+	// protocol, tagged fluentTag, buffering up to fluentBuffer bytes.
+	fluentAddr   string
+	fluentTag    string
+	fluentBuffer int
+	// pidFile and stateFile receive the child's PID and state; empty for
+	// none.
+	pidFile   string
+	stateFile string
+	// processTitle is the init's title; nil for the default, empty to
+	// keep its argv.
+	processTitle *string
+	// reexecPath is the binary re-executed to run submain; empty for the
+	// running one.
+	reexecPath string
+	// restart holds the child restart policy and backoff settings.
+	restart restartConfig
+	// sidecars are auxiliary processes supervised next to the child.
+	sidecars []Sidecar
+	// command is the external program's argv in command mode (Exec);
+	// empty when supervising a Go submain.
+	command []string
+	// pdeathSignal is delivered to the init when its parent dies (Linux).
+	pdeathSignal syscall.Signal
+	// verbosity enables lifecycle (1) and per-signal (2) log lines.
+	verbosity int
+	// unsharePID runs the init as PID 1 of a new PID namespace when it is
+	// not PID 1 already.
+	unsharePID bool
+	// subreaper supervises via PR_SET_CHILD_SUBREAPER when not PID 1.
+	subreaper bool
+	// forceSupervise supervises when not PID 1, without adopting orphans.
+	forceSupervise bool
+	// cgroup confines the child in a cgroup v2 sub-cgroup killed through
+	// cgroup.kill on forced shutdown.
+	cgroup bool
+	// cgroupFreeze freezes the child cgroup around the final SIGKILL.
+	cgroupFreeze bool
+	// dumpTreeOnKill logs the process tree before a forced kill.
+	dumpTreeOnKill bool
+	// autoTune sizes GOMAXPROCS/GOMEMLIMIT from cgroup limits before
+	// submain starts.
+	autoTune bool
+	// oomScoreAdj and childOOMScoreAdj are written to oom_score_adj of the
+	// init and the child; nil leaves them alone.
+	oomScoreAdj      *int
+	childOOMScoreAdj *int
+	// spawnOOMScoreAdj is the init's value before oomScoreAdj, which the
+	// other processes it starts begin with.
+	spawnOOMScoreAdj *int
+	// rlimits are resource limits (by RLIMIT_* name) set on each child generation.
+	rlimits map[string]rlimitValue
+	// sched holds the child's nice value, I/O priority and CPU scheduling
+	// policy.
+	sched schedConfig
+	// cpus is the child's CPU affinity; empty leaves it alone.
+	cpus []int
+	// user, uid and gid select the credentials the child runs with.
+	user string
+	uid  *uint32
+	gid  *uint32
+	// caps restricts the child's capabilities.
+	caps capConfig
+	// noNewPrivs sets PR_SET_NO_NEW_PRIVS on the child before exec.
+	noNewPrivs bool
+	// seccompProfile is the path of the child's seccomp profile.
+	seccompProfile string
+	// landlock confines the child's filesystem access.
+	landlock landlockConfig
+	// isolation gives the child a read-only mount namespace.
+	isolation isolationConfig
+	// chdir and chroot are the child's working directory and root.
+	chdir  string
+	chroot string
+	// umask is the child's file mode creation mask; nil inherits the init's.
+	umask *int
+	// hostname is set before the child starts, in a new UTS namespace for
+	// the child when unshareUTS is set.
+	hostname   string
+	unshareUTS bool
+	// mountProc mounts procfs at /proc during startup if it is missing.
+	mountProc bool
+	// setupDev creates missing basic device nodes in /dev during startup.
+	setupDev bool
+	// sysctls are written to /proc/sys during startup.
+	sysctls []sysctl
+	// envAllow lists PSI_* patterns passed through to the child.
+	envAllow []string
+	// envFiles are dotenv files whose variables are added to the child's
+	// environment.
+	envFiles []string
+	// envDirs are directories whose files become variables in the child's
+	// environment.
+	envDirs []string
+	// watchFiles are paths whose changes make the init send watchSignal
+	// to the child.
+	watchFiles  []string
+	watchSignal syscall.Signal
+	// startTimeout is the child's startup window.
+	startTimeout time.Duration
+	// readyNotify makes the init wait for the child to report readiness.
+	readyNotify bool
+	// liveness is the child's liveness probe.
+	liveness Probe
+	// healthAddr is where the health endpoint listens.
+	healthAddr string
+	// signalAudit is the path of the signal audit log.
+	signalAudit string
+	// controlSocket is the path of the control socket.
+	controlSocket string
+	// metricsAddr is where the metrics endpoint listens.
+	metricsAddr string
+	// pprofAddr is where the debugging endpoint listens.
+	pprofAddr string
+	// diagSignal makes a Go submain dump its goroutines; diagFile receives
+	// the dumps instead of stderr and diagHeap adds a heap profile.
+	diagSignal syscall.Signal
+	diagFile   string
+	diagHeap   bool
+	// restartRequests lets the child ask for a restart.
+	restartRequests bool
+	// stopExtendMax is the most the child may extend the shutdown by.
+	stopExtendMax time.Duration
+	// watchdog is the child's sd_notify watchdog period.
+	watchdog time.Duration
+	// listen are the sockets bound by the init for the child.
+	listen []string
+	// initTasks run before the first child generation.
+	initTasks []InitTask
+	// cronJobs run periodically while supervising.
+	cronJobs []CronJob
+	// preStart and postStop run before the first and after the last child
+	// generation.
+	preStart Hook
+	postStop Hook
+	// preStop runs on the first terminate signal, followed by preStopSleep,
+	// before the child is signalled.
+	preStop      Hook
+	preStopSleep time.Duration
+	// hupAction is what the init does with SIGHUP.
+	hupAction HupAction
+	// upgradeSignal starts a zero-downtime upgrade of the child.
+	upgradeSignal syscall.Signal
+	// logFormat is the init's log format.
+	logFormat LogFormat
+	// logger receives the init's messages instead of the standard logger.
+	logger Logger
+	// logLevel is the minimum level of the init's messages; nil picks the
+	// default.
+	logLevel *LogLevel
+	// eventHandlers receive the lifecycle events.
+	eventHandlers []func(Event)
+	// onSupervise receives a handle on the supervisor.
+	onSupervise func(*Supervisor)
+	// configFile is the YAML file read before the environment overrides.
+	configFile string
+	// expandArgs expands ${VAR} references in command and sidecar argv.
+	expandArgs bool
+}
+
+// WithStopSignal sets the signal forwarded to the child's process group when
+// the init receives a terminate-like signal (SIGTERM, SIGINT, SIGQUIT,
+// SIGHUP unless WithHupAction says otherwise). Useful for apps such as nginx that shut down gracefully on
+// SIGQUIT. Overridden by PSI_STOP_SIGNAL.
+func WithStopSignal(sig syscall.Signal) Option {
+	return func(c *config) {
+		c.stopSignal = sig
+	}
+}
+
+// WithIgnoredSignals stops the init from forwarding the given signals to the
+// child's process group. An ignored terminate-like signal does not start the
+// shutdown sequence either. Overridden by PSI_IGNORE_SIGNALS.
+func WithIgnoredSignals(sigs ...syscall.Signal) Option {
+	return func(c *config) {
+		c.ignored = make(map[syscall.Signal]bool, len(sigs))
+		for _, sig := range sigs {
+			c.ignored[sig] = true
+		}
+	}
+}
+
+// WithSignalMap translates received signals before the init acts on them,
+// e.g. {SIGHUP: SIGUSR1} for an app that reloads on SIGUSR1. The translated
+// signal decides whether shutdown starts, so mapping SIGHUP to SIGUSR1 also
+// stops SIGHUP from arming the stop timer. Overridden by PSI_SIGNAL_MAP.
+func WithSignalMap(m map[syscall.Signal]syscall.Signal) Option {
+	return func(c *config) {
+		c.signalMap = make(map[syscall.Signal]syscall.Signal, len(m))
+		for from, to := range m {
+			c.signalMap[from] = to
+		}
+	}
+}
+
+// WithForceOnSecondSignal makes a second terminate-like signal received while
+// shutdown is in progress (e.g. pressing Ctrl+C twice) SIGKILL the child's
+// process group immediately instead of waiting out the stop timeout.
+// Overridden by PSI_FORCE_ON_SECOND_SIGNAL.
+func WithForceOnSecondSignal() Option {
+	return func(c *config) {
+		c.forceOnSecond = true
+	}
+}
+
+// WithParentDeathSignal makes the kernel send sig to the init when the
+// process that started it dies (Linux and FreeBSD), e.g. when psi runs under a
+// supervisor rather than as container PID 1. Overridden by
+// PSI_PARENT_DEATH_SIGNAL.
+func WithParentDeathSignal(sig syscall.Signal) Option {
+	return func(c *config) {
+		c.pdeathSignal = sig
+	}
+}
+
+// WithVerbosity makes the init log lifecycle events (1) and, additionally,
+// every forwarded signal (2). Overridden by PSI_VERBOSITY.
+func WithVerbosity(level int) Option {
+	return func(c *config) {
+		c.verbosity = level
+	}
+}
+
+// WithPIDNamespace makes psi, when not started as PID 1, re-exec itself as
+// PID 1 of a new PID namespace (falling back to a user namespace when
+// unprivileged), so reaping and signal semantics match production during
+// local development. Linux only. Overridden by PSI_UNSHARE_PID.
+func WithPIDNamespace() Option {
+	return func(c *config) {
+		c.unsharePID = true
+	}
+}
+
+// WithSubreaper makes psi supervise even when it is not PID 1 (e.g. in a
+// Kubernetes pod with shareProcessNamespace): it registers as a child
+// subreaper (PR_SET_CHILD_SUBREAPER, or PROC_REAP_ACQUIRE on FreeBSD, e.g. as
+// the init of a jail) so orphans are re-parented to it and reaped, and runs
+// the usual re-exec, forwarding and reaping path. Linux and FreeBSD only.
+// Overridden by PSI_SUBREAPER.
+func WithSubreaper() Option {
+	return func(c *config) {
+		c.subreaper = true
+	}
+}
+
+// WithForceSupervise makes psi supervise even when it is not PID 1, on any
+// platform: the re-exec, forwarding, reaping and stop-timeout path runs
+// exactly as in a container, but orphans are not adopted. Meant for local
+// testing, e.g. on macOS. Overridden by PSI_FORCE_SUPERVISE.
+func WithForceSupervise() Option {
+	return func(c *config) {
+		c.forceSupervise = true
+	}
+}
+
+// WithNoNewPrivs sets PR_SET_NO_NEW_PRIVS on the child before exec, so
+// neither it nor its descendants can gain privileges through setuid/setgid
+// binaries or file capabilities. Linux and FreeBSD 14+ only. Overridden by
+// PSI_NO_NEW_PRIVS.
+func WithNoNewPrivs() Option {
+	return func(c *config) {
+		c.noNewPrivs = true
+	}
+}
+
+// WithCgroup places the child's process tree in a dedicated cgroup v2
+// sub-cgroup ("psi-child" below the init's cgroup). The forced SIGKILL at the
+// end of shutdown is then delivered through cgroup.kill, reaching processes
+// that escaped the process group (double-forking daemons). Requires a
+// writable cgroup2 mount; otherwise psi falls back to process-group signals.
+// Linux only. Overridden by PSI_CGROUP.
+func WithCgroup() Option {
+	return func(c *config) {
+		c.cgroup = true
+	}
+}
+
+// WithCgroupFreeze enables cgroup mode (see WithCgroup) and freezes the
+// child's cgroup before the final SIGKILL, thawing it afterwards, so no
+// process can fork its way out of the forced shutdown. Overridden by
+// PSI_CGROUP_FREEZE.
+func WithCgroupFreeze() Option {
+	return func(c *config) {
+		c.cgroup = true
+		c.cgroupFreeze = true
+	}
+}
+
+// newConfig resolves the effective configuration: options first, then the
+// configuration file, then environment overrides.
+func newConfig(opts ...Option) *config {
+	c := &config{restart: defaultRestartConfig()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	c.loadConfigFile()
+	c.loadEnv()
+	return c
+}
+
+// loadEnv applies PSI_* environment overrides. Invalid values are logged and
+// ignored.
+func (c *config) loadEnv() {
+	if val := strings.TrimSpace(os.Getenv(stopSignalEnv)); val != "" {
+		sig, err := ParseSignal(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", stopSignalEnv, val, err)
+		} else {
+			c.stopSignal = sig
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(stopChainEnv)); val != "" {
+		steps, err := parseStopChain(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", stopChainEnv, val, err)
+		} else {
+			c.stopChain = steps
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(ignoreSignalsEnv)); val != "" {
+		sigs, err := parseSignalList(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", ignoreSignalsEnv, val, err)
+		} else {
+			WithIgnoredSignals(sigs...)(c)
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(signalMapEnv)); val != "" {
+		m, err := parseSignalMap(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", signalMapEnv, val, err)
+		} else {
+			c.signalMap = m
+		}
+	}
+	envBool(forceSecondEnv, &c.forceOnSecond)
+	envBool(cleanExitOnStopEnv, &c.cleanExitOnStop)
+	c.loadTerminationLogEnv()
+	c.loadCrashBufferEnv()
+	c.loadLogFilesEnv()
+	c.loadLogPrefixEnv()
+	envBool(logTimestampsEnv, &c.logTimestamps)
+	envBool(logWrapJSONEnv, &c.logWrapJSON)
+	envBool(logStripANSIEnv, &c.logStripANSI)
+	envBool(ptyEnv, &c.pty)
+	c.loadStdinEnv()
+	c.loadLogRateEnv()
+	c.loadRedactEnv()
+	envBool(journaldEnv, &c.journald)
+	c.loadFluentEnv()
+	c.loadStateFileEnv()
+	c.loadProcessTitleEnv()
+	c.loadReexecEnv()
+	c.restart.loadEnv()
+	envBool(unsharePIDEnv, &c.unsharePID)
+	envBool(subreaperEnv, &c.subreaper)
+	envBool(forceSuperviseEnv, &c.forceSupervise)
+	envBool(cgroupEnv, &c.cgroup)
+	envBool(cgroupFreezeEnv, &c.cgroupFreeze)
+	envBool(dumpTreeOnKillEnv, &c.dumpTreeOnKill)
+	envBool(autoTuneEnv, &c.autoTune)
+	envOOMScoreAdj(oomScoreAdjEnv, &c.oomScoreAdj)
+	envOOMScoreAdj(childOOMScoreAdjEnv, &c.childOOMScoreAdj)
+	c.loadRlimitEnv()
+	c.sched.loadEnv()
+	c.loadCPUSetEnv()
+	c.loadUserEnv()
+	c.caps.loadEnv()
+	envBool(noNewPrivsEnv, &c.noNewPrivs)
+	c.loadSeccompEnv()
+	c.landlock.loadEnv()
+	c.isolation.loadEnv()
+	c.loadRootEnv()
+	c.loadUmaskEnv()
+	c.loadHostnameEnv()
+	envBool(mountProcEnv, &c.mountProc)
+	envBool(setupDevEnv, &c.setupDev)
+	c.loadSysctlEnv()
+	c.loadEnvAllowEnv()
+	c.loadEnvFileEnv()
+	c.loadEnvDirEnv()
+	c.loadWatchEnv()
+	envDuration(startTimeoutEnv, &c.startTimeout)
+	envBool(readyNotifyEnv, &c.readyNotify)
+	c.liveness.loadEnv()
+	c.loadHealthEnv()
+	c.loadControlEnv()
+	c.loadSignalAuditEnv()
+	c.loadMetricsEnv()
+	c.loadPprofEnv()
+	c.loadDiagEnv()
+	envDuration(watchdogEnv, &c.watchdog)
+	envDuration(stopExtendMaxEnv, &c.stopExtendMax)
+	envBool(restartRequestsEnv, &c.restartRequests)
+	c.loadListenEnv()
+	c.loadUpgradeEnv()
+	c.loadHupEnv()
+	c.loadHookEnv()
+	c.loadPreStopEnv()
+	c.loadLogFormatEnv()
+	c.loadLogLevelEnv()
+	envBool(expandArgsEnv, &c.expandArgs)
+	if c.cgroupFreeze {
+		c.cgroup = true
+	}
+	if val := strings.TrimSpace(os.Getenv(pdeathSignalEnv)); val != "" {
+		sig, err := ParseSignal(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", pdeathSignalEnv, val, err)
+		} else {
+			c.pdeathSignal = sig
+		}
+	}
+	if val := strings.TrimSpace(os.Getenv(verbosityEnv)); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			log.Printf("psi: invalid %s=%q; ignoring", verbosityEnv, val)
+		} else {
+			c.verbosity = n
+		}
+	}
+}
+
+// applyToInit applies settings that affect the init process itself.
+func (c *config) applyToInit() {
+	// /proc first: the other steps may need it.
+	c.applyMountProc()
+	c.applySetupDev()
+	c.applySysctls()
+	c.applyInitOOMScoreAdj()
+	c.applyInitHostname()
+	if c.subreaper && os.Getpid() != 1 {
+		if err := setChildSubreaper(); err != nil {
+			log.Printf("psi: failed to become child subreaper: %v", err)
+		}
+	}
+	if c.pdeathSignal != 0 {
+		if err := setParentDeathSignal(c.pdeathSignal); err != nil {
+			log.Printf("psi: failed to set parent-death signal: %v", err)
+		}
+	}
+}
+
+// debugf logs when the configured verbosity is at least level, or at debug
+// level (see WithLogLevel).
+func (c *config) debugf(level int, format string, args ...any) {
+	// Enabled verbose messages are logged at info level, the others at debug.
+	msgLevel := LogInfo
+	if c.verbosity < level {
+		msgLevel = LogDebug
+	}
+	if logEnabled(msgLevel) {
+		logMessage(msgLevel, fmt.Sprintf(format, args...))
+	}
+}
+
+// envDuration sets *dst from a duration environment variable (Go duration
+// syntax or bare seconds). Unset or empty variables leave *dst untouched.
+func envDuration(key string, dst *time.Duration) {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return
+	}
+	d, err := parseDuration(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", key, val, err)
+		return
+	}
+	*dst = d
+}
+
+// envBool sets *dst from a boolean environment variable ("1", "true", "yes",
+// "on" or their negations). Unset or empty variables leave *dst untouched.
+func envBool(key string, dst *bool) {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return
+	}
+	b, err := parseBool(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q; ignoring", key, val)
+		return
+	}
+	*dst = b
+}
+
+// parseBool accepts "1", "true", "yes", "on" and their negations.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", s)
+}
+
+// parseSize parses a byte count with an optional binary unit: "65536",
+// "64K", "64KiB", "1M", "1MiB" or "2G".
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSpace(s)
+	unit := strings.TrimLeft(num, "0123456789")
+	num = num[:len(num)-len(unit)]
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	var shift uint
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "", "b":
+	case "k", "kb", "kib":
+		shift = 10
+	case "m", "mb", "mib":
+		shift = 20
+	case "g", "gb", "gib":
+		shift = 30
+	default:
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt32>>shift {
+		return 0, fmt.Errorf("size %q too large", s)
+	}
+	return n << shift, nil
+}
+
+// forwardSignal returns the signal to send to the child's process group for
+// a received signal.
+func (c *config) forwardSignal(sig syscall.Signal) syscall.Signal {
+	if c.stopSignal != 0 && c.isTerminate(sig) {
+		return c.stopSignal
+	}
+	return sig
+}
+
+// isIgnored reports whether sig must not be propagated to the child.
+func (c *config) isIgnored(sig syscall.Signal) bool {
+	return c.ignored[sig] || (sig == syscall.SIGHUP && c.hupAction == HupIgnore)
+}
+
+// translateSignal applies the configured signal map to a received signal.
+func (c *config) translateSignal(sig syscall.Signal) syscall.Signal {
+	if to, ok := c.signalMap[sig]; ok {
+		return to
+	}
+	return sig
+}
+
+// wantPIDNamespace reports whether a non-PID1 process should re-exec itself
+// into a new PID namespace.
+func (c *config) wantPIDNamespace() bool {
+	return c.unsharePID && os.Getenv(unsharedEnv) != "1"
+}
+
+// supervise reports whether the current (non-child) process should act as
+// the init: as PID 1, anywhere in subreaper or forced mode, or always on
+// platforms without a process-wide reaper (Windows) where psi tracks its own
+// child.
+func (c *config) supervise() bool {
+	return os.Getpid() == 1 || c.subreaper || c.forceSupervise || alwaysSupervise
+}
+
+// prepareUnsupervised sets up the current process to run the program or
+// submain itself when psi does not supervise it, re-executing into a new PID
+// namespace first if one is wanted. Both Run and Exec start here.
+func (c *config) prepareUnsupervised() {
+	if c.wantPIDNamespace() {
+		runInPIDNamespace()
+		// runInPIDNamespace never returns.
+	}
+	c.applyToInit()
+	c.applyRlimits()
+	c.setExtraEnv()
+}
+
+// prepareSubmain applies settings to the process about to run submain.
+func (c *config) prepareSubmain() {
+	c.setUmask()
+	c.startDiag()
+	if c.autoTune {
+		procs, mem := AutoTuneRuntime()
+		c.debugf(1, "auto-tuned runtime: GOMAXPROCS=%d GOMEMLIMIT=%d (0 = unchanged)", procs, mem)
+	}
+}
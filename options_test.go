@@ -0,0 +1,106 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestWithStopSignal(t *testing.T) {
+	t.Setenv(stopSignalEnv, "")
+	cfg := newConfig(WithStopSignal(syscall.SIGQUIT))
+	if cfg.stopSignal != syscall.SIGQUIT {
+		t.Fatalf("expected SIGQUIT, got %v", cfg.stopSignal)
+	}
+	if got := cfg.forwardSignal(syscall.SIGTERM); got != syscall.SIGQUIT {
+		t.Fatalf("expected SIGTERM to be forwarded as SIGQUIT, got %v", got)
+	}
+	if got := cfg.forwardSignal(syscall.SIGUSR1); got != syscall.SIGUSR1 {
+		t.Fatalf("expected SIGUSR1 to pass through, got %v", got)
+	}
+}
+
+func TestStopSignalEnvOverridesOption(t *testing.T) {
+	t.Setenv(stopSignalEnv, "SIGINT")
+	cfg := newConfig(WithStopSignal(syscall.SIGQUIT))
+	if cfg.stopSignal != syscall.SIGINT {
+		t.Fatalf("expected env SIGINT to win, got %v", cfg.stopSignal)
+	}
+}
+
+func TestStopSignalDefaultPassThrough(t *testing.T) {
+	t.Setenv(stopSignalEnv, "")
+	cfg := newConfig()
+	if got := cfg.forwardSignal(syscall.SIGTERM); got != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM pass-through, got %v", got)
+	}
+}
+
+func TestIgnoredSignals(t *testing.T) {
+	t.Setenv(ignoreSignalsEnv, "")
+	cfg := newConfig(WithIgnoredSignals(syscall.SIGHUP))
+	if !cfg.isIgnored(syscall.SIGHUP) {
+		t.Fatal("SIGHUP should be ignored via option")
+	}
+	if cfg.isIgnored(syscall.SIGTERM) {
+		t.Fatal("SIGTERM should not be ignored")
+	}
+}
+
+func TestIgnoredSignalsEnv(t *testing.T) {
+	t.Setenv(ignoreSignalsEnv, "SIGUSR2, prof")
+	cfg := newConfig(WithIgnoredSignals(syscall.SIGHUP))
+	if cfg.isIgnored(syscall.SIGHUP) {
+		t.Fatal("env list should replace the option list")
+	}
+	if !cfg.isIgnored(syscall.SIGUSR2) || !cfg.isIgnored(syscall.SIGPROF) {
+		t.Fatal("expected SIGUSR2 and SIGPROF to be ignored")
+	}
+}
+
+func TestSignalMap(t *testing.T) {
+	t.Setenv(signalMapEnv, "")
+	cfg := newConfig(WithSignalMap(map[syscall.Signal]syscall.Signal{syscall.SIGHUP: syscall.SIGUSR1}))
+	if got := cfg.translateSignal(syscall.SIGHUP); got != syscall.SIGUSR1 {
+		t.Fatalf("expected SIGHUP -> SIGUSR1, got %v", got)
+	}
+	if got := cfg.translateSignal(syscall.SIGTERM); got != syscall.SIGTERM {
+		t.Fatalf("expected SIGTERM unchanged, got %v", got)
+	}
+	t.Setenv(signalMapEnv, "SIGINT:SIGTERM")
+	cfg = newConfig(WithSignalMap(map[syscall.Signal]syscall.Signal{syscall.SIGHUP: syscall.SIGUSR1}))
+	if got := cfg.translateSignal(syscall.SIGHUP); got != syscall.SIGHUP {
+		t.Fatalf("env map should replace option map, got %v", got)
+	}
+	if got := cfg.translateSignal(syscall.SIGINT); got != syscall.SIGTERM {
+		t.Fatalf("expected SIGINT -> SIGTERM, got %v", got)
+	}
+}
+
+func TestForceOnSecondSignal(t *testing.T) {
+	t.Setenv(forceSecondEnv, "")
+	if newConfig().forceOnSecond {
+		t.Fatal("force on second signal should be off by default")
+	}
+	if !newConfig(WithForceOnSecondSignal()).forceOnSecond {
+		t.Fatal("option should enable force on second signal")
+	}
+	t.Setenv(forceSecondEnv, "0")
+	if newConfig(WithForceOnSecondSignal()).forceOnSecond {
+		t.Fatal("env should override option")
+	}
+	t.Setenv(forceSecondEnv, "1")
+	if !newConfig().forceOnSecond {
+		t.Fatal("env should enable force on second signal")
+	}
+}
+
+func TestCgroupFreezeImpliesCgroup(t *testing.T) {
+	t.Setenv(cgroupEnv, "")
+	t.Setenv(cgroupFreezeEnv, "1")
+	cfg := newConfig()
+	if !cfg.cgroup || !cfg.cgroupFreeze {
+		t.Fatalf("PSI_CGROUP_FREEZE should enable cgroup mode, got cgroup=%v freeze=%v", cfg.cgroup, cfg.cgroupFreeze)
+	}
+}
@@ -0,0 +1,37 @@
+package psi
+
+import "os"
+
+// adoptedOrphans counts the init's live children that it did not start,
+// i.e. orphans re-parented to it that have not exited yet. It needs /proc.
+func (r *reaper) adoptedOrphans() (int, error) {
+	pids, err := childPIDs()
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, pid := range pids {
+		if _, ok := r.watched[pid]; !ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// childPIDs lists the init's children, zombies included.
+func childPIDs() ([]int, error) {
+	procs, err := listProcs()
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var pids []int
+	for _, p := range procs {
+		if p.ppid == self {
+			pids = append(pids, p.pid)
+		}
+	}
+	return pids, nil
+}
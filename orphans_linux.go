@@ -0,0 +1,39 @@
+package psi
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// siPidOffset is the offset of si_pid in a siginfo_t: three ints, then the
+// union aligned to a pointer.
+const siPidOffset = (12 + unsafe.Sizeof(uintptr(0)) - 1) &^ (unsafe.Sizeof(uintptr(0)) - 1)
+
+// peekExited waits like Wait4(-1, options) but leaves the process unreaped
+// (WNOWAIT), so that its /proc entry can still be read. It returns the PID,
+// or -1 if there is none.
+func peekExited(options int) int {
+	var info [16]uint64 // siginfo_t is 128 bytes
+	const pAll = 0
+	_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pAll, 0, uintptr(unsafe.Pointer(&info[0])),
+		uintptr(syscall.WEXITED|syscall.WNOWAIT|options), 0, 0)
+	if errno != 0 {
+		return -1
+	}
+	if pid := *(*int32)(unsafe.Add(unsafe.Pointer(&info[0]), siPidOffset)); pid > 0 {
+		return int(pid)
+	}
+	return -1
+}
+
+// procComm returns the command name of pid, "?" if unknown.
+func procComm(pid int) string {
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm")
+	if err != nil {
+		return "?"
+	}
+	return strings.TrimSpace(string(b))
+}
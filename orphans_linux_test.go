@@ -0,0 +1,54 @@
+package psi
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAdoptedOrphans(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep: %v", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+	if got := procComm(pid); got != "sleep" {
+		t.Fatalf("procComm = %q", got)
+	}
+	pids, err := childPIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(pids, pid) {
+		t.Fatalf("childPIDs = %v, want %d among them", pids, pid)
+	}
+	r := newReaper()
+	before, err := r.adoptedOrphans()
+	if err != nil || before < 1 {
+		t.Fatalf("adoptedOrphans = %d, %v", before, err)
+	}
+	r.watched[pid] = &procHandle{pid: pid}
+	if after, _ := r.adoptedOrphans(); after != before-1 {
+		t.Fatalf("watched child counted as an orphan: %d then %d", before, after)
+	}
+}
+
+func TestSupervisorReapsOrphans(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-orphans", helperCountEnv+"="+countFile, logLevelEnv+"=debug")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr=%q)", exit, stderr.String())
+	}
+	waitForContent(t, countFile, "orphan exit code 7\n")
+	if !strings.Contains(stderr.String(), "(sh) status 0x700 (exit code 7, orphan true)") {
+		t.Fatalf("reap of the orphan not logged:\n%s", stderr.String())
+	}
+}
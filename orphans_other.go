@@ -0,0 +1,7 @@
+//go:build !linux
+
+package psi
+
+func peekExited(int) int { return -1 }
+
+func procComm(int) string { return "?" }
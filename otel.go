@@ -0,0 +1,391 @@
+package psi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Standard OpenTelemetry variables read by the init's tracer.
+const (
+	otelEndpointEnv        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otelTracesEndpointEnv  = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	otelHeadersEnv         = "OTEL_EXPORTER_OTLP_HEADERS"
+	otelTracesHeadersEnv   = "OTEL_EXPORTER_OTLP_TRACES_HEADERS"
+	otelProtocolEnv        = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	otelTracesProtocolEnv  = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+	otelTimeoutEnv         = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	otelTracesExporterEnv  = "OTEL_TRACES_EXPORTER"
+	otelSDKDisabledEnv     = "OTEL_SDK_DISABLED"
+	otelServiceNameEnv     = "OTEL_SERVICE_NAME"
+	otelResourceAttrsEnv   = "OTEL_RESOURCE_ATTRIBUTES"
+	traceparentEnv         = "TRACEPARENT"
+	defaultOTLPTimeout     = 10 * time.Second
+	otelInstrumentationLib = "pkt.systems/psi"
+)
+
+// tracer turns the lifecycle events into spans and exports them as
+// OTLP/HTTP JSON.
+type tracer struct {
+	otlpExporter
+	resource []otlpKeyValue
+	traceID  string
+
+	mu        sync.Mutex
+	root      *otlpSpan
+	start     *otlpSpan
+	readiness *otlpSpan
+	drain     *otlpSpan
+	exports   sync.WaitGroup
+}
+
+// otlpKeyValue, otlpValue and otlpSpan follow the OTLP JSON encoding, where
+// IDs are hex strings and 64-bit integers are decimal strings.
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Kind         int            `json:"kind"`
+	Start        string         `json:"startTimeUnixNano"`
+	End          string         `json:"endTimeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	Status       otlpStatus     `json:"status"`
+}
+
+// otlpStatusError marks a failed span.
+const otlpStatusError = 2
+
+func stringAttr(key, val string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpValue{StringValue: &val}}
+}
+
+func intAttr(key string, val int) otlpKeyValue {
+	s := strconv.Itoa(val)
+	return otlpKeyValue{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// otlpSignal names the OTEL_* variables specific to one kind of telemetry
+// and the path appended to OTEL_EXPORTER_OTLP_ENDPOINT for it.
+type otlpSignal struct {
+	name                                 string
+	endpointEnv, headersEnv, protocolEnv string
+	exporterEnv                          string
+	path                                 string
+}
+
+var otlpTraces = otlpSignal{
+	name:        "tracing",
+	endpointEnv: otelTracesEndpointEnv,
+	headersEnv:  otelTracesHeadersEnv,
+	protocolEnv: otelTracesProtocolEnv,
+	exporterEnv: otelTracesExporterEnv,
+	path:        "/v1/traces",
+}
+
+// otlpExporter is where and how telemetry is exported.
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// newOTLPExporter returns the exporter the OTEL_* variables configure for
+// sig, or false when its export is off.
+func newOTLPExporter(sig otlpSignal) (otlpExporter, bool) {
+	endpoint := strings.TrimSpace(os.Getenv(sig.endpointEnv))
+	if endpoint == "" {
+		if base := strings.TrimSpace(os.Getenv(otelEndpointEnv)); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + sig.path
+		}
+	}
+	if endpoint == "" || strings.EqualFold(strings.TrimSpace(os.Getenv(sig.exporterEnv)), "none") {
+		return otlpExporter{}, false
+	}
+	var disabled bool
+	envBool(otelSDKDisabledEnv, &disabled)
+	if disabled {
+		return otlpExporter{}, false
+	}
+	protocol := strings.TrimSpace(os.Getenv(sig.protocolEnv))
+	if protocol == "" {
+		protocol = strings.TrimSpace(os.Getenv(otelProtocolEnv))
+	}
+	if protocol == "grpc" {
+		log.Printf("psi: %s disabled: %s=grpc is not supported, use http/json", sig.name, otelProtocolEnv)
+		return otlpExporter{}, false
+	}
+	timeout := defaultOTLPTimeout
+	if val := strings.TrimSpace(os.Getenv(otelTimeoutEnv)); val != "" {
+		if ms, err := strconv.Atoi(val); err == nil && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		} else {
+			log.Printf("psi: invalid %s=%q; ignoring", otelTimeoutEnv, val)
+		}
+	}
+	exp := otlpExporter{
+		endpoint: endpoint,
+		headers:  parseOTLPHeaders(os.Getenv(otelHeadersEnv)),
+		client:   &http.Client{Timeout: timeout},
+	}
+	for k, v := range parseOTLPHeaders(os.Getenv(sig.headersEnv)) {
+		exp.headers[k] = v
+	}
+	return exp, true
+}
+
+// post sends an OTLP/HTTP JSON request with body.
+func (exp otlpExporter) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, exp.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range exp.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := exp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// newTracer returns the tracer configured by the OTEL_* variables, or nil
+// when tracing is off.
+func newTracer() *tracer {
+	exp, ok := newOTLPExporter(otlpTraces)
+	if !ok {
+		return nil
+	}
+	t := &tracer{
+		otlpExporter: exp,
+		resource:     otelResource(),
+		traceID:      randomID(16),
+	}
+	t.root = t.newSpan("psi.lifecycle", time.Now())
+	if traceID, spanID, ok := parseTraceparent(os.Getenv(traceparentEnv)); ok {
+		t.traceID, t.root.TraceID, t.root.ParentSpanID = traceID, traceID, spanID
+	}
+	t.root.Attributes = append(t.root.Attributes, intAttr("process.pid", os.Getpid()))
+	t.start = t.newSpan("psi.start", t.root.startTime())
+	return t
+}
+
+// parseOTLPHeaders parses "key1=value1,key2=value2" with URL-encoded values.
+func parseOTLPHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(val)); err == nil {
+			val = unescaped
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return headers
+}
+
+// otelResource returns the resource attributes from OTEL_RESOURCE_ATTRIBUTES
+// and OTEL_SERVICE_NAME.
+func otelResource() []otlpKeyValue {
+	attrs := parseOTLPHeaders(os.Getenv(otelResourceAttrsEnv))
+	if name := strings.TrimSpace(os.Getenv(otelServiceNameEnv)); name != "" {
+		attrs["service.name"] = name
+	}
+	if attrs["service.name"] == "" {
+		attrs["service.name"] = "psi"
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = stringAttr(k, attrs[k])
+	}
+	return kvs
+}
+
+// parseTraceparent extracts the trace and parent span IDs of a W3C
+// traceparent ("00-<trace id>-<span id>-<flags>").
+func parseTraceparent(s string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	for _, id := range parts[1:3] {
+		if _, err := hex.DecodeString(id); err != nil || strings.Trim(id, "0") == "" {
+			return "", "", false
+		}
+	}
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), true
+}
+
+// newSpan starts a child span of the lifecycle span.
+func (t *tracer) newSpan(name string, start time.Time) *otlpSpan {
+	sp := &otlpSpan{TraceID: t.traceID, SpanID: randomID(8), Name: name, Kind: 1, Start: unixNano(start)}
+	if t.root != nil {
+		sp.ParentSpanID = t.root.SpanID
+	}
+	return sp
+}
+
+func (sp *otlpSpan) startTime() time.Time {
+	ns, _ := strconv.ParseInt(sp.Start, 10, 64)
+	return time.Unix(0, ns)
+}
+
+// end ends sp at at and exports it.
+func (t *tracer) end(sp *otlpSpan, at time.Time) {
+	sp.End = unixNano(at)
+	t.exports.Add(1)
+	go func() {
+		defer t.exports.Done()
+		t.export(sp)
+	}()
+}
+
+// export sends sp to the collector.
+func (t *tracer) export(sp *otlpSpan) {
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": t.resource},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": otelInstrumentationLib},
+				"spans": []*otlpSpan{sp},
+			}},
+		}},
+	})
+	if err != nil {
+		return
+	}
+	if err := t.post(body); err != nil {
+		log.Printf("psi: exporting span: %v", err)
+	}
+}
+
+// event records a lifecycle event in the spans.
+func (t *tracer) event(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Type {
+	case EventChildStarted:
+		if t.start != nil {
+			t.start.Attributes = append(t.start.Attributes, intAttr("child.pid", e.PID))
+			t.end(t.start, e.Time)
+			t.start = nil
+		}
+		t.readiness = t.newSpan("psi.readiness", e.Time)
+		t.readiness.Attributes = append(t.readiness.Attributes, intAttr("child.pid", e.PID))
+	case EventChildReady:
+		if t.readiness != nil {
+			t.end(t.readiness, e.Time)
+			t.readiness = nil
+		}
+	case EventSignalReceived:
+		if e.Signal == sigPreempt {
+			// Go's runtime preempts goroutines with SIGURG.
+			return
+		}
+		sp := t.newSpan("psi.signal", e.Time)
+		sp.Attributes = append(sp.Attributes, stringAttr("signal", signalName(e.Signal)))
+		t.end(sp, e.Time)
+	case EventStopTimerArmed:
+		if t.drain == nil {
+			t.drain = t.newSpan("psi.drain", e.Time)
+			t.drain.Attributes = append(t.drain.Attributes, intAttr("child.pid", e.PID))
+		}
+	case EventForcedKill:
+		sp := t.newSpan("psi.forced_kill", e.Time)
+		sp.Attributes = append(sp.Attributes, intAttr("child.pid", e.PID))
+		sp.Status = otlpStatus{Code: otlpStatusError, Message: "killed with SIGKILL"}
+		t.end(sp, e.Time)
+		if t.drain != nil {
+			t.drain.Status = otlpStatus{Code: otlpStatusError, Message: "child killed"}
+		}
+	case EventChildExited:
+		if t.readiness != nil {
+			t.readiness.Status = otlpStatus{Code: otlpStatusError, Message: "child exited before becoming ready"}
+			t.end(t.readiness, e.Time)
+			t.readiness = nil
+		}
+		if t.drain != nil {
+			t.drain.Attributes = append(t.drain.Attributes, intAttr("exit_code", e.ExitCode))
+			t.end(t.drain, e.Time)
+			t.drain = nil
+		}
+	}
+}
+
+// startTracing starts the lifecycle trace if tracing is configured.
+func (s *supervisor) startTracing() {
+	if s.tracer = newTracer(); s.tracer != nil {
+		addEventHandler(s.tracer.event)
+		s.cfg.debugf(1, "tracing to %s", s.tracer.endpoint)
+	}
+}
+
+// stopTracing ends the lifecycle trace with the init's exit code and waits
+// for the spans to be exported.
+func (s *supervisor) stopTracing(code int) {
+	t := s.tracer
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	for _, sp := range []*otlpSpan{t.start, t.readiness, t.drain} {
+		if sp != nil {
+			t.end(sp, now)
+		}
+	}
+	t.start, t.readiness, t.drain = nil, nil, nil
+	t.root.Attributes = append(t.root.Attributes, intAttr("exit_code", code))
+	t.end(t.root, now)
+	t.mu.Unlock()
+	t.exports.Wait()
+}
@@ -0,0 +1,177 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID, ok := parseTraceparent("00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	if !ok || traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Fatalf("parseTraceparent = %q, %q, %v", traceID, spanID, ok)
+	}
+	for _, bad := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01"} {
+		if _, _, ok := parseTraceparent(bad); ok {
+			t.Fatalf("parseTraceparent(%q) accepted", bad)
+		}
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	h := parseOTLPHeaders("api-key=secret, x-team = a%20b,broken")
+	if len(h) != 2 || h["api-key"] != "secret" || h["x-team"] != "a b" {
+		t.Fatalf("headers = %v", h)
+	}
+}
+
+func TestNewTracerEnv(t *testing.T) {
+	if newTracer() != nil {
+		t.Fatal("tracing must be off without an endpoint")
+	}
+	t.Setenv(otelEndpointEnv, "http://collector:4318/")
+	t.Setenv(otelServiceNameEnv, "shop")
+	t.Setenv(otelResourceAttrsEnv, "service.name=ignored,deployment.environment=prod")
+	tr := newTracer()
+	if tr == nil || tr.endpoint != "http://collector:4318/v1/traces" {
+		t.Fatalf("tracer = %+v", tr)
+	}
+	if len(tr.resource) != 2 || *tr.resource[0].Value.StringValue != "prod" || *tr.resource[1].Value.StringValue != "shop" {
+		t.Fatalf("resource = %+v", tr.resource)
+	}
+	t.Setenv(otelTracesEndpointEnv, "http://traces:4318/custom")
+	if tr := newTracer(); tr.endpoint != "http://traces:4318/custom" {
+		t.Fatalf("endpoint = %q", tr.endpoint)
+	}
+	t.Setenv(otelTracesExporterEnv, "none")
+	if newTracer() != nil {
+		t.Fatal("OTEL_TRACES_EXPORTER=none must turn tracing off")
+	}
+	t.Setenv(otelTracesExporterEnv, "")
+	t.Setenv(otelProtocolEnv, "grpc")
+	if newTracer() != nil {
+		t.Fatal("grpc is not supported")
+	}
+}
+
+// collector records the spans posted to it.
+type collector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+	auth  []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.auth = append(c.auth, r.Header.Get("Authorization"))
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *collector) names() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for _, sp := range c.spans {
+		names = append(names, sp.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestTracer(t *testing.T) {
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+	t.Setenv(otelEndpointEnv, srv.URL)
+	t.Setenv(otelHeadersEnv, "Authorization=Bearer%20token")
+	t.Setenv(traceparentEnv, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s := &supervisor{tracer: newTracer()}
+	at := time.Now()
+	for _, e := range []Event{
+		{Type: EventChildStarted, Time: at, PID: 42},
+		{Type: EventChildReady, Time: at.Add(time.Second), PID: 42},
+		{Type: EventSignalReceived, Time: at.Add(2 * time.Second), Signal: syscall.SIGURG},
+		{Type: EventSignalReceived, Time: at.Add(2 * time.Second), Signal: syscall.SIGTERM},
+		{Type: EventStopTimerArmed, Time: at.Add(2 * time.Second), PID: 42},
+		{Type: EventForcedKill, Time: at.Add(3 * time.Second), PID: 42},
+		{Type: EventChildExited, Time: at.Add(3 * time.Second), PID: 42, ExitCode: 137},
+	} {
+		s.tracer.event(e)
+	}
+	s.stopTracing(137)
+	if got, want := col.names(), "psi.drain,psi.forced_kill,psi.lifecycle,psi.readiness,psi.signal,psi.start"; got != want {
+		t.Fatalf("spans = %s, want %s", got, want)
+	}
+	var root otlpSpan
+	for _, sp := range col.spans {
+		if sp.Name == "psi.lifecycle" {
+			root = sp
+		}
+	}
+	if root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("lifecycle span = %+v", root)
+	}
+	for _, sp := range col.spans {
+		if sp.TraceID != root.TraceID || (sp.Name != "psi.lifecycle" && sp.ParentSpanID != root.SpanID) {
+			t.Fatalf("span %s is not part of the lifecycle: %+v", sp.Name, sp)
+		}
+		if (sp.Name == "psi.drain" || sp.Name == "psi.forced_kill") && sp.Status.Code != otlpStatusError {
+			t.Fatalf("span %s must be an error", sp.Name)
+		}
+	}
+	for _, auth := range col.auth {
+		if auth != "Bearer token" {
+			t.Fatalf("Authorization = %q", auth)
+		}
+	}
+}
+
+func TestSupervisorTracing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-events", helperCountEnv+"="+countFile, otelEndpointEnv+"="+srv.URL)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile+".child", "running\n")
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	if got, want := col.names(), "psi.drain,psi.lifecycle,psi.readiness,psi.signal,psi.start"; got != want {
+		t.Fatalf("spans = %s, want %s", got, want)
+	}
+}
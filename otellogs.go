@@ -0,0 +1,303 @@
+package psi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Standard OpenTelemetry variables read by the init's log exporter.
+const (
+	otelLogsEndpointEnv = "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"
+	otelLogsHeadersEnv  = "OTEL_EXPORTER_OTLP_LOGS_HEADERS"
+	otelLogsProtocolEnv = "OTEL_EXPORTER_OTLP_LOGS_PROTOCOL"
+	otelLogsExporterEnv = "OTEL_LOGS_EXPORTER"
+	otelBLRPDelayEnv    = "OTEL_BLRP_SCHEDULE_DELAY"
+	otelBLRPQueueEnv    = "OTEL_BLRP_MAX_QUEUE_SIZE"
+	otelBLRPBatchEnv    = "OTEL_BLRP_MAX_EXPORT_BATCH_SIZE"
+)
+
+const (
+	defaultLogExportDelay = time.Second
+	defaultLogQueueSize   = 2048
+	defaultLogBatchSize   = 512
+	// logExportFlushTimeout bounds the wait for the queued records to be
+	// exported when the init exits.
+	logExportFlushTimeout = 5 * time.Second
+)
+
+var otlpLogs = otlpSignal{
+	name:        "log export",
+	endpointEnv: otelLogsEndpointEnv,
+	headersEnv:  otelLogsHeadersEnv,
+	protocolEnv: otelLogsProtocolEnv,
+	exporterEnv: otelLogsExporterEnv,
+	path:        "/v1/logs",
+}
+
+// OTLP severity numbers.
+const (
+	otlpSeverityDebug = 5
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// otlpLogRecord follows the OTLP JSON encoding of a log record.
+type otlpLogRecord struct {
+	Time           string         `json:"timeUnixNano"`
+	ObservedTime   string         `json:"observedTimeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpValue      `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// logExporter batches the child's output and the lifecycle events as log
+// records and exports them as OTLP/HTTP JSON from a goroutine started with
+// the first record. Records are dropped while the queue is full and
+// batches the collector fails to take are dropped too, as the
+// OpenTelemetry SDKs do.
+type logExporter struct {
+	otlpExporter
+	resource []otlpKeyValue
+	delay    time.Duration
+	maxQueue int
+	maxBatch int
+
+	start sync.Once
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	queue   []otlpLogRecord
+	dropped bool
+
+	// failing belongs to the exporting goroutine.
+	failing bool
+}
+
+// newLogExporter returns the log exporter configured by the OTEL_*
+// variables, or nil when log export is off.
+func newLogExporter() *logExporter {
+	exp, ok := newOTLPExporter(otlpLogs)
+	if !ok {
+		return nil
+	}
+	l := &logExporter{
+		otlpExporter: exp,
+		resource:     otelResource(),
+		maxQueue:     defaultLogQueueSize,
+		maxBatch:     defaultLogBatchSize,
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	delayMS := int(defaultLogExportDelay / time.Millisecond)
+	envInt(otelBLRPDelayEnv, 1, math.MaxInt32, &delayMS)
+	l.delay = time.Duration(delayMS) * time.Millisecond
+	envInt(otelBLRPQueueEnv, 1, math.MaxInt32, &l.maxQueue)
+	envInt(otelBLRPBatchEnv, 1, math.MaxInt32, &l.maxBatch)
+	l.maxBatch = min(l.maxBatch, l.maxQueue)
+	return l
+}
+
+// attachLogExport adds line sinks exporting the output of the process
+// called name.
+func (s *supervisor) attachLogExport(o *childOutput, name string) {
+	if s.logExporter == nil {
+		return
+	}
+	o.stdout.lineSinks = append(o.stdout.lineSinks, &otlpLogWriter{l: s.logExporter, name: name, stream: "stdout", severity: otlpSeverityInfo})
+	o.stderr.lineSinks = append(o.stderr.lineSinks, &otlpLogWriter{l: s.logExporter, name: name, stream: "stderr", severity: otlpSeverityError})
+}
+
+// startLogExport exports the lifecycle events too.
+func (s *supervisor) startLogExport() {
+	if s.logExporter != nil {
+		addEventHandler(s.logExporter.event)
+		s.cfg.debugf(1, "exporting logs to %s", s.logExporter.endpoint)
+	}
+}
+
+// stopLogExport exports the queued records, waiting at most
+// logExportFlushTimeout.
+func (s *supervisor) stopLogExport() {
+	if s.logExporter != nil {
+		s.logExporter.close(logExportFlushTimeout)
+	}
+}
+
+// otlpLogWriter is a line sink turning each line of a stream into a record.
+type otlpLogWriter struct {
+	l        *logExporter
+	name     string
+	stream   string
+	severity int
+}
+
+func (w *otlpLogWriter) Write(p []byte) (int, error) {
+	w.l.add(newOTLPLogRecord(time.Now(), w.severity, string(bytes.TrimSuffix(p, []byte("\n"))),
+		stringAttr("log.iostream", w.stream),
+		stringAttr("process.executable.name", w.name),
+	))
+	return len(p), nil
+}
+
+// event exports e as a record.
+func (l *logExporter) event(e Event) {
+	level, msg, ok := eventMessage(e)
+	if !ok {
+		return
+	}
+	severity := otlpSeverityInfo
+	switch {
+	case level <= LogDebug:
+		severity = otlpSeverityDebug
+	case level == LogWarn:
+		severity = otlpSeverityWarn
+	case level >= LogError:
+		severity = otlpSeverityError
+	}
+	attrs := []otlpKeyValue{stringAttr("event.name", "psi."+string(e.Type))}
+	if e.PID > 0 {
+		attrs = append(attrs, intAttr("process.pid", e.PID))
+	}
+	if e.Signal != 0 {
+		attrs = append(attrs, stringAttr("signal", signalName(e.Signal)))
+	}
+	if e.Exit != nil {
+		attrs = append(attrs, intAttr("process.exit.code", e.ExitCode))
+	}
+	l.add(newOTLPLogRecord(e.Time, severity, msg, attrs...))
+}
+
+// newOTLPLogRecord returns a record of msg at t, now if zero.
+func newOTLPLogRecord(t time.Time, severity int, msg string, attrs ...otlpKeyValue) otlpLogRecord {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	text := "INFO"
+	switch severity {
+	case otlpSeverityDebug:
+		text = "DEBUG"
+	case otlpSeverityWarn:
+		text = "WARN"
+	case otlpSeverityError:
+		text = "ERROR"
+	}
+	return otlpLogRecord{
+		Time:           unixNano(t),
+		ObservedTime:   unixNano(time.Now()),
+		SeverityNumber: severity,
+		SeverityText:   text,
+		Body:           otlpValue{StringValue: &msg},
+		Attributes:     attrs,
+	}
+}
+
+// add queues rec, dropping it if the queue is full.
+func (l *logExporter) add(rec otlpLogRecord) {
+	l.start.Do(func() { go l.run() })
+	l.mu.Lock()
+	if len(l.queue) >= l.maxQueue {
+		if !l.dropped {
+			l.dropped = true
+			log.Printf("psi: log export queue full; dropping records")
+		}
+		l.mu.Unlock()
+		return
+	}
+	l.queue = append(l.queue, rec)
+	full := len(l.queue) >= l.maxBatch
+	l.mu.Unlock()
+	if full {
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports the queue whenever a batch is full or the schedule delay has
+// passed, and a last time when stopped.
+func (l *logExporter) run() {
+	defer close(l.done)
+	tick := time.NewTicker(l.delay)
+	defer tick.Stop()
+	for {
+		select {
+		case <-l.wake:
+		case <-tick.C:
+		case <-l.stop:
+			l.flush()
+			return
+		}
+		l.flush()
+	}
+}
+
+// flush exports the queued records in batches of at most maxBatch.
+func (l *logExporter) flush() {
+	for {
+		l.mu.Lock()
+		n := min(len(l.queue), l.maxBatch)
+		batch := l.queue[:n:n]
+		l.queue = l.queue[n:]
+		if len(l.queue) == 0 {
+			l.queue, l.dropped = nil, false
+		}
+		l.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		l.export(batch)
+	}
+}
+
+// export sends batch to the collector.
+func (l *logExporter) export(batch []otlpLogRecord) {
+	body, err := json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": l.resource},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]string{"name": otelInstrumentationLib},
+				"logRecords": batch,
+			}},
+		}},
+	})
+	if err != nil {
+		return
+	}
+	if err := l.post(body); err != nil {
+		if !l.failing {
+			l.failing = true
+			log.Printf("psi: exporting logs: %v", err)
+		}
+		return
+	}
+	if l.failing {
+		l.failing = false
+		log.Printf("psi: exporting logs to %s resumed", l.endpoint)
+	}
+}
+
+// close stops the exporter after exporting the queued records, waiting at
+// most timeout.
+func (l *logExporter) close(timeout time.Duration) {
+	l.start.Do(func() { close(l.done) })
+	select {
+	case <-l.stop:
+		return
+	default:
+	}
+	close(l.stop)
+	select {
+	case <-l.done:
+	case <-time.After(timeout):
+	}
+}
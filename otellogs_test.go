@@ -0,0 +1,157 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewLogExporterEnv(t *testing.T) {
+	if newLogExporter() != nil {
+		t.Fatal("log export must be off without an endpoint")
+	}
+	t.Setenv(otelEndpointEnv, "http://collector:4318")
+	t.Setenv(otelBLRPDelayEnv, "250")
+	t.Setenv(otelBLRPQueueEnv, "100")
+	t.Setenv(otelBLRPBatchEnv, "1000")
+	l := newLogExporter()
+	if l == nil || l.endpoint != "http://collector:4318/v1/logs" {
+		t.Fatalf("exporter = %+v", l)
+	}
+	if l.delay != 250*time.Millisecond || l.maxQueue != 100 || l.maxBatch != 100 {
+		t.Fatalf("delay %v, queue %d, batch %d", l.delay, l.maxQueue, l.maxBatch)
+	}
+	t.Setenv(otelLogsEndpointEnv, "http://logs:4318/custom")
+	if l := newLogExporter(); l.endpoint != "http://logs:4318/custom" {
+		t.Fatalf("endpoint = %q", l.endpoint)
+	}
+	t.Setenv(otelLogsExporterEnv, "none")
+	if newLogExporter() != nil {
+		t.Fatal("OTEL_LOGS_EXPORTER=none must turn log export off")
+	}
+	if newTracer() == nil {
+		t.Fatal("OTEL_LOGS_EXPORTER=none must leave tracing on")
+	}
+}
+
+// logCollector records the log records posted to it.
+type logCollector struct {
+	mu      sync.Mutex
+	records []otlpLogRecord
+}
+
+func (c *logCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []otlpLogRecord `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if r.URL.Path != "/v1/logs" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			c.records = append(c.records, sl.LogRecords...)
+		}
+	}
+}
+
+// find returns the record with body msg.
+func (c *logCollector) find(msg string) (otlpLogRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rec := range c.records {
+		if rec.Body.StringValue != nil && *rec.Body.StringValue == msg {
+			return rec, true
+		}
+	}
+	return otlpLogRecord{}, false
+}
+
+// logAttrs returns rec's attributes as strings.
+func logAttrs(rec otlpLogRecord) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range rec.Attributes {
+		switch {
+		case kv.Value.StringValue != nil:
+			m[kv.Key] = *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			m[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	return m
+}
+
+func TestLogExporterBatches(t *testing.T) {
+	col := &logCollector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+	t.Setenv(otelEndpointEnv, srv.URL)
+	t.Setenv(otelBLRPBatchEnv, "2")
+	s := &supervisor{cfg: newConfig(), logExporter: newLogExporter()}
+	var out childOutput
+	s.attachLogExport(&out, "web")
+	out.stdout.lineSinks[0].Write([]byte("one\n"))
+	out.stderr.lineSinks[0].Write([]byte("two\n"))
+	s.logExporter.event(Event{Type: EventChildExited, Time: time.Now(), PID: 7, ExitCode: 2, Exit: &ExitSummary{ExitCode: 2}})
+	s.stopLogExport()
+
+	rec, ok := col.find("one")
+	if !ok || rec.SeverityNumber != otlpSeverityInfo {
+		t.Fatalf("stdout record = %+v, %v", rec, ok)
+	}
+	if a := logAttrs(rec); a["log.iostream"] != "stdout" || a["process.executable.name"] != "web" {
+		t.Fatalf("stdout attributes = %v", a)
+	}
+	if rec, ok := col.find("two"); !ok || rec.SeverityText != "ERROR" || logAttrs(rec)["log.iostream"] != "stderr" {
+		t.Fatalf("stderr record = %+v, %v", rec, ok)
+	}
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if len(col.records) != 3 {
+		t.Fatalf("records = %+v", col.records)
+	}
+	if a := logAttrs(col.records[2]); a["event.name"] != "psi.child_exited" || a["process.pid"] != "7" || a["process.exit.code"] != "2" {
+		t.Fatalf("event attributes = %v", a)
+	}
+}
+
+func TestSupervisorExportsLogs(t *testing.T) {
+	col := &logCollector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+	cmd := helperCommand("init-prefixed", otelLogsEndpointEnv+"="+srv.URL+"/v1/logs")
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	if !strings.Contains(stdout.String(), "out") {
+		t.Fatalf("output not forwarded: %q", stdout.String())
+	}
+	for msg, stream := range map[string]string{"out": "stdout", "err": "stderr", "proxy up": "stdout"} {
+		rec, ok := col.find(msg)
+		if !ok || logAttrs(rec)["log.iostream"] != stream {
+			t.Errorf("record for %q = %+v, %v", msg, rec, ok)
+		}
+	}
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	for _, rec := range col.records {
+		if logAttrs(rec)["event.name"] == "psi.child_started" {
+			return
+		}
+	}
+	t.Fatalf("no child_started event in %+v", col.records)
+}
@@ -0,0 +1,248 @@
+package psi
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// childOutput routes the child's stdout and stderr. A stream without sinks
+// that is forwarded goes to the init's own descriptor directly; otherwise
+// the child writes to a pipe the init copies to the init's descriptor
+// (unless dropped) and to the sinks: the termination message's lineTail,
+// the crash buffer and the log files.
+type childOutput struct {
+	stdout, stderr outputStream
+	// copies tracks the goroutines copying child output.
+	copies sync.WaitGroup
+}
+
+// outputStream is where one of the child's streams goes besides, unless
+// drop is set, the init's own. filters rewrite each line forwarded to the
+// init's own, in order, and sinkFilters each line written to the sinks.
+// lineSinks get one Write per line, newline included. limit, if set, rate
+// limits the lines forwarded.
+type outputStream struct {
+	sinks       []io.Writer
+	lineSinks   []io.Writer
+	drop        bool
+	filters     []lineFilter
+	sinkFilters []lineFilter
+	limit       *rateLimiter
+}
+
+// piped reports whether the stream needs a pipe.
+func (st *outputStream) piped() bool {
+	return len(st.sinks) > 0 || len(st.lineSinks) > 0 || st.drop || len(st.filters) > 0 || st.limit != nil
+}
+
+// attach points cmd's stdout and stderr, initially the init's, at pipes
+// where needed. The returned function closes the write ends and must be
+// called after Start.
+func (o *childOutput) attach(cmd *exec.Cmd) (func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	for _, st := range []struct {
+		stream *outputStream
+		target *io.Writer
+	}{{&o.stdout, &cmd.Stdout}, {&o.stderr, &cmd.Stderr}} {
+		if !st.stream.piped() {
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		o.copy(st.stream, *st.target, r)
+		*st.target = w
+		closers = append(closers, func() { w.Close() })
+	}
+	return closeAll, nil
+}
+
+// copy copies r, until it fails, to the sinks of st and, unless dropped,
+// forward, and closes it.
+func (o *childOutput) copy(st *outputStream, forward io.Writer, r io.ReadCloser) {
+	var dst fanout
+	var lines []*lineWriter
+	if !st.drop {
+		if len(st.filters) > 0 || st.limit != nil {
+			l := &lineWriter{dst: forward, filters: st.filters, limit: st.limit}
+			lines, forward = append(lines, l), l
+		}
+		dst = append(dst, forward)
+	}
+	byteSinks, lineSinks := st.sinks, st.lineSinks
+	if len(st.sinkFilters) > 0 {
+		byteSinks, lineSinks = nil, append(append([]io.Writer(nil), lineSinks...), byteSinks...)
+	}
+	dst = append(dst, byteSinks...)
+	if len(lineSinks) > 0 {
+		l := &lineWriter{dst: fanout(lineSinks), filters: st.sinkFilters}
+		lines, dst = append(lines, l), append(dst, l)
+	}
+	o.copies.Add(1)
+	go func() {
+		defer o.copies.Done()
+		io.Copy(dst, r)
+		for _, l := range lines {
+			l.flush()
+		}
+		r.Close()
+	}()
+}
+
+// flush waits up to timeout for the copied output to reach EOF, which is
+// late or never when the child's descendants still hold it.
+func (o *childOutput) flush(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		o.copies.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// fanout writes to every writer, carrying on past failures: one broken
+// destination must not stop the copy and block the child on a full pipe.
+type fanout []io.Writer
+
+func (f fanout) Write(p []byte) (int, error) {
+	for _, w := range f {
+		w.Write(p)
+	}
+	return len(p), nil
+}
+
+// addLineFilters sets up the filters of the output streams of the process
+// called name. Each wraps the result of the one before. The streams share a
+// rate limit.
+func (c *config) addLineFilters(o *childOutput, name string) {
+	redact := c.newRedactor()
+	if limit := newRateLimiter(c.logRate, c.logBurst); limit != nil {
+		o.stdout.limit, o.stderr.limit = limit, limit
+	}
+	for _, st := range []struct {
+		stream *outputStream
+		name   string
+	}{{&o.stdout, "stdout"}, {&o.stderr, "stderr"}} {
+		if c.logStripANSI {
+			st.stream.filters = append(st.stream.filters, stripANSI)
+			st.stream.sinkFilters = append(st.stream.sinkFilters, stripANSI)
+		}
+		if redact != nil {
+			st.stream.filters = append(st.stream.filters, redact.line)
+			st.stream.sinkFilters = append(st.stream.sinkFilters, redact.line)
+		}
+		if c.logWrapJSON {
+			st.stream.filters = append(st.stream.filters, lineJSON(name, st.name))
+			continue
+		}
+		if c.prefixLines() {
+			st.stream.filters = append(st.stream.filters, linePrefix(name, st.name))
+		}
+		if c.logTimestamps {
+			st.stream.filters = append(st.stream.filters, lineTimestamp)
+		}
+	}
+}
+
+// maxLineLength bounds the partial line a lineWriter buffers; longer lines
+// are split.
+const maxLineLength = 64 << 10
+
+// lineFilter rewrites a line, given without its newline.
+type lineFilter func(line []byte) []byte
+
+// lineWriter writes whole lines, each passed through the filters, to dst
+// with one Write each, so lines from processes sharing dst never mix. An
+// unterminated line is held until its newline, flush, or maxLineLength.
+// Lines over limit, if set, are dropped and counted in suppressed.
+type lineWriter struct {
+	dst        io.Writer
+	filters    []lineFilter
+	limit      *rateLimiter
+	suppressed int
+	buf        []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.buf = append(l.buf, p...)
+			if len(l.buf) >= maxLineLength {
+				n := len(l.buf) - len(l.buf)%maxLineLength
+				l.emit(l.buf[:n])
+				l.buf = append(l.buf[:0], l.buf[n:]...)
+			}
+			break
+		}
+		if len(l.buf) > 0 {
+			l.buf = append(l.buf, p[:i]...)
+			l.emit(l.buf)
+			l.buf = l.buf[:0]
+		} else {
+			l.emit(p[:i])
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush writes out an unterminated last line and the count of lines the
+// limit suppressed.
+func (l *lineWriter) flush() {
+	if len(l.buf) > 0 {
+		l.emit(l.buf)
+		l.buf = l.buf[:0]
+	}
+	if l.suppressed > 0 {
+		l.write(suppressedLine(l.suppressed))
+		l.suppressed = 0
+	}
+}
+
+// emit writes line, split into pieces of at most maxLineLength, unless the
+// limit suppresses them.
+func (l *lineWriter) emit(line []byte) {
+	for {
+		piece := line
+		if len(piece) > maxLineLength {
+			piece = piece[:maxLineLength]
+		}
+		line = line[len(piece):]
+		if l.limit != nil && !l.limit.allow(time.Now()) {
+			l.suppressed++
+		} else {
+			if l.suppressed > 0 {
+				l.write(suppressedLine(l.suppressed))
+				l.suppressed = 0
+			}
+			l.write(piece)
+		}
+		if len(line) == 0 {
+			return
+		}
+	}
+}
+
+// write writes a piece of a line through the filters.
+func (l *lineWriter) write(piece []byte) {
+	for _, f := range l.filters {
+		piece = f(piece)
+	}
+	l.dst.Write(append(piece[:len(piece):len(piece)], '\n'))
+}
@@ -0,0 +1,176 @@
+package psi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const pprofAddrEnv = "PSI_PPROF_ADDR"
+
+// WithPprofAddr makes the init serve debugging endpoints for itself on addr,
+// e.g. "localhost:6060": the net/http/pprof paths under /debug/pprof/
+// (profile, trace and the named profiles such as goroutine and heap), the
+// expvar document at /debug/vars, and POST /debug/child/stacks, which asks
+// the child to dump its stacks by sending it ?signal= (SIGQUIT for an
+// external program, e.g. a JVM, and for a Go submain the signal of
+// WithDiagSignal, or SIGUSR1, by default).
+// Nothing is registered on http.DefaultServeMux. Serving only happens while
+// supervising. Overridden by PSI_PPROF_ADDR.
+func WithPprofAddr(addr string) Option {
+	return func(c *config) {
+		c.pprofAddr = addr
+	}
+}
+
+// loadPprofEnv applies the PSI_PPROF_ADDR override.
+func (c *config) loadPprofEnv() {
+	if val := strings.TrimSpace(os.Getenv(pprofAddrEnv)); val != "" {
+		c.pprofAddr = val
+	}
+}
+
+// stackDumpSignal is the signal that makes the child dump its stacks when
+// the request does not name one.
+func (c *config) stackDumpSignal() syscall.Signal {
+	if len(c.command) > 0 {
+		return syscall.SIGQUIT
+	}
+	if c.diagSignal != 0 {
+		return c.diagSignal
+	}
+	return sigGoStackDump
+}
+
+// durationParam reads a "seconds" query parameter, def when absent.
+func durationParam(r *http.Request, def time.Duration) (time.Duration, error) {
+	val := r.URL.Query().Get("seconds")
+	if val == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid seconds %q", val)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// sleepFor waits d or until the client goes away.
+func sleepFor(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+// debugHandler serves the pprof, expvar and child stack dump endpoints.
+func (s *supervisor) debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/pprof/{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "profile\ntrace")
+		for _, p := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s (%d)\n", p.Name(), p.Count())
+		}
+	})
+	mux.HandleFunc("GET /debug/pprof/profile", func(w http.ResponseWriter, r *http.Request) {
+		d, err := durationParam(r, 30*time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleepFor(r, d)
+		pprof.StopCPUProfile()
+	})
+	mux.HandleFunc("GET /debug/pprof/trace", func(w http.ResponseWriter, r *http.Request) {
+		d, err := durationParam(r, time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := trace.Start(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleepFor(r, d)
+		trace.Stop()
+	})
+	mux.HandleFunc("GET /debug/pprof/{name}", func(w http.ResponseWriter, r *http.Request) {
+		p := pprof.Lookup(r.PathValue("name"))
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		if r.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+		p.WriteTo(w, debug)
+	})
+	mux.HandleFunc("GET /debug/vars", func(w http.ResponseWriter, _ *http.Request) {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]any{"cmdline": os.Args, "memstats": ms})
+	})
+	mux.HandleFunc("POST /debug/child/stacks", func(w http.ResponseWriter, r *http.Request) {
+		sig := s.cfg.stackDumpSignal()
+		if name := r.URL.Query().Get("signal"); name != "" {
+			var err error
+			if sig, err = ParseSignal(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		report := s.status.report(time.Now())
+		if !report.Running {
+			http.Error(w, "child not running", http.StatusServiceUnavailable)
+			return
+		}
+		emit(Event{Type: EventSignalForwarded, PID: report.PID, Signal: sig})
+		if err := kill(report.PID, sig); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.cfg.debugf(1, "sent %s to child (pid %d) for a stack dump", signalName(sig), report.PID)
+		fmt.Fprintf(w, "sent %s to pid %d\n", signalName(sig), report.PID)
+	})
+	return mux
+}
+
+// serveDebug starts the debugging endpoint if configured. Failing to listen
+// is logged and otherwise ignored.
+func (s *supervisor) serveDebug() {
+	if s.cfg.pprofAddr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", s.cfg.pprofAddr)
+	if err != nil {
+		log.Printf("psi: pprof endpoint disabled: %v", err)
+		return
+	}
+	s.cfg.debugf(1, "serving pprof on %s", ln.Addr())
+	srv := &http.Server{Handler: s.debugHandler(), ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+}
@@ -0,0 +1,100 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	s := newSupervisor(newConfig())
+	srv := httptest.NewServer(s.debugHandler())
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, body := get("/debug/pprof/"); code != http.StatusOK || !strings.Contains(body, "goroutine (") {
+		t.Fatalf("index = %d %q", code, body)
+	}
+	if code, body := get("/debug/pprof/goroutine?debug=1"); code != http.StatusOK || !strings.Contains(body, "TestDebugHandler") {
+		t.Fatalf("goroutine profile = %d %q", code, body)
+	}
+	if code, _ := get("/debug/pprof/nope"); code != http.StatusNotFound {
+		t.Fatalf("unknown profile = %d", code)
+	}
+	if code, _ := get("/debug/pprof/profile?seconds=x"); code != http.StatusBadRequest {
+		t.Fatalf("invalid seconds = %d", code)
+	}
+	code, body := get("/debug/vars")
+	var vars struct {
+		Cmdline  []string
+		Memstats struct{ HeapAlloc uint64 }
+	}
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &vars) != nil || len(vars.Cmdline) == 0 || vars.Memstats.HeapAlloc == 0 {
+		t.Fatalf("vars = %d %q", code, body)
+	}
+}
+
+func TestChildStacks(t *testing.T) {
+	s := newSupervisor(newConfig())
+	srv := httptest.NewServer(s.debugHandler())
+	defer srv.Close()
+	post := func(query string) int {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/debug/child/stacks"+query, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(""); code != http.StatusServiceUnavailable {
+		t.Fatalf("without a child = %d", code)
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("sleep unavailable: %v", err)
+	}
+	defer cmd.Process.Kill()
+	s.status.childStarted(cmd.Process.Pid, 0, 1, time.Now())
+	if code := post("?signal=bogus"); code != http.StatusBadRequest {
+		t.Fatalf("invalid signal = %d", code)
+	}
+	if code := post("?signal=SIGTERM"); code != http.StatusOK {
+		t.Fatalf("stack dump request = %d", code)
+	}
+	err := cmd.Wait()
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); !ok || !ws.Signaled() || ws.Signal() != syscall.SIGTERM {
+		t.Fatalf("child did not get SIGTERM: %v", err)
+	}
+}
+
+func TestStackDumpSignal(t *testing.T) {
+	if sig := newConfig().stackDumpSignal(); sig != syscall.SIGUSR1 {
+		t.Fatalf("Go submain signal = %v", sig)
+	}
+	c := newConfig()
+	c.command = []string{"java", "-jar", "app.jar"}
+	if sig := c.stackDumpSignal(); sig != syscall.SIGQUIT {
+		t.Fatalf("external program signal = %v", sig)
+	}
+	t.Setenv(pprofAddrEnv, "localhost:6060")
+	if c := newConfig(WithPprofAddr(":1")); c.pprofAddr != "localhost:6060" {
+		t.Fatalf("pprofAddr = %q", c.pprofAddr)
+	}
+}
@@ -0,0 +1,25 @@
+package psi
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setParentDeathSignal asks the kernel to deliver sig to the init when its
+// parent process dies.
+func setParentDeathSignal(sig syscall.Signal) error {
+	return unix.Prctl(unix.PR_SET_PDEATHSIG, uintptr(sig), 0, 0, 0)
+}
+
+// setChildSubreaper marks the init as a child subreaper so orphaned
+// descendants are re-parented to it instead of the real PID 1.
+func setChildSubreaper() error {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
+
+// setNoNewPrivs sets the calling thread's no_new_privs flag, which is
+// inherited by processes it forks and cannot be cleared.
+func setNoNewPrivs() error {
+	return unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !freebsd
+
+package psi
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setParentDeathSignal(syscall.Signal) error {
+	return errors.New("parent-death signal is only supported on Linux")
+}
+
+func setChildSubreaper() error {
+	return errors.New("child subreaper is only supported on Linux")
+}
+
+func setNoNewPrivs() error {
+	return errors.New("no_new_privs is only supported on Linux")
+}
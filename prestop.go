@@ -0,0 +1,123 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	preStopCmdEnv     = "PSI_PRESTOP_CMD"
+	preStopSleepEnv   = "PSI_PRESTOP_SLEEP"
+	preStopTimeoutEnv = "PSI_PRESTOP_TIMEOUT"
+)
+
+// WithPreStop runs h when the first terminate signal arrives, before the
+// stop signal is forwarded to the child, e.g. to deregister from a load
+// balancer while the child keeps serving. The hook's time counts against
+// the first step of the stop chain (PSI_STOP_TIMEOUT by default): once that
+// runs out, the hook is killed and shutdown proceeds. h.Timeout bounds the
+// hook on its own. Overridden by PSI_PRESTOP_CMD (a command line split on
+// spaces) and PSI_PRESTOP_TIMEOUT.
+func WithPreStop(h Hook) Option {
+	return func(c *config) {
+		c.preStop = h.clone()
+	}
+}
+
+// WithPreStopSleep waits d after the pre-stop hook, if any, before the stop
+// signal is forwarded, to let load balancers notice the pod is terminating.
+// Like the hook, it counts against the stop timeout. Overridden by
+// PSI_PRESTOP_SLEEP.
+func WithPreStopSleep(d time.Duration) Option {
+	return func(c *config) {
+		c.preStopSleep = d
+	}
+}
+
+// loadPreStopEnv applies the PSI_PRESTOP_* overrides.
+func (c *config) loadPreStopEnv() {
+	if argv := strings.Fields(os.Getenv(preStopCmdEnv)); len(argv) > 0 {
+		c.preStop = Hook{Path: argv[0], Args: argv[1:], Timeout: c.preStop.Timeout}
+	}
+	envDuration(preStopTimeoutEnv, &c.preStop.Timeout)
+	envDuration(preStopSleepEnv, &c.preStopSleep)
+}
+
+// beginPreStop runs the pre-stop hook and sleep in the background, holding
+// back sig, the first step's signal, until they finish. It reports false if
+// there is nothing to run.
+func (s *supervisor) beginPreStop(sig syscall.Signal) bool {
+	h, sleep := s.cfg.preStop, s.cfg.preStopSleep
+	if h.Path == "" && sleep <= 0 {
+		return false
+	}
+	done, abort := make(chan struct{}), make(chan struct{})
+	s.preStopSignal, s.preStopDone, s.preStopAbort = sig, done, abort
+	go func() {
+		defer close(done)
+		if h.Path != "" {
+			s.runPreStopHook(h, abort)
+		}
+		if sleep > 0 {
+			s.cfg.debugf(1, "pre-stop: sleeping %s", sleep)
+			select {
+			case <-time.After(sleep):
+			case <-abort:
+			}
+		}
+	}()
+	return true
+}
+
+// runPreStopHook runs h until it exits, times out or abort is closed.
+func (s *supervisor) runPreStopHook(h Hook, abort <-chan struct{}) {
+	proc, exited, err := s.reaper.start(s.hookCommand(h))
+	if err != nil {
+		log.Printf("psi: pre-stop hook: %v", err)
+		return
+	}
+	defer proc.close()
+	s.cfg.debugf(1, "running pre-stop hook %s (pid %d)", h.Path, proc.pid)
+	var timeout <-chan time.Time
+	if h.Timeout > 0 {
+		timeout = time.After(h.Timeout)
+	}
+	select {
+	case code := <-exited:
+		if code != 0 {
+			log.Printf("psi: pre-stop hook %s exited with code %d", h.Path, code)
+		}
+		return
+	case <-timeout:
+		log.Printf("psi: pre-stop hook %s did not finish within %s; killing", h.Path, h.Timeout)
+	case <-abort:
+		log.Printf("psi: pre-stop hook %s ran out of stop timeout; killing", h.Path)
+	}
+	_ = proc.signalGroup(syscall.SIGKILL)
+	<-exited
+}
+
+// endPreStop stops a pre-stop phase still running and forwards the held
+// back signal to the child.
+func (s *supervisor) endPreStop() {
+	if s.preStopDone == nil {
+		return
+	}
+	sig := s.preStopSignal
+	s.cancelPreStop()
+	s.signalAll(sig)
+}
+
+// cancelPreStop stops a pre-stop phase still running, e.g. because the child
+// exited meanwhile.
+func (s *supervisor) cancelPreStop() {
+	if s.preStopDone == nil {
+		return
+	}
+	close(s.preStopAbort)
+	<-s.preStopDone
+	s.preStopSignal, s.preStopDone, s.preStopAbort = 0, nil, nil
+}
@@ -0,0 +1,18 @@
+package psi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreStopEnv(t *testing.T) {
+	t.Setenv(preStopCmdEnv, "/bin/sh -c deregister")
+	t.Setenv(preStopSleepEnv, "5")
+	c := newConfig(WithPreStop(Hook{Path: "/bin/true", Timeout: time.Second}))
+	if c.preStop.Path != "/bin/sh" || len(c.preStop.Args) != 2 || c.preStop.Timeout != time.Second {
+		t.Fatalf("preStop = %+v", c.preStop)
+	}
+	if c.preStopSleep != 5*time.Second {
+		t.Fatalf("preStopSleep = %s, want 5s", c.preStopSleep)
+	}
+}
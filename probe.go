@@ -0,0 +1,253 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	livenessExecEnv      = "PSI_LIVENESS_EXEC"
+	livenessTCPEnv       = "PSI_LIVENESS_TCP"
+	livenessHTTPEnv      = "PSI_LIVENESS_HTTP"
+	livenessIntervalEnv  = "PSI_LIVENESS_INTERVAL"
+	livenessTimeoutEnv   = "PSI_LIVENESS_TIMEOUT"
+	livenessDelayEnv     = "PSI_LIVENESS_DELAY"
+	livenessThresholdEnv = "PSI_LIVENESS_THRESHOLD"
+
+	defaultProbeInterval  = 10 * time.Second
+	defaultProbeTimeout   = time.Second
+	defaultProbeThreshold = 3
+)
+
+// Probe checks the child's health from the init. Exactly one of Exec, TCP and
+// HTTP must be set.
+type Probe struct {
+	// Exec is a command (path and arguments) that must exit 0.
+	Exec []string
+	// TCP is a host:port that must accept a connection.
+	TCP string
+	// HTTP is a URL whose GET must answer with a 2xx or 3xx status.
+	HTTP string
+	// Interval is the time between checks (default 10s).
+	Interval time.Duration
+	// Timeout bounds a single check (default 1s).
+	Timeout time.Duration
+	// InitialDelay postpones the first check after the child starts.
+	InitialDelay time.Duration
+	// FailureThreshold is the number of consecutive failed checks after
+	// which the child is stopped (default 3).
+	FailureThreshold int
+}
+
+// WithLivenessProbe checks the child periodically with p once it has
+// started (see WithStartTimeout and WithReadyNotify). After
+// p.FailureThreshold consecutive failures the child is sent the stop signal,
+// killed if it outlives PSI_STOP_TIMEOUT, and handled by the restart policy
+// with exit code ExitLivenessFailed. Overridden by PSI_LIVENESS_EXEC (a
+// command line split on spaces), PSI_LIVENESS_TCP, PSI_LIVENESS_HTTP,
+// PSI_LIVENESS_INTERVAL, PSI_LIVENESS_TIMEOUT, PSI_LIVENESS_DELAY and
+// PSI_LIVENESS_THRESHOLD.
+func WithLivenessProbe(p Probe) Option {
+	return func(c *config) {
+		c.liveness = p
+		c.liveness.Exec = append([]string(nil), p.Exec...)
+	}
+}
+
+// loadEnv applies the PSI_LIVENESS_* overrides.
+func (p *Probe) loadEnv() {
+	execArgs := strings.Fields(os.Getenv(livenessExecEnv))
+	tcp := strings.TrimSpace(os.Getenv(livenessTCPEnv))
+	url := strings.TrimSpace(os.Getenv(livenessHTTPEnv))
+	set := 0
+	for _, ok := range []bool{len(execArgs) > 0, tcp != "", url != ""} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		log.Printf("psi: invalid liveness probe: set only one of %s, %s and %s; ignoring", livenessExecEnv, livenessTCPEnv, livenessHTTPEnv)
+	case set == 1:
+		p.Exec, p.TCP, p.HTTP = execArgs, tcp, url
+	}
+	envDuration(livenessIntervalEnv, &p.Interval)
+	envDuration(livenessTimeoutEnv, &p.Timeout)
+	envDuration(livenessDelayEnv, &p.InitialDelay)
+	if val := strings.TrimSpace(os.Getenv(livenessThresholdEnv)); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 {
+			log.Printf("psi: invalid %s=%q; ignoring", livenessThresholdEnv, val)
+		} else {
+			p.FailureThreshold = n
+		}
+	}
+}
+
+func (p *Probe) enabled() bool {
+	return len(p.Exec) > 0 || p.TCP != "" || p.HTTP != ""
+}
+
+func (p *Probe) interval() time.Duration {
+	if p.Interval <= 0 {
+		return defaultProbeInterval
+	}
+	return p.Interval
+}
+
+func (p *Probe) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return p.Timeout
+}
+
+func (p *Probe) threshold() int {
+	if p.FailureThreshold <= 0 {
+		return defaultProbeThreshold
+	}
+	return p.FailureThreshold
+}
+
+// check runs the probe once. Exec probes are started through the reaper,
+// which owns all waiting in the init.
+func (p *Probe) check(r *reaper, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout())
+	defer cancel()
+	switch {
+	case len(p.Exec) > 0:
+		cmd := exec.Command(p.Exec[0], p.Exec[1:]...)
+		cmd.Env = env
+		cmd.SysProcAttr = newSysProcAttr(nil)
+		h, done, err := r.start(cmd)
+		if err != nil {
+			return err
+		}
+		defer h.close()
+		select {
+		case code := <-done:
+			if code != 0 {
+				return fmt.Errorf("%s exited with code %d", p.Exec[0], code)
+			}
+			return nil
+		case <-ctx.Done():
+			_ = h.signalGroup(syscall.SIGKILL)
+			<-done
+			return fmt.Errorf("%s timed out after %s", p.Exec[0], p.timeout())
+		}
+	case p.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("GET %s: %s", p.HTTP, resp.Status)
+		}
+		return nil
+	}
+	return errors.New("no probe configured")
+}
+
+// probeResult is the outcome of a check against child generation gen.
+type probeResult struct {
+	gen int
+	err error
+}
+
+// beginProbes schedules the first liveness check for a newly started child.
+func (s *supervisor) beginProbes() {
+	s.probeFailures, s.unhealthy, s.unhealthyKill = 0, false, nil
+	s.probeDue = nil
+	if s.cfg.liveness.enabled() {
+		s.probeDue = time.After(s.cfg.liveness.InitialDelay)
+	}
+}
+
+// runProbe checks the current child in the background.
+func (s *supervisor) runProbe() {
+	s.probeDue = nil
+	if s.esc.started() {
+		return
+	}
+	gen, env := s.generation, s.cfg.childEnv(os.Environ())
+	go func() {
+		s.probeResults <- probeResult{gen: gen, err: s.cfg.liveness.check(s.reaper, env)}
+	}()
+}
+
+// probeDone handles a liveness check's result and schedules the next one.
+func (s *supervisor) probeDone(res probeResult) {
+	if res.gen != s.generation || s.unhealthy || s.esc.started() {
+		return
+	}
+	s.probeDue = time.After(s.cfg.liveness.interval())
+	if s.starting {
+		// Failures only count once the child has started.
+		return
+	}
+	if res.err == nil {
+		s.probeFailures = 0
+		return
+	}
+	s.probeFailures++
+	threshold := s.cfg.liveness.threshold()
+	log.Printf("psi: liveness probe failed (%d/%d): %v", s.probeFailures, threshold, res.err)
+	if s.probeFailures < threshold {
+		return
+	}
+	s.stopUnhealthy("failed its liveness probe")
+}
+
+// stopUnhealthy sends the stop signal to a child found unhealthy and arms
+// the timer that kills it if it outlives the stop timeout.
+func (s *supervisor) stopUnhealthy(reason string) {
+	if s.unhealthy || s.esc.started() {
+		return
+	}
+	log.Printf("psi: child (pid %d) %s; stopping it", s.childPID, reason)
+	s.probeDue, s.watchdogDue = nil, nil
+	s.unhealthy = true
+	s.signalChild(s.cfg.forwardSignal(syscall.SIGTERM))
+	s.unhealthyKill = time.After(s.stopTimeout)
+	tracef("kill timer armed for %s (pid %d)", s.stopTimeout, s.childPID)
+}
+
+// unhealthyKillDue kills an unhealthy child that outlived the stop timeout.
+func (s *supervisor) unhealthyKillDue() {
+	s.unhealthyKill = nil
+	log.Printf("psi: child (pid %d) did not stop within %s; killing", s.childPID, s.stopTimeout)
+	s.signalChild(syscall.SIGKILL)
+}
+
+// unhealthyExitCode returns the exit code to act on for a child that exited
+// with code, replacing it when the child was stopped as unhealthy.
+func (s *supervisor) unhealthyExitCode(code int) int {
+	unhealthy := s.unhealthy
+	s.probeDue, s.watchdogDue, s.unhealthyKill, s.unhealthy = nil, nil, nil, false
+	if !unhealthy || s.esc.started() {
+		return code
+	}
+	return ExitLivenessFailed
+}
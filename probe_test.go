@@ -0,0 +1,67 @@
+package psi
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProbeEnv(t *testing.T) {
+	t.Setenv(livenessExecEnv, "/app/healthcheck --quick")
+	t.Setenv(livenessIntervalEnv, "5")
+	t.Setenv(livenessThresholdEnv, "2")
+	cfg := newConfig(WithLivenessProbe(Probe{HTTP: "http://127.0.0.1/healthz", Timeout: 2 * time.Second}))
+	p := cfg.liveness
+	if !slices.Equal(p.Exec, []string{"/app/healthcheck", "--quick"}) || p.HTTP != "" {
+		t.Fatalf("probe = %+v; PSI_LIVENESS_EXEC must replace the HTTP probe", p)
+	}
+	if p.interval() != 5*time.Second || p.timeout() != 2*time.Second || p.threshold() != 2 {
+		t.Fatalf("probe timing = %s, %s, %d", p.interval(), p.timeout(), p.threshold())
+	}
+
+	t.Setenv(livenessTCPEnv, "127.0.0.1:1")
+	if cfg := newConfig(); cfg.liveness.enabled() {
+		t.Fatalf("conflicting probe variables must be ignored, got %+v", cfg.liveness)
+	}
+}
+
+func TestProbeDefaults(t *testing.T) {
+	var p Probe
+	if p.enabled() || p.interval() != defaultProbeInterval || p.timeout() != defaultProbeTimeout || p.threshold() != defaultProbeThreshold {
+		t.Fatalf("unexpected zero probe: %+v", p)
+	}
+}
+
+func TestProbeCheckNetwork(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := Probe{HTTP: srv.URL}
+	if err := p.check(nil, nil); err != nil {
+		t.Fatalf("HTTP probe: %v", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := p.check(nil, nil); err == nil {
+		t.Fatal("HTTP probe passed on 503")
+	}
+
+	p = Probe{TCP: srv.Listener.Addr().String()}
+	if err := p.check(nil, nil); err != nil {
+		t.Fatalf("TCP probe: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	p = Probe{TCP: ln.Addr().String()}
+	if err := p.check(nil, nil); err == nil {
+		t.Fatal("TCP probe passed against a closed port")
+	}
+}
@@ -0,0 +1,19 @@
+package psi
+
+import "syscall"
+
+// procHandle refers to a process started by the init as the leader of its
+// own process group. On Linux it carries a pidfd so signals can never reach
+// an unrelated process that recycled the PID.
+type procHandle struct {
+	pid int
+	// pidfd is -1 when pidfds are unavailable (non-Linux, old kernels).
+	pidfd int
+	// job is the process's Job Object on Windows, 0 if none.
+	job uintptr
+	// done receives the exit code once the process is reaped, after status
+	// and rusage have been filled in.
+	done   chan int
+	status syscall.WaitStatus
+	rusage syscall.Rusage
+}
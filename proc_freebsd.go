@@ -0,0 +1,31 @@
+package psi
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// requestPidfd yields no descriptor: os/exec forks with fork(2), so the
+// process descriptors of pdfork(2) are out of reach. Reaper subtrees give the
+// same guarantee for signals instead.
+func requestPidfd(_ *exec.Cmd, fd *int) { *fd = -1 }
+
+// signalGroup sends sig to the tree led by h. While the init is a reaper
+// (PID 1 of a jail, or subreaper mode) it goes through PROC_REAP_KILL, which
+// reaches the child's whole subtree, daemons that called setsid included,
+// and nothing outside it. Otherwise it signals the process group.
+func (h *procHandle) signalGroup(sig syscall.Signal) error {
+	if isReaper() {
+		if err := killSubtree(h.pid, sig); err == nil {
+			return nil
+		}
+	}
+	return syscall.Kill(-h.pid, sig)
+}
+
+// signal sends sig to h's process alone.
+func (h *procHandle) signal(sig syscall.Signal) error {
+	return syscall.Kill(h.pid, sig)
+}
+
+func (h *procHandle) close() {}
@@ -0,0 +1,60 @@
+package psi
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// pidfdSignalProcessGroup is PIDFD_SIGNAL_PROCESS_GROUP (Linux 6.9+), not yet
+// exported by x/sys/unix.
+const pidfdSignalProcessGroup = 0x4
+
+// requestPidfd asks exec to return a pidfd for cmd (CLONE_PIDFD). Older
+// kernels yield -1.
+func requestPidfd(cmd *exec.Cmd, fd *int) {
+	*fd = -1
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.PidFD = fd
+}
+
+// signalGroup sends sig to the process group led by h. Reaping stays on the
+// init's Wait4(-1) loop, which cannot race: a PID is not reused until the
+// reaper has collected it. Signalling is what races, as it may happen after
+// the leader has been reaped, so it goes through the pidfd when available.
+func (h *procHandle) signalGroup(sig syscall.Signal) error {
+	if h.pidfd < 0 {
+		return syscall.Kill(-h.pid, sig)
+	}
+	err := unix.PidfdSendSignal(h.pidfd, sig, nil, pidfdSignalProcessGroup)
+	if !errors.Is(err, unix.EINVAL) {
+		return err
+	}
+	// Kernels before 6.9 cannot signal a group through a pidfd. Probe the
+	// leader instead: while it exists its PID, and thus the group ID, cannot
+	// have been recycled.
+	if err := unix.PidfdSendSignal(h.pidfd, 0, nil, 0); err != nil {
+		return err
+	}
+	return syscall.Kill(-h.pid, sig)
+}
+
+// signal sends sig to h's process alone, through the pidfd when available.
+func (h *procHandle) signal(sig syscall.Signal) error {
+	if h.pidfd < 0 {
+		return syscall.Kill(h.pid, sig)
+	}
+	return unix.PidfdSendSignal(h.pidfd, sig, nil, 0)
+}
+
+// close releases the pidfd.
+func (h *procHandle) close() {
+	if h.pidfd >= 0 {
+		_ = unix.Close(h.pidfd)
+		h.pidfd = -1
+	}
+}
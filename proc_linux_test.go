@@ -0,0 +1,42 @@
+package psi
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestProcHandleSignalGroup(t *testing.T) {
+	r := newReaper()
+	cmd := exec.Command("sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	h, done, err := r.start(cmd)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer h.close()
+	if h.pidfd < 0 {
+		t.Log("kernel does not provide pidfds; exercising kill(-pgid) fallback")
+	}
+	if err := h.signalGroup(syscall.SIGTERM); err != nil {
+		t.Fatalf("signalGroup: %v", err)
+	}
+	for {
+		if _, err := r.reapOne(0); err != nil && err != syscall.EINTR {
+			t.Fatalf("reapOne: %v", err)
+		}
+		select {
+		case code := <-done:
+			if code != 128+int(syscall.SIGTERM) {
+				t.Fatalf("expected exit code %d, got %d", 128+int(syscall.SIGTERM), code)
+			}
+			if h.pidfd >= 0 {
+				if err := h.signalGroup(syscall.SIGTERM); err == nil {
+					t.Fatal("signalling a reaped process through its pidfd should fail")
+				}
+			}
+			return
+		default:
+		}
+	}
+}
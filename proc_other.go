@@ -0,0 +1,22 @@
+//go:build !linux && !windows && !freebsd
+
+package psi
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func requestPidfd(_ *exec.Cmd, fd *int) { *fd = -1 }
+
+// signalGroup sends sig to the process group led by h.
+func (h *procHandle) signalGroup(sig syscall.Signal) error {
+	return syscall.Kill(-h.pid, sig)
+}
+
+// signal sends sig to h's process alone.
+func (h *procHandle) signal(sig syscall.Signal) error {
+	return syscall.Kill(h.pid, sig)
+}
+
+func (h *procHandle) close() {}
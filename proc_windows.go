@@ -0,0 +1,61 @@
+package psi
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func requestPidfd(_ *exec.Cmd, fd *int) { *fd = -1 }
+
+// newJob creates a Job Object that kills its processes when its last handle
+// is closed, and assigns pid to it. Processes pid starts from then on join
+// it as well.
+func newJob(pid int) (uintptr, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	defer windows.CloseHandle(proc)
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		windows.CloseHandle(job)
+		return 0, err
+	}
+	return uintptr(job), nil
+}
+
+// signalGroup emulates sending sig to h's process tree: SIGKILL terminates
+// its Job Object, other signals go through kill.
+func (h *procHandle) signalGroup(sig syscall.Signal) error {
+	if sig == syscall.SIGKILL && h.job != 0 {
+		return windows.TerminateJobObject(windows.Handle(h.job), 128+uint32(syscall.SIGKILL))
+	}
+	return kill(h.pid, sig)
+}
+
+// signal sends sig to h's process alone, emulated by kill.
+func (h *procHandle) signal(sig syscall.Signal) error {
+	return kill(h.pid, sig)
+}
+
+// close releases the Job Object, killing whatever is left in it.
+func (h *procHandle) close() {
+	if h.job != 0 {
+		windows.CloseHandle(windows.Handle(h.job))
+		h.job = 0
+	}
+}
@@ -0,0 +1,102 @@
+package psi
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// procctl(2) commands and flags, from sys/procctl.h.
+const (
+	procReapAcquire      = 2
+	procReapStatus       = 4
+	procReapKill         = 6
+	procPdeathsigCtl     = 11
+	procNoNewPrivsCtl    = 19
+	procNoNewPrivsEnable = 1
+
+	reaperStatusOwned = 0x1
+	reaperKillSubtree = 0x2
+)
+
+// reaperStatus is struct procctl_reaper_status.
+type reaperStatus struct {
+	flags       uint32
+	children    uint32
+	descendants uint32
+	reaper      int32
+	pid         int32
+	_           [15]uint32
+}
+
+// reaperKill is struct procctl_reaper_kill.
+type reaperKill struct {
+	sig     int32
+	flags   uint32
+	subtree int32
+	killed  uint32
+	fpid    int32
+	_       [15]uint32
+}
+
+// procctl runs cmd on the calling process (P_PID with id 0). The id is an
+// id_t, 64 bits wide, so 32-bit ABIs pass it in two words, aligned to an
+// even register on arm.
+func procctl(cmd int, data unsafe.Pointer) error {
+	var errno syscall.Errno
+	switch {
+	case unsafe.Sizeof(uintptr(0)) == 8:
+		_, _, errno = syscall.Syscall6(unix.SYS_PROCCTL, 0, 0, uintptr(cmd), uintptr(data), 0, 0)
+	case runtime.GOARCH == "arm":
+		_, _, errno = syscall.Syscall6(unix.SYS_PROCCTL, 0, 0, 0, 0, uintptr(cmd), uintptr(data))
+	default:
+		_, _, errno = syscall.Syscall6(unix.SYS_PROCCTL, 0, 0, 0, uintptr(cmd), uintptr(data), 0)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setParentDeathSignal asks the kernel to deliver sig to the init when its
+// parent process dies (PROC_PDEATHSIG_CTL).
+func setParentDeathSignal(sig syscall.Signal) error {
+	n := int32(sig)
+	return procctl(procPdeathsigCtl, unsafe.Pointer(&n))
+}
+
+// setChildSubreaper makes the init the reaper of its descendants
+// (PROC_REAP_ACQUIRE), so orphans are re-parented to it instead of the real
+// init, e.g. when psi is the init of a jail.
+func setChildSubreaper() error {
+	return procctl(procReapAcquire, nil)
+}
+
+// setNoNewPrivs sets the no_new_privs flag (FreeBSD 14+), which is inherited
+// by processes the init forks and cannot be cleared.
+func setNoNewPrivs() error {
+	n := int32(procNoNewPrivsEnable)
+	return procctl(procNoNewPrivsCtl, unsafe.Pointer(&n))
+}
+
+// isReaper reports whether the init is the reaper of its descendants.
+func isReaper() bool {
+	var st reaperStatus
+	return procctl(procReapStatus, unsafe.Pointer(&st)) == nil && st.flags&reaperStatusOwned != 0
+}
+
+// killSubtree sends sig to every descendant in the reaper subtree rooted at
+// the direct child pid (PROC_REAP_KILL), including processes that left its
+// process group and orphans re-parented to the init. It must only be used
+// while the init is a reaper: otherwise the kernel would act on the real
+// init's subtree.
+func killSubtree(pid int, sig syscall.Signal) error {
+	rk := reaperKill{sig: int32(sig), flags: reaperKillSubtree, subtree: int32(pid)}
+	if err := procctl(procReapKill, unsafe.Pointer(&rk)); err != nil {
+		return err
+	}
+	tracef("procctl: sent %s to %d processes in subtree %d", signalName(sig), rk.killed, pid)
+	return nil
+}
@@ -0,0 +1,29 @@
+package psi
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestKillSubtree(t *testing.T) {
+	if err := setChildSubreaper(); err != nil {
+		t.Fatalf("setChildSubreaper: %v", err)
+	}
+	if !isReaper() {
+		t.Fatal("isReaper()=false after PROC_REAP_ACQUIRE")
+	}
+	cmd := exec.Command("/bin/sh", "-c", "sleep 30 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	h := &procHandle{pid: cmd.Process.Pid, pidfd: -1}
+	if err := h.signalGroup(syscall.SIGKILL); err != nil {
+		t.Fatalf("signalGroup: %v", err)
+	}
+	err := cmd.Wait()
+	if ws := cmd.ProcessState.Sys().(syscall.WaitStatus); ws.Signal() != syscall.SIGKILL {
+		t.Fatalf("child was not killed: %v", err)
+	}
+}
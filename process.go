@@ -0,0 +1,152 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// initReaper is the reaper of the init running in this process, nil
+// elsewhere.
+var initReaper atomic.Pointer[reaper]
+
+// Processes starts subprocesses and waits for them without racing the
+// init's reap loop. In the init, whose Wait4(-1) loop collects every child
+// it has, exec.Cmd.Wait would find the process already reaped and fail with
+// ECHILD, so code running there (see WithSupervisor) must start processes
+// through Processes, which takes their exit over from the loop. Elsewhere,
+// e.g. in the child running submain or when psi does not supervise, no such
+// loop runs and it falls back to exec.Cmd.Wait, so the same code works
+// wherever it runs. (The init's own handle is Supervisor.)
+type Processes struct {
+	ctx context.Context
+	r   *reaper
+}
+
+// NewProcesses returns a Processes killing the processes it started that
+// are still running when ctx is done, like exec.CommandContext.
+//
+//	p, err := psi.NewProcesses(ctx).Start(exec.Command("pg_dump", "app"))
+//	if err != nil {
+//		return err
+//	}
+//	code := p.Wait()
+func NewProcesses(ctx context.Context) *Processes {
+	return &Processes{ctx: ctx, r: initReaper.Load()}
+}
+
+// Process is a subprocess started by Processes.
+type Process struct {
+	cmd *exec.Cmd
+	// proc is the reaper's handle, nil when exec.Cmd.Wait waits. mu keeps
+	// it from being closed, which sets closed, while signalling.
+	mu     sync.Mutex
+	proc   *procHandle
+	closed bool
+	done   chan struct{}
+	code   int
+}
+
+// Start starts cmd, which must not have been started yet and must not be
+// waited for with its own Wait: use the returned Process's.
+func (ps *Processes) Start(cmd *exec.Cmd) (*Process, error) {
+	if err := ps.ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := &Process{cmd: cmd, done: make(chan struct{})}
+	var exited <-chan int
+	if ps.r != nil {
+		proc, done, err := ps.r.start(cmd)
+		if err != nil {
+			return nil, err
+		}
+		p.proc, exited = proc, done
+	} else {
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		exited = p.waitCmd()
+	}
+	go p.wait(ps.ctx, exited)
+	return p, nil
+}
+
+// waitCmd waits for the process with exec.Cmd.Wait and sends its exit code.
+func (p *Process) waitCmd() <-chan int {
+	exited := make(chan int, 1)
+	go func() {
+		p.cmd.Wait()
+		code := 1
+		if st := p.cmd.ProcessState; st != nil {
+			if ws, ok := st.Sys().(syscall.WaitStatus); ok {
+				code = exitCode(ws)
+			}
+		}
+		exited <- code
+	}()
+	return exited
+}
+
+// wait waits for the process to exit, killing it if ctx is done first.
+func (p *Process) wait(ctx context.Context, exited <-chan int) {
+	select {
+	case p.code = <-exited:
+	case <-ctx.Done():
+		p.Signal(os.Kill)
+		p.code = <-exited
+	}
+	if p.proc != nil {
+		// The reap loop has collected the process: Wait fails, but joins
+		// the goroutines copying its output and closes the pipes.
+		p.cmd.Wait()
+		p.mu.Lock()
+		p.proc.close()
+		p.closed = true
+		p.mu.Unlock()
+	}
+	close(p.done)
+}
+
+// PID returns the process ID.
+func (p *Process) PID() int {
+	return p.cmd.Process.Pid
+}
+
+// Signal sends sig to the process. Started in the init, it goes through
+// the process's pidfd where the kernel provides one, so it cannot hit
+// another process that reused the PID. It fails with os.ErrProcessDone
+// once the process has been reaped.
+func (p *Process) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc == nil {
+		return p.cmd.Process.Signal(sig)
+	}
+	if p.closed {
+		return os.ErrProcessDone
+	}
+	s, ok := toSyscallSignal(sig)
+	if !ok {
+		return fmt.Errorf("psi: cannot send %v", sig)
+	}
+	if err := p.proc.signal(s); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// Wait waits for the process to exit, and for its output to be copied if
+// cmd's Stdout or Stderr is not a file, and returns its exit code, 128+N if
+// it was killed by signal N.
+func (p *Process) Wait() int {
+	<-p.done
+	return p.code
+}
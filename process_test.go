@@ -0,0 +1,76 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcessesWithoutReaper(t *testing.T) {
+	var out bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", "echo hi; exit 3")
+	cmd.Stdout = &out
+	p, err := NewProcesses(context.Background()).Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := p.Wait(); code != 3 || out.String() != "hi\n" {
+		t.Fatalf("exit code %d, output %q", code, out.String())
+	}
+}
+
+func TestProcessesKillOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err := NewProcesses(ctx).Start(exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if code := p.Wait(); code != 128+int(syscall.SIGKILL) {
+		t.Fatalf("exit code %d", code)
+	}
+	if _, err := NewProcesses(ctx).Start(exec.Command("true")); err != context.Canceled {
+		t.Fatalf("Start after cancel: %v", err)
+	}
+}
+
+func TestProcessSignalThroughReaper(t *testing.T) {
+	r := newReaper()
+	p, err := (&Processes{ctx: context.Background(), r: r}).Start(exec.Command("sleep", "30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.proc == nil {
+		t.Fatal("process not started through the reaper")
+	}
+	// The process shares the test's process group: only it may get the
+	// signal.
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("Signal: %v", err)
+	}
+	if _, err := r.reapOne(0); err != nil {
+		t.Fatalf("reapOne: %v", err)
+	}
+	if code := p.Wait(); code != 128+int(syscall.SIGTERM) {
+		t.Fatalf("exit code %d", code)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != os.ErrProcessDone {
+		t.Fatalf("Signal after exit: %v", err)
+	}
+}
+
+func TestSupervisorProcesses(t *testing.T) {
+	countFile := filepath.Join(t.TempDir(), "count")
+	cmd := helperCommand("init-processes", helperCountEnv+"="+countFile)
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	waitForContent(t, countFile, "5 hi\n")
+}
@@ -0,0 +1,29 @@
+package psi
+
+import "log"
+
+const mountProcEnv = "PSI_MOUNT_PROC"
+
+// WithMountProc mounts procfs at /proc during init startup if nothing is
+// mounted there yet, as on scratch images run by raw runc. Needs
+// CAP_SYS_ADMIN; Linux only. Overridden by PSI_MOUNT_PROC.
+func WithMountProc() Option {
+	return func(c *config) {
+		c.mountProc = true
+	}
+}
+
+// applyMountProc mounts /proc when enabled and missing.
+func (c *config) applyMountProc() {
+	if !c.mountProc {
+		return
+	}
+	mounted, err := mountProc()
+	if err != nil {
+		log.Printf("psi: cannot mount /proc: %v", err)
+		return
+	}
+	if mounted {
+		c.debugf(1, "mounted proc at /proc")
+	}
+}
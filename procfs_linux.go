@@ -0,0 +1,29 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountProc mounts procfs at /proc unless it is already there. It reports
+// whether it mounted anything.
+func mountProc() (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs("/proc", &st); err == nil && st.Type == unix.PROC_SUPER_MAGIC {
+		return false, nil
+	}
+	if err := os.Mkdir("/proc", 0o555); err != nil && !errors.Is(err, os.ErrExist) {
+		return false, err
+	}
+	flags := uintptr(unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC)
+	if err := unix.Mount("proc", "/proc", "proc", flags, ""); err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return false, fmt.Errorf("%w (needs CAP_SYS_ADMIN)", err)
+		}
+		return false, err
+	}
+	return true, nil
+}
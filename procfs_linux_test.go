@@ -0,0 +1,10 @@
+package psi
+
+import "testing"
+
+func TestMountProcAlreadyMounted(t *testing.T) {
+	mounted, err := mountProc()
+	if err != nil || mounted {
+		t.Fatalf("mountProc() = %v, %v; want no-op on a mounted /proc", mounted, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func mountProc() (bool, error) {
+	return false, errors.New("mounting /proc is only supported on Linux")
+}
@@ -0,0 +1,68 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const dumpTreeOnKillEnv = "PSI_DUMP_TREE_ON_KILL"
+
+// WithDumpTreeOnKill makes the init log its surviving process tree (pid,
+// ppid, state and command name of every descendant) as warnings before it
+// kills the child with SIGKILL, showing which subprocess ignored the stop
+// signal. Needs /proc (Linux). Overridden by PSI_DUMP_TREE_ON_KILL.
+func WithDumpTreeOnKill() Option {
+	return func(c *config) {
+		c.dumpTreeOnKill = true
+	}
+}
+
+// procInfo is a process as listed in /proc.
+type procInfo struct {
+	pid, ppid int
+	state     string
+	comm      string
+}
+
+// processTree renders the descendants of root, one indented line per
+// process, children ordered by PID.
+func processTree(procs []procInfo, root int) []string {
+	children := make(map[int][]procInfo)
+	for _, p := range procs {
+		if p.pid != p.ppid {
+			children[p.ppid] = append(children[p.ppid], p)
+		}
+	}
+	var lines []string
+	var walk func(pid, depth int)
+	walk = func(pid, depth int) {
+		kids := children[pid]
+		sort.Slice(kids, func(i, j int) bool { return kids[i].pid < kids[j].pid })
+		for _, p := range kids {
+			lines = append(lines, fmt.Sprintf("%spid %d ppid %d state %s %s",
+				strings.Repeat("  ", depth), p.pid, p.ppid, p.state, p.comm))
+			walk(p.pid, depth+1)
+		}
+	}
+	walk(root, 1)
+	return lines
+}
+
+// dumpTree logs the init's process tree before a forced kill if enabled.
+func (c *config) dumpTree() {
+	if !c.dumpTreeOnKill {
+		return
+	}
+	procs, err := listProcs()
+	if err != nil {
+		logMessage(LogWarn, fmt.Sprintf("cannot dump the process tree: %v", err))
+		return
+	}
+	lines := processTree(procs, os.Getpid())
+	logMessage(LogWarn, fmt.Sprintf("process tree before SIGKILL (%d processes):", len(lines)))
+	for _, line := range lines {
+		logMessage(LogWarn, line)
+	}
+}
@@ -0,0 +1,50 @@
+package psi
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listProcs reads every process from /proc. Processes exiting meanwhile are
+// skipped.
+func listProcs() ([]procInfo, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var procs []procInfo
+	for _, ent := range entries {
+		pid, err := strconv.Atoi(ent.Name())
+		if err != nil {
+			continue
+		}
+		if p, ok := procStat(pid); ok {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
+
+// procStat parses /proc/<pid>/stat. The command name in parentheses may
+// contain anything, so the fields are counted after the last ')'.
+func procStat(pid int) (procInfo, bool) {
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return procInfo{}, false
+	}
+	open, end := strings.IndexByte(string(b), '('), strings.LastIndexByte(string(b), ')')
+	if open < 0 || end < open {
+		return procInfo{}, false
+	}
+	// After the name: state, ppid, ...
+	fields := strings.Fields(string(b[end+1:]))
+	if len(fields) < 2 {
+		return procInfo{}, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procInfo{}, false
+	}
+	return procInfo{pid: pid, ppid: ppid, state: fields[0], comm: string(b[open+1 : end])}, true
+}
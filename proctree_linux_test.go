@@ -0,0 +1,40 @@
+package psi
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcStat(t *testing.T) {
+	p, ok := procStat(os.Getpid())
+	if !ok {
+		t.Fatal("cannot read own stat")
+	}
+	if p.ppid != os.Getppid() || p.state == "" || p.comm == "" {
+		t.Fatalf("procStat = %+v", p)
+	}
+}
+
+func TestSupervisorDumpsTreeOnKill(t *testing.T) {
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-stubborn", helperCountEnv+"="+countFile, dumpTreeOnKillEnv+"=1", stopTimeoutEnv+"=200ms")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, countFile, "running\n")
+	time.Sleep(100 * time.Millisecond)
+	cmd.Process.Signal(syscall.SIGTERM)
+	if exit := exitStatus(cmd.Wait()); exit != ExitStopTimeout {
+		t.Fatalf("expected exit code %d, got %d (stderr=%q)", ExitStopTimeout, exit, stderr.String())
+	}
+	for _, want := range []string{"psi: process tree before SIGKILL (2 processes):\n", "psi:   pid ", " state S sh\n", " state S sleep\n"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("stderr lacks %q:\n%s", want, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func listProcs() ([]procInfo, error) {
+	return nil, errors.New("listing processes needs /proc (Linux only)")
+}
@@ -0,0 +1,33 @@
+package psi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProcessTree(t *testing.T) {
+	procs := []procInfo{
+		{pid: 1, ppid: 0, state: "S", comm: "psi"},
+		{pid: 30, ppid: 7, state: "S", comm: "sleep"},
+		{pid: 7, ppid: 1, state: "S", comm: "sh"},
+		{pid: 9, ppid: 1, state: "Z", comm: "orphan"},
+		{pid: 12, ppid: 7, state: "R", comm: "my worker"},
+		{pid: 40, ppid: 2, state: "S", comm: "elsewhere"},
+	}
+	want := []string{
+		"  pid 7 ppid 1 state S sh",
+		"    pid 12 ppid 7 state R my worker",
+		"    pid 30 ppid 7 state S sleep",
+		"  pid 9 ppid 1 state Z orphan",
+	}
+	if got := processTree(procs, 1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("processTree = %q, want %q", got, want)
+	}
+}
+
+func TestDumpTreeOnKillEnv(t *testing.T) {
+	t.Setenv(dumpTreeOnKillEnv, "1")
+	if c := newConfig(); !c.dumpTreeOnKill {
+		t.Fatal("PSI_DUMP_TREE_ON_KILL=1 not applied")
+	}
+}
@@ -3,6 +3,222 @@
 // proper signal forwarding (to the child's process group), zombie reaping, and
 // a configurable forced-shutdown timeout via PSI_STOP_TIMEOUT (default 30s).
 //
+// Behaviour is tuned with options passed to Run, a YAML configuration file
+// (see WithConfigFile) or, overriding both, environment variables:
+//
+//	PSI_CONFIG          configuration file (default /etc/psi/psi.yaml if it exists)
+//	PSI_STOP_TIMEOUT    grace period before SIGKILL ("30s", "1m", "45")
+//	PSI_STOP_SIGNAL     signal forwarded on shutdown instead of the received one
+//	PSI_STOP_CHAIN      escalation sequence, e.g. "SIGTERM:20s,SIGINT:5s,SIGKILL"
+//	PSI_STOP_EXTEND_MAX the most the child may push the next stop step back by in total
+//	                    with psi.RequestExtraTime (sd_notify EXTEND_TIMEOUT_USEC), e.g. "5m"
+//	PSI_IGNORE_SIGNALS  signals never forwarded to the child, e.g. "SIGHUP"
+//	PSI_SIGNAL_MAP      signal translation, e.g. "SIGHUP:SIGUSR1,SIGINT:SIGTERM"
+//	PSI_HUP_ACTION      SIGHUP handling: terminate (default), reload (forward without
+//	                    starting shutdown) or ignore
+//	PSI_FORCE_ON_SECOND_SIGNAL=1  SIGKILL on a second terminate signal
+//	PSI_CLEAN_EXIT_ON_STOP=1  exit 0 when the child dies of the stop signal sent on a
+//	                    terminate signal instead of 128+N (a forced SIGKILL still exits 124)
+//	PSI_TERMINATION_LOG report how the child ended and its last stderr lines to this
+//	                    file on exit, or to /dev/termination-log if set to 1
+//	PSI_CRASH_BUFFER    keep the child's last stderr output (64KiB if set to 1, or a size
+//	                    like 256K) and log it when the child exits abnormally
+//	PSI_STDIN           the child's stdin: inherit (default), null (/dev/null), closed
+//	                    (at end of file) or open (held open, never written to)
+//	PSI_PTY=1           run the child on a pseudo-terminal proxied to the init's (Linux)
+//	PSI_LOG_DIR         also write the child's output to stdout.log and stderr.log here
+//	PSI_LOG_MAX_SIZE    rotate those files before they exceed this size (default 10M)
+//	PSI_LOG_MAX_FILES   rotated files kept per stream (default 5)
+//	PSI_LOG_MAX_AGE     rotate those files once this old, e.g. "24h" (default never)
+//	PSI_LOG_FILES_ONLY=1  write the child's output to PSI_LOG_DIR only
+//	PSI_LOG_PREFIX      prefix forwarded output lines with "[name/stream] ": 1, 0 or
+//	                    auto (default: only with sidecars)
+//	PSI_CHILD_NAME      the child's name in those prefixes (default: its program's name)
+//	PSI_LOG_TIMESTAMPS=1  start forwarded output lines with an RFC 3339 UTC timestamp
+//	PSI_LOG_WRAP_JSON=1 forward output lines as {"ts","stream","app","msg"} JSON records,
+//	                    passing lines that are JSON objects through
+//	PSI_LOG_STRIP_ANSI=1  remove ANSI escape sequences from the child's output
+//	PSI_REDACT          mask secrets in the child's output and the init's log: variable
+//	                    names whose values to mask ("API_KEY,*_TOKEN") and /regexps/
+//	PSI_LOG_RATE        forward at most this many output lines per process, e.g. "100/s",
+//	                    "6000/m" or "100/s,500" with a burst, noting the lines suppressed
+//	PSI_JOURNALD=1      also send the child's output and the lifecycle events to journald
+//	                    over its native protocol, when it is running
+//	PSI_FLUENT_ADDR     also ship the child's output to this Fluentd/Fluent Bit forward
+//	                    input, "host:port" or "unix:/path"
+//	PSI_FLUENT_TAG      the tag of those records (default "psi")
+//	PSI_FLUENT_BUFFER   records kept while it is unreachable (default 8M)
+//	PSI_PIDFILE         file holding the running child's PID, e.g. "/run/app.pid"
+//	PSI_STATE_FILE      JSON file kept up to date with the child's PID, generation, start
+//	                    time, status and last exit, e.g. "/run/app.state.json"
+//	PSI_PROCESS_TITLE   the init's title in ps (default "psi[init] <child argv>"),
+//	                    "off" to keep the argv it shares with the child
+//	PSI_REEXEC_PATH     binary re-executed to run submain instead of the running one
+//	                    (/proc/self/exe), e.g. to start an upgraded binary
+//	PSI_RESTART         child restart policy: never, always or on-failure
+//	PSI_RESTART_DELAY   initial restart backoff (default 1s, doubles per restart and
+//	                    starts over once a generation stays up for PSI_MIN_UPTIME)
+//	PSI_RESTART_MAX_DELAY  backoff cap (default 30s)
+//	PSI_MAX_RESTARTS    restart cap, 0 for unlimited
+//	PSI_MIN_UPTIME      exits sooner than this count towards crash-loop detection
+//	PSI_CRASH_LOOP_LIMIT  consecutive fast exits tolerated (default 5)
+//	PSI_RESTART_REQUESTS=1  let the child ask for a restart (psi.RequestRestart or
+//	                    sd_notify X_PSI_RESTART=1), whatever the restart policy
+//	PSI_START_TIMEOUT   a child exiting this soon after starting failed to start (exit 121)
+//	PSI_READY_NOTIFY=1  wait for the child to report readiness (psi.Ready, $PSI_READY_FD
+//	                    or sd_notify READY=1 on $NOTIFY_SOCKET)
+//	PSI_LIVENESS_EXEC   liveness probe command, e.g. "/app/healthcheck --quick"
+//	PSI_LIVENESS_TCP    liveness probe address that must accept connections, e.g. "127.0.0.1:8080"
+//	PSI_LIVENESS_HTTP   liveness probe URL that must answer 2xx/3xx
+//	PSI_LIVENESS_INTERVAL, PSI_LIVENESS_TIMEOUT, PSI_LIVENESS_DELAY  probe timing (10s, 1s, 0)
+//	PSI_LIVENESS_THRESHOLD  consecutive failures before the child is restarted (default 3)
+//	PSI_WATCHDOG        the child must send sd_notify WATCHDOG=1 this often once started
+//	PSI_LISTEN          sockets bound by the init and passed to the child (fd 3 and up,
+//	                    LISTEN_FDS/LISTEN_FDNAMES), e.g. "http=tcp::8080,unix:/run/app.sock"
+//	PSI_UPGRADE_SIGNAL  signal that starts a new child generation on the same sockets and
+//	                    stops the old one once the new one is ready, e.g. "SIGUSR2"
+//	PSI_HEALTH_ADDR     serve /healthz, /readyz and /status (JSON) over HTTP, e.g. ":9097"
+//	PSI_CONTROL_SOCKET  unix socket taking status, send-signal, stop, restart and
+//	                    set-log-level commands (words or JSON, one per line), e.g. "/run/psi.sock"
+//	PSI_SIGNAL_AUDIT    append a JSON line per signal received or sent, stop timer armed,
+//	                    forced kill and control command (the latter with its sender) to this file
+//	PSI_METRICS_ADDR    serve Prometheus metrics (restarts, reaps, forwarded signals, forced
+//	                    kills, child uptime, stop duration) at /metrics, e.g. ":9098"
+//	PSI_PPROF_ADDR      serve pprof and expvar for the init and POST /debug/child/stacks
+//	                    (signals the child to dump its stacks), e.g. "localhost:6060"
+//	PSI_DIAG_SIGNAL     a Go submain dumps all goroutine stacks on this signal, e.g. SIGUSR1
+//	PSI_DIAG_FILE       append those dumps to this file instead of stderr
+//	PSI_DIAG_HEAP=1     add a heap profile (text form) to each dump
+//	PSI_PRE_START       command run before the first child starts, e.g. "/app/migrate up";
+//	                    the init exits with 123 if it fails
+//	PSI_POST_STOP       command run after the last child exits, e.g. "/app/flush"
+//	PSI_PRE_START_TIMEOUT, PSI_POST_STOP_TIMEOUT  kill the hooks after this long
+//	PSI_PRESTOP_CMD     command run on the first terminate signal before the child is
+//	                    signalled, counting against PSI_STOP_TIMEOUT
+//	PSI_PRESTOP_SLEEP   wait this long after it before signalling the child, e.g. "5s"
+//	PSI_PRESTOP_TIMEOUT kill the pre-stop command after this long
+//	PSI_LOG_FORMAT      the init's log format: text (default) or json, with a record per
+//	                    signal, forwarded signal, stop timer, forced kill, reap and exit
+//	PSI_LOG_LEVEL       the init's minimum log level: debug (also traces every signal,
+//	                    wait4 result and timer), info (default), warn, error or off
+//	PSI_UNSHARE_PID=1   become PID 1 of a new PID namespace when not PID 1
+//	PSI_SUBREAPER=1     supervise as a child subreaper when not PID 1
+//	PSI_FORCE_SUPERVISE=1  supervise when not PID 1 without adopting orphans (any OS)
+//	PSI_CGROUP=1        confine the child in a cgroup v2 sub-cgroup, killed via cgroup.kill
+//	PSI_CGROUP_FREEZE=1 freeze that cgroup around the final SIGKILL (implies PSI_CGROUP)
+//	PSI_DUMP_TREE_ON_KILL=1  log the surviving process tree (from /proc) before SIGKILL
+//	PSI_AUTOTUNE=1      size GOMAXPROCS/GOMEMLIMIT from cgroup limits before submain
+//	PSI_OOM_SCORE_ADJ   the init's oom_score_adj, e.g. -1000 (the processes it starts keep
+//	                    the value it started with)
+//	PSI_CHILD_OOM_SCORE_ADJ  the child's oom_score_adj, set before it runs
+//	PSI_RLIMIT_<NAME>   resource limit for the child, e.g. PSI_RLIMIT_NOFILE=65536,
+//	                    PSI_RLIMIT_CORE=unlimited or "SOFT:HARD"
+//	PSI_NICE            the child's nice value (-20 to 19)
+//	PSI_IONICE_CLASS    the child's I/O class: none, realtime, best-effort or idle
+//	PSI_IONICE_LEVEL    I/O priority within the class (0-7, default 4)
+//	PSI_SCHED_POLICY    the child's CPU policy: other, batch, idle, fifo or rr
+//	PSI_SCHED_PRIORITY  real-time priority for fifo and rr (1-99)
+//	PSI_CPUSET          CPUs the child is pinned to, e.g. "0-3,8"
+//	PSI_USER            run the child as "user[:group]" or "uid[:gid]"
+//	PSI_UID, PSI_GID    numeric overrides of the child's user and group
+//	PSI_CAP_DROP        capabilities removed from the child, e.g. "ALL"
+//	PSI_CAP_KEEP        capabilities exempt from PSI_CAP_DROP, e.g. "NET_BIND_SERVICE"
+//	PSI_NO_NEW_PRIVS=1  set no_new_privs on the child so setuid binaries cannot escalate
+//	PSI_SECCOMP_PROFILE seccomp profile (Docker JSON or raw BPF) installed on the child
+//	PSI_LANDLOCK_RO     paths the child may read and execute, e.g. "/"
+//	PSI_LANDLOCK_RW     paths the child may also write, e.g. "/data,/tmp"
+//	PSI_ISOLATION=1     run the child in a private mount namespace with a read-only root
+//	PSI_ISOLATION_RW    paths kept writable in that namespace, e.g. "/data,/tmp"
+//	PSI_CHDIR           the child's working directory
+//	PSI_CHROOT          directory the child is chrooted into
+//	PSI_UMASK           the child's umask in octal, e.g. "0027"
+//	PSI_HOSTNAME        hostname set before the child starts
+//	PSI_UNSHARE_UTS=1   give the child its own UTS namespace for that hostname
+//	PSI_MOUNT_PROC=1    mount procfs at /proc during startup if it is missing
+//	PSI_SETUP_DEV=1     create /dev/null, /dev/zero, /dev/urandom etc. if missing
+//	PSI_SYSCTL          kernel parameters set at startup, e.g. "net.core.somaxconn=4096"
+//	PSI_ENV_ALLOW       PSI_* patterns passed to the child; all others are scrubbed
+//	PSI_ENV_FILE        dotenv files whose variables are added to the child's environment
+//	PSI_ENV_DIR         directories whose files (name=key, contents=value) are added
+//	                    to the child's environment, e.g. mounted Kubernetes secrets
+//	PSI_EXPAND_ARGS=1   expand ${VAR} and ${VAR:-default} in command and sidecar argv
+//	PSI_WATCH_FILES     paths watched for changes, e.g. "/etc/app/config.yaml,/run/secrets/tls"
+//	PSI_WATCH_SIGNAL    signal sent to the child when they change (default SIGHUP)
+//
+// The init's first log record, at info level, names its version, the Go
+// version and build settings it was built with, why it supervises (mode
+// pid1, subreaper or supervised), its PID, stop timeout, stop signal and
+// whether it is a child subreaper:
+//
+//	psi: starting version=v1.4.0 go=go1.25.1 GOOS=linux GOARCH=amd64 ... mode=pid1 pid=1 stop_timeout=30s stop_signal=forwarded subreaper=false
+//
+// The init exits with the child's exit code, 128+N if it was killed by signal
+// N, except when it has a failure of its own to report:
+//
+//	120  ExitCrashLoop       restarts given up on a crash-looping child
+//	121  ExitStartTimeout    the child exited before completing startup
+//	122  ExitLivenessFailed  the child was stopped for failing its liveness probe
+//	123  ExitPreStartFailed  an init task or the pre-start hook failed
+//	124  ExitStopTimeout     the child ignored the shutdown and was killed
+//	125  ExitInternalError   psi itself failed (configuration, setup, starting a sidecar)
+//	126  ExitCannotExecute   the child's program could not be executed
+//	127  ExitNotFound        the child's program was not found
+//
+// When the init runs as a systemd service of Type=notify (NOTIFY_SOCKET set),
+// it reports READY=1 once the child is ready, STOPPING=1 on shutdown and the
+// child's STATUS, and sends watchdog keepalives while the child runs when
+// WatchdogSec is set.
+//
+// When an OTLP endpoint is configured (OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTLP/HTTP JSON), the init exports a
+// trace per run: a psi.lifecycle span with psi.start (until the first child
+// starts), psi.readiness (per child generation), psi.signal (per signal
+// received), psi.drain (from the first stop signal to the child's exit) and
+// psi.forced_kill spans. OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME,
+// OTEL_RESOURCE_ATTRIBUTES and a TRACEPARENT parent are honoured;
+// OTEL_TRACES_EXPORTER=none or OTEL_SDK_DISABLED=true turns tracing off.
+// The child's and sidecars' output, a record per line with log.iostream and
+// process.executable.name attributes, and the lifecycle events, with
+// event.name psi.<type>, are exported likewise as OTLP log records to
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_LOGS_ENDPOINT, batched per
+// OTEL_BLRP_SCHEDULE_DELAY, OTEL_BLRP_MAX_QUEUE_SIZE and
+// OTEL_BLRP_MAX_EXPORT_BATCH_SIZE; OTEL_LOGS_EXPORTER=none turns that off.
+//
+// The child runs in a process group of its own, which the kernel does not
+// send SIGWINCH when the init's terminal is resized. The init forwards it,
+// having first copied its terminal's window size to the terminals of the
+// child's stdin and stdout, so full-screen programs redraw at the new size.
+// With PSI_PTY the child's own terminal is resized instead.
+//
+// Sockets passed to the init for socket activation (LISTEN_FDS and
+// LISTEN_PID) are passed on to every child generation with LISTEN_PID
+// pointing at the child.
+//
+// Exec (or Command) supervises an external program instead of a Go submain.
+// Sidecar processes declared with WithSidecar are started before the child
+// and stopped in reverse order after it exits. Code running in the init, such
+// as a WithSupervisor callback, starts processes of its own with
+// NewProcesses, which waits for them without racing the init's reap loop.
+//
+// On macOS and the BSDs the reaper sleeps in kqueue (EVFILT_PROC NOTE_EXIT
+// for each child it starts, EVFILT_SIGNAL for SIGCHLD) instead of wait4.
+// PSI_FORCE_SUPERVISE=1 runs the supervised path there, or anywhere, without
+// being PID 1, so local runs behave like the container.
+//
+// On FreeBSD, where os/exec cannot hand out pdfork(2) process descriptors,
+// the init uses reaper subtrees instead: as PID 1 or in subreaper mode
+// (PROC_REAP_ACQUIRE, for psi as the init of a jail) signals reach the
+// child's whole subtree through PROC_REAP_KILL and never a recycled PID
+// outside it.
+//
+// On Windows, which has no PID 1, the init always supervises. The child is
+// placed in a Job Object that kills it and its descendants when the init
+// goes away; CTRL_C and CTRL_BREAK (os.Interrupt) and CTRL_CLOSE, logoff and
+// shutdown (SIGTERM) stop it by sending CTRL_BREAK to its process group and
+// cancelling submain's context, and the stop timeout ends in
+// TerminateJobObject. Chroot, user switching and POSIX-only signals are not
+// available there.
+//
 // Usage:
 //
 //	func submain(ctx context.Context) int { /* your old main */ }
@@ -15,7 +231,6 @@ package psi
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -33,156 +248,177 @@ const stopTimeoutEnv = "PSI_STOP_TIMEOUT"
 const defaultStopTimeout = 30 * time.Second
 
 // SubMain is your application's entrypoint (old main), returning an exit code.
-// The provided context is cancelled when a termination signal is received.
+// The provided context is cancelled when a termination signal is received;
+// SignalFromContext tells which and ShutdownDeadline how long is left.
 type SubMain func(ctx context.Context) int
 
 // Run wraps submain with PID1 responsibilities when needed. If PID != 1 and
 // PSI_CHILD not set: runs submain directly (nice for local dev). If PID == 1
-// and PSI_CHILD not set: forks/execs itself; parent becomes init, child runs
-// submain. If PSI_CHILD == "1": executes submain path (child).
-func Run(submain SubMain) {
+// (or subreaper mode is enabled) and PSI_CHILD not set: forks/execs itself;
+// parent becomes init, child runs submain. If PSI_CHILD == "1": executes
+// submain path (child).
+func Run(submain SubMain, opts ...Option) {
+	cfg := newConfig(opts...)
+	takeReadyFD()
+	fixListenPID()
+	awaitRlimitGate()
 	if os.Getenv(childEnvKey) == childEnvVal {
-		runChild(submain)
+		runChild(cfg, submain)
 		// runChild never returns.
 		return
 	}
-	if os.Getpid() != 1 {
+	if !cfg.supervise() {
+		cfg.prepareUnsupervised()
+		cfg.enterRoot()
+		cfg.dropPrivileges()
+		killAfter := cfg.killAfter(parseStopTimeout(defaultStopTimeout))
+		cfg.scrubEnv()
+		cfg.prepareSubmain()
 		code := submain(context.Background())
+		runShutdownHooks(time.Now().Add(killAfter))
 		os.Exit(code)
 	}
-	runAsInit()
+	runAsInit(cfg)
 	// runAsInit never returns.
 }
 
-func runChild(submain SubMain) {
+func runChild(cfg *config, submain SubMain) {
+	// Read before the PSI_* variables are scrubbed. The init kills the child
+	// once its stop chain reaches SIGKILL.
+	killAfter := cfg.killAfter(parseStopTimeout(defaultStopTimeout))
+	stopClock.max = cfg.stopExtendMax
+	cfg.scrubEnv()
+	cfg.prepareSubmain()
 	// Child path: set up graceful cancellation on termination signals.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 	termCh := make(chan os.Signal, 8)
 	signal.Notify(termCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	if cfg.stopSignal != 0 {
+		// A custom stop signal must cancel the context as well.
+		signal.Notify(termCh, cfg.stopSignal)
+	}
+	// stopped receives when the first termination signal arrived.
+	stopped := make(chan time.Time, 1)
 	go func() {
-		for range termCh {
-			// Cancel once; repeated signals are fine.
-			cancel()
+		for sig := range termCh {
+			if sig == syscall.SIGHUP && cfg.hupAction == HupReload {
+				// A reload request: the submain handles it, if at all.
+				continue
+			}
+			now := time.Now()
+			select {
+			case stopped <- now:
+				stopClock.start(now.Add(killAfter))
+			default:
+			}
+			// Cancel once; repeated signals are fine. The first one is
+			// the cause SignalFromContext and ShutdownDeadline report.
+			cancel(stopCause{sig, now.Add(killAfter)})
 		}
 	}()
 	code := submain(ctx)
+	stopAt := time.Now()
+	select {
+	case stopAt = <-stopped:
+	default:
+	}
+	runShutdownHooks(stopAt.Add(killAfter))
 	os.Exit(code)
 }
 
-func runAsInit() {
-	// Re-exec this binary as the managed child running submain.
-	cmd := exec.Command(os.Args[0], os.Args[1:]...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", childEnvKey, childEnvVal))
-	cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		// Put child in its own process group so signals can be forwarded to the whole tree.
-		Setpgid: true,
-	}
+// runAsInit supervises the re-executed child as PID 1 and exits with the
+// child's exit code.
+func runAsInit(cfg *config) {
+	os.Exit(newSupervisor(cfg).run())
+}
+
+// reaper is the init's single Wait4(-1) loop. Processes started through it
+// are watched by PID and get their exit code (shell-style) delivered on a
+// channel, with the wait status and resource usage left in their handle;
+// every other reaped PID is an adopted orphan and is only counted. At the
+// debug level each reap is logged with the process's name from /proc.
+type reaper struct {
+	mu      sync.Mutex
+	watched map[int]*procHandle
+	orphans int
+
+	// forkMu serializes starting processes, around which the init's
+	// oom_score_adj may be changed (see forkWithOOMScoreAdj).
+	forkMu sync.Mutex
+	// oomScoreAdj is the oom_score_adj processes started through start
+	// begin with; nil for the init's own.
+	oomScoreAdj *int
+}
+
+func newReaper() *reaper {
+	return &reaper{watched: make(map[int]*procHandle)}
+}
+
+// orphansReaped returns the number of adopted orphans reaped so far.
+func (r *reaper) orphansReaped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.orphans
+}
+
+// start starts cmd with r.oomScoreAdj and watches its PID. The returned
+// handle must be closed by the caller once the exit code has been received.
+func (r *reaper) start(cmd *exec.Cmd) (h *procHandle, done <-chan int, err error) {
+	err = r.forkWithOOMScoreAdj(r.oomScoreAdj, func() (err error) {
+		h, done, err = r.spawn(cmd)
+		return err
+	})
+	return h, done, err
+}
+
+// spawn starts cmd and watches its PID. Holding the lock across Start
+// guarantees the reap loop cannot collect the process before it is watched.
+func (r *reaper) spawn(cmd *exec.Cmd) (*procHandle, <-chan int, error) {
+	h := &procHandle{}
+	requestPidfd(cmd, &h.pidfd)
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if err := cmd.Start(); err != nil {
-		log.Fatalf("psi: failed to start child: %v", err)
-	}
-	childPID := cmd.Process.Pid
-	// Channel that yields the child's exit code once reaped.
-	done := make(chan int, 1)
-	go func() {
-		done <- reapUntilChildExit(childPID)
-	}()
-	// Signal forwarding and shutdown policy.
-	allSig := make(chan os.Signal, 64)
-	// Subscribe to all signals we can catch; SIGKILL/SIGSTOP cannot be caught.
-	signal.Notify(allSig)
-	// Parse stop timeout once.
-	stopTimeout := parseStopTimeout(defaultStopTimeout)
-	// Start the kill timer on the first terminate-like signal.
-	var startOnce sync.Once
-	var killTimer *time.Timer
-	startKillTimer := func() {
-		if killTimer == nil {
-			killTimer = time.NewTimer(stopTimeout)
-		} else {
-			if !killTimer.Stop() {
-				select {
-				case <-killTimer.C:
-				default:
-				}
-			}
-			killTimer.Reset(stopTimeout)
-		}
-	}
-	// Supervisor loop: wait on signals, child exit, or forced kill timer.
-	for {
-		select {
-		case code := <-done:
-			// Child exited; small grace to reap stragglers, then exit with its code.
-			time.Sleep(50 * time.Millisecond)
-			drainZombiesNonBlock()
-			os.Exit(code)
-		case s := <-allSig:
-			// Never handle SIGCHLD here (we reap in reapUntilChildExit).
-			if s == syscall.SIGCHLD {
-				continue
-			}
-			// Forward everything we can to the child's process group.
-			if sig, ok := toSyscallSignal(s); ok {
-				_ = syscall.Kill(-childPID, sig)
-			}
-			// On first terminate-like signal, start the forced-kill countdown.
-			if isTerminateSignal(s) {
-				startOnce.Do(func() {
-					startKillTimer()
-				})
-			}
-		case <-killTimerC(killTimer):
-			// Forced shutdown: SIGKILL the child's process group.
-			_ = syscall.Kill(-childPID, syscall.SIGKILL)
-			// Wait for reap loop to deliver child's exit code.
-			code := <-done
-			os.Exit(code)
-		}
+		return nil, nil, err
 	}
+	h.pid = cmd.Process.Pid
+	h.done = make(chan int, 1)
+	r.watched[h.pid] = h
+	r.watch(h, cmd)
+	return h, h.done, nil
 }
 
-// reapUntilChildExit reaps children until the managed child exits,
-// returning the managed child's exit code (shell-style).
-func reapUntilChildExit(childPID int) int {
-	for {
-		var ws syscall.WaitStatus
-		var ru syscall.Rusage
-		pid, err := syscall.Wait4(-1, &ws, 0, &ru)
-		if err != nil {
-			if err == syscall.EINTR {
-				continue
-			}
-			if err == syscall.ECHILD {
-				// No children left; assume success if we somehow missed it.
-				return 0
-			}
-			time.Sleep(10 * time.Millisecond)
-			continue
-		}
-		if pid == childPID {
-			if ws.Exited() {
-				return ws.ExitStatus()
-			}
-			if ws.Signaled() {
-				return 128 + int(ws.Signal())
-			}
-			return 1
-		}
-		// Reaped some other orphan; keep looping.
+// reaped dispatches the exit of pid with status ws: to its handle if it is
+// watched, otherwise it is counted as an orphan. comm names the process if
+// known.
+func (r *reaper) reaped(pid int, ws syscall.WaitStatus, ru syscall.Rusage, comm string) {
+	r.mu.Lock()
+	h, ok := r.watched[pid]
+	delete(r.watched, pid)
+	if !ok {
+		// Some other orphan.
+		r.orphans++
+	}
+	r.mu.Unlock()
+	code := exitCode(ws)
+	tracef("wait4: pid %d (%s) status %#x (exit code %d, orphan %t)", pid, comm, rawStatus(ws), code, !ok)
+	emit(Event{Type: EventReaped, PID: pid, ExitCode: code, Orphan: !ok})
+	if ok {
+		h.status, h.rusage = ws, ru
+		h.done <- code
 	}
 }
 
-// drainZombiesNonBlock performs a single non-blocking reap pass.
-func drainZombiesNonBlock() {
-	for {
-		var ws syscall.WaitStatus
-		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
-		if err != nil || pid <= 0 {
-			return
-		}
+// exitCode converts a wait status into a shell-style exit code.
+func exitCode(ws syscall.WaitStatus) int {
+	if ws.Exited() {
+		return ws.ExitStatus()
+	}
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
 	}
+	return 1
 }
 
 // parseStopTimeout reads PSI_STOP_TIMEOUT, accepts Go time.Duration strings.
@@ -230,19 +466,10 @@ func toSyscallSignal(s os.Signal) (syscall.Signal, bool) {
 	if sig, ok := s.(syscall.Signal); ok {
 		return sig, true
 	}
-	switch strings.ToUpper(s.String()) {
-	case "SIGTERM":
-		return syscall.SIGTERM, true
-	case "SIGINT":
-		return syscall.SIGINT, true
-	case "SIGQUIT":
-		return syscall.SIGQUIT, true
-	case "SIGHUP":
-		return syscall.SIGHUP, true
-	case "SIGUSR1":
-		return syscall.SIGUSR1, true
-	case "SIGUSR2":
-		return syscall.SIGUSR2, true
+	switch name := strings.ToUpper(s.String()); name {
+	case "SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGUSR1", "SIGUSR2":
+		sig, ok := signalTable[name]
+		return sig, ok
 	default:
 		return 0, false
 	}
@@ -270,6 +497,8 @@ func EffectiveTimeout() time.Duration {
 func IsPID1() bool { return os.Getpid() == 1 }
 
 // ChildPIDEnv returns the PSI_CHILD env var as seen by the current process.
+// Like other PSI_* variables it is scrubbed before submain runs unless
+// allowed with WithEnvAllow.
 func ChildPIDEnv() (string, bool) {
 	v, ok := os.LookupEnv(childEnvKey)
 	return v, ok
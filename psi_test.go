@@ -1,3 +1,5 @@
+//go:build !windows
+
 package psi
 
 import (
@@ -6,9 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"testing"
@@ -16,8 +21,13 @@ import (
 )
 
 const (
-	helperEnv     = "GO_WANT_HELPER_PROCESS"
-	helperModeEnv = "GO_HELPER_MODE"
+	helperEnv      = "GO_WANT_HELPER_PROCESS"
+	helperModeEnv  = "GO_HELPER_MODE"
+	helperCountEnv = "GO_HELPER_COUNT_FILE"
+	// helperListenAddrEnv is the address of the socket passed to the helper.
+	helperListenAddrEnv = "GO_HELPER_LISTEN_ADDR"
+	// helperFailUpgradeEnv makes the helper's second generation fail.
+	helperFailUpgradeEnv = "GO_HELPER_FAIL_UPGRADE"
 )
 
 func TestRunNonPID1(t *testing.T) {
@@ -49,6 +59,465 @@ func TestRunChildContextCancellation(t *testing.T) {
 	}
 }
 
+func TestSupervisorRestartOnFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-exit3",
+		helperCountEnv+"="+countFile,
+		restartEnv+"=on-failure",
+		restartDelayEnv+"=10ms",
+		maxRestartsEnv+"=2",
+	)
+	err := cmd.Run()
+	if exit := exitStatus(err); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d (err=%v)", exit, err)
+	}
+	if runs := countLines(t, countFile); runs != 3 {
+		t.Fatalf("expected 3 child generations, got %d", runs)
+	}
+}
+
+func TestSupervisorCrashLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-exit3",
+		helperCountEnv+"="+countFile,
+		restartEnv+"=always",
+		restartDelayEnv+"=10ms",
+		minUptimeEnv+"=5s",
+		crashLoopLimitEnv+"=1",
+	)
+	err := cmd.Run()
+	if exit := exitStatus(err); exit != ExitCrashLoop {
+		t.Fatalf("expected exit code %d, got %d (err=%v)", ExitCrashLoop, exit, err)
+	}
+	if runs := countLines(t, countFile); runs != 2 {
+		t.Fatalf("expected 2 child generations, got %d", runs)
+	}
+}
+
+func TestSupervisorStartTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-exit3",
+		helperCountEnv+"="+countFile,
+		startTimeoutEnv+"=5s",
+		restartEnv+"=on-failure",
+		restartDelayEnv+"=10ms",
+		maxRestartsEnv+"=1",
+	)
+	err := cmd.Run()
+	if exit := exitStatus(err); exit != ExitStartTimeout {
+		t.Fatalf("expected exit code %d, got %d (err=%v)", ExitStartTimeout, exit, err)
+	}
+	if runs := countLines(t, countFile); runs != 2 {
+		t.Fatalf("expected 2 child generations, got %d", runs)
+	}
+}
+
+func TestSupervisorReadyNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-ready", helperCountEnv+"="+countFile)
+	err := cmd.Run()
+	if exit := exitStatus(err); exit != 7 {
+		t.Fatalf("expected exit code 7, got %d (err=%v)", exit, err)
+	}
+	b, err := os.ReadFile(countFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", countFile, err)
+	}
+	if got := string(b); got != "main\nsidecar\n" {
+		t.Fatalf("expected the AfterReady sidecar to start after readiness, got %q", got)
+	}
+}
+
+func TestSupervisorReadyTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	start := time.Now()
+	err := helperCommand("init-notready").Run()
+	if exit := exitStatus(err); exit != ExitStartTimeout {
+		t.Fatalf("expected exit code %d, got %d (err=%v)", ExitStartTimeout, exit, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("child not killed at the startup timeout (took %s)", elapsed)
+	}
+}
+
+func TestSupervisorCommandReady(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	err := helperCommand("init-command-ready").Run()
+	if exit := exitStatus(err); exit != 8 {
+		t.Fatalf("expected external command's exit code 8, got %d (err=%v)", exit, err)
+	}
+}
+
+func TestSupervisorLivenessProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	err := helperCommand("init-liveness-pass").Run()
+	if exit := exitStatus(err); exit != 4 {
+		t.Fatalf("expected external command's exit code 4 with a passing probe, got %d (err=%v)", exit, err)
+	}
+	start := time.Now()
+	err = helperCommand("init-liveness-fail").Run()
+	if exit := exitStatus(err); exit != ExitLivenessFailed {
+		t.Fatalf("expected exit code %d, got %d (err=%v)", ExitLivenessFailed, exit, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("child not stopped after failing its probe (took %s)", elapsed)
+	}
+}
+
+func TestSupervisorSdNotify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the sd_notify socket is Linux-only")
+	}
+	err := helperCommand("init-sdnotify").Run()
+	if exit := exitStatus(err); exit != 6 {
+		t.Fatalf("expected the notifying child's exit code 6, got %d (err=%v)", exit, err)
+	}
+	start := time.Now()
+	err = helperCommand("init-watchdog").Run()
+	if exit := exitStatus(err); exit != ExitLivenessFailed {
+		t.Fatalf("expected exit code %d after a missed watchdog, got %d (err=%v)", ExitLivenessFailed, exit, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("child not stopped after missing its watchdog (took %s)", elapsed)
+	}
+}
+
+func TestSupervisorSocketActivation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, mode := range []string{"init-listen-command", "init-listen-run"} {
+		cmd := helperCommand(mode, helperListenAddrEnv+"="+ln.Addr().String(), listenFDsEnv+"=1", listenFDNamesEnv+"=http")
+		// Like a service manager: the socket is fd 3 and LISTEN_PID names the init.
+		cmd.Args = append([]string{"/bin/sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`}, cmd.Args...)
+		cmd.Path = "/bin/sh"
+		cmd.ExtraFiles = []*os.File{f}
+		err := cmd.Run()
+		if exit := exitStatus(err); exit != 47 {
+			t.Fatalf("%s: expected the child to find its socket (exit 47), got %d (err=%v)", mode, exit, err)
+		}
+	}
+}
+
+func TestSupervisorBoundListener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	err := helperCommand("init-listen-bound").Run()
+	if exit := exitStatus(err); exit != 48 {
+		t.Fatalf("expected the child to accept on the init's socket (exit 48), got %d (err=%v)", exit, err)
+	}
+}
+
+func TestSupervisorUpgrade(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	for _, tc := range []struct {
+		name      string
+		mode      string
+		env       []string
+		overlap   string
+		completed string
+	}{
+		{"signal", "init-upgrade", nil, "start 1\nstart 2\nstop 1\n", "start 1\nstart 2\nstop 1\nstop 2\n"},
+		{"signal-failed", "init-upgrade", []string{helperFailUpgradeEnv + "=1"}, "start 1\nstart 2\n", "start 1\nstart 2\nstop 1\n"},
+		{"api", "init-upgrade-api", nil, "start 1\nstart 2\nstop 1\nupgraded to generation 2\n", "start 1\nstart 2\nstop 1\nupgraded to generation 2\nstop 2\n"},
+		{"api-failed", "init-upgrade-api", []string{helperFailUpgradeEnv + "=1"}, "start 1\nstart 2\nupgrade failed\n", "start 1\nstart 2\nupgrade failed\nstop 1\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			countFile := t.TempDir() + "/count"
+			cmd := helperCommand(tc.mode, append(tc.env, helperCountEnv+"="+countFile)...)
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("start helper: %v", err)
+			}
+			defer cmd.Process.Kill()
+			if tc.mode == "init-upgrade" {
+				waitForContent(t, countFile, "start 1\n")
+				if err := cmd.Process.Signal(syscall.SIGUSR2); err != nil {
+					t.Fatalf("failed to signal helper: %v", err)
+				}
+			}
+			waitForContent(t, countFile, tc.overlap)
+			// Give a failed upgrade time to fall back before shutting down.
+			time.Sleep(200 * time.Millisecond)
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				t.Fatalf("failed to signal helper: %v", err)
+			}
+			if exit := exitStatus(cmd.Wait()); exit != 0 {
+				t.Fatalf("expected exit code 0, got %d", exit)
+			}
+			waitForContent(t, countFile, tc.completed)
+		})
+	}
+}
+
+func TestSupervisorHupReload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-hup-reload", helperCountEnv+"="+countFile)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "started\n")
+	if err := cmd.Process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 5 {
+		t.Fatalf("expected the child to get SIGHUP without shutdown (exit 5), got %d", exit)
+	}
+}
+
+func TestSupervisorShutdownHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-shutdown-hooks", helperCountEnv+"="+countFile, stopTimeoutEnv+"=7s")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "started\n")
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	waitForContent(t, countFile, "started\nstopped\nsecond\nfirst\n")
+}
+
+func TestSupervisorHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	for _, tc := range []struct {
+		name     string
+		preStart string
+		exit     int
+		want     string
+	}{
+		{"ok", `echo pre >> "$0"`, 4, "pre\nmain\npost\n"},
+		{"failed", `echo pre >> "$0"; exit 3`, ExitPreStartFailed, "pre\n"},
+		{"timeout", `echo pre >> "$0"; exec sleep 10`, ExitPreStartFailed, "pre\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			countFile := t.TempDir() + "/count"
+			start := time.Now()
+			err := helperCommand("init-hooks", helperCountEnv+"="+countFile, "GO_HELPER_PRE_START="+tc.preStart).Run()
+			if exit := exitStatus(err); exit != tc.exit {
+				t.Fatalf("expected exit code %d, got %d (err=%v)", tc.exit, exit, err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("pre-start hook not killed at its timeout (took %s)", elapsed)
+			}
+			waitForContent(t, countFile, tc.want)
+		})
+	}
+}
+
+func TestSupervisorPreStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	for _, tc := range []struct {
+		name    string
+		timeout string
+		exit    int
+		want    string
+	}{
+		{"completes", "5s", 0, "started\nprestop\nstopped\n"},
+		// The stop timeout runs out during the pre-stop sleep: the child
+		// is signalled, then killed right away.
+		{"timeout", "300ms", ExitStopTimeout, "started\nprestop\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			countFile := t.TempDir() + "/count"
+			cmd := helperCommand("init-prestop", helperCountEnv+"="+countFile, stopTimeoutEnv+"="+tc.timeout)
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("start helper: %v", err)
+			}
+			defer cmd.Process.Kill()
+			waitForContent(t, countFile, "started\n")
+			start := time.Now()
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				t.Fatalf("failed to signal helper: %v", err)
+			}
+			if exit := exitStatus(cmd.Wait()); exit != tc.exit {
+				t.Fatalf("expected exit code %d, got %d", tc.exit, exit)
+			}
+			if elapsed := time.Since(start); elapsed < 300*time.Millisecond || elapsed > 5*time.Second {
+				t.Fatalf("pre-stop phase took %s", elapsed)
+			}
+			waitForContent(t, countFile, tc.want)
+		})
+	}
+}
+
+func TestSupervisorInitTasks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	for _, tc := range []struct {
+		name     string
+		required string
+		exit     int
+		want     string
+	}{
+		{"ok", "exit 0", 4, "optional\nrequired\nmain\n"},
+		{"failed", "exit 2", ExitPreStartFailed, "optional\nrequired\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			countFile := t.TempDir() + "/count"
+			err := helperCommand("init-tasks", helperCountEnv+"="+countFile, "GO_HELPER_TASK_EXIT="+tc.required).Run()
+			if exit := exitStatus(err); exit != tc.exit {
+				t.Fatalf("expected exit code %d, got %d (err=%v)", tc.exit, exit, err)
+			}
+			waitForContent(t, countFile, tc.want)
+		})
+	}
+}
+
+func TestSupervisorCron(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	err := helperCommand("init-cron", helperCountEnv+"="+countFile).Run()
+	if exit := exitStatus(err); exit != 4 {
+		t.Fatalf("expected exit code 4, got %d (err=%v)", exit, err)
+	}
+	b, err := os.ReadFile(countFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", countFile, err)
+	}
+	// Runs take longer than the interval: they must not overlap, and the
+	// last one is stopped before the init exits.
+	lines := strings.Fields(string(b))
+	if len(lines) < 4 || len(lines)%2 != 0 {
+		t.Fatalf("expected completed runs, got %q", b)
+	}
+	for i, line := range lines {
+		if want := []string{"start", "end"}[i%2]; line != want {
+			t.Fatalf("runs overlap: %q", b)
+		}
+	}
+}
+
+func TestSupervisorEvents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-events", helperCountEnv+"="+countFile)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper: %v", err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile+".child", "running\n")
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	waitForContent(t, countFile, "child_started\nchild_ready\nsignal_received terminated\nstop_timer_armed\nsignal_forwarded terminated\nreaped\nchild_exited\n")
+}
+
+func TestSupervisorStopsSidecarsAfterChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-sidecar", helperCountEnv+"="+countFile)
+	err := cmd.Run()
+	if exit := exitStatus(err); exit != 5 {
+		t.Fatalf("expected main child's exit code 5, got %d (err=%v)", exit, err)
+	}
+	b, err := os.ReadFile(countFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", countFile, err)
+	}
+	if got := string(b); got != "main\nsidecar\n" {
+		t.Fatalf("expected main to exit before the sidecar was stopped, got %q", got)
+	}
+}
+
+func TestSupervisorCommandMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	err := helperCommand("init-command").Run()
+	if exit := exitStatus(err); exit != 9 {
+		t.Fatalf("expected external command's exit code 9, got %d (err=%v)", exit, err)
+	}
+}
+
+func TestRunInPIDNamespace(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("PID namespaces are Linux only")
+	}
+	var stderr bytes.Buffer
+	cmd := helperCommand("unshare-pid")
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if strings.Contains(stderr.String(), "failed to start in new PID namespace") {
+		t.Skipf("namespaces not permitted here: %s", stderr.String())
+	}
+	if exit := exitStatus(err); exit != 41 {
+		t.Fatalf("expected submain to run under a PID 1 init (41), got %d (err=%v, stderr=%q)", exit, err, stderr.String())
+	}
+}
+
+func TestRunSubreaperSupervises(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("subreaper mode is Linux and FreeBSD only")
+	}
+	err := helperCommand("subreaper", "GO_HELPER_PARENT="+strconv.Itoa(os.Getpid())).Run()
+	if exit := exitStatus(err); exit != 43 {
+		t.Fatalf("expected submain to run as supervised child with a scrubbed env (43), got %d (err=%v)", exit, err)
+	}
+}
+
+func TestRunForceSupervise(t *testing.T) {
+	err := helperCommand("force-supervise", "GO_HELPER_PARENT="+strconv.Itoa(os.Getpid())).Run()
+	if exit := exitStatus(err); exit != 43 {
+		t.Fatalf("expected submain to run as supervised child with a scrubbed env (43), got %d (err=%v)", exit, err)
+	}
+}
+
 func TestParseStopTimeoutDefault(t *testing.T) {
 	t.Setenv(stopTimeoutEnv, "")
 	def := 45 * time.Second
@@ -174,7 +643,7 @@ func TestChildPIDEnv(t *testing.T) {
 	}
 }
 
-func TestReapUntilChildExit(t *testing.T) {
+func TestReaperDispatchesWatchedExit(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Wait4 not available on Windows")
 	}
@@ -182,22 +651,35 @@ func TestReapUntilChildExit(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to fork extra child: %v", err)
 	}
-	targetPID, err := forkExecExit(7)
+	r := newReaper()
+	h, done, err := r.start(exec.Command("/bin/sh", "-c", "exit 7"))
 	if err != nil {
-		t.Fatalf("failed to fork target child: %v", err)
+		t.Fatalf("failed to start target child: %v", err)
 	}
-	if code := reapUntilChildExit(targetPID); code != 7 {
-		t.Fatalf("expected exit status 7, got %d", code)
-	}
-	// Ensure the extra child is also reaped to avoid leaks.
-	var ws syscall.WaitStatus
-	_, err = syscall.Wait4(otherPID, &ws, syscall.WNOHANG, nil)
-	if err != nil && !errors.Is(err, syscall.ECHILD) {
-		t.Fatalf("unexpected wait after reap: %v", err)
+	defer h.close()
+	for {
+		if _, err := r.reapOne(0); err != nil && !errors.Is(err, syscall.EINTR) {
+			t.Fatalf("reapOne: %v", err)
+		}
+		select {
+		case code := <-done:
+			if code != 7 {
+				t.Fatalf("expected exit status 7, got %d", code)
+			}
+			// Ensure the extra child is also reaped to avoid leaks.
+			r.drain()
+			var ws syscall.WaitStatus
+			_, err = syscall.Wait4(otherPID, &ws, syscall.WNOHANG, nil)
+			if err != nil && !errors.Is(err, syscall.ECHILD) {
+				t.Fatalf("unexpected wait after reap: %v", err)
+			}
+			return
+		default:
+		}
 	}
 }
 
-func TestDrainZombiesNonBlock(t *testing.T) {
+func TestReaperDrain(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Wait4 not available on Windows")
 	}
@@ -206,7 +688,7 @@ func TestDrainZombiesNonBlock(t *testing.T) {
 		t.Fatalf("failed to fork child: %v", err)
 	}
 	time.Sleep(50 * time.Millisecond)
-	drainZombiesNonBlock()
+	newReaper().drain()
 	var ws syscall.WaitStatus
 	_, err = syscall.Wait4(pid, &ws, syscall.WNOHANG, nil)
 	if err == nil {
@@ -217,6 +699,16 @@ func TestDrainZombiesNonBlock(t *testing.T) {
 	}
 }
 
+func TestExitCode(t *testing.T) {
+	// WaitStatus encodings: exit code in bits 8-15, terminating signal in 0-6.
+	if got := exitCode(syscall.WaitStatus(3 << 8)); got != 3 {
+		t.Fatalf("exitCode(exit 3) = %d", got)
+	}
+	if got := exitCode(syscall.WaitStatus(syscall.SIGKILL)); got != 137 {
+		t.Fatalf("exitCode(SIGKILL) = %d", got)
+	}
+}
+
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv(helperEnv) != "1" {
 		return
@@ -234,6 +726,435 @@ func TestHelperProcess(t *testing.T) {
 				return 23
 			}
 		})
+	case "run-child-signal":
+		Run(func(ctx context.Context) int {
+			<-ctx.Done()
+			sig, ok := SignalFromContext(ctx)
+			if !ok {
+				return 98
+			}
+			return int(sig.(syscall.Signal))
+		})
+	case "run-child-deadline":
+		Run(func(ctx context.Context) int {
+			<-ctx.Done()
+			deadline, ok := ShutdownDeadline(ctx)
+			if !ok {
+				return 98
+			}
+			return int(time.Until(deadline).Round(time.Second) / time.Second)
+		})
+	case "init-exit3":
+		// Acts as the init when not yet a child; the re-exec'd child records
+		// each generation in the file named by helperCountEnv.
+		runHelperInit(func(context.Context) int {
+			appendLine(os.Getenv(helperCountEnv), "run")
+			return 3
+		})
+	case "init-sidecar":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(context.Context) int {
+			time.Sleep(300 * time.Millisecond)
+			appendLine(countFile, "main")
+			return 5
+		}, WithSidecar(Sidecar{
+			Name: "recorder",
+			Path: "/bin/sh",
+			Args: []string{"-c", `trap 'echo sidecar >> "$0"; exit 0' TERM; while :; do sleep 0.05; done`, countFile},
+		}))
+	case "init-ready":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(context.Context) int {
+			appendLine(countFile, "main")
+			if err := Ready(); err != nil {
+				return 1
+			}
+			time.Sleep(300 * time.Millisecond)
+			return 7
+		}, WithReadyNotify(), WithStartTimeout(5*time.Second), WithSidecar(Sidecar{
+			Name:       "after-ready",
+			Path:       "/bin/sh",
+			Args:       []string{"-c", `echo sidecar >> "$0"; while :; do sleep 0.05; done`, countFile},
+			AfterReady: true,
+		}))
+	case "init-notready":
+		runHelperInit(func(context.Context) int {
+			time.Sleep(10 * time.Second)
+			return 0
+		}, WithReadyNotify(), WithStartTimeout(200*time.Millisecond))
+	case "init-command-ready":
+		cfg := newConfig(WithReadyNotify(), WithStartTimeout(200*time.Millisecond))
+		cfg.command = []string{"/bin/sh", "-c", `echo READY=1 >&"$PSI_READY_FD"; sleep 0.5; exit 8`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-liveness-pass":
+		cfg := newConfig(WithLivenessProbe(Probe{Exec: []string{"/bin/true"}, Interval: 20 * time.Millisecond, FailureThreshold: 1}))
+		cfg.command = []string{"/bin/sh", "-c", "sleep 0.5; exit 4"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-liveness-fail":
+		// Nothing listens on the port of a closed listener.
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			os.Exit(2)
+		}
+		ln.Close()
+		cfg := newConfig(WithLivenessProbe(Probe{TCP: ln.Addr().String(), Interval: 20 * time.Millisecond, FailureThreshold: 2}))
+		cfg.command = []string{"/bin/sh", "-c", "sleep 10"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-sdnotify":
+		// The child is this binary again, in sdnotify-child mode.
+		cfg := newConfig(WithReadyNotify(), WithStartTimeout(2*time.Second), WithWatchdog(time.Second))
+		cfg.command = []string{"/bin/sh", "-c", `GO_HELPER_MODE=sdnotify-child exec "$0" -test.run=TestHelperProcess`, os.Args[0]}
+		os.Exit(newSupervisor(cfg).run())
+	case "sdnotify-child":
+		if os.Getenv(watchdogUsecEnv) != "1000000" {
+			os.Exit(2)
+		}
+		if err := sdNotify(os.Getenv(notifySocketEnv), "STATUS=starting\nREADY=1"); err != nil {
+			os.Exit(3)
+		}
+		for range 5 {
+			time.Sleep(300 * time.Millisecond)
+			if err := Watchdog(); err != nil {
+				os.Exit(4)
+			}
+		}
+		os.Exit(6)
+	case "init-watchdog":
+		cfg := newConfig(WithWatchdog(100 * time.Millisecond))
+		cfg.command = []string{"/bin/sh", "-c", "sleep 10"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-listen-command":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `GO_HELPER_MODE=listen-child exec "$0" -test.run=TestHelperProcess`, os.Args[0]}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-listen-run":
+		runHelperInit(func(context.Context) int { return checkListenFDs() })
+	case "listen-child":
+		os.Exit(checkListenFDs())
+	case "init-listen-bound":
+		runHelperInit(func(context.Context) int {
+			if os.Getenv(listenFDPrefix+"HTTP") != "3" {
+				return 2
+			}
+			ln, err := Listener("http")
+			if err != nil {
+				return 3
+			}
+			defer ln.Close()
+			go func() {
+				if conn, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+					conn.Close()
+				}
+			}()
+			conn, err := ln.Accept()
+			if err != nil {
+				return 4
+			}
+			conn.Close()
+			return 48
+		}, WithListen("http=tcp:127.0.0.1:0"))
+	case "init-upgrade":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(upgradeSubmain(countFile), WithReadyNotify(), WithListen("http=tcp:127.0.0.1:0"), WithUpgradeSignal(syscall.SIGUSR2))
+	case "init-upgrade-api":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(upgradeSubmain(countFile), WithReadyNotify(), WithListen("http=tcp:127.0.0.1:0"), WithSupervisor(func(sup *Supervisor) {
+			go func() {
+				for b, _ := os.ReadFile(countFile); string(b) != "start 1\n"; b, _ = os.ReadFile(countFile) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := sup.Upgrade(ctx); err != nil {
+					appendLine(countFile, "upgrade failed")
+					return
+				}
+				appendLine(countFile, fmt.Sprintf("upgraded to generation %d", sup.s.status.report(time.Now()).Generation))
+			}()
+		}))
+	case "init-hup-reload":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			appendLine(countFile, "started")
+			select {
+			case <-hup:
+			case <-time.After(5 * time.Second):
+				return 2
+			}
+			time.Sleep(50 * time.Millisecond)
+			if ctx.Err() != nil {
+				return 3
+			}
+			return 5
+		}, WithHupAction(HupReload), WithStopSignal(syscall.SIGQUIT))
+	case "init-shutdown-hooks":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			OnShutdown(func(context.Context) error {
+				appendLine(countFile, "first")
+				return nil
+			})
+			OnShutdown(func(ctx context.Context) error {
+				// The deadline is PSI_STOP_TIMEOUT after the signal.
+				if d, ok := ctx.Deadline(); ok && time.Until(d) > 6*time.Second && time.Until(d) <= 7*time.Second {
+					appendLine(countFile, "second")
+				}
+				return nil
+			})
+			appendLine(countFile, "started")
+			<-ctx.Done()
+			appendLine(countFile, "stopped")
+			return 0
+		})
+	case "init-hooks":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(context.Context) int {
+			appendLine(countFile, "main")
+			return 4
+		}, WithPreStart(Hook{
+			Path:    "/bin/sh",
+			Args:    []string{"-c", os.Getenv("GO_HELPER_PRE_START"), countFile},
+			Timeout: time.Second,
+		}), WithPostStop(Hook{
+			Path: "/bin/sh",
+			Args: []string{"-c", `echo post >> "$0"`, countFile},
+		}))
+	case "init-prestop":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			appendLine(countFile, "started")
+			<-ctx.Done()
+			// Linger so that a kill right after the signal wins.
+			time.Sleep(100 * time.Millisecond)
+			appendLine(countFile, "stopped")
+			return 0
+		}, WithPreStop(Hook{
+			Path: "/bin/sh",
+			Args: []string{"-c", `echo prestop >> "$0"`, countFile},
+		}), WithPreStopSleep(time.Second))
+	case "init-tasks":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(context.Context) int {
+			appendLine(countFile, "main")
+			return 4
+		}, WithInitTask(InitTask{
+			Name:     "optional",
+			Path:     "/bin/sh",
+			Args:     []string{"-c", `echo optional >> "$0"; exit 1`, countFile},
+			Optional: true,
+		}), WithInitTask(InitTask{
+			Name: "required",
+			Path: "/bin/sh",
+			Args: []string{"-c", `echo required >> "$0"; ` + os.Getenv("GO_HELPER_TASK_EXIT"), countFile},
+		}))
+	case "init-cron":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(context.Context) int {
+			time.Sleep(time.Second)
+			return 4
+		}, WithCronJob(CronJob{
+			Name:     "tick",
+			Schedule: "@every 100ms",
+			Path:     "/bin/sh",
+			Args:     []string{"-c", `trap 'echo end >> "$0"; exit 0' TERM; echo start >> "$0"; sleep 0.25; echo end >> "$0"`, countFile},
+		}))
+	case "init-summary":
+		countFile := os.Getenv(helperCountEnv)
+		var sup *Supervisor
+		runHelperInit(func(ctx context.Context) int {
+			buf := make([]byte, 32<<20)
+			for i := range buf {
+				buf[i] = byte(i)
+			}
+			return 3
+		}, WithSupervisor(func(s *Supervisor) { sup = s }), WithEventHandler(func(e Event) {
+			if e.Type != EventChildExited {
+				return
+			}
+			last, ok := sup.LastExit()
+			appendLine(countFile, fmt.Sprintf("code=%d signal=%d uptime=%t cpu=%t rss=%t last=%t",
+				e.Exit.ExitCode, e.Exit.Signal, e.Exit.Uptime > 0, e.Exit.UserCPU+e.Exit.SystemCPU > 0,
+				e.Exit.MaxRSS > 32<<20, ok && last == *e.Exit))
+		}))
+	case "init-orphans":
+		// A double fork: the grandchild outlives its parent and is
+		// re-parented to the init.
+		countFile := os.Getenv(helperCountEnv)
+		if err := setChildSubreaper(); err != nil {
+			os.Exit(2)
+		}
+		runHelperInit(func(ctx context.Context) int {
+			if err := exec.Command("sh", "-c", "(sleep 0.2; exit 7) &").Run(); err != nil {
+				return 1
+			}
+			time.Sleep(time.Second)
+			return 0
+		}, WithEventHandler(func(e Event) {
+			if e.Type == EventReaped && e.Orphan {
+				appendLine(countFile, fmt.Sprintf("orphan exit code %d", e.ExitCode))
+			}
+		}))
+	case "init-events":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			appendLine(countFile+".child", "running")
+			<-ctx.Done()
+			return 0
+		}, WithEventHandler(func(e Event) {
+			switch {
+			case e.Signal == syscall.SIGURG:
+				// Go's runtime preempts goroutines with SIGURG.
+			case e.Signal != 0:
+				appendLine(countFile, string(e.Type)+" "+e.Signal.String())
+			default:
+				appendLine(countFile, string(e.Type))
+			}
+		}))
+	case "unshare-pid":
+		Run(func(context.Context) int {
+			if os.Getppid() == 1 {
+				return 41
+			}
+			return 40
+		}, WithPIDNamespace())
+	case "subreaper":
+		Run(func(context.Context) int {
+			if _, ok := os.LookupEnv(childEnvKey); ok {
+				// PSI_* must not leak into submain.
+				return 45
+			}
+			if strconv.Itoa(os.Getppid()) != os.Getenv("GO_HELPER_PARENT") {
+				// Running below the init rather than directly.
+				return 43
+			}
+			return 44
+		}, WithSubreaper())
+	case "force-supervise":
+		Run(func(context.Context) int {
+			if _, ok := os.LookupEnv(childEnvKey); ok {
+				return 45
+			}
+			if strconv.Itoa(os.Getppid()) != os.Getenv("GO_HELPER_PARENT") {
+				return 43
+			}
+			return 44
+		}, WithForceSupervise())
+	case "init-extra-time":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			appendLine(countFile, "started")
+			<-ctx.Done()
+			if err := RequestExtraTime(3 * time.Second); err != nil {
+				return 2
+			}
+			if deadline, _ := ShutdownDeadline(ctx); time.Until(deadline) > 2*time.Second {
+				appendLine(countFile, "extended")
+			}
+			time.Sleep(600 * time.Millisecond)
+			appendLine(countFile, "checkpointed")
+			return 0
+		})
+	case "init-self-restart":
+		countFile := os.Getenv(helperCountEnv)
+		runHelperInit(func(ctx context.Context) int {
+			b, _ := os.ReadFile(countFile)
+			appendLine(countFile, "start")
+			if len(b) > 0 {
+				return 7
+			}
+			if err := RequestRestart(ctx); err != nil {
+				return 3
+			}
+			<-ctx.Done()
+			appendLine(countFile, "stopped")
+			return 0
+		})
+	case "init-sleep":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", "echo running > \"$" + helperCountEnv + "\"; exec sleep 30"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-prefixed":
+		cfg := newConfig(WithChildName("app"), WithSidecar(Sidecar{
+			Name: "proxy",
+			Path: "/bin/sh",
+			Args: []string{"-c", "echo proxy up; exec sleep 30"},
+		}))
+		cfg.command = []string{"/bin/sh", "-c", "sleep 0.2; echo out; echo err >&2; printf partial"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-color":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `printf '\033[32mgreen\033[0m\n'; exit 1`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-flood":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `i=0; while [ $i -lt 200 ]; do echo line $i; i=$((i+1)); done`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-secret":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `echo "token is $APP_TOKEN"; echo "password=hunter22 ok" >&2`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-pty":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `[ -t 0 ] && [ -t 1 ] && [ -t 2 ] && echo tty; stty size; [ "$(cut -d' ' -f6 /proc/$$/stat)" = $$ ] && echo leader`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-winsize":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `trap 'stty size <&1 > "$` + helperCountEnv + `.winch"' WINCH; stty size <&1 > "$` + helperCountEnv + `"; while :; do sleep 0.1; done`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-stdin":
+		// Reports what a read of stdin gets within half a second.
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `exec 3<&0; (if read line <&3; then echo "read $line"; else echo "eof $line"; fi) & pid=$!; sleep 0.5; kill -0 $pid 2>/dev/null && { echo blocked; kill $pid; }; wait`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-control":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", `f="$` + helperCountEnv + `"; trap 'echo hup >> "$f"' HUP; trap 'echo term >> "$f"; exit 0' TERM; echo start >> "$f"; while :; do sleep 0.05; done`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-control-chain":
+		// The child notes USR1 but only exits on INT.
+		cfg := newConfig(WithStopChain(StopStep{Signal: syscall.SIGUSR1, Wait: 100 * time.Millisecond}, StopStep{Signal: syscall.SIGINT}))
+		cfg.command = []string{"/bin/sh", "-c", `f="$` + helperCountEnv + `"; trap 'echo usr1 >> "$f"' USR1; trap 'echo int >> "$f"; exit 0' INT; trap 'echo term >> "$f"' TERM; echo start >> "$f"; while :; do sleep 0.05; done`}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-stderr":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", "echo starting >&2; echo 'panic: boom' >&2; exit 3"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-stubborn":
+		// A shell and its subprocess that both ignore SIGTERM.
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", "trap '' TERM; sleep 30 & echo running > \"$" + helperCountEnv + "\"; wait"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-processes":
+		// The init runs a subprocess of its own and reports how it exited;
+		// the child lasts until then.
+		countFile := os.Getenv(helperCountEnv)
+		cfg := newConfig(WithSupervisor(func(*Supervisor) {
+			go func() {
+				var out bytes.Buffer
+				cmd := exec.Command("/bin/sh", "-c", "echo hi; exit 5")
+				cmd.Stdout = &out
+				p, err := NewProcesses(context.Background()).Start(cmd)
+				if err != nil {
+					appendLine(countFile, err.Error())
+					return
+				}
+				code := p.Wait()
+				appendLine(countFile, fmt.Sprintf("%d %s", code, strings.TrimSpace(out.String())))
+			}()
+		}))
+		cfg.command = []string{"/bin/sh", "-c", "while [ ! -s \"$" + helperCountEnv + "\" ]; do sleep 0.05; done"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-command":
+		cfg := newConfig()
+		cfg.command = []string{"/bin/sh", "-c", "exit 9"}
+		os.Exit(newSupervisor(cfg).run())
+	case "init-program":
+		// Supervise the program named by GO_HELPER_PROGRAM.
+		cfg := newConfig()
+		cfg.command = []string{os.Getenv("GO_HELPER_PROGRAM")}
+		os.Exit(newSupervisor(cfg).run())
 	default:
 		fmt.Fprintf(os.Stderr, "unknown helper mode %q\n", mode)
 		os.Exit(3)
@@ -241,6 +1162,97 @@ func TestHelperProcess(t *testing.T) {
 	os.Exit(0)
 }
 
+// runHelperInit runs the supervisor as if the helper were PID 1; the
+// re-exec'd child (PSI_CHILD=1) takes the regular child path.
+func runHelperInit(submain SubMain, opts ...Option) {
+	if os.Getenv(childEnvKey) == childEnvVal {
+		Run(submain, opts...)
+	}
+	os.Exit(newSupervisor(newConfig(opts...)).run())
+}
+
+// checkListenFDs verifies the socket activation environment of a child and
+// returns 47 if it is complete.
+func checkListenFDs() int {
+	switch {
+	case os.Getenv(listenPIDEnv) != strconv.Itoa(os.Getpid()):
+		return 2
+	case os.Getenv(listenFDsEnv) != "1":
+		return 3
+	case os.Getenv(listenFDNamesEnv) != "http":
+		return 4
+	case os.Getenv(listenPIDFixEnv) != "":
+		return 5
+	}
+	l, err := net.FileListener(os.NewFile(listenFDStart, "http"))
+	if err != nil {
+		return 6
+	}
+	if l.Addr().String() != os.Getenv(helperListenAddrEnv) {
+		return 7
+	}
+	return 47
+}
+
+func appendLine(path, line string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+// upgradeSubmain is a child that serves on the init's socket until stopped,
+// logging its generation's start and stop to countFile. With
+// GO_HELPER_FAIL_UPGRADE set, generations after the first fail to start.
+func upgradeSubmain(countFile string) SubMain {
+	return func(ctx context.Context) int {
+		ln, err := Listener("http")
+		if err != nil {
+			return 3
+		}
+		defer ln.Close()
+		b, _ := os.ReadFile(countFile)
+		gen := strings.Count(string(b), "start") + 1
+		appendLine(countFile, fmt.Sprintf("start %d", gen))
+		if gen > 1 && os.Getenv(helperFailUpgradeEnv) != "" {
+			return 9
+		}
+		if err := Ready(); err != nil {
+			return 1
+		}
+		<-ctx.Done()
+		appendLine(countFile, fmt.Sprintf("stop %d", gen))
+		return 0
+	}
+}
+
+// waitForContent waits for the file at path to hold want.
+func waitForContent(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if string(b) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to contain %q, got %q", path, want, b)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
 type fakeSignal string
 
 func (f fakeSignal) String() string { return string(f) }
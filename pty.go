@@ -0,0 +1,181 @@
+package psi
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+const ptyEnv = "PSI_PTY"
+
+// WithPTY runs the child on a pseudo-terminal of its own, for interactive
+// containers (docker run -it): the child is the session leader with the
+// terminal as its controlling one, so job control, line editing and Ctrl-C
+// behave as on a real terminal. The init puts its own terminal, if stdin is
+// one, in raw mode, proxies the bytes both ways, passes its window size on
+// at start and on SIGWINCH, and restores its terminal on exit. The child's
+// stdout and stderr are merged and go the way of stdout (see WithLogDir);
+// sidecars are unaffected. Only supported on Linux. Overridden by PSI_PTY.
+func WithPTY() Option {
+	return func(c *config) {
+		c.pty = true
+	}
+}
+
+// ptyProxy connects the init's stdin and stdout to the pseudo-terminal of
+// the current child generation.
+type ptyProxy struct {
+	stdin   StdinMode
+	input   sync.Once
+	restore func()
+
+	mu     sync.Mutex
+	master *os.File
+	// eof is set once stdin has ended; lastByte is the last byte copied.
+	eof      bool
+	lastByte byte
+}
+
+// newPTYProxy returns the proxy if the child runs on a pseudo-terminal,
+// nil otherwise.
+func (c *config) newPTYProxy() *ptyProxy {
+	if !c.pty {
+		return nil
+	}
+	if !ptySupported {
+		log.Printf("psi: %s ignored: pseudo-terminals are only supported on Linux", ptyEnv)
+		return nil
+	}
+	return &ptyProxy{stdin: c.stdin}
+}
+
+// attach points cmd's stdin, stdout and stderr at a new pseudo-terminal
+// whose output is copied the way of o's stdout. The returned function
+// closes the init's end of the child's side and must be called after
+// Start.
+func (p *ptyProxy) attach(cmd *exec.Cmd, o *childOutput) (func(), error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if isTerminal(os.Stdin) {
+		if err := copyWinsize(os.Stdin, master); err != nil {
+			log.Printf("psi: setting the child's window size: %v", err)
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	p.mu.Lock()
+	p.master, p.lastByte = master, 0
+	switch p.stdin {
+	case StdinNull, StdinClosed:
+		p.sendEOF()
+	case StdinOpen:
+	default:
+		if p.eof {
+			p.sendEOF()
+		}
+	}
+	p.mu.Unlock()
+	if p.stdin == StdinInherit || p.stdin == "" {
+		p.input.Do(p.startInput)
+	}
+	o.copy(&o.stdout, os.Stdout, ptyReader{master})
+	return func() { slave.Close() }, nil
+}
+
+// startInput puts the init's terminal in raw mode, so that keys reach the
+// child's terminal as typed, and copies stdin to the child's terminal until
+// it ends, which it then passes on.
+func (p *ptyProxy) startInput() {
+	if isTerminal(os.Stdin) {
+		restore, err := makeRaw(os.Stdin)
+		if err != nil {
+			log.Printf("psi: switching the terminal to raw mode: %v", err)
+		} else {
+			p.restore = restore
+		}
+	}
+	go func() {
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := os.Stdin.Read(buf)
+			p.mu.Lock()
+			if n > 0 && p.master != nil {
+				p.master.Write(buf[:n])
+				p.lastByte = buf[n-1]
+			}
+			if err != nil {
+				p.eof = true
+				p.sendEOF()
+			}
+			p.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// ctrlD is the default EOF character of a terminal.
+const ctrlD = 0x04
+
+// sendEOF makes the child's next read of its terminal return end of file:
+// the EOF character ends a pending partial line, and a second one on an
+// empty line is the end of file. p.mu must be held.
+func (p *ptyProxy) sendEOF() {
+	if p.master == nil {
+		return
+	}
+	if p.lastByte != 0 && p.lastByte != '\n' {
+		p.master.Write([]byte{ctrlD})
+		p.lastByte = 0
+	}
+	p.master.Write([]byte{ctrlD})
+}
+
+// resize passes the init's window size on to the child's terminal, whose
+// foreground process group the kernel then sends SIGWINCH.
+func (p *ptyProxy) resize() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.master != nil && isTerminal(os.Stdin) {
+		copyWinsize(os.Stdin, p.master)
+	}
+}
+
+// close restores the init's terminal.
+func (p *ptyProxy) close() {
+	if p.restore != nil {
+		p.restore()
+		p.restore = nil
+	}
+}
+
+// stopPTY restores the init's terminal if the child ran on a
+// pseudo-terminal.
+func (s *supervisor) stopPTY() {
+	if s.pty != nil {
+		s.pty.close()
+	}
+}
+
+// ptyReader reads a pseudo-terminal's master side, taking the error it
+// returns once the child's side is closed for good (EIO on Linux) for the
+// end of the output.
+type ptyReader struct {
+	f *os.File
+}
+
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if err != nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r ptyReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,105 @@
+package psi
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTestPTY opens a pseudo-terminal of rows by cols.
+func openTestPTY(t *testing.T, rows, cols uint16) (master, slave *os.File) {
+	t.Helper()
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() {
+		master.Close()
+		slave.Close()
+	})
+	err = fdControl(master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return master, slave
+}
+
+func TestPTYTerminalModes(t *testing.T) {
+	_, a := openTestPTY(t, 24, 80)
+	_, b := openTestPTY(t, 0, 0)
+	if !isTerminal(a) {
+		t.Fatal("pseudo-terminal not a terminal")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if isTerminal(r) {
+		t.Fatal("pipe taken for a terminal")
+	}
+	if err := copyWinsize(a, b); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := unix.IoctlGetWinsize(int(b.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Row != 24 || ws.Col != 80 {
+		t.Fatalf("window size %+v, %v", ws, err)
+	}
+	restore, err := makeRaw(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tio, _ := unix.IoctlGetTermios(int(a.Fd()), unix.TCGETS)
+	if tio.Lflag&(unix.ICANON|unix.ECHO|unix.ISIG) != 0 {
+		t.Fatalf("still cooked: lflag %#x", tio.Lflag)
+	}
+	restore()
+	tio, _ = unix.IoctlGetTermios(int(a.Fd()), unix.TCGETS)
+	if tio.Lflag&unix.ICANON == 0 {
+		t.Fatal("terminal mode not restored")
+	}
+}
+
+func TestSupervisorRunsChildOnPTY(t *testing.T) {
+	_, slave := openTestPTY(t, 24, 80)
+	cmd := helperCommand("init-pty", ptyEnv+"=1")
+	cmd.Stdin = slave
+	out, err := cmd.Output()
+	if exit := exitStatus(err); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	if got := strings.ReplaceAll(string(out), "\r", ""); got != "tty\n24 80\nleader\n" {
+		t.Fatalf("output = %q", out)
+	}
+	tio, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err != nil || tio.Lflag&unix.ICANON == 0 {
+		t.Fatalf("init's terminal not restored: %+v, %v", tio, err)
+	}
+}
+
+func TestSupervisorPassesEOFToPTY(t *testing.T) {
+	for _, tc := range []struct {
+		mode, input, want string
+	}{
+		{"inherit", "hello\n", "read hello"},
+		{"inherit", "partial", "eof partial"},
+		{"inherit", "", "eof "},
+		{"null", "hello\n", "eof "},
+		{"open", "hello\n", "blocked"},
+	} {
+		cmd := helperCommand("init-stdin", ptyEnv+"=1", stdinEnv+"="+tc.mode)
+		cmd.Stdin = strings.NewReader(tc.input)
+		out, err := cmd.Output()
+		if exit := exitStatus(err); exit != 0 {
+			t.Fatalf("expected exit code 0, got %d", exit)
+		}
+		if !strings.Contains(strings.ReplaceAll(string(out), "\r", ""), tc.want+"\n") {
+			t.Errorf("%s %q: output = %q, want %q", tc.mode, tc.input, out, tc.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package psi
+
+import (
+	"errors"
+	"os"
+)
+
+// ptySupported reports whether the child can run on a pseudo-terminal.
+const ptySupported = false
+
+var errNoPTY = errors.New("pseudo-terminals are only supported on Linux")
+
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, errNoPTY
+}
@@ -0,0 +1,127 @@
+package psi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+const (
+	readyNotifyEnv = "PSI_READY_NOTIFY"
+	// readyFDEnv tells the child which inherited descriptor to report
+	// readiness on. It is always passed through to the child.
+	readyFDEnv = "PSI_READY_FD"
+)
+
+// WithReadyNotify makes the init wait for the child to report readiness by
+// calling Ready, or, for an external program, by writing a line to the file
+// descriptor named by PSI_READY_FD (e.g. echo READY=1 >&"$PSI_READY_FD") or
+// sending READY=1 to NOTIFY_SOCKET as with systemd's Type=notify.
+// Until then the child counts as starting: the startup timeout (see
+// WithStartTimeout) kills a child that does not become ready in time, and
+// sidecars declared with AfterReady are held back. Overridden by
+// PSI_READY_NOTIFY.
+func WithReadyNotify() Option {
+	return func(c *config) {
+		c.readyNotify = true
+	}
+}
+
+var (
+	readyMu   sync.Mutex
+	readyFile *os.File
+)
+
+// takeReadyFD claims the readiness descriptor inherited from the init, so it
+// is neither passed on to the submain's own children nor visible in its
+// environment.
+func takeReadyFD() {
+	val, ok := os.LookupEnv(readyFDEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(readyFDEnv)
+	fd, err := strconv.Atoi(val)
+	if err != nil || fd < 3 {
+		log.Printf("psi: invalid %s=%q; ignoring", readyFDEnv, val)
+		return
+	}
+	closeOnExec(fd)
+	readyMu.Lock()
+	readyFile = os.NewFile(uintptr(fd), "psi-ready")
+	readyMu.Unlock()
+}
+
+// Ready tells the init that submain has finished starting up, e.g. once its
+// listeners are open. It does nothing when the init is not waiting for
+// readiness (see WithReadyNotify) or readiness was already reported.
+func Ready() error {
+	readyMu.Lock()
+	defer readyMu.Unlock()
+	if readyFile == nil {
+		return nil
+	}
+	f := readyFile
+	readyFile = nil
+	defer f.Close()
+	if _, err := io.WriteString(f, "READY=1\n"); err != nil {
+		return fmt.Errorf("psi: reporting readiness: %w", err)
+	}
+	return nil
+}
+
+// prepareReady passes the write end of a readiness pipe to cmd when the init
+// waits for readiness. The returned function must be called once cmd has been
+// started, or failed to start; on success it arms s.readyc.
+func (s *supervisor) prepareReady(cmd *exec.Cmd) (func(started bool), error) {
+	s.ready, s.readyc = false, nil
+	if !s.cfg.readyNotify {
+		return func(bool) {}, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", readyFDEnv, 2+len(cmd.ExtraFiles)))
+	return func(started bool) {
+		w.Close()
+		if !started {
+			r.Close()
+			return
+		}
+		readyc := make(chan struct{})
+		s.readyc = readyc
+		go func() {
+			defer r.Close()
+			buf := make([]byte, 64)
+			// Any data means ready; EOF means the child closed the
+			// descriptor or exited without reporting readiness.
+			if n, _ := r.Read(buf); n > 0 {
+				close(readyc)
+			}
+		}()
+	}, nil
+}
+
+// childReady handles the child's readiness report, which may arrive over
+// the readiness pipe and the sd_notify socket alike.
+func (s *supervisor) childReady() {
+	s.readyc = nil
+	if s.ready {
+		return
+	}
+	s.ready = true
+	s.markReady()
+	s.cfg.debugf(1, "child (pid %d) reported ready", s.childPID)
+	if s.starting {
+		s.startupDeadline()
+	}
+	if err := s.startReadySidecars(); err != nil {
+		log.Printf("psi: failed to start sidecar: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package psi
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestReadyWithoutInit(t *testing.T) {
+	if err := Ready(); err != nil {
+		t.Fatalf("Ready without a readiness descriptor: %v", err)
+	}
+}
+
+func TestReadyWritesInheritedFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// Hand over a duplicate, as the child inherits its own descriptor.
+	fd, err := syscall.Dup(int(w.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	t.Setenv(readyFDEnv, strconv.Itoa(fd))
+	takeReadyFD()
+	if _, ok := os.LookupEnv(readyFDEnv); ok {
+		t.Fatalf("%s still set after takeReadyFD", readyFDEnv)
+	}
+	if err := Ready(); err != nil {
+		t.Fatalf("Ready: %v", err)
+	}
+	// A second call is a no-op.
+	if err := Ready(); err != nil {
+		t.Fatalf("second Ready: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "READY=1\n" {
+		t.Fatalf("read %q from the readiness pipe", b)
+	}
+}
+
+func TestReadyFDPassedThrough(t *testing.T) {
+	cfg := newConfig()
+	if !cfg.keepEnv(readyFDEnv) {
+		t.Fatalf("%s must reach the child", readyFDEnv)
+	}
+}
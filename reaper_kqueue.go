@@ -0,0 +1,85 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package psi
+
+import (
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// The kqueue reports the exit of every watched child (EVFILT_PROC with
+// NOTE_EXIT) and every SIGCHLD (EVFILT_SIGNAL), which covers children that
+// were not started through the reaper. It is created on first use.
+var (
+	kqOnce sync.Once
+	kqFD   = -1
+)
+
+// kqueue returns the reaper's kqueue, or -1 if it could not be set up.
+func kqueue() int {
+	kqOnce.Do(func() {
+		fd, err := unix.Kqueue()
+		if err != nil {
+			log.Printf("psi: kqueue: %v; falling back to wait4", err)
+			return
+		}
+		unix.CloseOnExec(fd)
+		ev := make([]unix.Kevent_t, 1)
+		unix.SetKevent(&ev[0], int(syscall.SIGCHLD), unix.EVFILT_SIGNAL, unix.EV_ADD)
+		if _, err := unix.Kevent(fd, ev, nil, nil); err != nil {
+			log.Printf("psi: kqueue: watching SIGCHLD: %v; falling back to wait4", err)
+			unix.Close(fd)
+			return
+		}
+		kqFD = fd
+	})
+	return kqFD
+}
+
+// watch registers h's exit with the kqueue. A child that has already exited
+// cannot be registered (ESRCH); it is reaped straight away instead.
+func (r *reaper) watch(h *procHandle, _ *exec.Cmd) {
+	kq := kqueue()
+	if kq < 0 {
+		return
+	}
+	ev := make([]unix.Kevent_t, 1)
+	unix.SetKevent(&ev[0], h.pid, unix.EVFILT_PROC, unix.EV_ADD|unix.EV_ONESHOT)
+	ev[0].Fflags = unix.NOTE_EXIT
+	if _, err := unix.Kevent(kq, ev, nil, nil); err != nil {
+		tracef("kevent: watching pid %d: %v", h.pid, err)
+		// The caller holds r.mu, which reaping takes.
+		go r.drain()
+	}
+}
+
+// loop reaps children forever, sleeping in kevent until one exits.
+func (r *reaper) loop() {
+	kq := kqueue()
+	if kq < 0 {
+		r.waitLoop()
+		return
+	}
+	events := make([]unix.Kevent_t, 16)
+	for {
+		n, err := unix.Kevent(kq, nil, events, nil)
+		if err != nil {
+			if err != unix.EINTR {
+				log.Printf("psi: kevent: %v; falling back to wait4", err)
+				r.waitLoop()
+				return
+			}
+			continue
+		}
+		for _, ev := range events[:n] {
+			if ev.Filter == unix.EVFILT_PROC {
+				tracef("kevent: pid %d exited", ev.Ident)
+			}
+		}
+		r.drain()
+	}
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"time"
+)
+
+// waitLoop reaps children forever, blocking in Wait4.
+func (r *reaper) waitLoop() {
+	for {
+		if _, err := r.reapOne(0); err != nil && err != syscall.EINTR {
+			// ECHILD: nothing to reap until the next child is started.
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// drain performs a single non-blocking reap pass.
+func (r *reaper) drain() {
+	for {
+		if pid, err := r.reapOne(syscall.WNOHANG); err != nil || pid <= 0 {
+			return
+		}
+	}
+}
+
+// reapOne reaps one child with Wait4(-1, options) and dispatches its exit
+// code if the PID is watched.
+func (r *reaper) reapOne(options int) (int, error) {
+	var ws syscall.WaitStatus
+	var ru syscall.Rusage
+	wpid, comm := -1, ""
+	if logEnabled(LogDebug) {
+		// Name the process while /proc still has it.
+		if pid := peekExited(options); pid > 0 {
+			wpid, comm = pid, procComm(pid)
+		}
+	}
+	pid, err := syscall.Wait4(wpid, &ws, options, &ru)
+	if err != nil || pid <= 0 {
+		return pid, err
+	}
+	r.reaped(pid, ws, ru, comm)
+	return pid, nil
+}
+
+// rawStatus returns the wait status as the kernel reported it.
+func rawStatus(ws syscall.WaitStatus) uint32 {
+	return uint32(ws)
+}
@@ -0,0 +1,11 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package psi
+
+import "os/exec"
+
+// watch has nothing to do: the reap loop collects every child.
+func (r *reaper) watch(*procHandle, *exec.Cmd) {}
+
+// loop reaps children forever.
+func (r *reaper) loop() { r.waitLoop() }
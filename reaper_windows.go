@@ -0,0 +1,39 @@
+package psi
+
+import (
+	"log"
+	"os/exec"
+	"syscall"
+)
+
+// watch puts the process in a kill-on-close Job Object, so that whatever it
+// starts dies with it or with the init, and waits for it: Windows has no
+// zombies to reap, nor orphans to adopt.
+func (r *reaper) watch(h *procHandle, cmd *exec.Cmd) {
+	job, err := newJob(h.pid)
+	if err != nil {
+		log.Printf("psi: no job object for pid %d: %v", h.pid, err)
+	}
+	h.job = job
+	go func() {
+		state, err := cmd.Process.Wait()
+		if err != nil {
+			log.Printf("psi: waiting for pid %d: %v", h.pid, err)
+			r.reaped(h.pid, syscall.WaitStatus{ExitCode: 1}, syscall.Rusage{}, cmd.Path)
+			return
+		}
+		ws, _ := state.Sys().(syscall.WaitStatus)
+		var ru syscall.Rusage
+		if u, ok := state.SysUsage().(*syscall.Rusage); ok {
+			ru = *u
+		}
+		r.reaped(h.pid, ws, ru, cmd.Path)
+	}()
+}
+
+func (r *reaper) loop()  {}
+func (r *reaper) drain() {}
+
+func rawStatus(ws syscall.WaitStatus) uint32 {
+	return ws.ExitCode
+}
@@ -0,0 +1,176 @@
+package psi
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactEnv = "PSI_REDACT"
+
+// redactMask replaces redacted text.
+const redactMask = "***"
+
+// minRedactLength is the length below which variable values are not
+// redacted: masking every "1" or "on" would garble the log.
+const minRedactLength = 4
+
+// WithRedact masks secrets in the child's and sidecars' output, forwarded
+// and written to the sinks, and in the init's own log, which may echo the
+// environment at debug level. Each entry is either the name of an
+// environment variable whose value is masked, possibly a pattern like
+// "*_TOKEN" (see path.Match), or a regular expression between slashes, as in
+// "/Bearer [A-Za-z0-9._-]+/", whose matches are masked, or only those of its
+// first group if it has one, as in "/password=(\S+)/". Values shorter than 4
+// bytes are left alone. Overridden by PSI_REDACT, a comma-separated list of
+// entries.
+func WithRedact(entries ...string) Option {
+	return func(c *config) {
+		c.redactNames, c.redactPatterns = nil, nil
+		c.addRedactions("WithRedact", entries)
+	}
+}
+
+// loadRedactEnv applies the PSI_REDACT override.
+func (c *config) loadRedactEnv() {
+	val := strings.TrimSpace(os.Getenv(redactEnv))
+	if val == "" {
+		return
+	}
+	c.redactNames, c.redactPatterns = nil, nil
+	c.addRedactions(redactEnv, splitRedactList(val))
+}
+
+// addRedactions adds the variable names and patterns of entries, logging
+// and skipping invalid ones.
+func (c *config) addRedactions(source string, entries []string) {
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "":
+		case len(e) > 2 && e[0] == '/' && e[len(e)-1] == '/':
+			re, err := regexp.Compile(e[1 : len(e)-1])
+			if err != nil {
+				log.Printf("psi: invalid %s pattern %q: %v; ignoring", source, e, err)
+				continue
+			}
+			c.redactPatterns = append(c.redactPatterns, re)
+		default:
+			if _, err := path.Match(e, ""); err != nil {
+				log.Printf("psi: invalid %s name %q: %v; ignoring", source, e, err)
+				continue
+			}
+			c.redactNames = append(c.redactNames, e)
+		}
+	}
+}
+
+// splitRedactList splits a PSI_REDACT list at the commas outside the
+// patterns between slashes.
+func splitRedactList(s string) []string {
+	var entries []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '/' {
+			if i := closingSlash(s); i > 0 {
+				entries, s = append(entries, s[:i+1]), strings.TrimPrefix(strings.TrimSpace(s[i+1:]), ",")
+				continue
+			}
+			return append(entries, s)
+		}
+		entry, rest, _ := strings.Cut(s, ",")
+		entries, s = append(entries, entry), rest
+	}
+	return entries
+}
+
+// closingSlash returns the index of the slash ending the pattern s starts
+// with: the first one followed by nothing but spaces and a comma or the end.
+func closingSlash(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != '/' {
+			continue
+		}
+		if rest := strings.TrimSpace(s[i+1:]); rest == "" || rest[0] == ',' {
+			return i
+		}
+	}
+	return -1
+}
+
+// redactor masks the values of the variables and the matches of the
+// patterns of WithRedact.
+type redactor struct {
+	values   [][]byte
+	patterns []*regexp.Regexp
+}
+
+// newRedactor returns the redactor for the current environment, nil if
+// there is nothing to redact.
+func (c *config) newRedactor() *redactor {
+	r := &redactor{patterns: c.redactPatterns}
+	seen := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		key, val, _ := strings.Cut(kv, "=")
+		if len(val) < minRedactLength || seen[val] {
+			continue
+		}
+		for _, name := range c.redactNames {
+			if ok, _ := path.Match(name, key); ok {
+				seen[val] = true
+				r.values = append(r.values, []byte(val))
+				break
+			}
+		}
+	}
+	if len(r.values) == 0 && len(r.patterns) == 0 {
+		return nil
+	}
+	// Longest first, so a value containing another is masked whole.
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+	return r
+}
+
+// line is a lineFilter masking the secrets in line.
+func (r *redactor) line(line []byte) []byte {
+	for _, v := range r.values {
+		if bytes.Contains(line, v) {
+			line = bytes.ReplaceAll(line, v, []byte(redactMask))
+		}
+	}
+	for _, re := range r.patterns {
+		line = redactMatches(re, line)
+	}
+	return line
+}
+
+// string masks the secrets in s; a nil redactor returns s as is.
+func (r *redactor) string(s string) string {
+	if r == nil {
+		return s
+	}
+	return string(r.line([]byte(s)))
+}
+
+// redactMatches masks the matches of re in b, or of its first group.
+func redactMatches(re *regexp.Regexp, b []byte) []byte {
+	matches := re.FindAllSubmatchIndex(b, -1)
+	if matches == nil {
+		return b
+	}
+	var out []byte
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		out = append(out, b[last:start]...)
+		out = append(out, redactMask...)
+		last = end
+	}
+	return append(out, b[last:]...)
+}
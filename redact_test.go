@@ -0,0 +1,70 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitRedactList(t *testing.T) {
+	got := splitRedactList(` API_KEY, /a{1,3}/ ,*_TOKEN,/x,y/`)
+	want := []string{"API_KEY", "/a{1,3}/", "*_TOKEN", "/x,y/"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	if newConfig().newRedactor() != nil {
+		t.Fatal("redactor without entries")
+	}
+	t.Setenv("APP_TOKEN", "s3cr3t-value")
+	t.Setenv("DB_TOKEN", "s3cr3t")
+	t.Setenv("SHORT_TOKEN", "on")
+	t.Setenv(redactEnv, `*_TOKEN,/password=(\S+)/,/Bearer \w+/,/(/`)
+	r := newConfig().newRedactor()
+	for in, want := range map[string]string{
+		"token s3cr3t-value and s3cr3t":     "token *** and ***",
+		"password=hunter2 user=bob":         "password=*** user=bob",
+		"Authorization: Bearer abc123 done": "Authorization: *** done",
+		"turned on":                         "turned on",
+	} {
+		if got := string(r.line([]byte(in))); got != want {
+			t.Errorf("line(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRedactInitLog(t *testing.T) {
+	t.Setenv("APP_TOKEN", "s3cr3t-value")
+	var buf bytes.Buffer
+	defer func(sink logSink, r *redactor) { initLog.sink, initLog.redact = sink, r }(initLog.sink, initLog.redact)
+	initLog.sink = textLog{log.New(&buf, "", 0)}
+	initLog.redact = newConfig(WithRedact("APP_TOKEN")).newRedactor()
+	logMessage(LogError, "environment: APP_TOKEN=s3cr3t-value")
+	if got := buf.String(); got != "psi: environment: APP_TOKEN=***\n" {
+		t.Fatalf("logged %q", got)
+	}
+}
+
+func TestSupervisorRedactsOutput(t *testing.T) {
+	dir := t.TempDir()
+	cmd := helperCommand("init-secret", "APP_TOKEN=tok-12345", redactEnv+`=APP_TOKEN,/password=(\S+)/`, logDirEnv+"="+dir)
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	if stdout.String() != "token is ***\n" || !strings.Contains(stderr.String(), "password=*** ok\n") {
+		t.Fatalf("stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+	b, err := os.ReadFile(dir + "/stdout.log")
+	if err != nil || string(b) != "token is ***\n" {
+		t.Fatalf("stdout.log = %q, %v", b, err)
+	}
+}
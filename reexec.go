@@ -0,0 +1,46 @@
+package psi
+
+import (
+	"os"
+	"strings"
+)
+
+const reexecPathEnv = "PSI_REEXEC_PATH"
+
+// procSelfExe names the running binary even when it was started by a
+// relative path, renamed, deleted or replaced since.
+const procSelfExe = "/proc/self/exe"
+
+// WithReexecPath sets the binary the init re-executes to run submain (and
+// as the socket activation trampoline). By default it is the running binary
+// itself, through /proc/self/exe where available and os.Executable
+// elsewhere, so neither a relative os.Args[0] nor a replaced binary on disk
+// breaks it; set a path to start the binary found there instead, e.g. to
+// pick up a new version on restart or upgrade. The child's argv[0] stays
+// os.Args[0]. Overridden by PSI_REEXEC_PATH.
+func WithReexecPath(path string) Option {
+	return func(c *config) {
+		c.reexecPath = path
+	}
+}
+
+// loadReexecEnv applies the PSI_REEXEC_PATH override.
+func (c *config) loadReexecEnv() {
+	if val := strings.TrimSpace(os.Getenv(reexecPathEnv)); val != "" {
+		c.reexecPath = val
+	}
+}
+
+// selfExe returns the path of the binary to re-execute.
+func (c *config) selfExe() string {
+	if c.reexecPath != "" {
+		return c.reexecPath
+	}
+	if _, err := os.Stat(procSelfExe); err == nil {
+		return procSelfExe
+	}
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+	return os.Args[0]
+}
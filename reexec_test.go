@@ -0,0 +1,47 @@
+//go:build !windows
+
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSelfExe(t *testing.T) {
+	if got := newConfig(WithReexecPath("/app/server")).selfExe(); got != "/app/server" {
+		t.Fatalf("selfExe = %q", got)
+	}
+	t.Setenv(reexecPathEnv, "/app/next")
+	if got := newConfig(WithReexecPath("/app/server")).selfExe(); got != "/app/next" {
+		t.Fatalf("selfExe with %s = %q", reexecPathEnv, got)
+	}
+	t.Setenv(reexecPathEnv, "")
+	got := newConfig().selfExe()
+	if runtime.GOOS == "linux" && got != procSelfExe {
+		t.Fatalf("selfExe = %q, want %s", got, procSelfExe)
+	}
+	if _, err := os.Stat(got); err != nil {
+		t.Fatalf("selfExe = %q: %v", got, err)
+	}
+}
+
+func TestSupervisorReexecRelativeArgv0(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	exe, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	countFile := t.TempDir() + "/count"
+	// The child runs elsewhere than the init, so a relative argv[0] would
+	// not find the binary.
+	cmd := helperCommand("init-exit3", helperCountEnv+"="+countFile, chdirEnv+"=/")
+	cmd.Path, cmd.Args[0], cmd.Dir = "./"+filepath.Base(exe), "./"+filepath.Base(exe), filepath.Dir(exe)
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	waitForContent(t, countFile, "run\n")
+}
@@ -0,0 +1,235 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	restartEnv         = "PSI_RESTART"
+	restartDelayEnv    = "PSI_RESTART_DELAY"
+	restartMaxDelayEnv = "PSI_RESTART_MAX_DELAY"
+	maxRestartsEnv     = "PSI_MAX_RESTARTS"
+	minUptimeEnv       = "PSI_MIN_UPTIME"
+	crashLoopLimitEnv  = "PSI_CRASH_LOOP_LIMIT"
+
+	defaultRestartDelay    = time.Second
+	defaultRestartMaxDelay = 30 * time.Second
+	defaultCrashLoopLimit  = 5
+)
+
+// RestartPolicy decides whether the init starts a new child after the
+// current one exits on its own (i.e. not because the init is shutting down).
+type RestartPolicy string
+
+const (
+	// RestartNever exits the init together with the child (default).
+	RestartNever RestartPolicy = "never"
+	// RestartAlways restarts the child regardless of its exit code.
+	RestartAlways RestartPolicy = "always"
+	// RestartOnFailure restarts the child when it exits non-zero.
+	RestartOnFailure RestartPolicy = "on-failure"
+)
+
+func parseRestartPolicy(s string) (RestartPolicy, error) {
+	switch p := RestartPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case RestartNever, RestartAlways, RestartOnFailure:
+		return p, nil
+	case "no", "":
+		return RestartNever, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q", s)
+	}
+}
+
+// WithRestart sets the child restart policy. Overridden by PSI_RESTART.
+func WithRestart(policy RestartPolicy) Option {
+	return func(c *config) {
+		c.restart.policy = policy
+	}
+}
+
+// WithRestartBackoff sets the initial and maximum delay between restarts. The
+// delay doubles for every consecutive restart and is jittered by up to 20%;
+// it starts over from the initial delay once a generation has stayed up for
+// the minimum uptime (see WithCrashLoopDetection), or for the maximum delay
+// if that is unset.
+// Overridden by PSI_RESTART_DELAY and PSI_RESTART_MAX_DELAY.
+func WithRestartBackoff(initial, maxDelay time.Duration) Option {
+	return func(c *config) {
+		c.restart.delay = initial
+		c.restart.maxDelay = maxDelay
+	}
+}
+
+// WithMaxRestarts caps the number of restarts; once reached, the init exits
+// with the child's last exit code. Zero means unlimited. Overridden by
+// PSI_MAX_RESTARTS.
+func WithMaxRestarts(n int) Option {
+	return func(c *config) {
+		c.restart.max = n
+	}
+}
+
+// WithCrashLoopDetection stops restarting once the child has exited within
+// minUptime of starting more than limit times in a row; the init then exits
+// with ExitCrashLoop. A zero minUptime disables detection. Overridden by
+// PSI_MIN_UPTIME and PSI_CRASH_LOOP_LIMIT.
+func WithCrashLoopDetection(minUptime time.Duration, limit int) Option {
+	return func(c *config) {
+		c.restart.minUptime = minUptime
+		c.restart.crashLoopLimit = limit
+	}
+}
+
+// restartConfig is the restart subsystem's part of config.
+type restartConfig struct {
+	policy   RestartPolicy
+	delay    time.Duration
+	maxDelay time.Duration
+	max      int
+
+	minUptime      time.Duration
+	crashLoopLimit int
+}
+
+func defaultRestartConfig() restartConfig {
+	return restartConfig{
+		policy:   RestartNever,
+		delay:    defaultRestartDelay,
+		maxDelay: defaultRestartMaxDelay,
+
+		crashLoopLimit: defaultCrashLoopLimit,
+	}
+}
+
+// loadEnv applies the PSI_RESTART* overrides.
+func (r *restartConfig) loadEnv() {
+	if val := strings.TrimSpace(os.Getenv(restartEnv)); val != "" {
+		p, err := parseRestartPolicy(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", restartEnv, val, err)
+		} else {
+			r.policy = p
+		}
+	}
+	envDuration(restartDelayEnv, &r.delay)
+	envDuration(restartMaxDelayEnv, &r.maxDelay)
+	if val := strings.TrimSpace(os.Getenv(maxRestartsEnv)); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			log.Printf("psi: invalid %s=%q; ignoring", maxRestartsEnv, val)
+		} else {
+			r.max = n
+		}
+	}
+	envDuration(minUptimeEnv, &r.minUptime)
+	if val := strings.TrimSpace(os.Getenv(crashLoopLimitEnv)); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			log.Printf("psi: invalid %s=%q; ignoring", crashLoopLimitEnv, val)
+		} else {
+			r.crashLoopLimit = n
+		}
+	}
+}
+
+// shouldRestart reports whether a child that exited with code should be
+// restarted, given how many restarts already happened.
+func (r *restartConfig) shouldRestart(code, restarts int) bool {
+	if r.max > 0 && restarts >= r.max {
+		return false
+	}
+	switch r.policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return code != 0
+	default:
+		return false
+	}
+}
+
+// backoffDelay returns the exponential delay before restart number n
+// (0-based), capped at maxDelay and jittered by up to 20%.
+func (r *restartConfig) backoffDelay(n int) time.Duration {
+	d := r.delay
+	for i := 0; i < n && d < r.maxDelay; i++ {
+		d *= 2
+	}
+	if r.maxDelay > 0 && d > r.maxDelay {
+		d = r.maxDelay
+	}
+	if d > 0 {
+		d += rand.N(d/5 + 1)
+	}
+	return d
+}
+
+// crashLoop records a child generation's uptime and reports whether the
+// child is crash looping: it exited before minUptime more than
+// crashLoopLimit times in a row.
+func (s *supervisor) crashLoop(uptime time.Duration) bool {
+	r := &s.cfg.restart
+	if r.minUptime <= 0 {
+		return false
+	}
+	if uptime >= r.minUptime {
+		s.fastExits = 0
+		return false
+	}
+	s.fastExits++
+	return s.fastExits > r.crashLoopLimit
+}
+
+// stableUptime is the uptime after which a generation counts as having run
+// healthily, resetting the backoff.
+func (r *restartConfig) stableUptime() time.Duration {
+	if r.minUptime > 0 {
+		return r.minUptime
+	}
+	return r.maxDelay
+}
+
+// shouldRestart applies the restart policy to the child that just exited.
+func (s *supervisor) shouldRestart(code int) bool {
+	return s.cfg.restart.shouldRestart(code, s.restarts)
+}
+
+// backoff waits before the next restart of a child that exited with code
+// after uptime while still honouring terminate signals. It returns false if
+// the init should exit instead of restarting.
+func (s *supervisor) backoff(code int, uptime time.Duration) bool {
+	if uptime >= s.cfg.restart.stableUptime() {
+		s.backoffs = 0
+	}
+	delay := s.cfg.restart.backoffDelay(s.backoffs)
+	s.backoffs++
+	log.Printf("psi: child exited with code %d; restarting in %s (restart %d)", code, delay.Round(time.Millisecond), s.restarts+1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			tracef("restart timer expired")
+			return true
+		case received := <-s.sigs:
+			if sig, ok := toSyscallSignal(received); ok && !s.cfg.isIgnored(sig) &&
+				s.cfg.isTerminate(s.cfg.translateSignal(sig)) {
+				return false
+			}
+		case call := <-s.controls:
+			if call.req.Command == "stop" {
+				log.Printf("psi: stop requested over the control socket")
+				call.result <- nil
+				return false
+			}
+			s.handleControl(call)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package psi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	cases := map[string]RestartPolicy{
+		"never":      RestartNever,
+		"no":         RestartNever,
+		"Always":     RestartAlways,
+		"on-failure": RestartOnFailure,
+	}
+	for input, want := range cases {
+		got, err := parseRestartPolicy(input)
+		if err != nil || got != want {
+			t.Fatalf("parseRestartPolicy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := parseRestartPolicy("sometimes"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
+
+func TestRestartShouldRestart(t *testing.T) {
+	r := restartConfig{policy: RestartOnFailure, max: 2}
+	if r.shouldRestart(0, 0) {
+		t.Fatal("on-failure must not restart a clean exit")
+	}
+	if !r.shouldRestart(1, 1) {
+		t.Fatal("on-failure should restart a failed child below the cap")
+	}
+	if r.shouldRestart(1, 2) {
+		t.Fatal("restart cap should stop restarts")
+	}
+	r = restartConfig{policy: RestartAlways}
+	if !r.shouldRestart(0, 100) {
+		t.Fatal("always with no cap should restart")
+	}
+	r = restartConfig{policy: RestartNever}
+	if r.shouldRestart(1, 0) {
+		t.Fatal("never must not restart")
+	}
+}
+
+func TestRestartBackoffDelay(t *testing.T) {
+	r := restartConfig{delay: 100 * time.Millisecond, maxDelay: time.Second}
+	for n, base := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		base *= time.Millisecond
+		got := r.backoffDelay(n)
+		if got < base || got > base+base/5 {
+			t.Fatalf("backoffDelay(%d) = %s, want within [%s, %s]", n, got, base, base+base/5)
+		}
+	}
+}
+
+func TestRestartEnv(t *testing.T) {
+	t.Setenv(restartEnv, "on-failure")
+	t.Setenv(restartDelayEnv, "2")
+	t.Setenv(restartMaxDelayEnv, "1m")
+	t.Setenv(maxRestartsEnv, "5")
+	cfg := newConfig(WithRestart(RestartAlways), WithMaxRestarts(1))
+	want := restartConfig{policy: RestartOnFailure, delay: 2 * time.Second, maxDelay: time.Minute, max: 5, crashLoopLimit: defaultCrashLoopLimit}
+	if cfg.restart != want {
+		t.Fatalf("restart config = %+v, want %+v", cfg.restart, want)
+	}
+}
+
+func TestCrashLoopDetection(t *testing.T) {
+	s := &supervisor{cfg: &config{restart: restartConfig{minUptime: time.Second, crashLoopLimit: 2}}}
+	if s.crashLoop(10 * time.Millisecond) {
+		t.Fatal("first fast exit should not be a crash loop")
+	}
+	if s.crashLoop(10 * time.Millisecond) {
+		t.Fatal("second fast exit is still within the limit")
+	}
+	if s.crashLoop(2 * time.Second) {
+		t.Fatal("a long-lived generation is not a crash")
+	}
+	if s.fastExits != 0 {
+		t.Fatalf("long-lived generation should reset the counter, got %d", s.fastExits)
+	}
+	for i := 0; i < 2; i++ {
+		s.crashLoop(time.Millisecond)
+	}
+	if !s.crashLoop(time.Millisecond) {
+		t.Fatal("third consecutive fast exit should be a crash loop")
+	}
+}
+
+func TestCrashLoopDisabled(t *testing.T) {
+	s := &supervisor{cfg: &config{restart: restartConfig{crashLoopLimit: 0}}}
+	for i := 0; i < 10; i++ {
+		if s.crashLoop(0) {
+			t.Fatal("crash-loop detection should be disabled without a minimum uptime")
+		}
+	}
+}
+
+func TestBackoffResetsAfterHealthyRun(t *testing.T) {
+	s := &supervisor{cfg: &config{restart: restartConfig{delay: time.Millisecond, maxDelay: time.Second, minUptime: 50 * time.Millisecond}}}
+	for i := 0; i < 3; i++ {
+		s.backoff(1, time.Millisecond)
+	}
+	if s.backoffs != 3 {
+		t.Fatalf("backoffs = %d after three fast exits", s.backoffs)
+	}
+	start := time.Now()
+	s.backoff(1, time.Hour)
+	if elapsed := time.Since(start); s.backoffs != 1 || elapsed > 100*time.Millisecond {
+		t.Fatalf("after a healthy run: backoffs = %d, delay %s", s.backoffs, elapsed)
+	}
+	if got := (&restartConfig{maxDelay: time.Minute}).stableUptime(); got != time.Minute {
+		t.Fatalf("stableUptime without minUptime = %s", got)
+	}
+}
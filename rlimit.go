@@ -0,0 +1,169 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const rlimitEnvPrefix = "PSI_RLIMIT_"
+
+// rlimitGateEnv names the descriptor on which a child waits for the init to
+// set its resource limits. It is always passed through to the child.
+const rlimitGateEnv = "PSI_RLIMIT_GATE"
+
+// RlimitUnlimited is the "unlimited" value (RLIM_INFINITY) for WithRlimit.
+const RlimitUnlimited = ^uint64(0)
+
+// rlimitValue is a soft/hard resource limit pair.
+type rlimitValue struct {
+	soft, hard uint64
+}
+
+// WithRlimit sets a resource limit for the child, e.g.
+// WithRlimit("NOFILE", 65536, 65536) or WithRlimit("CORE", RlimitUnlimited,
+// RlimitUnlimited). Resource names follow RLIMIT_* without the prefix. The
+// limits are applied to the init before it starts children, which inherit
+// them. Linux only. Overridden by PSI_RLIMIT_<RESOURCE>, whose value is
+// "N", "SOFT:HARD" or "unlimited".
+func WithRlimit(resource string, soft, hard uint64) Option {
+	return func(c *config) {
+		if c.rlimits == nil {
+			c.rlimits = make(map[string]rlimitValue)
+		}
+		c.rlimits[strings.ToUpper(resource)] = rlimitValue{soft: soft, hard: hard}
+	}
+}
+
+// parseRlimit parses "N", "SOFT:HARD" or "unlimited" (also usable for either
+// half of a pair).
+func parseRlimit(s string) (rlimitValue, error) {
+	soft, hard, pair := strings.Cut(strings.TrimSpace(s), ":")
+	sv, err := parseRlimitNumber(soft)
+	if err != nil {
+		return rlimitValue{}, err
+	}
+	hv := sv
+	if pair {
+		if hv, err = parseRlimitNumber(hard); err != nil {
+			return rlimitValue{}, err
+		}
+	}
+	if sv > hv {
+		return rlimitValue{}, fmt.Errorf("soft limit %q exceeds hard limit", s)
+	}
+	return rlimitValue{soft: sv, hard: hv}, nil
+}
+
+func parseRlimitNumber(s string) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "unlimited" || s == "infinity" {
+		return RlimitUnlimited, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// loadRlimitEnv collects PSI_RLIMIT_* variables.
+func (c *config) loadRlimitEnv() {
+	for _, kv := range os.Environ() {
+		key, val, _ := strings.Cut(kv, "=")
+		resource, ok := strings.CutPrefix(key, rlimitEnvPrefix)
+		if !ok || resource == "" {
+			continue
+		}
+		v, err := parseRlimit(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", key, val, err)
+			continue
+		}
+		WithRlimit(resource, v.soft, v.hard)(c)
+	}
+}
+
+// applyRlimits sets the configured resource limits on the current process,
+// when it runs submain or the program itself rather than supervising it.
+func (c *config) applyRlimits() {
+	c.applyRlimitsTo(0)
+}
+
+// applyRlimitsTo sets the configured resource limits on process pid, 0 for
+// the current one.
+func (c *config) applyRlimitsTo(pid int) {
+	names := make([]string, 0, len(c.rlimits))
+	for name := range c.rlimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := setRlimit(pid, name, c.rlimits[name]); err != nil {
+			log.Printf("psi: failed to set RLIMIT_%s: %v", name, err)
+		}
+	}
+}
+
+// prepareRlimits passes cmd one end of a socket pair on which the child, once
+// it runs, reports that it is waiting for its resource limits, and waits for
+// the init to have set them. An external program is started through this
+// binary as a trampoline that waits in its stead. The returned function must
+// be called with the child's PID once cmd has been started, or with 0 if it
+// failed to start.
+func (s *supervisor) prepareRlimits(cmd *exec.Cmd) (func(pid int), error) {
+	if len(s.cfg.rlimits) == 0 {
+		return func(int) {}, nil
+	}
+	if !rlimitsSupported {
+		log.Printf("psi: %s* ignored: resource limits are only supported on Linux", rlimitEnvPrefix)
+		s.cfg.rlimits = nil
+		return func(int) {}, nil
+	}
+	local, remote, err := socketPair()
+	if err != nil {
+		return nil, err
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, remote)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", rlimitGateEnv, 2+len(cmd.ExtraFiles)))
+	s.cfg.viaTrampoline(cmd)
+	return func(pid int) {
+		remote.Close()
+		if pid == 0 {
+			local.Close()
+			return
+		}
+		go func() {
+			defer local.Close()
+			// Nothing to read means the child exited first.
+			if n, _ := local.Read(make([]byte, 1)); n == 0 {
+				return
+			}
+			s.cfg.applyRlimitsTo(pid)
+			local.Write([]byte{1})
+		}()
+	}, nil
+}
+
+// awaitRlimitGate has the init set this process's resource limits if it
+// asked to: the process reports that it is waiting only now, once the Go
+// runtime has raised its NOFILE soft limit, which would otherwise override
+// the configured one.
+func awaitRlimitGate() {
+	val, ok := os.LookupEnv(rlimitGateEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(rlimitGateEnv)
+	fd, err := strconv.Atoi(val)
+	if err != nil || fd < 3 {
+		log.Printf("psi: invalid %s=%q; ignoring", rlimitGateEnv, val)
+		return
+	}
+	f := os.NewFile(uintptr(fd), "psi-rlimit-gate")
+	defer f.Close()
+	if _, err := f.Write([]byte{1}); err == nil {
+		f.Read(make([]byte, 1))
+	}
+	keepNofileLimit()
+}
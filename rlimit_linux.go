@@ -0,0 +1,66 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const rlimitsSupported = true
+
+var rlimitResources = map[string]int{
+	"AS":         unix.RLIMIT_AS,
+	"CORE":       unix.RLIMIT_CORE,
+	"CPU":        unix.RLIMIT_CPU,
+	"DATA":       unix.RLIMIT_DATA,
+	"FSIZE":      unix.RLIMIT_FSIZE,
+	"LOCKS":      unix.RLIMIT_LOCKS,
+	"MEMLOCK":    unix.RLIMIT_MEMLOCK,
+	"MSGQUEUE":   unix.RLIMIT_MSGQUEUE,
+	"NICE":       unix.RLIMIT_NICE,
+	"NOFILE":     unix.RLIMIT_NOFILE,
+	"NPROC":      unix.RLIMIT_NPROC,
+	"RSS":        unix.RLIMIT_RSS,
+	"RTPRIO":     unix.RLIMIT_RTPRIO,
+	"RTTIME":     unix.RLIMIT_RTTIME,
+	"SIGPENDING": unix.RLIMIT_SIGPENDING,
+	"STACK":      unix.RLIMIT_STACK,
+}
+
+// setRlimit applies a resource limit to process pid with prlimit, or to the
+// current process if pid is 0. There NOFILE goes through syscall.Setrlimit
+// (via unix.Setrlimit) so the Go runtime stops restoring its startup NOFILE
+// soft limit in children.
+func setRlimit(pid int, name string, v rlimitValue) error {
+	res, ok := rlimitResources[name]
+	if !ok {
+		return fmt.Errorf("unknown resource %q", name)
+	}
+	lim := &unix.Rlimit{Cur: v.soft, Max: v.hard}
+	if pid == 0 {
+		return unix.Setrlimit(res, lim)
+	}
+	return unix.Prlimit(pid, res, lim, nil)
+}
+
+// keepNofileLimit stops the Go runtime from restoring its startup NOFILE
+// soft limit in the programs this process execs, now that the init has set
+// the limit: setting it, even to its current value, does.
+func keepNofileLimit() {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err == nil {
+		syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+	}
+}
+
+// socketPair returns the two ends of a connected Unix socket pair, both
+// close-on-exec.
+func socketPair() (*os.File, *os.File, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+	return os.NewFile(uintptr(fds[0]), "psi-rlimit-gate"), os.NewFile(uintptr(fds[1]), "psi-rlimit-gate"), nil
+}
@@ -0,0 +1,35 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestSupervisorRlimitsChildOnly(t *testing.T) {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatal(err)
+	}
+	if lim.Cur <= 1000 {
+		t.Skipf("NOFILE soft limit %d too low", lim.Cur)
+	}
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "count")
+	script := filepath.Join(dir, "report")
+	report := "#!/bin/sh\nulimit -n >> \"$" + helperCountEnv + "\"\n" +
+		"awk '/^Max open files/ { print $4 }' /proc/$PPID/limits >> \"$" + helperCountEnv + "\"\n"
+	if err := os.WriteFile(script, []byte(report), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The child gets the limit; the init, a Go program like this test, keeps
+	// the soft limit the runtime raised at startup.
+	cmd := helperCommand("init-program", "GO_HELPER_PROGRAM="+script, helperCountEnv+"="+countFile,
+		rlimitEnvPrefix+"NOFILE=1000")
+	if exit := exitStatus(cmd.Run()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+	waitForContent(t, countFile, "1000\n"+strconv.FormatUint(lim.Cur, 10)+"\n")
+}
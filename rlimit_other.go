@@ -0,0 +1,22 @@
+//go:build !linux
+
+package psi
+
+import (
+	"errors"
+	"os"
+)
+
+const rlimitsSupported = false
+
+var errRlimitUnsupported = errors.New("resource limits are only supported on Linux")
+
+func setRlimit(int, string, rlimitValue) error {
+	return errRlimitUnsupported
+}
+
+func keepNofileLimit() {}
+
+func socketPair() (*os.File, *os.File, error) {
+	return nil, nil, errRlimitUnsupported
+}
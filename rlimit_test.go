@@ -0,0 +1,36 @@
+package psi
+
+import "testing"
+
+func TestParseRlimit(t *testing.T) {
+	cases := map[string]rlimitValue{
+		"65536":       {soft: 65536, hard: 65536},
+		"1024:4096":   {soft: 1024, hard: 4096},
+		"unlimited":   {soft: RlimitUnlimited, hard: RlimitUnlimited},
+		"0:unlimited": {soft: 0, hard: RlimitUnlimited},
+		" 10 : 20 ":   {soft: 10, hard: 20},
+	}
+	for input, want := range cases {
+		got, err := parseRlimit(input)
+		if err != nil || got != want {
+			t.Fatalf("parseRlimit(%q) = %+v, %v; want %+v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "lots", "10:5", "unlimited:10"} {
+		if _, err := parseRlimit(input); err == nil {
+			t.Fatalf("parseRlimit(%q) should fail", input)
+		}
+	}
+}
+
+func TestRlimitEnv(t *testing.T) {
+	t.Setenv(rlimitEnvPrefix+"NOFILE", "4096")
+	t.Setenv(rlimitEnvPrefix+"core", "unlimited")
+	cfg := newConfig(WithRlimit("nofile", 1024, 1024))
+	if got := cfg.rlimits["NOFILE"]; got != (rlimitValue{soft: 4096, hard: 4096}) {
+		t.Fatalf("expected env NOFILE to win, got %+v", got)
+	}
+	if got := cfg.rlimits["CORE"]; got.soft != RlimitUnlimited {
+		t.Fatalf("expected CORE unlimited, got %+v", got)
+	}
+}
@@ -0,0 +1,9 @@
+package psi
+
+import "syscall"
+
+// maxRSSBytes returns ru's peak resident set size in bytes, which Darwin
+// reports as is.
+func maxRSSBytes(ru *syscall.Rusage) int64 {
+	return ru.Maxrss
+}
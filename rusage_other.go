@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package psi
+
+import "syscall"
+
+// maxRSSBytes returns ru's peak resident set size in bytes; Linux and the
+// BSDs report it in KiB.
+func maxRSSBytes(ru *syscall.Rusage) int64 {
+	return int64(ru.Maxrss) * 1024
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"time"
+)
+
+// userCPU returns the user CPU time in ru.
+func userCPU(ru *syscall.Rusage) time.Duration {
+	return time.Duration(ru.Utime.Nano())
+}
+
+// systemCPU returns the system CPU time in ru.
+func systemCPU(ru *syscall.Rusage) time.Duration {
+	return time.Duration(ru.Stime.Nano())
+}
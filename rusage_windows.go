@@ -0,0 +1,24 @@
+package psi
+
+import (
+	"syscall"
+	"time"
+)
+
+// filetimeDuration converts a FILETIME holding a duration, in 100ns units.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
+
+func userCPU(ru *syscall.Rusage) time.Duration {
+	return filetimeDuration(ru.UserTime)
+}
+
+func systemCPU(ru *syscall.Rusage) time.Duration {
+	return filetimeDuration(ru.KernelTime)
+}
+
+// maxRSSBytes is unknown: Windows reports no peak working set on exit.
+func maxRSSBytes(*syscall.Rusage) int64 {
+	return 0
+}
@@ -0,0 +1,174 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	niceEnv          = "PSI_NICE"
+	ioniceClassEnv   = "PSI_IONICE_CLASS"
+	ioniceLevelEnv   = "PSI_IONICE_LEVEL"
+	schedPolicyEnv   = "PSI_SCHED_POLICY"
+	schedPriorityEnv = "PSI_SCHED_PRIORITY"
+)
+
+// IOClass is an I/O scheduling class for ioprio_set.
+type IOClass int
+
+// I/O scheduling classes, as understood by ionice(1).
+const (
+	IOClassNone IOClass = iota
+	IOClassRealtime
+	IOClassBestEffort
+	IOClassIdle
+)
+
+// SchedPolicy is a CPU scheduling policy for sched_setattr.
+type SchedPolicy string
+
+// Scheduling policies. SCHED_FIFO and SCHED_RR need a priority (1-99) and
+// CAP_SYS_NICE.
+const (
+	SchedOther SchedPolicy = "other"
+	SchedBatch SchedPolicy = "batch"
+	SchedIdle  SchedPolicy = "idle"
+	SchedFIFO  SchedPolicy = "fifo"
+	SchedRR    SchedPolicy = "rr"
+)
+
+// schedConfig holds the child's CPU and I/O priority settings.
+type schedConfig struct {
+	nice     *int
+	ioClass  *IOClass
+	ioLevel  int
+	policy   SchedPolicy
+	priority int
+}
+
+// WithNice sets the child's nice value (-20 to 19). Overridden by PSI_NICE.
+func WithNice(nice int) Option {
+	return func(c *config) {
+		c.sched.nice = &nice
+	}
+}
+
+// WithIOPriority sets the child's I/O scheduling class and level (0-7, lower
+// is higher priority; ignored for IOClassIdle). Overridden by
+// PSI_IONICE_CLASS (none, realtime, best-effort, idle or 0-3) and
+// PSI_IONICE_LEVEL.
+func WithIOPriority(class IOClass, level int) Option {
+	return func(c *config) {
+		c.sched.ioClass = &class
+		c.sched.ioLevel = level
+	}
+}
+
+// WithSchedPolicy sets the child's CPU scheduling policy; priority only
+// applies to SchedFIFO and SchedRR. Overridden by PSI_SCHED_POLICY and
+// PSI_SCHED_PRIORITY.
+func WithSchedPolicy(policy SchedPolicy, priority int) Option {
+	return func(c *config) {
+		c.sched.policy = policy
+		c.sched.priority = priority
+	}
+}
+
+func parseIOClass(s string) (IOClass, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "0", "none":
+		return IOClassNone, nil
+	case "1", "realtime", "rt":
+		return IOClassRealtime, nil
+	case "2", "best-effort", "be":
+		return IOClassBestEffort, nil
+	case "3", "idle":
+		return IOClassIdle, nil
+	default:
+		return 0, fmt.Errorf("unknown I/O class %q", s)
+	}
+}
+
+func parseSchedPolicy(s string) (SchedPolicy, error) {
+	p := SchedPolicy(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "sched_"))
+	switch p {
+	case SchedOther, SchedBatch, SchedIdle, SchedFIFO, SchedRR:
+		return p, nil
+	case "normal":
+		return SchedOther, nil
+	default:
+		return "", fmt.Errorf("unknown scheduling policy %q", s)
+	}
+}
+
+// envInt sets *dst from an integer environment variable within [lo, hi].
+func envInt(key string, lo, hi int, dst *int) bool {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return false
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < lo || n > hi {
+		log.Printf("psi: invalid %s=%q; ignoring", key, val)
+		return false
+	}
+	*dst = n
+	return true
+}
+
+// loadEnv applies the PSI_NICE, PSI_IONICE_* and PSI_SCHED_* overrides.
+func (s *schedConfig) loadEnv() {
+	var nice int
+	if envInt(niceEnv, -20, 19, &nice) {
+		s.nice = &nice
+	}
+	if val := strings.TrimSpace(os.Getenv(ioniceClassEnv)); val != "" {
+		class, err := parseIOClass(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", ioniceClassEnv, val, err)
+		} else {
+			s.ioClass = &class
+		}
+	}
+	envInt(ioniceLevelEnv, 0, 7, &s.ioLevel)
+	if val := strings.TrimSpace(os.Getenv(schedPolicyEnv)); val != "" {
+		policy, err := parseSchedPolicy(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", schedPolicyEnv, val, err)
+		} else {
+			s.policy = policy
+		}
+	}
+	envInt(schedPriorityEnv, 0, 99, &s.priority)
+}
+
+// enabled reports whether any priority is configured.
+func (s *schedConfig) enabled() bool {
+	return s.nice != nil || s.ioClass != nil || s.policy != ""
+}
+
+// applyThread sets the configured priorities on the calling thread, which
+// must be locked and about to fork the child: the child inherits them, and
+// so do the processes and threads it creates.
+func (s *schedConfig) applyThread() {
+	// sched_setattr also resets the nice value for the normal policies, so
+	// the policy goes first.
+	if s.policy != "" {
+		if err := setThreadSchedPolicy(s.policy, s.priority); err != nil {
+			log.Printf("psi: failed to set child scheduling policy: %v", err)
+		}
+	}
+	if s.nice != nil {
+		if err := setThreadNice(*s.nice); err != nil {
+			log.Printf("psi: failed to set child nice value: %v", err)
+		}
+	}
+	if s.ioClass != nil {
+		if err := setThreadIOPriority(*s.ioClass, s.ioLevel); err != nil {
+			log.Printf("psi: failed to set child I/O priority: %v", err)
+		}
+	}
+}
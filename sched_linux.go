@@ -0,0 +1,44 @@
+package psi
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+var schedPolicies = map[SchedPolicy]uint32{
+	SchedOther: unix.SCHED_NORMAL,
+	SchedFIFO:  unix.SCHED_FIFO,
+	SchedRR:    unix.SCHED_RR,
+	SchedBatch: unix.SCHED_BATCH,
+	SchedIdle:  unix.SCHED_IDLE,
+}
+
+// setThreadNice sets the nice value of the calling thread.
+func setThreadNice(nice int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), nice)
+}
+
+// setThreadIOPriority calls ioprio_set(IOPRIO_WHO_PROCESS) for the calling
+// thread.
+func setThreadIOPriority(class IOClass, level int) error {
+	prio := int(class)<<ioprioClassShift | level
+	if class == IOClassNone || class == IOClassIdle {
+		prio = int(class) << ioprioClassShift
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(unix.Gettid()), uintptr(prio))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setThreadSchedPolicy applies a scheduling policy to the calling thread.
+func setThreadSchedPolicy(policy SchedPolicy, priority int) error {
+	attr := &unix.SchedAttr{Policy: schedPolicies[policy]}
+	if policy == SchedFIFO || policy == SchedRR {
+		attr.Priority = uint32(max(priority, 1))
+	}
+	return unix.SchedSetAttr(0, attr, 0)
+}
@@ -0,0 +1,52 @@
+package psi
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSchedApplyThread(t *testing.T) {
+	nice := 15
+	class := IOClassIdle
+	c := &config{sched: schedConfig{nice: &nice, ioClass: &class, policy: SchedBatch}}
+	cmd := exec.Command("sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := c.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+
+	// getpriority returns 20 - nice to avoid negative return values.
+	if prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid); err != nil || 20-prio != nice {
+		t.Fatalf("expected nice %d, got 20-%d (%v)", nice, prio, err)
+	}
+	attr, err := unix.SchedGetAttr(pid, 0)
+	if err != nil {
+		t.Fatalf("sched_getattr: %v", err)
+	}
+	if attr.Policy != unix.SCHED_BATCH {
+		t.Fatalf("expected SCHED_BATCH, got %d", attr.Policy)
+	}
+	prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, 1, uintptr(pid), 0)
+	if errno != 0 {
+		t.Fatalf("ioprio_get: %v", errno)
+	}
+	if IOClass(prio>>ioprioClassShift) != IOClassIdle {
+		t.Fatalf("expected idle I/O class, got %#x", prio)
+	}
+
+	// The init's own threads keep their priorities.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if prio, err := unix.Getpriority(unix.PRIO_PROCESS, unix.Gettid()); err != nil || 20-prio == nice {
+		t.Fatalf("calling thread got nice %d (%v)", 20-prio, err)
+	}
+}
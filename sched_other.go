@@ -0,0 +1,13 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+var errSchedUnsupported = errors.New("scheduling settings are only supported on Linux")
+
+func setThreadNice(int) error { return errSchedUnsupported }
+
+func setThreadIOPriority(IOClass, int) error { return errSchedUnsupported }
+
+func setThreadSchedPolicy(SchedPolicy, int) error { return errSchedUnsupported }
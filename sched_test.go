@@ -0,0 +1,56 @@
+package psi
+
+import "testing"
+
+func TestParseIOClass(t *testing.T) {
+	cases := map[string]IOClass{
+		"none":        IOClassNone,
+		"RealTime":    IOClassRealtime,
+		"best-effort": IOClassBestEffort,
+		"be":          IOClassBestEffort,
+		"3":           IOClassIdle,
+	}
+	for input, want := range cases {
+		if got, err := parseIOClass(input); err != nil || got != want {
+			t.Fatalf("parseIOClass(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseIOClass("4"); err == nil {
+		t.Fatal("parseIOClass(4) should fail")
+	}
+}
+
+func TestParseSchedPolicy(t *testing.T) {
+	cases := map[string]SchedPolicy{
+		"other":       SchedOther,
+		"SCHED_BATCH": SchedBatch,
+		"normal":      SchedOther,
+		"fifo":        SchedFIFO,
+		" rr ":        SchedRR,
+	}
+	for input, want := range cases {
+		if got, err := parseSchedPolicy(input); err != nil || got != want {
+			t.Fatalf("parseSchedPolicy(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := parseSchedPolicy("deadline"); err == nil {
+		t.Fatal("parseSchedPolicy(deadline) should fail")
+	}
+}
+
+func TestSchedEnv(t *testing.T) {
+	t.Setenv(niceEnv, "10")
+	t.Setenv(ioniceClassEnv, "idle")
+	t.Setenv(schedPolicyEnv, "batch")
+	t.Setenv(schedPriorityEnv, "100")
+	cfg := newConfig(WithNice(5), WithIOPriority(IOClassBestEffort, 7), WithSchedPolicy(SchedFIFO, 10))
+	if cfg.sched.nice == nil || *cfg.sched.nice != 10 {
+		t.Fatalf("expected env nice 10, got %v", cfg.sched.nice)
+	}
+	if cfg.sched.ioClass == nil || *cfg.sched.ioClass != IOClassIdle || cfg.sched.ioLevel != 7 {
+		t.Fatalf("unexpected I/O priority %v/%d", cfg.sched.ioClass, cfg.sched.ioLevel)
+	}
+	if cfg.sched.policy != SchedBatch || cfg.sched.priority != 10 {
+		t.Fatalf("expected batch with the option's priority kept, got %s/%d", cfg.sched.policy, cfg.sched.priority)
+	}
+}
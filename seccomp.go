@@ -0,0 +1,437 @@
+package psi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const seccompProfileEnv = "PSI_SECCOMP_PROFILE"
+
+// WithSeccompProfile installs the seccomp filter in path on the child before
+// exec. The file is either a Docker/OCI style JSON profile (defaultAction,
+// syscalls with names, action and optional args) or a raw BPF program of
+// struct sock_filter entries. As with Docker, a rule applies only if the
+// native architecture is among its "includes" arches, the child's bounding
+// set holds all of its "includes" caps and the kernel is at least its
+// "includes" minKernel; and not if any of its "excludes" conditions holds.
+// The filter is
+// installed on the thread that forks the child, so it must allow the
+// syscalls needed to fork and exec. Installing it needs CAP_SYS_ADMIN or
+// no_new_privs (WithNoNewPrivs). Syscall names are resolved on linux/amd64
+// and linux/arm64 only. Overridden by PSI_SECCOMP_PROFILE.
+func WithSeccompProfile(path string) Option {
+	return func(c *config) {
+		c.seccompProfile = path
+	}
+}
+
+// bpfInsn is a classic BPF instruction (struct sock_filter).
+type bpfInsn struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+const (
+	bpfLdWAbs = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfAndK   = 0x54 // BPF_ALU | BPF_AND | BPF_K
+	bpfJeqK   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgtK   = 0x25 // BPF_JMP | BPF_JGT | BPF_K
+	bpfJgeK   = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK   = 0x06 // BPF_RET | BPF_K
+
+	// Offsets into struct seccomp_data.
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetKillThread  = 0x00000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetTrace       = 0x7ff00000
+	seccompRetLog         = 0x7ffc0000
+	seccompRetAllow       = 0x7fff0000
+
+	// x32 syscalls on amd64 carry this bit in their number.
+	x32SyscallBit = 0x40000000
+	// eperm is the default errno for SCMP_ACT_ERRNO.
+	eperm = 1
+)
+
+// seccompProfile is the subset of the Docker/OCI seccomp profile format psi
+// understands.
+type seccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet *uint         `json:"defaultErrnoRet"`
+	Syscalls        []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Name     string        `json:"name"`
+	Names    []string      `json:"names"`
+	Action   string        `json:"action"`
+	ErrnoRet *uint         `json:"errnoRet"`
+	Args     []seccompArg  `json:"args"`
+	Includes *seccompConds `json:"includes"`
+	Excludes *seccompConds `json:"excludes"`
+}
+
+// seccompConds are the conditions of a rule's "includes" or "excludes".
+type seccompConds struct {
+	Arches    []string `json:"arches"`
+	Caps      []string `json:"caps"`
+	MinKernel string   `json:"minKernel"`
+}
+
+// seccompTarget is what the conditions of a profile's rules are checked
+// against: the native architecture (as GOARCH names it, which Docker's
+// profiles use), the child's bounding set and the kernel version.
+type seccompTarget struct {
+	arch   string
+	caps   uint64
+	kernel [2]int
+}
+
+// appliesTo reports whether the rule's conditions let it apply to t.
+func (r *seccompRule) appliesTo(t seccompTarget) (bool, error) {
+	ok, err := r.Includes.includes(t)
+	if err != nil || !ok {
+		return false, err
+	}
+	excluded, err := r.Excludes.excludes(t)
+	return !excluded, err
+}
+
+// includes reports whether t meets every condition in c.
+func (c *seccompConds) includes(t seccompTarget) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	if len(c.Arches) > 0 && !slices.Contains(c.Arches, t.arch) {
+		return false, nil
+	}
+	for _, name := range c.Caps {
+		if !t.hasCap(name) {
+			return false, nil
+		}
+	}
+	return t.atLeast(c.MinKernel)
+}
+
+// excludes reports whether t meets any condition in c.
+func (c *seccompConds) excludes(t seccompTarget) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	if slices.Contains(c.Arches, t.arch) || slices.ContainsFunc(c.Caps, t.hasCap) {
+		return true, nil
+	}
+	if c.MinKernel == "" {
+		return false, nil
+	}
+	return t.atLeast(c.MinKernel)
+}
+
+// hasCap reports whether the capability name is in t's bounding set.
+func (t seccompTarget) hasCap(name string) bool {
+	n, ok := capabilityNames[strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "CAP_")]
+	return ok && t.caps&(1<<n) != 0
+}
+
+// atLeast reports whether t's kernel is version ("4.8") or later; an empty
+// version always holds.
+func (t seccompTarget) atLeast(version string) (bool, error) {
+	if version == "" {
+		return true, nil
+	}
+	v, err := parseKernelVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("invalid minKernel %q", version)
+	}
+	return t.kernel[0] > v[0] || t.kernel[0] == v[0] && t.kernel[1] >= v[1], nil
+}
+
+// parseKernelVersion parses the major and minor version from a kernel
+// release such as "6.8.0-45-generic" or a minKernel such as "4.8".
+func parseKernelVersion(s string) ([2]int, error) {
+	var v [2]int
+	major, rest, _ := strings.Cut(strings.TrimSpace(s), ".")
+	minor, _, _ := strings.Cut(rest, ".")
+	minor = minor[:len(minor)-len(strings.TrimLeft(minor, "0123456789"))]
+	var err error
+	if v[0], err = strconv.Atoi(major); err != nil {
+		return v, err
+	}
+	if v[1], err = strconv.Atoi(minor); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+type seccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+// loadSeccompProfile reads a JSON or raw BPF profile into a BPF program,
+// with the conditions of JSON rules checked against t.
+func loadSeccompProfile(path string, t seccompTarget) ([]bpfInsn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var p seccompProfile
+		if err := json.Unmarshal(trimmed, &p); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		prog, err := p.compile(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return prog, nil
+	}
+	if len(data) == 0 || len(data)%8 != 0 {
+		return nil, fmt.Errorf("%s: not a JSON profile or a BPF program", path)
+	}
+	prog := make([]bpfInsn, len(data)/8)
+	if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, prog); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return prog, nil
+}
+
+// seccompAction converts a profile action to a SECCOMP_RET_* value.
+func seccompAction(action string, errnoRet *uint) (uint32, error) {
+	switch strings.ToUpper(action) {
+	case "SCMP_ACT_ALLOW":
+		return seccompRetAllow, nil
+	case "SCMP_ACT_ERRNO":
+		errno := uint(eperm)
+		if errnoRet != nil {
+			errno = *errnoRet
+		}
+		return seccompRetErrno | uint32(errno&0xffff), nil
+	case "SCMP_ACT_KILL", "SCMP_ACT_KILL_THREAD":
+		return seccompRetKillThread, nil
+	case "SCMP_ACT_KILL_PROCESS":
+		return seccompRetKillProcess, nil
+	case "SCMP_ACT_TRAP":
+		return seccompRetTrap, nil
+	case "SCMP_ACT_TRACE":
+		return seccompRetTrace, nil
+	case "SCMP_ACT_LOG":
+		return seccompRetLog, nil
+	default:
+		return 0, fmt.Errorf("unsupported action %q", action)
+	}
+}
+
+// compile translates the profile into a BPF program for the native
+// architecture, keeping the rules whose conditions t meets. Other
+// architectures (and x32 syscalls) get the default action.
+func (p *seccompProfile) compile(t seccompTarget) ([]bpfInsn, error) {
+	if seccompSyscalls == nil {
+		return nil, fmt.Errorf("syscall names are not supported on %s/%s; use a BPF profile", runtime.GOOS, runtime.GOARCH)
+	}
+	def, err := seccompAction(p.DefaultAction, p.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+	prog := []bpfInsn{
+		{Code: bpfLdWAbs, K: seccompDataArch},
+		{Code: bpfJeqK, Jt: 1, K: seccompAuditArch},
+		{Code: bpfRetK, K: def},
+		{Code: bpfLdWAbs, K: seccompDataNr},
+		{Code: bpfJgeK, Jf: 1, K: x32SyscallBit},
+		{Code: bpfRetK, K: def},
+	}
+	for _, rule := range p.Syscalls {
+		ok, err := rule.appliesTo(t)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		action, err := seccompAction(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+		names := rule.Names
+		if rule.Name != "" {
+			names = append(slices.Clip(names), rule.Name)
+		}
+		for _, name := range names {
+			nr, ok := seccompSyscalls[name]
+			if !ok {
+				// Profiles list syscalls of every architecture.
+				continue
+			}
+			block, err := seccompArgChecks(rule.Args)
+			if err != nil {
+				return nil, fmt.Errorf("syscall %s: %w", name, err)
+			}
+			block = append(block, bpfInsn{Code: bpfRetK, K: action})
+			if len(block) > 255 {
+				return nil, fmt.Errorf("syscall %s: too many argument checks", name)
+			}
+			if len(rule.Args) > 0 {
+				// The checks clobber the accumulator, so reload the number.
+				block = append(block, bpfInsn{Code: bpfLdWAbs, K: seccompDataNr})
+			}
+			prog = append(prog, bpfInsn{Code: bpfJeqK, Jf: uint8(len(block)), K: nr})
+			prog = append(prog, block...)
+		}
+	}
+	prog = append(prog, bpfInsn{Code: bpfRetK, K: def})
+	if len(prog) > 4096 {
+		return nil, fmt.Errorf("profile compiles to %d BPF instructions, more than the kernel's 4096", len(prog))
+	}
+	return prog, nil
+}
+
+// Jump targets used while assembling argument checks; resolved once the
+// block length is known.
+const (
+	jumpNext = iota // fall through to the next instruction
+	jumpPass        // the current argument check passed
+	jumpFail        // the rule does not match
+)
+
+// seccompArgChecks returns instructions that fall through when every
+// argument comparison holds and otherwise jump past the rule's return (to the
+// instruction reloading the syscall number). 64-bit arguments are compared as
+// two little-endian 32-bit halves.
+func seccompArgChecks(args []seccompArg) ([]bpfInsn, error) {
+	type insn struct {
+		bpfInsn
+		jt, jf int
+	}
+	var code []insn
+	var passAt []int // per check: index of the instruction after it
+	var owner []int  // per instruction: its check
+	emit := func(check int, i bpfInsn, jt, jf int) {
+		code = append(code, insn{i, jt, jf})
+		owner = append(owner, check)
+	}
+	for n, a := range args {
+		if a.Index > 5 {
+			return nil, fmt.Errorf("argument index %d out of range", a.Index)
+		}
+		lo := uint32(seccompDataArgs + 8*a.Index)
+		hi := lo + 4
+		val, val2 := a.Value, a.ValueTwo
+		ld := func(off uint32) { emit(n, bpfInsn{Code: bpfLdWAbs, K: off}, jumpNext, jumpNext) }
+		jmp := func(op uint16, k uint32, jt, jf int) { emit(n, bpfInsn{Code: op, K: k}, jt, jf) }
+		switch strings.ToUpper(a.Op) {
+		case "SCMP_CMP_EQ":
+			ld(hi)
+			jmp(bpfJeqK, uint32(val>>32), jumpNext, jumpFail)
+			ld(lo)
+			jmp(bpfJeqK, uint32(val), jumpPass, jumpFail)
+		case "SCMP_CMP_NE":
+			ld(hi)
+			jmp(bpfJeqK, uint32(val>>32), jumpNext, jumpPass)
+			ld(lo)
+			jmp(bpfJeqK, uint32(val), jumpFail, jumpPass)
+		case "SCMP_CMP_MASKED_EQ":
+			ld(hi)
+			emit(n, bpfInsn{Code: bpfAndK, K: uint32(val >> 32)}, jumpNext, jumpNext)
+			jmp(bpfJeqK, uint32(val2>>32), jumpNext, jumpFail)
+			ld(lo)
+			emit(n, bpfInsn{Code: bpfAndK, K: uint32(val)}, jumpNext, jumpNext)
+			jmp(bpfJeqK, uint32(val2), jumpPass, jumpFail)
+		case "SCMP_CMP_GT", "SCMP_CMP_GE":
+			op := uint16(bpfJgtK)
+			if strings.EqualFold(a.Op, "SCMP_CMP_GE") {
+				op = bpfJgeK
+			}
+			ld(hi)
+			jmp(bpfJgtK, uint32(val>>32), jumpPass, jumpNext)
+			jmp(bpfJeqK, uint32(val>>32), jumpNext, jumpFail)
+			ld(lo)
+			jmp(op, uint32(val), jumpPass, jumpFail)
+		case "SCMP_CMP_LT", "SCMP_CMP_LE":
+			// a < v is !(a >= v); a <= v is !(a > v).
+			op := uint16(bpfJgeK)
+			if strings.EqualFold(a.Op, "SCMP_CMP_LE") {
+				op = bpfJgtK
+			}
+			ld(hi)
+			jmp(bpfJgtK, uint32(val>>32), jumpFail, jumpNext)
+			jmp(bpfJeqK, uint32(val>>32), jumpNext, jumpPass)
+			ld(lo)
+			jmp(op, uint32(val), jumpFail, jumpPass)
+		default:
+			return nil, fmt.Errorf("unsupported comparison %q", a.Op)
+		}
+		passAt = append(passAt, len(code))
+	}
+	end := len(code) + 1 // skip the rule's return instruction
+	resolve := func(i, target int) (uint8, error) {
+		var to int
+		switch target {
+		case jumpNext:
+			return 0, nil
+		case jumpPass:
+			to = passAt[owner[i]]
+		case jumpFail:
+			to = end
+		}
+		d := to - i - 1
+		if d < 0 || d > 255 {
+			return 0, fmt.Errorf("argument checks too long")
+		}
+		return uint8(d), nil
+	}
+	out := make([]bpfInsn, len(code))
+	for i, c := range code {
+		out[i] = c.bpfInsn
+		var err error
+		if out[i].Jt, err = resolve(i, c.jt); err != nil {
+			return nil, err
+		}
+		if out[i].Jf, err = resolve(i, c.jf); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// loadSeccompEnv applies the PSI_SECCOMP_PROFILE override.
+func (c *config) loadSeccompEnv() {
+	if val := strings.TrimSpace(os.Getenv(seccompProfileEnv)); val != "" {
+		c.seccompProfile = val
+	}
+}
+
+// seccompFilter loads the configured profile, or returns nil without one.
+// Rule conditions are checked against the bounding set the child will have,
+// the current one less the capabilities dropped for it.
+func (c *config) seccompFilter() ([]bpfInsn, error) {
+	if c.seccompProfile == "" {
+		return nil, nil
+	}
+	drop, _, err := c.caps.resolve()
+	if err != nil {
+		return nil, err
+	}
+	t := seccompTarget{arch: runtime.GOARCH, caps: boundingCaps() &^ drop, kernel: kernelVersion()}
+	prog, err := loadSeccompProfile(c.seccompProfile, t)
+	if err != nil {
+		return nil, fmt.Errorf("seccomp profile: %w", err)
+	}
+	return prog, nil
+}
@@ -0,0 +1,50 @@
+package psi
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// boundingCaps returns the calling thread's capability bounding set.
+func boundingCaps() uint64 {
+	var caps uint64
+	for n := range uintptr(64) {
+		in, err := unix.PrctlRetInt(unix.PR_CAPBSET_READ, n, 0, 0, 0)
+		if err != nil {
+			// EINVAL: beyond the kernel's last capability.
+			break
+		}
+		if in == 1 {
+			caps |= 1 << n
+		}
+	}
+	return caps
+}
+
+// kernelVersion returns the running kernel's major and minor version, zero
+// if unknown.
+func kernelVersion() [2]int {
+	var uts unix.Utsname
+	if unix.Uname(&uts) != nil {
+		return [2]int{}
+	}
+	v, _ := parseKernelVersion(unix.ByteSliceToString(uts.Release[:]))
+	return v
+}
+
+// installSeccomp installs prog as a seccomp filter on the calling thread.
+// Without CAP_SYS_ADMIN the kernel requires no_new_privs first.
+func installSeccomp(prog []bpfInsn) error {
+	filter := make([]unix.SockFilter, len(prog))
+	for i, in := range prog {
+		filter[i] = unix.SockFilter{Code: in.Code, Jt: in.Jt, Jf: in.Jf, K: in.K}
+	}
+	fprog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&fprog)), 0, 0)
+	if errors.Is(err, unix.EACCES) {
+		return errors.New("permission denied; enable no_new_privs (PSI_NO_NEW_PRIVS=1) or grant CAP_SYS_ADMIN")
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func boundingCaps() uint64 { return 0 }
+
+func kernelVersion() [2]int { return [2]int{} }
+
+func installSeccomp([]bpfInsn) error {
+	return errors.New("seccomp is only supported on Linux")
+}
@@ -0,0 +1,9 @@
+//go:build !linux || (!amd64 && !arm64)
+
+package psi
+
+// Syscall names in seccomp profiles are only resolvable on linux/amd64 and
+// linux/arm64; elsewhere only raw BPF profiles can be loaded.
+const seccompAuditArch = 0
+
+var seccompSyscalls map[string]uint32
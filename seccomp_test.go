@@ -0,0 +1,204 @@
+package psi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// runBPF evaluates a seccomp program for the given syscall.
+func runBPF(t *testing.T, prog []bpfInsn, arch, nr uint32, args [6]uint64) uint32 {
+	t.Helper()
+	data := make([]byte, 64)
+	binary.LittleEndian.PutUint32(data[seccompDataNr:], nr)
+	binary.LittleEndian.PutUint32(data[seccompDataArch:], arch)
+	for i, a := range args {
+		binary.LittleEndian.PutUint64(data[seccompDataArgs+8*i:], a)
+	}
+	var acc uint32
+	for pc := 0; pc < len(prog); pc++ {
+		in := prog[pc]
+		jump := func(cond bool) {
+			if cond {
+				pc += int(in.Jt)
+			} else {
+				pc += int(in.Jf)
+			}
+		}
+		switch in.Code {
+		case bpfLdWAbs:
+			acc = binary.LittleEndian.Uint32(data[in.K:])
+		case bpfAndK:
+			acc &= in.K
+		case bpfJeqK:
+			jump(acc == in.K)
+		case bpfJgtK:
+			jump(acc > in.K)
+		case bpfJgeK:
+			jump(acc >= in.K)
+		case bpfRetK:
+			return in.K
+		default:
+			t.Fatalf("unexpected instruction %#x at %d", in.Code, pc)
+		}
+	}
+	t.Fatal("program fell off the end")
+	return 0
+}
+
+func TestSeccompCompile(t *testing.T) {
+	if seccompSyscalls == nil {
+		t.Skip("no syscall table for this platform")
+	}
+	errno := uint(38)
+	p := seccompProfile{
+		DefaultAction:   "SCMP_ACT_ERRNO",
+		DefaultErrnoRet: &errno,
+		Syscalls: []seccompRule{
+			{Names: []string{"read", "write", "no_such_syscall"}, Action: "SCMP_ACT_ALLOW"},
+			{Name: "close", Action: "SCMP_ACT_KILL_PROCESS"},
+			{Names: []string{"mmap"}, Action: "SCMP_ACT_ALLOW", Includes: &seccompConds{Caps: []string{"CAP_SYS_ADMIN"}}},
+			{Names: []string{"ioctl"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{
+				{Index: 1, Value: 0xff00, ValueTwo: 0x5400, Op: "SCMP_CMP_MASKED_EQ"},
+			}},
+			{Names: []string{"kill"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{
+				{Index: 0, Value: 1 << 32, Op: "SCMP_CMP_GE"},
+				{Index: 1, Value: 9, Op: "SCMP_CMP_NE"},
+			}},
+			{Names: []string{"lseek"}, Action: "SCMP_ACT_ALLOW", Args: []seccompArg{
+				{Index: 2, Value: 3, Op: "SCMP_CMP_LT"},
+			}},
+		},
+	}
+	prog, err := p.compile(seccompTarget{arch: runtime.GOARCH, kernel: [2]int{6, 1}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	deny := uint32(seccompRetErrno | 38)
+	nr := func(name string) uint32 { return seccompSyscalls[name] }
+	cases := []struct {
+		name string
+		arch uint32
+		nr   uint32
+		args [6]uint64
+		want uint32
+	}{
+		{"read", seccompAuditArch, nr("read"), [6]uint64{}, seccompRetAllow},
+		{"write", seccompAuditArch, nr("write"), [6]uint64{}, seccompRetAllow},
+		{"close", seccompAuditArch, nr("close"), [6]uint64{}, seccompRetKillProcess},
+		{"mmap skipped", seccompAuditArch, nr("mmap"), [6]uint64{}, deny},
+		{"other arch", 0x40000003, nr("read"), [6]uint64{}, deny},
+		{"x32", seccompAuditArch, nr("read") | x32SyscallBit, [6]uint64{}, deny},
+		{"ioctl match", seccompAuditArch, nr("ioctl"), [6]uint64{0, 0x5413}, seccompRetAllow},
+		{"ioctl mismatch", seccompAuditArch, nr("ioctl"), [6]uint64{0, 0x8913}, deny},
+		{"kill match", seccompAuditArch, nr("kill"), [6]uint64{1<<32 + 5, 15}, seccompRetAllow},
+		{"kill low pid", seccompAuditArch, nr("kill"), [6]uint64{5, 15}, deny},
+		{"kill sigkill", seccompAuditArch, nr("kill"), [6]uint64{1 << 33, 9}, deny},
+		{"lseek lt", seccompAuditArch, nr("lseek"), [6]uint64{0, 0, 2}, seccompRetAllow},
+		{"lseek eq", seccompAuditArch, nr("lseek"), [6]uint64{0, 0, 3}, deny},
+		{"lseek high", seccompAuditArch, nr("lseek"), [6]uint64{0, 0, 1 << 32}, deny},
+	}
+	for _, tc := range cases {
+		if got := runBPF(t, prog, tc.arch, tc.nr, tc.args); got != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSeccompCompileConditions(t *testing.T) {
+	if seccompSyscalls == nil {
+		t.Skip("no syscall table for this platform")
+	}
+	// Rules in the style of Docker's default profile.
+	var p seccompProfile
+	profile := `{
+		"defaultAction": "SCMP_ACT_ERRNO",
+		"syscalls": [
+			{"names": ["read"], "action": "SCMP_ACT_ALLOW"},
+			{"names": ["arch_prctl"], "action": "SCMP_ACT_ALLOW", "includes": {"arches": ["amd64", "x32"]}},
+			{"names": ["arch_prctl"], "action": "SCMP_ACT_ALLOW", "includes": {"arches": ["arm64"]}},
+			{"names": ["mount"], "action": "SCMP_ACT_ALLOW", "includes": {"caps": ["CAP_SYS_ADMIN"]}},
+			{"names": ["bpf"], "action": "SCMP_ACT_ALLOW", "includes": {"minKernel": "5.8"}},
+			{"names": ["socket"], "action": "SCMP_ACT_ALLOW", "excludes": {"caps": ["CAP_NET_ADMIN"]}},
+			{"names": ["write"], "action": "SCMP_ACT_ALLOW", "excludes": {"minKernel": "6.0"}}
+		]
+	}`
+	if err := json.Unmarshal([]byte(profile), &p); err != nil {
+		t.Fatal(err)
+	}
+	deny := uint32(seccompRetErrno | eperm)
+	for _, tc := range []struct {
+		name    string
+		target  seccompTarget
+		syscall string
+		want    uint32
+	}{
+		{"arch_prctl on amd64", seccompTarget{arch: "amd64"}, "arch_prctl", seccompRetAllow},
+		{"arch_prctl elsewhere", seccompTarget{arch: "riscv64"}, "arch_prctl", deny},
+		{"mount without the cap", seccompTarget{arch: "amd64"}, "mount", deny},
+		{"mount with the cap", seccompTarget{arch: "amd64", caps: 1 << capabilityNames["SYS_ADMIN"]}, "mount", seccompRetAllow},
+		{"bpf on an old kernel", seccompTarget{arch: "amd64", kernel: [2]int{5, 4}}, "bpf", deny},
+		{"bpf on a new kernel", seccompTarget{arch: "amd64", kernel: [2]int{5, 10}}, "bpf", seccompRetAllow},
+		{"socket without the excluded cap", seccompTarget{arch: "amd64"}, "socket", seccompRetAllow},
+		{"socket with the excluded cap", seccompTarget{arch: "amd64", caps: 1 << capabilityNames["NET_ADMIN"]}, "socket", deny},
+		{"write on an old kernel", seccompTarget{arch: "amd64", kernel: [2]int{5, 15}}, "write", seccompRetAllow},
+		{"write on an excluded kernel", seccompTarget{arch: "amd64", kernel: [2]int{6, 1}}, "write", deny},
+	} {
+		nr, ok := seccompSyscalls[tc.syscall]
+		if !ok {
+			continue
+		}
+		prog, err := p.compile(tc.target)
+		if err != nil {
+			t.Fatalf("%s: compile: %v", tc.name, err)
+		}
+		if got := runBPF(t, prog, seccompAuditArch, nr, [6]uint64{}); got != tc.want {
+			t.Errorf("%s: got %#x, want %#x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	for in, want := range map[string][2]int{"4.8": {4, 8}, "6.8.0-45-generic": {6, 8}, "6.18.44-fc-v130": {6, 18}, "5.10-rc1": {5, 10}} {
+		if got, err := parseKernelVersion(in); err != nil || got != want {
+			t.Errorf("parseKernelVersion(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "6", "x.y"} {
+		if _, err := parseKernelVersion(in); err == nil {
+			t.Errorf("parseKernelVersion(%q) should fail", in)
+		}
+	}
+}
+
+func TestLoadSeccompProfile(t *testing.T) {
+	dir := t.TempDir()
+	raw := filepath.Join(dir, "allow.bpf")
+	var buf []byte
+	buf = binary.NativeEndian.AppendUint16(buf, bpfRetK)
+	buf = append(buf, 0, 0)
+	buf = binary.NativeEndian.AppendUint32(buf, seccompRetAllow)
+	if err := os.WriteFile(raw, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prog, err := loadSeccompProfile(raw, seccompTarget{})
+	if err != nil || len(prog) != 1 || prog[0] != (bpfInsn{Code: bpfRetK, K: seccompRetAllow}) {
+		t.Fatalf("raw profile = %+v, %v", prog, err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"defaultAction":"SCMP_ACT_FROB"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSeccompProfile(bad, seccompTarget{}); err == nil {
+		t.Fatal("expected unsupported action to fail")
+	}
+	if err := os.WriteFile(bad, []byte("short"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSeccompProfile(bad, seccompTarget{}); err == nil {
+		t.Fatal("expected truncated BPF to fail")
+	}
+}
@@ -0,0 +1,96 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// CheckStatus is the outcome of a self-check.
+type CheckStatus string
+
+const (
+	// CheckOK: the environment is as the init needs it.
+	CheckOK CheckStatus = "ok"
+	// CheckWarn: the init works, but not as well as it could.
+	CheckWarn CheckStatus = "warn"
+	// CheckFail: the init will not work as configured.
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is a finding of SelfCheck.
+type Check struct {
+	// Name identifies the check, e.g. "pid1" or "procfs".
+	Name   string
+	Status CheckStatus
+	Detail string
+}
+
+func (c Check) String() string {
+	return fmt.Sprintf("%-4s  %-12s  %s", c.Status, c.Name, c.Detail)
+}
+
+// SelfCheck verifies the environment the init runs in, for the CI of base
+// images: whether it is PID 1, whether /proc is mounted, whether it may
+// become a child subreaper, which cgroup version is available, whether it
+// inherited blocked or ignored signals its children would inherit too, and
+// whether PSI_STOP_TIMEOUT and PSI_STOP_CHAIN parse. The checks that need
+// Linux are left out elsewhere. Becoming a subreaper is tried and undone.
+func SelfCheck() []Check {
+	checks := []Check{checkPID1()}
+	checks = append(checks, platformChecks()...)
+	return append(checks, checkStopTimeout(), checkStopChain())
+}
+
+// SelfCheckFailed reports whether any of checks failed.
+func SelfCheckFailed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPID1() Check {
+	if pid := os.Getpid(); pid != 1 {
+		return Check{"pid1", CheckWarn, fmt.Sprintf("running as pid %d; without PID 1, psi only supervises with %s=1 or %s=1", pid, subreaperEnv, forceSuperviseEnv)}
+	}
+	return Check{"pid1", CheckOK, "running as PID 1"}
+}
+
+func checkStopTimeout() Check {
+	val := strings.TrimSpace(os.Getenv(stopTimeoutEnv))
+	if val == "" {
+		return Check{"stop_timeout", CheckOK, fmt.Sprintf("%s (default)", defaultStopTimeout)}
+	}
+	d, err := parseDuration(val)
+	if err != nil {
+		return Check{"stop_timeout", CheckFail, fmt.Sprintf("invalid %s=%q: %v; the default %s would be used", stopTimeoutEnv, val, err, defaultStopTimeout)}
+	}
+	return Check{"stop_timeout", CheckOK, d.String()}
+}
+
+func checkStopChain() Check {
+	val := strings.TrimSpace(os.Getenv(stopChainEnv))
+	if val == "" {
+		return Check{"stop_chain", CheckOK, "forward the stop signal, then SIGKILL after the stop timeout"}
+	}
+	steps, err := parseStopChain(val)
+	if err != nil {
+		return Check{"stop_chain", CheckFail, fmt.Sprintf("invalid %s=%q: %v", stopChainEnv, val, err)}
+	}
+	var parts []string
+	for _, step := range steps {
+		part := signalName(step.Signal)
+		if step.Wait > 0 {
+			part += " for " + step.Wait.String()
+		}
+		parts = append(parts, part)
+	}
+	if steps[len(steps)-1].Signal != syscall.SIGKILL {
+		parts = append(parts, "SIGKILL")
+	}
+	return Check{"stop_chain", CheckOK, strings.Join(parts, ", then ")}
+}
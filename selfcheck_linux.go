@@ -0,0 +1,97 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// platformChecks are the checks of SelfCheck that need Linux.
+func platformChecks() []Check {
+	return []Check{checkProcfs(), checkSubreaper(), checkCgroup(), checkSignalMasks()}
+}
+
+func checkProcfs() Check {
+	var st unix.Statfs_t
+	if err := unix.Statfs("/proc", &st); err != nil || st.Type != unix.PROC_SUPER_MAGIC {
+		return Check{"procfs", CheckFail, fmt.Sprintf("/proc is not mounted; set %s=1 to mount it", mountProcEnv)}
+	}
+	return Check{"procfs", CheckOK, "/proc is mounted"}
+}
+
+// checkSubreaper becomes a child subreaper and then reverts to what the
+// process was.
+func checkSubreaper() Check {
+	var was int32
+	if err := unix.Prctl(unix.PR_GET_CHILD_SUBREAPER, uintptr(unsafe.Pointer(&was)), 0, 0, 0); err != nil {
+		return Check{"subreaper", CheckWarn, fmt.Sprintf("PR_GET_CHILD_SUBREAPER: %v", err)}
+	}
+	if err := setChildSubreaper(); err != nil {
+		return Check{"subreaper", CheckWarn, fmt.Sprintf("cannot become a child subreaper: %v", err)}
+	}
+	if was == 0 {
+		unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 0, 0, 0, 0)
+	}
+	return Check{"subreaper", CheckOK, "can become a child subreaper"}
+}
+
+func checkCgroup() Check {
+	if mnt, err := cgroup2Mount(); err == nil {
+		return Check{"cgroup", CheckOK, "cgroup v2 at " + mnt}
+	}
+	if _, err := os.Stat("/sys/fs/cgroup"); err == nil {
+		return Check{"cgroup", CheckWarn, fmt.Sprintf("cgroup v1 only; %s needs cgroup v2", cgroupEnv)}
+	}
+	return Check{"cgroup", CheckWarn, fmt.Sprintf("no cgroup filesystem; %s is unavailable", cgroupEnv)}
+}
+
+// checkSignalMasks reports the signals the process was started with blocked
+// or ignored, from /proc/self/status.
+func checkSignalMasks() Check {
+	b, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return Check{"signals", CheckWarn, err.Error()}
+	}
+	var problems []string
+	for _, line := range strings.Split(string(b), "\n") {
+		key, val, _ := strings.Cut(line, ":")
+		var what string
+		switch key {
+		case "SigBlk":
+			what = "blocked"
+		case "SigIgn":
+			what = "ignored"
+		default:
+			continue
+		}
+		if sigs := sigMaskNames(strings.TrimSpace(val)); len(sigs) > 0 {
+			problems = append(problems, what+": "+strings.Join(sigs, ", "))
+		}
+	}
+	if len(problems) > 0 {
+		return Check{"signals", CheckWarn, "inherited and passed on to the child, " + strings.Join(problems, "; ")}
+	}
+	return Check{"signals", CheckOK, "no signals blocked or ignored"}
+}
+
+// sigMaskNames returns the names of the signals in a hexadecimal signal
+// mask as in /proc/<pid>/status, leaving out the Go runtime's preemption
+// signal.
+func sigMaskNames(mask string) []string {
+	m, err := strconv.ParseUint(mask, 16, 64)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for n := 1; n <= 64; n++ {
+		if sig := syscall.Signal(n); m&(1<<(n-1)) != 0 && sig != sigPreempt {
+			names = append(names, signalName(sig))
+		}
+	}
+	return names
+}
@@ -0,0 +1,29 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSigMaskNames(t *testing.T) {
+	// Bits 2 and 15 are SIGINT and SIGTERM; bit 23 is SIGURG, left out.
+	if got := sigMaskNames("0000000000404002"); !slices.Equal(got, []string{"SIGINT", "SIGTERM"}) {
+		t.Fatalf("sigMaskNames = %v", got)
+	}
+	if got := sigMaskNames("0000000000000000"); len(got) != 0 {
+		t.Fatalf("empty mask = %v", got)
+	}
+}
+
+func TestPlatformChecks(t *testing.T) {
+	var names []string
+	for _, c := range platformChecks() {
+		names = append(names, c.Name)
+		if c.Name == "procfs" && c.Status != CheckOK {
+			t.Errorf("procfs check = %+v", c)
+		}
+	}
+	if !slices.Equal(names, []string{"procfs", "subreaper", "cgroup", "signals"}) {
+		t.Fatalf("checks = %v", names)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package psi
+
+// platformChecks are the checks of SelfCheck that need Linux.
+func platformChecks() []Check {
+	return nil
+}
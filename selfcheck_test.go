@@ -0,0 +1,41 @@
+//go:build !windows
+
+package psi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelfCheckStopSettings(t *testing.T) {
+	t.Setenv(stopTimeoutEnv, "45")
+	t.Setenv(stopChainEnv, "TERM:20s,INT")
+	if c := checkStopTimeout(); c.Status != CheckOK || c.Detail != "45s" {
+		t.Fatalf("stop timeout check = %+v", c)
+	}
+	if c := checkStopChain(); c.Status != CheckOK || c.Detail != "SIGTERM for 20s, then SIGINT, then SIGKILL" {
+		t.Fatalf("stop chain check = %+v", c)
+	}
+	t.Setenv(stopTimeoutEnv, "soon")
+	t.Setenv(stopChainEnv, "TERM:x")
+	checks := SelfCheck()
+	if !SelfCheckFailed(checks) {
+		t.Fatalf("invalid settings passed: %v", checks)
+	}
+	var failed []string
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			failed = append(failed, c.Name)
+		}
+	}
+	if got := strings.Join(failed, ","); got != "stop_timeout,stop_chain" {
+		t.Fatalf("failed checks = %s", got)
+	}
+}
+
+func TestCheckString(t *testing.T) {
+	c := Check{Name: "pid1", Status: CheckWarn, Detail: "running as pid 7"}
+	if got := c.String(); got != "warn  pid1          running as pid 7" {
+		t.Fatalf("String() = %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package psi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+)
+
+const (
+	restartRequestsEnv = "PSI_RESTART_REQUESTS"
+	// restartRequestKey is the sd_notify message asking for a restart; the
+	// X_ prefix marks it as the init's own.
+	restartRequestKey = "X_PSI_RESTART"
+)
+
+// WithRestartRequests lets the child ask for a restart with RequestRestart,
+// e.g. after its configuration changed: the init stops it like the restart
+// command of the control socket (see WithControlSocket) and starts a new
+// generation whatever the restart policy. Overridden by
+// PSI_RESTART_REQUESTS.
+func WithRestartRequests() Option {
+	return func(c *config) {
+		c.restartRequests = true
+	}
+}
+
+// RequestRestart asks the init to restart the child: the child then gets
+// the stop signal as on shutdown (submain's context is cancelled), and a new
+// generation starts once it has exited. The init must run with
+// WithRestartRequests; other programs can send the sd_notify message
+// X_PSI_RESTART=1 instead. It returns an error if the child has no
+// NOTIFY_SOCKET or ctx is done before the request is sent.
+func RequestRestart(ctx context.Context) error {
+	addr := os.Getenv(notifySocketEnv)
+	if addr == "" {
+		return fmt.Errorf("psi: restart request: %s not set", notifySocketEnv)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("psi: restart request: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte(restartRequestKey + "=1")); err != nil {
+		return fmt.Errorf("psi: restart request: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package psi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequestRestartWithoutSocket(t *testing.T) {
+	t.Setenv(notifySocketEnv, "")
+	if err := RequestRestart(context.Background()); err == nil {
+		t.Fatal("expected an error without NOTIFY_SOCKET")
+	}
+}
+
+func TestSupervisorRestartRequest(t *testing.T) {
+	countFile := filepath.Join(t.TempDir(), "count")
+	cmd := helperCommand("init-self-restart", helperCountEnv+"="+countFile, restartRequestsEnv+"=1")
+	if exit := exitStatus(cmd.Run()); exit != 7 {
+		t.Fatalf("expected exit code 7, got %d", exit)
+	}
+	if b, _ := os.ReadFile(countFile); string(b) != "start\nstopped\nstart\n" {
+		t.Fatalf("generations = %q", b)
+	}
+}
+
+func TestSupervisorRestartRequestDisabled(t *testing.T) {
+	countFile := filepath.Join(t.TempDir(), "count")
+	cmd := helperCommand("init-self-restart", helperCountEnv+"="+countFile)
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+}
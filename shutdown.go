@@ -0,0 +1,46 @@
+package psi
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// shutdownHooks are the callbacks registered with OnShutdown.
+var shutdownHooks struct {
+	mu  sync.Mutex
+	fns []func(ctx context.Context) error
+}
+
+// OnShutdown registers fn to run when submain returns, before the process
+// exits. Hooks run one at a time in reverse order of registration, and their
+// context expires when the init would kill the child: once the stop chain
+// reaches SIGKILL (by default PSI_STOP_TIMEOUT) after the termination
+// signal was received, later if the child got extra time (see
+// RequestExtraTime), or after submain returned if there was none. Errors are logged. Hooks run in the process running submain and
+// not for external programs (see Exec).
+func OnShutdown(fn func(ctx context.Context) error) {
+	shutdownHooks.mu.Lock()
+	defer shutdownHooks.mu.Unlock()
+	shutdownHooks.fns = append(shutdownHooks.fns, fn)
+}
+
+// runShutdownHooks runs the registered hooks in reverse order with a context
+// expiring at deadline. Each hook runs once.
+func runShutdownHooks(deadline time.Time) {
+	shutdownHooks.mu.Lock()
+	fns := shutdownHooks.fns
+	shutdownHooks.fns = nil
+	shutdownHooks.mu.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+	ctx, cancel := stopClock.context(deadline)
+	defer cancel()
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := fns[i](ctx); err != nil {
+			log.Printf("psi: shutdown hook: %v", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunShutdownHooks(t *testing.T) {
+	var order []string
+	deadline := time.Now().Add(time.Minute)
+	OnShutdown(func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	OnShutdown(func(ctx context.Context) error {
+		order = append(order, "second")
+		return errors.New("failed")
+	})
+	OnShutdown(func(ctx context.Context) error {
+		if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+			t.Errorf("hook deadline = %v, %v; want %v", d, ok, deadline)
+		}
+		order = append(order, "third")
+		return nil
+	})
+	runShutdownHooks(deadline)
+	if want := []string{"third", "second", "first"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("hooks ran in order %v, want %v", order, want)
+	}
+	runShutdownHooks(deadline)
+	if len(order) != 3 {
+		t.Fatalf("hooks ran more than once: %v", order)
+	}
+}
@@ -0,0 +1,164 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Sidecar declares an auxiliary process (log shipper, proxy, ...) supervised
+// by the init next to the main child. Sidecars are started in declaration
+// order before the main child and stopped in reverse order after it exits.
+type Sidecar struct {
+	// Name identifies the sidecar in logs.
+	Name string
+	// Path is the executable to run; Args are its arguments (without argv[0]).
+	Path string
+	Args []string
+	// Env is appended to the init's environment (without PSI_* variables).
+	Env []string
+	// StopSignal is sent when the sidecar is stopped (default SIGTERM).
+	StopSignal syscall.Signal
+	// StopTimeout bounds how long the sidecar may take to exit before it is
+	// killed (default PSI_STOP_TIMEOUT).
+	StopTimeout time.Duration
+	// AfterReady starts the sidecar once the main child first reports
+	// readiness instead of before the child (see WithReadyNotify).
+	AfterReady bool
+}
+
+// WithSidecar adds a sidecar process. Sidecars only run when the init is
+// active (PID 1). Signals other than terminate-like ones are forwarded to
+// sidecars as well as to the main child.
+func WithSidecar(sc Sidecar) Option {
+	return func(c *config) {
+		c.sidecars = append(c.sidecars, sc)
+	}
+}
+
+// sidecarProc is a running sidecar.
+type sidecarProc struct {
+	spec Sidecar
+	proc *procHandle
+	code int
+	// exited is closed once the sidecar has been reaped; code is valid then.
+	exited chan struct{}
+}
+
+// startSidecars starts the configured sidecars not waiting for readiness in
+// declaration order.
+func (s *supervisor) startSidecars() error {
+	for _, spec := range s.cfg.sidecars {
+		if spec.AfterReady && s.cfg.readyNotify {
+			continue
+		}
+		if err := s.startSidecar(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startReadySidecars starts the AfterReady sidecars in declaration order the
+// first time the child reports readiness.
+func (s *supervisor) startReadySidecars() error {
+	if s.readySidecars {
+		return nil
+	}
+	s.readySidecars = true
+	for _, spec := range s.cfg.sidecars {
+		if !spec.AfterReady {
+			continue
+		}
+		if err := s.startSidecar(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSidecar starts one sidecar.
+func (s *supervisor) startSidecar(spec Sidecar) error {
+	env := append(s.cfg.childEnv(os.Environ()), spec.Env...)
+	argv := s.cfg.expandArgv(append([]string{spec.Path}, spec.Args...), env)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	var out childOutput
+	name := spec.Name
+	if name == "" {
+		name = filepath.Base(spec.Path)
+	}
+	s.cfg.addLineFilters(&out, name)
+	s.attachJournal(&out, name)
+	s.attachFluent(&out, name)
+	s.attachLogExport(&out, name)
+	closeOutput, err := out.attach(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.SysProcAttr = newSysProcAttr(nil)
+	h, done, err := s.reaper.start(cmd)
+	closeOutput()
+	if err != nil {
+		return err
+	}
+	p := &sidecarProc{spec: spec, proc: h, exited: make(chan struct{})}
+	go func() {
+		p.code = <-done
+		close(p.exited)
+	}()
+	s.sidecars = append(s.sidecars, p)
+	return nil
+}
+
+// stopSidecars stops running sidecars in reverse start order, each bounded by
+// its own stop timeout before SIGKILL.
+func (s *supervisor) stopSidecars() {
+	for i := len(s.sidecars) - 1; i >= 0; i-- {
+		s.sidecars[i].stop(s.stopTimeout)
+	}
+	s.sidecars = nil
+}
+
+func (p *sidecarProc) stop(defaultTimeout time.Duration) {
+	defer p.proc.close()
+	select {
+	case <-p.exited:
+		log.Printf("psi: sidecar %q had already exited with code %d", p.spec.Name, p.code)
+		return
+	default:
+	}
+	sig := p.spec.StopSignal
+	if sig == 0 {
+		sig = syscall.SIGTERM
+	}
+	timeout := p.spec.StopTimeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	_ = p.proc.signalGroup(sig)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.exited:
+	case <-timer.C:
+		log.Printf("psi: sidecar %q did not stop within %s; killing", p.spec.Name, timeout)
+		_ = p.proc.signalGroup(syscall.SIGKILL)
+		<-p.exited
+	}
+}
+
+// signalSidecars forwards sig to every running sidecar's process group.
+func (s *supervisor) signalSidecars(sig syscall.Signal) {
+	for _, p := range s.sidecars {
+		select {
+		case <-p.exited:
+		default:
+			_ = p.proc.signalGroup(sig)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package psi
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const signalAuditEnv = "PSI_SIGNAL_AUDIT"
+
+// WithSignalAudit appends a JSON line to the file at path for every signal
+// the init receives, every signal it sends (to the child, a previous
+// generation or their process groups), every stop timer it arms, including
+// for extra time the child asked for, and every forced kill, as well as for
+// the commands that act on the child over the control socket (see
+// WithControlSocket), as a trail of what stopped or restarted a container
+// and when:
+//
+//	{"time":"...","event":"signal_received","signal":"SIGTERM"}
+//	{"time":"...","event":"signal_forwarded","signal":"SIGTERM","pid":42}
+//	{"time":"...","event":"stop_timer_armed","pid":42,"timeout":"30s"}
+//	{"time":"...","event":"control","command":"restart","sender_pid":977,"sender_uid":0}
+//
+// Only control commands carry their sender, on Linux (SO_PEERCRED). Signal
+// records do not say who sent the signal: the Go runtime does not pass on
+// its siginfo. Overridden by PSI_SIGNAL_AUDIT.
+func WithSignalAudit(path string) Option {
+	return func(c *config) {
+		c.signalAudit = path
+	}
+}
+
+// loadSignalAuditEnv applies the PSI_SIGNAL_AUDIT override.
+func (c *config) loadSignalAuditEnv() {
+	if val := strings.TrimSpace(os.Getenv(signalAuditEnv)); val != "" {
+		c.signalAudit = val
+	}
+}
+
+// auditRecord is a line of the signal audit log.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Signal  string    `json:"signal,omitempty"`
+	PID     int       `json:"pid,omitempty"`
+	Timeout string    `json:"timeout,omitempty"`
+	// Command, Level, Error and the sender describe a control command.
+	Command   string `json:"command,omitempty"`
+	Level     string `json:"level,omitempty"`
+	Error     string `json:"error,omitempty"`
+	SenderPID int    `json:"sender_pid,omitempty"`
+	SenderUID *int   `json:"sender_uid,omitempty"`
+}
+
+// signalAudit writes the signal audit log. It gives up, logging why, at the
+// first write error.
+type signalAudit struct {
+	mu     sync.Mutex
+	f      *os.File
+	failed bool
+}
+
+// startSignalAudit opens the signal audit log if configured and records the
+// events to come. Failing to open it is logged and otherwise ignored.
+func (s *supervisor) startSignalAudit() {
+	if s.cfg.signalAudit == "" {
+		return
+	}
+	f, err := os.OpenFile(s.cfg.signalAudit, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		log.Printf("psi: signal audit disabled: %v", err)
+		return
+	}
+	s.audit = &signalAudit{f: f}
+	addEventHandler(s.audit.event)
+}
+
+// stopSignalAudit closes the signal audit log.
+func (s *supervisor) stopSignalAudit() {
+	if s.audit != nil {
+		s.audit.mu.Lock()
+		defer s.audit.mu.Unlock()
+		s.audit.f.Close()
+		// Events emitted from now on are dropped quietly.
+		s.audit.failed = true
+	}
+}
+
+// event records e if it is about signals.
+func (a *signalAudit) event(e Event) {
+	rec := auditRecord{Time: e.Time, Event: string(e.Type), PID: e.PID}
+	switch e.Type {
+	case EventSignalReceived, EventSignalForwarded:
+		if e.Signal == sigPreempt {
+			// The Go runtime's preemption signal is noise here.
+			return
+		}
+		rec.Signal = signalName(e.Signal)
+	case EventStopTimerArmed:
+		rec.Timeout = e.Timeout.String()
+	case EventForcedKill:
+	default:
+		return
+	}
+	a.write(rec)
+}
+
+// control records a control command from the peer pid and uid, 0 and -1
+// when unknown, and its outcome.
+func (a *signalAudit) control(req controlRequest, pid, uid int, err error) {
+	if a == nil || req.Command == "status" {
+		return
+	}
+	rec := auditRecord{Time: time.Now(), Event: "control", Command: req.Command, Signal: req.Signal, Level: req.Level, SenderPID: pid}
+	if uid >= 0 {
+		rec.SenderUID = &uid
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	a.write(rec)
+}
+
+func (a *signalAudit) write(rec auditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failed {
+		return
+	}
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		a.failed = true
+		log.Printf("psi: writing the signal audit log: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+//go:build !windows
+
+package psi
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalAuditEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &signalAudit{f: f}
+	now := time.Now()
+	a.event(Event{Type: EventSignalReceived, Time: now, Signal: syscall.SIGTERM})
+	a.event(Event{Type: EventChildStarted, Time: now, PID: 7})
+	a.event(Event{Type: EventStopTimerArmed, Time: now, PID: 7, Timeout: 30 * time.Second})
+	a.control(controlRequest{Command: "status"}, 1, 0, nil)
+	a.control(controlRequest{Command: "send-signal", Signal: "HUP"}, 12, 1000, nil)
+	f.Close()
+	var recs []auditRecord
+	b, _ := os.ReadFile(path)
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var rec auditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("records = %+v", recs)
+	}
+	if recs[0].Event != "signal_received" || recs[0].Signal != "SIGTERM" {
+		t.Errorf("received = %+v", recs[0])
+	}
+	if recs[1].Event != "stop_timer_armed" || recs[1].PID != 7 || recs[1].Timeout != "30s" {
+		t.Errorf("timer = %+v", recs[1])
+	}
+	if r := recs[2]; r.Event != "control" || r.Command != "send-signal" || r.Signal != "HUP" || r.SenderPID != 12 || r.SenderUID == nil || *r.SenderUID != 1000 {
+		t.Errorf("control = %+v", r)
+	}
+}
+
+func TestSupervisorSignalAudit(t *testing.T) {
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "count")
+	sock := filepath.Join(dir, "psi.sock")
+	auditFile := filepath.Join(dir, "audit.log")
+	cmd := helperCommand("init-control", helperCountEnv+"="+countFile, controlSocketEnv+"="+sock, signalAuditEnv+"="+auditFile)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "start\n")
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("send-signal HUP\n"))
+	if !bufio.NewScanner(conn).Scan() {
+		t.Fatal("no reply")
+	}
+	waitForContent(t, countFile, "start\nhup\n")
+	cmd.Process.Signal(syscall.SIGTERM)
+	if exit := exitStatus(cmd.Wait()); exit != 0 {
+		t.Fatalf("expected exit code 0, got %d", exit)
+	}
+
+	b, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var rec auditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if rec.Event == "control" && runtime.GOOS == "linux" && rec.SenderPID != os.Getpid() {
+			t.Errorf("control sender = %d, want %d", rec.SenderPID, os.Getpid())
+		}
+		events = append(events, rec.Event+" "+rec.Signal)
+	}
+	want := "signal_forwarded SIGHUP,control HUP,signal_received SIGTERM,stop_timer_armed ,signal_forwarded SIGTERM"
+	if got := strings.Join(events, ","); got != want {
+		t.Fatalf("audit trail = %s, want %s", got, want)
+	}
+}
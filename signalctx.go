@@ -0,0 +1,92 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// stopCause is the cancellation cause of submain's context: the signal that
+// asked it to stop and when the init will kill the child for good.
+type stopCause struct {
+	sig      os.Signal
+	deadline time.Time
+}
+
+func (c stopCause) Error() string {
+	if sig, ok := c.sig.(syscall.Signal); ok {
+		return "psi: received " + signalName(sig)
+	}
+	return "psi: received " + c.sig.String()
+}
+
+// SignalFromContext returns the signal that cancelled submain's context, or
+// a context derived from it, so a submain can tell SIGINT from SIGTERM or
+// SIGHUP. It reports false while the context is live, and when it was
+// cancelled for another reason.
+func SignalFromContext(ctx context.Context) (os.Signal, bool) {
+	var cause stopCause
+	if !errors.As(context.Cause(ctx), &cause) {
+		return nil, false
+	}
+	return cause.sig, true
+}
+
+// ShutdownDeadline returns when the init will kill the process running
+// submain, once the stop chain (see WithStopChain; by default a single wait
+// of PSI_STOP_TIMEOUT) reaches SIGKILL after the termination signal that
+// cancelled ctx (or a context derived from it), so a submain can size its
+// own graceful shutdown to fit:
+//
+//	<-ctx.Done()
+//	deadline, ok := psi.ShutdownDeadline(ctx)
+//	if !ok {
+//		deadline = time.Now().Add(10 * time.Second)
+//	}
+//	sctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-time.Second))
+//	defer cancel()
+//	srv.Shutdown(sctx)
+//
+// It reports false while ctx is live and when it was not cancelled by a
+// signal. RequestExtraTime moves it. Hooks registered with OnShutdown get
+// the same deadline.
+func ShutdownDeadline(ctx context.Context) (time.Time, bool) {
+	var cause stopCause
+	if !errors.As(context.Cause(ctx), &cause) {
+		return time.Time{}, false
+	}
+	if deadline, ok := stopClock.get(); ok && deadline.After(cause.deadline) {
+		// Moved by RequestExtraTime.
+		return deadline, true
+	}
+	return cause.deadline, true
+}
+
+// Notify calls handler with each of sigs the process receives until ctx is
+// done, for reload or toggle signals such as SIGUSR1 and SIGUSR2 that the
+// init forwards to the child as is. Calls are made one at a time from a
+// single goroutine, in the order the signals arrived, and none is dropped
+// while handler is busy; repeats of a signal still pending in the kernel
+// coalesce as usual. Termination signals keep cancelling submain's context;
+// for SIGHUP to reach handler without stopping the child, run the init with
+// WithHupAction(HupReload). Without sigs, handler gets every signal.
+func Notify(ctx context.Context, handler func(os.Signal), sigs ...os.Signal) {
+	in := make(chan os.Signal, 64)
+	out := make(chan os.Signal)
+	signal.Notify(in, sigs...)
+	go queueSignals(in, out, ctx.Done())
+	go func() {
+		defer signal.Stop(in)
+		for {
+			select {
+			case sig := <-out:
+				handler(sig)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
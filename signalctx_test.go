@@ -0,0 +1,110 @@
+//go:build !windows
+
+package psi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalFromContext(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	derived, stop := context.WithTimeout(ctx, time.Minute)
+	defer stop()
+	if _, ok := SignalFromContext(derived); ok {
+		t.Fatal("SignalFromContext reported a signal on a live context")
+	}
+	if _, ok := ShutdownDeadline(derived); ok {
+		t.Fatal("ShutdownDeadline reported a deadline on a live context")
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	cancel(stopCause{syscall.SIGHUP, deadline})
+	cancel(stopCause{syscall.SIGTERM, deadline.Add(time.Second)})
+	if sig, ok := SignalFromContext(derived); !ok || sig != syscall.SIGHUP {
+		t.Fatalf("SignalFromContext = %v, %v; want SIGHUP", sig, ok)
+	}
+	if d, ok := ShutdownDeadline(derived); !ok || !d.Equal(deadline) {
+		t.Fatalf("ShutdownDeadline = %v, %v; want %v", d, ok, deadline)
+	}
+	if got := context.Cause(ctx).Error(); got != "psi: received SIGHUP" {
+		t.Fatalf("cause = %q", got)
+	}
+
+	plain, cancelPlain := context.WithCancel(context.Background())
+	cancelPlain()
+	if _, ok := SignalFromContext(plain); ok {
+		t.Fatal("SignalFromContext reported a signal for a plain cancellation")
+	}
+}
+
+func TestRunChildSignalFromContext(t *testing.T) {
+	cmd := helperCommand("run-child-signal", fmt.Sprintf("%s=%s", childEnvKey, childEnvVal))
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+	// Give runChild time to install the signal handler.
+	time.Sleep(100 * time.Millisecond)
+	if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != int(syscall.SIGINT) {
+		t.Fatalf("submain saw signal %d, want SIGINT (%d)", exit, syscall.SIGINT)
+	}
+}
+
+func TestRunChildShutdownDeadline(t *testing.T) {
+	cmd := helperCommand("run-child-deadline", fmt.Sprintf("%s=%s", childEnvKey, childEnvVal), stopTimeoutEnv+"=7s")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 7 {
+		t.Fatalf("submain saw %ds left, want 7s", exit)
+	}
+}
+
+func TestRunChildShutdownDeadlineStopChain(t *testing.T) {
+	// SIGKILL follows 20s of SIGTERM and 5s of SIGINT, not the stop timeout.
+	cmd := helperCommand("run-child-deadline", fmt.Sprintf("%s=%s", childEnvKey, childEnvVal),
+		stopTimeoutEnv+"=30s", stopChainEnv+"=SIGTERM:20s,SIGINT:5s,SIGKILL")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = cmd.Process.Kill()
+		t.Fatalf("failed to signal helper: %v", err)
+	}
+	if exit := exitStatus(cmd.Wait()); exit != 25 {
+		t.Fatalf("submain saw %ds left, want 25s", exit)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	got := make(chan os.Signal, 4)
+	Notify(ctx, func(sig os.Signal) { got <- sig }, syscall.SIGUSR1, syscall.SIGUSR2)
+	for _, sig := range []syscall.Signal{syscall.SIGUSR2, syscall.SIGUSR1} {
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case s := <-got:
+			if s != sig {
+				t.Fatalf("handler got %v, want %v", s, sig)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("handler not called for %v", sig)
+		}
+	}
+	cancel()
+}
@@ -0,0 +1,203 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// signalTable maps canonical signal names to their numbers. Names are
+// matched case-insensitively, with or without the SIG prefix.
+var signalTable = withSignals(map[string]syscall.Signal{
+	"SIGABRT": syscall.SIGABRT,
+	"SIGALRM": syscall.SIGALRM,
+	"SIGBUS":  syscall.SIGBUS,
+	"SIGFPE":  syscall.SIGFPE,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGILL":  syscall.SIGILL,
+	"SIGINT":  syscall.SIGINT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGPIPE": syscall.SIGPIPE,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGSEGV": syscall.SIGSEGV,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGTRAP": syscall.SIGTRAP,
+}, platformSignals, osSignals)
+
+// withSignals adds more to table.
+func withSignals(table map[string]syscall.Signal, more ...map[string]syscall.Signal) map[string]syscall.Signal {
+	for _, m := range more {
+		for name, sig := range m {
+			table[name] = sig
+		}
+	}
+	return table
+}
+
+// ParseSignal converts a signal name ("SIGTERM", "term", "Quit", "RTMIN+3")
+// or number ("15") into a syscall.Signal. It accepts the same syntax as the
+// PSI_* signal environment variables.
+func ParseSignal(s string) (syscall.Signal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty signal name")
+	}
+	if isAllDigits(s) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n >= nsig {
+			return 0, fmt.Errorf("invalid signal number %q", s)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := signalTable[name]; ok {
+		return sig, nil
+	}
+	if sig, ok := parseRealtimeSignal(name); ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// parseRealtimeSignal parses SIGRTMIN, SIGRTMAX, SIGRTMIN+N and SIGRTMAX-N
+// where the platform has real-time signals.
+func parseRealtimeSignal(name string) (syscall.Signal, bool) {
+	if sigRTMin == 0 {
+		return 0, false
+	}
+	base, sign := sigRTMin, 1
+	rest, ok := strings.CutPrefix(name, "SIGRTMIN")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "SIGRTMAX"); !ok {
+			return 0, false
+		}
+		base, sign = sigRTMax, -1
+	}
+	n := 0
+	if rest != "" {
+		op, num := rest[0], rest[1:]
+		if (sign > 0 && op != '+') || (sign < 0 && op != '-') || !isAllDigits(num) || num == "" {
+			return 0, false
+		}
+		n, _ = strconv.Atoi(num)
+	}
+	sig := base + sign*n
+	if sig < sigRTMin || sig > sigRTMax {
+		return 0, false
+	}
+	return syscall.Signal(sig), true
+}
+
+// signalName returns the canonical name of sig (e.g. "SIGTERM", or
+// "SIGRTMIN+2" as kill -l has it), falling back to the numeric form for
+// signals missing from signalTable.
+func signalName(sig syscall.Signal) string {
+	for name, s := range signalTable {
+		if s == sig {
+			return name
+		}
+	}
+	if n := int(sig); sigRTMin != 0 && n >= sigRTMin && n <= sigRTMax {
+		switch {
+		case n == sigRTMin:
+			return "SIGRTMIN"
+		case n == sigRTMax:
+			return "SIGRTMAX"
+		case n-sigRTMin <= (sigRTMax-sigRTMin)/2:
+			return "SIGRTMIN+" + strconv.Itoa(n-sigRTMin)
+		default:
+			return "SIGRTMAX-" + strconv.Itoa(sigRTMax-n)
+		}
+	}
+	return "SIG" + strconv.Itoa(int(sig))
+}
+
+// parseSignalList parses a comma-separated list of signal names or numbers.
+func parseSignalList(s string) ([]syscall.Signal, error) {
+	var sigs []syscall.Signal
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		sig, err := ParseSignal(part)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// parseSignalMap parses "FROM:TO" pairs such as "SIGHUP:SIGUSR1,SIGINT:SIGTERM".
+func parseSignalMap(s string) (map[syscall.Signal]syscall.Signal, error) {
+	m := make(map[syscall.Signal]syscall.Signal)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("mapping %q lacks ':'", part)
+		}
+		fromSig, err := ParseSignal(from)
+		if err != nil {
+			return nil, err
+		}
+		toSig, err := ParseSignal(to)
+		if err != nil {
+			return nil, err
+		}
+		m[fromSig] = toSig
+	}
+	return m, nil
+}
+
+// relaySignals subscribes to every signal the init can catch and passes them
+// on to out, in order, through a queue that grows as needed. signal.Notify
+// drops a signal when its channel is full, so a burst (say, SIGCHLD from a
+// crowd of exiting children while the supervisor is busy) could push out a
+// SIGTERM; the relay keeps that channel drained instead. Like any delivery
+// through the runtime's signal handler, a signal arriving again while the
+// runtime still holds it pending is coalesced, and no siginfo comes along.
+//
+// This is not a signalfd loop, which would keep every queued instance and
+// its siginfo (the sender's PID and UID): that needs the signals blocked on
+// every thread, but the Go runtime unblocks SIGTERM, SIGINT, SIGHUP, the
+// other signals that terminate by default and SIGQUIT on every thread it
+// starts, and gives new threads the mask the process started with, so
+// signals would keep reaching its handler rather than the descriptor.
+func relaySignals(out chan<- os.Signal) {
+	in := make(chan os.Signal, 64)
+	// SIGKILL and SIGSTOP cannot be caught.
+	signal.Notify(in)
+	go queueSignals(in, out, nil)
+}
+
+// queueSignals moves signals from in to out, queueing those out is not
+// ready for, until done is closed.
+func queueSignals(in <-chan os.Signal, out chan<- os.Signal, done <-chan struct{}) {
+	var queue []os.Signal
+	for {
+		var send chan<- os.Signal
+		var next os.Signal
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+		select {
+		case sig := <-in:
+			queue = append(queue, sig)
+		case send <- next:
+			queue[0] = nil
+			queue = queue[1:]
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package psi
+
+import "syscall"
+
+// nsig is one more than the highest signal number (_SIG_MAXSIG).
+const nsig = 129
+
+// No real-time signals are named outside Linux.
+const sigRTMin, sigRTMax = 0, 0
+
+// osSignals is empty outside Linux.
+var osSignals map[string]syscall.Signal
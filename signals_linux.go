@@ -0,0 +1,20 @@
+package psi
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sigRTMin is the first real-time signal left to applications: the C
+// libraries keep the kernel's first two for themselves, so kill -l and
+// sigqueue users count from 34. sigRTMax is the last one.
+const (
+	sigRTMin = 34
+	sigRTMax = nsig - 1
+)
+
+// osSignals are the signals Linux has beyond the other Unixes.
+var osSignals = withSignals(map[string]syscall.Signal{
+	"SIGPWR": unix.SIGPWR,
+}, archSignals)
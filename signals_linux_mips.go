@@ -0,0 +1,11 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package psi
+
+import "syscall"
+
+// nsig is one more than the highest signal number.
+const nsig = 128
+
+// archSignals is empty: MIPS has no SIGSTKFLT.
+var archSignals map[string]syscall.Signal
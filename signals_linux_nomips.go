@@ -0,0 +1,17 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package psi
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// nsig is one more than the highest signal number.
+const nsig = 65
+
+// archSignals are the signals of this architecture beyond the common ones.
+var archSignals = map[string]syscall.Signal{
+	"SIGSTKFLT": unix.SIGSTKFLT,
+}
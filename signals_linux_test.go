@@ -0,0 +1,46 @@
+package psi
+
+import (
+	"strconv"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseLinuxSignal(t *testing.T) {
+	cases := map[string]syscall.Signal{
+		"SIGPWR":      unix.SIGPWR,
+		"rtmin":       34,
+		"SIGRTMIN+3":  37,
+		"RTMAX":       nsig - 1,
+		"SIGRTMAX-2":  nsig - 3,
+		"sigrtmin+30": 64,
+		"64":          64,
+	}
+	for input, want := range cases {
+		if got, err := ParseSignal(input); err != nil || got != want {
+			t.Errorf("ParseSignal(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"SIGRTMIN-1", "SIGRTMAX+1", "SIGRTMIN+", "SIGRTMIN+x", "SIGRTMIN+200", strconv.Itoa(nsig)} {
+		if _, err := ParseSignal(input); err == nil {
+			t.Errorf("ParseSignal(%q) should fail", input)
+		}
+	}
+}
+
+func TestLinuxSignalName(t *testing.T) {
+	cases := map[syscall.Signal]string{
+		unix.SIGPWR: "SIGPWR",
+		34:          "SIGRTMIN",
+		35:          "SIGRTMIN+1",
+		nsig - 2:    "SIGRTMAX-1",
+		nsig - 1:    "SIGRTMAX",
+	}
+	for sig, want := range cases {
+		if got := signalName(sig); got != want {
+			t.Errorf("signalName(%d) = %q, want %q", sig, got, want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !freebsd
+
+package psi
+
+import "syscall"
+
+// nsig is one more than the highest signal number.
+const nsig = 32
+
+// No real-time signals are named outside Linux.
+const sigRTMin, sigRTMax = 0, 0
+
+// osSignals is empty outside Linux.
+var osSignals map[string]syscall.Signal
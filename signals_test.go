@@ -0,0 +1,82 @@
+//go:build !windows
+
+package psi
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal(t *testing.T) {
+	cases := map[string]syscall.Signal{
+		"SIGTERM": syscall.SIGTERM,
+		"sigquit": syscall.SIGQUIT,
+		"INT":     syscall.SIGINT,
+		" usr1 ":  syscall.SIGUSR1,
+		"9":       syscall.SIGKILL,
+	}
+	for input, want := range cases {
+		got, err := ParseSignal(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseSignal(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "SIGBOGUS", "0", "-1", "200"} {
+		if _, err := ParseSignal(input); err == nil {
+			t.Fatalf("ParseSignal(%q) should fail", input)
+		}
+	}
+}
+
+func TestSignalName(t *testing.T) {
+	if got := signalName(syscall.SIGQUIT); got != "SIGQUIT" {
+		t.Fatalf("signalName(SIGQUIT) = %q", got)
+	}
+	if got := signalName(syscall.Signal(200)); got != "SIG200" {
+		t.Fatalf("signalName(200) = %q", got)
+	}
+}
+
+func TestParseSignalMap(t *testing.T) {
+	m, err := parseSignalMap("SIGHUP:SIGUSR1, int:term")
+	if err != nil {
+		t.Fatalf("parseSignalMap: %v", err)
+	}
+	if m[syscall.SIGHUP] != syscall.SIGUSR1 || m[syscall.SIGINT] != syscall.SIGTERM || len(m) != 2 {
+		t.Fatalf("unexpected map %v", m)
+	}
+	for _, input := range []string{"SIGHUP", "SIGHUP:BOGUS", "BOGUS:SIGHUP"} {
+		if _, err := parseSignalMap(input); err == nil {
+			t.Fatalf("parseSignalMap(%q) should fail", input)
+		}
+	}
+}
+
+func TestQueueSignalsKeepsBursts(t *testing.T) {
+	in := make(chan os.Signal, 64)
+	out := make(chan os.Signal)
+	go queueSignals(in, out, nil)
+	const n = 1000
+	for i := 0; i < n; i++ {
+		sig := syscall.SIGCHLD
+		if i == n-1 {
+			sig = syscall.SIGTERM
+		}
+		select {
+		case in <- sig:
+		case <-time.After(time.Second):
+			t.Fatalf("relay stopped draining after %d signals", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		var want os.Signal = syscall.SIGCHLD
+		if i == n-1 {
+			want = syscall.SIGTERM
+		}
+		if got := <-out; got != want {
+			t.Fatalf("signal %d = %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package psi
+
+import "syscall"
+
+// platformSignals is empty: the syscall package defines only the common
+// signals on Windows.
+var platformSignals map[string]syscall.Signal
@@ -0,0 +1,61 @@
+package psi
+
+import (
+	"log"
+	"syscall"
+	"time"
+)
+
+const startTimeoutEnv = "PSI_START_TIMEOUT"
+
+// WithStartTimeout bounds the child's startup: a child that exits within d
+// of being started has failed to start, and its exit code is replaced by
+// ExitStartTimeout. With WithReadyNotify the child must also report readiness
+// within d or it is killed. Zero disables the check. Overridden by
+// PSI_START_TIMEOUT.
+func WithStartTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.startTimeout = d
+	}
+}
+
+// beginStartup arms the startup window for a newly started child. Without
+// one, the child is ready as soon as it runs unless it reports readiness.
+func (s *supervisor) beginStartup() {
+	s.starting = s.cfg.startTimeout > 0
+	s.startDeadline = nil
+	if s.starting {
+		s.startDeadline = time.After(s.cfg.startTimeout)
+		tracef("startup timer armed for %s", s.cfg.startTimeout)
+	} else if !s.cfg.readyNotify {
+		s.markReady()
+	}
+}
+
+// startupDeadline handles the end of the startup window, which a readiness
+// report also ends early.
+func (s *supervisor) startupDeadline() {
+	s.startDeadline = nil
+	if s.cfg.readyNotify && !s.ready {
+		if !s.esc.started() {
+			log.Printf("psi: child (pid %d) did not report ready within %s; killing it", s.childPID, s.cfg.startTimeout)
+			s.signalChild(syscall.SIGKILL)
+		}
+		return
+	}
+	s.starting = false
+	s.markReady()
+	s.cfg.debugf(1, "child (pid %d) completed startup", s.childPID)
+}
+
+// startupExitCode returns the exit code to act on for a child that exited
+// with code, replacing it when the child failed to start.
+func (s *supervisor) startupExitCode(code int) int {
+	starting := s.starting
+	s.starting, s.startDeadline = false, nil
+	if !starting || s.esc.started() {
+		return code
+	}
+	log.Printf("psi: child (pid %d) exited with code %d within the %s startup timeout", s.childPID, code, s.cfg.startTimeout)
+	return ExitStartTimeout
+}
@@ -0,0 +1,32 @@
+package psi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartupExitCode(t *testing.T) {
+	s := newSupervisor(newConfig(WithStartTimeout(time.Hour)))
+	s.beginStartup()
+	if got := s.startupExitCode(0); got != ExitStartTimeout {
+		t.Fatalf("exit during startup = %d, want %d", got, ExitStartTimeout)
+	}
+	s.beginStartup()
+	s.startupDeadline()
+	if got := s.startupExitCode(3); got != 3 {
+		t.Fatalf("exit after startup = %d, want 3", got)
+	}
+
+	s = newSupervisor(newConfig())
+	s.beginStartup()
+	if s.startDeadline != nil || s.startupExitCode(3) != 3 {
+		t.Fatal("startup check active without a start timeout")
+	}
+}
+
+func TestStartTimeoutEnv(t *testing.T) {
+	t.Setenv(startTimeoutEnv, "90")
+	if cfg := newConfig(WithStartTimeout(time.Second)); cfg.startTimeout != 90*time.Second {
+		t.Fatalf("startTimeout = %s, want 90s", cfg.startTimeout)
+	}
+}
@@ -0,0 +1,102 @@
+package psi
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pidFileEnv   = "PSI_PIDFILE"
+	stateFileEnv = "PSI_STATE_FILE"
+)
+
+// WithPidFile makes the init write the running child's PID to path,
+// rewriting it whenever a new generation takes over and removing it when
+// the init exits. Overridden by PSI_PIDFILE.
+func WithPidFile(path string) Option {
+	return func(c *config) {
+		c.pidFile = path
+	}
+}
+
+// WithStateFile makes the init keep path up to date with the child's state
+// as a JSON document, the same as the health endpoint's /status: PID,
+// whether it is running and ready, start time, uptime, restarts,
+// generation, sd_notify STATUS and last exit. It is rewritten on every
+// change and left in place, with the final exit, when the init exits.
+// Overridden by PSI_STATE_FILE.
+func WithStateFile(path string) Option {
+	return func(c *config) {
+		c.stateFile = path
+	}
+}
+
+// loadStateFileEnv applies the PSI_PIDFILE and PSI_STATE_FILE overrides.
+func (c *config) loadStateFileEnv() {
+	if val := strings.TrimSpace(os.Getenv(pidFileEnv)); val != "" {
+		c.pidFile = val
+	}
+	if val := strings.TrimSpace(os.Getenv(stateFileEnv)); val != "" {
+		c.stateFile = val
+	}
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// saveState writes the PID and state files, if enabled, after a change of
+// the child's state. Failures are logged and otherwise ignored.
+func (s *supervisor) saveState() {
+	if s.cfg.pidFile == "" && s.cfg.stateFile == "" {
+		return
+	}
+	r := s.status.report(time.Now())
+	if s.cfg.pidFile != "" && r.Running && r.PID != s.savedPID {
+		if err := writeFileAtomic(s.cfg.pidFile, []byte(strconv.Itoa(r.PID)+"\n")); err != nil {
+			log.Printf("psi: cannot write pid file: %v", err)
+		} else {
+			s.savedPID = r.PID
+		}
+	}
+	if s.cfg.stateFile != "" {
+		b, _ := json.MarshalIndent(r, "", "  ")
+		if err := writeFileAtomic(s.cfg.stateFile, append(b, '\n')); err != nil {
+			log.Printf("psi: cannot write state file: %v", err)
+		}
+	}
+}
+
+// removePidFile removes the PID file once the init is done with the child.
+func (s *supervisor) removePidFile() {
+	if s.cfg.pidFile == "" {
+		return
+	}
+	if err := os.Remove(s.cfg.pidFile); err != nil && !os.IsNotExist(err) {
+		log.Printf("psi: cannot remove pid file: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build !windows
+
+package psi
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSaveState(t *testing.T) {
+	dir := t.TempDir()
+	s := newSupervisor(newConfig(WithPidFile(dir+"/app.pid"), WithStateFile(dir+"/state.json")))
+	s.status.childStarted(42, 1, 2, time.Now())
+	s.saveState()
+	if b, err := os.ReadFile(dir + "/app.pid"); err != nil || string(b) != "42\n" {
+		t.Fatalf("pid file = %q, %v", b, err)
+	}
+	s.status.childExited(ExitSummary{PID: 42, ExitCode: 3, Exited: time.Now()})
+	s.saveState()
+	var st statusReport
+	b, err := os.ReadFile(dir + "/state.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		t.Fatalf("state file %q: %v", b, err)
+	}
+	if st.PID != 42 || st.Running || st.Restarts != 1 || st.Generation != 2 || st.Started == nil ||
+		st.LastExitCode == nil || *st.LastExitCode != 3 {
+		t.Fatalf("state = %+v", st)
+	}
+	if b, _ := os.ReadFile(dir + "/app.pid"); string(b) != "42\n" {
+		t.Fatalf("pid file rewritten after the exit: %q", b)
+	}
+	s.removePidFile()
+	if _, err := os.Stat(dir + "/app.pid"); !os.IsNotExist(err) {
+		t.Fatalf("pid file not removed: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestStateFileEnv(t *testing.T) {
+	t.Setenv(pidFileEnv, "/run/app.pid")
+	t.Setenv(stateFileEnv, "/run/state.json")
+	c := newConfig(WithPidFile("/x"))
+	if c.pidFile != "/run/app.pid" || c.stateFile != "/run/state.json" {
+		t.Fatalf("pidFile = %q, stateFile = %q", c.pidFile, c.stateFile)
+	}
+}
+
+func TestSupervisorStateFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	dir := t.TempDir()
+	cmd := helperCommand("init-stderr", pidFileEnv+"="+dir+"/app.pid", stateFileEnv+"="+dir+"/state.json")
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	if _, err := os.Stat(dir + "/app.pid"); !os.IsNotExist(err) {
+		t.Fatalf("pid file left behind: %v", err)
+	}
+	var st statusReport
+	b, err := os.ReadFile(dir + "/state.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &st); err != nil || st.PID == 0 || st.Running || st.LastExitCode == nil || *st.LastExitCode != 3 {
+		t.Fatalf("state file %s: %v", b, err)
+	}
+}
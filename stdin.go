@@ -0,0 +1,92 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const stdinEnv = "PSI_STDIN"
+
+// StdinMode decides what the child reads on stdin.
+type StdinMode string
+
+const (
+	// StdinInherit gives the child the init's stdin (default). With WithPTY
+	// it is copied to the child's terminal, and its end of file passed on
+	// as the terminal's EOF character.
+	StdinInherit StdinMode = "inherit"
+	// StdinNull gives the child /dev/null, for programs that would block
+	// forever on an inherited stdin nothing is ever written to.
+	StdinNull StdinMode = "null"
+	// StdinClosed gives the child a stdin already at end of file. It is a
+	// pipe rather than a closed descriptor 0, which the next file the child
+	// opens would silently become.
+	StdinClosed StdinMode = "closed"
+	// StdinOpen gives the child a pipe the init holds open and never writes
+	// to, for programs that exit on end of file: reads block until the
+	// child is stopped.
+	StdinOpen StdinMode = "open"
+)
+
+func parseStdinMode(s string) (StdinMode, error) {
+	switch m := StdinMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case StdinInherit, StdinNull, StdinClosed, StdinOpen:
+		return m, nil
+	case "":
+		return StdinInherit, nil
+	default:
+		return "", fmt.Errorf("unknown stdin mode %q", s)
+	}
+}
+
+// WithStdin sets what the child reads on stdin. Overridden by PSI_STDIN
+// (inherit, null, closed or open).
+func WithStdin(mode StdinMode) Option {
+	return func(c *config) {
+		c.stdin = mode
+	}
+}
+
+// loadStdinEnv applies the PSI_STDIN override.
+func (c *config) loadStdinEnv() {
+	val := strings.TrimSpace(os.Getenv(stdinEnv))
+	if val == "" {
+		return
+	}
+	m, err := parseStdinMode(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", stdinEnv, val, err)
+		return
+	}
+	c.stdin = m
+}
+
+// childStdin returns the stdin of a new child generation, nil for
+// /dev/null, and the function closing the init's copy once it has started.
+func (s *supervisor) childStdin() (*os.File, func(), error) {
+	switch s.cfg.stdin {
+	case StdinNull:
+		return nil, func() {}, nil
+	case StdinClosed:
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		w.Close()
+		return r, func() { r.Close() }, nil
+	case StdinOpen:
+		if s.heldStdin == nil {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return nil, nil, err
+			}
+			// The write end stays open until the init exits.
+			s.heldStdin, s.heldStdinWriter = r, w
+		}
+		return s.heldStdin, func() {}, nil
+	default:
+		return os.Stdin, func() {}, nil
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package psi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStdinMode(t *testing.T) {
+	for in, want := range map[string]StdinMode{"": StdinInherit, "NULL": StdinNull, " closed ": StdinClosed, "open": StdinOpen} {
+		if got, err := parseStdinMode(in); err != nil || got != want {
+			t.Errorf("parseStdinMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := parseStdinMode("tty"); err == nil {
+		t.Fatal("unknown mode accepted")
+	}
+}
+
+func TestSupervisorStdinModes(t *testing.T) {
+	for mode, want := range map[StdinMode]string{
+		StdinInherit: "read hello\n",
+		StdinNull:    "eof \n",
+		StdinClosed:  "eof \n",
+		StdinOpen:    "blocked\n",
+	} {
+		t.Run(string(mode), func(t *testing.T) {
+			cmd := helperCommand("init-stdin", stdinEnv+"="+string(mode))
+			cmd.Stdin = strings.NewReader("hello\n")
+			out, err := cmd.Output()
+			if exit := exitStatus(err); exit != 0 {
+				t.Fatalf("expected exit code 0, got %d", exit)
+			}
+			if string(out) != want {
+				t.Fatalf("output = %q, want %q", out, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package psi
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const stopChainEnv = "PSI_STOP_CHAIN"
+
+// StopStep is one stage of the shutdown escalation chain: Signal is sent to
+// the child's process group, then the init waits up to Wait before moving on
+// to the next step. A zero Signal forwards the received terminate signal (or
+// the configured stop signal); a zero Wait uses PSI_STOP_TIMEOUT.
+type StopStep struct {
+	Signal syscall.Signal
+	Wait   time.Duration
+}
+
+// WithStopChain replaces the single stop timeout with an escalation sequence,
+// e.g. SIGTERM for 20s, then SIGINT for 5s, then SIGKILL. SIGKILL is appended
+// if the chain does not end with it. Overridden by PSI_STOP_CHAIN.
+func WithStopChain(steps ...StopStep) Option {
+	return func(c *config) {
+		c.stopChain = append([]StopStep(nil), steps...)
+	}
+}
+
+// parseStopChain parses a PSI_STOP_CHAIN value such as
+// "SIGTERM:20s,SIGINT:5s,SIGKILL". Durations follow PSI_STOP_TIMEOUT syntax
+// (bare numbers are seconds) and may be omitted.
+func parseStopChain(s string) ([]StopStep, error) {
+	var steps []StopStep
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, wait, hasWait := strings.Cut(part, ":")
+		sig, err := ParseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+		step := StopStep{Signal: sig}
+		if hasWait {
+			d, err := parseDuration(wait)
+			if err != nil {
+				return nil, fmt.Errorf("step %q: %w", part, err)
+			}
+			step.Wait = d
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("empty stop chain")
+	}
+	return steps, nil
+}
+
+// parseDuration accepts Go duration strings and bare seconds ("30").
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if isAllDigits(s) {
+		s += "s"
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	return d, nil
+}
+
+// resolveStopChain returns the escalation steps to run, falling back to the
+// classic "forward, wait stopTimeout, SIGKILL" sequence. Zero waits are
+// replaced by stopTimeout and a final SIGKILL is guaranteed.
+func (c *config) resolveStopChain(stopTimeout time.Duration) []StopStep {
+	steps := append([]StopStep(nil), c.stopChain...)
+	if len(steps) == 0 {
+		steps = []StopStep{{}}
+	}
+	for i := range steps {
+		if steps[i].Wait == 0 {
+			steps[i].Wait = stopTimeout
+		}
+	}
+	if steps[len(steps)-1].Signal != syscall.SIGKILL {
+		steps = append(steps, StopStep{Signal: syscall.SIGKILL})
+	}
+	return steps
+}
+
+// killAfter returns how long after the first stop step the chain resolved
+// from stopTimeout sends SIGKILL: the sum of the waits before it.
+func (c *config) killAfter(stopTimeout time.Duration) time.Duration {
+	var d time.Duration
+	for _, step := range c.resolveStopChain(stopTimeout) {
+		if step.Signal == syscall.SIGKILL {
+			break
+		}
+		d += step.Wait
+	}
+	return d
+}
+
+// escalation is the shutdown state machine driven by runAsInit. It is idle
+// until begin is called; each expiry of its timer advances to the next step.
+type escalation struct {
+	steps []StopStep
+	next  int
+	timer *time.Timer
+	// deadline is when timer fires.
+	deadline time.Time
+}
+
+func newEscalation(steps []StopStep) *escalation {
+	return &escalation{steps: steps}
+}
+
+// started reports whether the escalation has been triggered.
+func (e *escalation) started() bool { return e.next > 0 }
+
+// advance returns the next step and arms the timer for its wait period. The
+// final step arms no timer; the init then simply waits for the child to exit.
+func (e *escalation) advance() (StopStep, bool) {
+	if e.next >= len(e.steps) {
+		return StopStep{}, false
+	}
+	step := e.steps[e.next]
+	e.next++
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if e.next < len(e.steps) {
+		e.timer = time.NewTimer(step.Wait)
+		e.deadline = time.Now().Add(step.Wait)
+	}
+	return step, true
+}
+
+// extend pushes the current step's deadline back by d.
+func (e *escalation) extend(d time.Duration) {
+	e.deadline = e.deadline.Add(d)
+	e.timer.Reset(time.Until(e.deadline))
+}
+
+// C returns the channel that fires when the current step's wait expires.
+func (e *escalation) C() <-chan time.Time {
+	return killTimerC(e.timer)
+}
+
+// advanceStop takes the next shutdown step, reporting its timer if armed.
+func (s *supervisor) advanceStop() (StopStep, bool) {
+	step, ok := s.esc.advance()
+	if ok && s.esc.timer != nil {
+		tracef("stop timer armed for %s", step.Wait)
+		emit(Event{Type: EventStopTimerArmed, PID: s.childPID, Timeout: step.Wait})
+	}
+	return step, ok
+}
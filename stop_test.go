@@ -0,0 +1,96 @@
+package psi
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseStopChain(t *testing.T) {
+	got, err := parseStopChain("SIGTERM:20s, int:5, SIGKILL")
+	if err != nil {
+		t.Fatalf("parseStopChain: %v", err)
+	}
+	want := []StopStep{
+		{Signal: syscall.SIGTERM, Wait: 20 * time.Second},
+		{Signal: syscall.SIGINT, Wait: 5 * time.Second},
+		{Signal: syscall.SIGKILL},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseStopChain = %+v, want %+v", got, want)
+	}
+	for _, input := range []string{"", "SIGBOGUS:1s", "SIGTERM:soon", "SIGTERM:-1s"} {
+		if _, err := parseStopChain(input); err == nil {
+			t.Fatalf("parseStopChain(%q) should fail", input)
+		}
+	}
+}
+
+func TestResolveStopChainDefault(t *testing.T) {
+	cfg := &config{}
+	got := cfg.resolveStopChain(7 * time.Second)
+	want := []StopStep{
+		{Wait: 7 * time.Second},
+		{Signal: syscall.SIGKILL},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("resolveStopChain = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveStopChainAppendsKill(t *testing.T) {
+	cfg := &config{stopChain: []StopStep{{Signal: syscall.SIGQUIT}}}
+	got := cfg.resolveStopChain(3 * time.Second)
+	if len(got) != 2 || got[0].Wait != 3*time.Second || got[1].Signal != syscall.SIGKILL {
+		t.Fatalf("unexpected chain %+v", got)
+	}
+}
+
+func TestEscalationAdvance(t *testing.T) {
+	esc := newEscalation([]StopStep{
+		{Signal: syscall.SIGTERM, Wait: 10 * time.Millisecond},
+		{Signal: syscall.SIGKILL},
+	})
+	if esc.started() {
+		t.Fatal("escalation should start idle")
+	}
+	step, ok := esc.advance()
+	if !ok || step.Signal != syscall.SIGTERM || !esc.started() {
+		t.Fatalf("unexpected first step %+v ok=%v", step, ok)
+	}
+	select {
+	case <-esc.C():
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("escalation timer did not fire")
+	}
+	step, ok = esc.advance()
+	if !ok || step.Signal != syscall.SIGKILL {
+		t.Fatalf("unexpected final step %+v ok=%v", step, ok)
+	}
+	select {
+	case <-esc.C():
+		t.Fatal("final step should not arm a timer")
+	default:
+	}
+	if _, ok := esc.advance(); ok {
+		t.Fatal("advance past the end should report false")
+	}
+}
+
+func TestKillAfter(t *testing.T) {
+	for _, tc := range []struct {
+		chain []StopStep
+		want  time.Duration
+	}{
+		{nil, 10 * time.Second},
+		{[]StopStep{{Signal: syscall.SIGTERM, Wait: 20 * time.Second}, {Signal: syscall.SIGINT, Wait: 5 * time.Second}}, 25 * time.Second},
+		{[]StopStep{{Signal: syscall.SIGTERM}, {Signal: syscall.SIGINT, Wait: time.Second}, {Signal: syscall.SIGKILL}}, 11 * time.Second},
+		{[]StopStep{{Signal: syscall.SIGKILL}}, 0},
+	} {
+		c := newConfig(WithStopChain(tc.chain...))
+		if got := c.killAfter(10 * time.Second); got != tc.want {
+			t.Errorf("killAfter(%v) = %s, want %s", tc.chain, got, tc.want)
+		}
+	}
+}
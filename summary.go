@@ -0,0 +1,69 @@
+package psi
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// ExitSummary describes how a child generation ended. It is logged when the
+// child exits, carried by its EventChildExited and returned by
+// Supervisor.LastExit.
+type ExitSummary struct {
+	PID int
+	// ExitCode is shell-style: 128+N when the child was killed by signal N.
+	ExitCode int
+	// Signal is the signal that killed the child, 0 if it exited.
+	Signal  syscall.Signal
+	Started time.Time
+	Exited  time.Time
+	Uptime  time.Duration
+	// UserCPU, SystemCPU and MaxRSS (peak resident set size in bytes) are
+	// the child's resource usage as reported by wait4, including the
+	// descendants it waited for.
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	MaxRSS    int64
+	// OrphansReaped counts the adopted orphans the init reaped while the
+	// child ran.
+	OrphansReaped int
+	// Stderr is the end of the child's stderr, kept by WithCrashBuffer, when
+	// it exited abnormally; empty otherwise.
+	Stderr string
+}
+
+func (e ExitSummary) String() string {
+	how := fmt.Sprintf("exited with code %d", e.ExitCode)
+	if e.Signal != 0 {
+		how = fmt.Sprintf("was killed by %s (exit code %d)", signalName(e.Signal), e.ExitCode)
+	}
+	return fmt.Sprintf("child (pid %d) %s after %s (user %s, sys %s, max RSS %.1f MiB, %d orphans reaped)",
+		e.PID, how, e.Uptime.Round(time.Millisecond), e.UserCPU.Round(time.Millisecond),
+		e.SystemCPU.Round(time.Millisecond), float64(e.MaxRSS)/(1<<20), e.OrphansReaped)
+}
+
+// LastExit returns the summary of the latest child generation to exit, or
+// false if none has exited yet.
+func (sup *Supervisor) LastExit() (ExitSummary, bool) {
+	return sup.s.status.lastExitSummary()
+}
+
+// exitSummary summarizes the exit of the current child, reaped with code.
+func (s *supervisor) exitSummary(code int, at time.Time) ExitSummary {
+	h := s.child
+	sum := ExitSummary{
+		PID:           s.childPID,
+		ExitCode:      code,
+		Started:       s.started,
+		Exited:        at,
+		Uptime:        at.Sub(s.started),
+		UserCPU:       userCPU(&h.rusage),
+		SystemCPU:     systemCPU(&h.rusage),
+		MaxRSS:        maxRSSBytes(&h.rusage),
+		OrphansReaped: s.reaper.orphansReaped() - s.orphansAtStart,
+	}
+	if h.status.Signaled() {
+		sum.Signal = h.status.Signal()
+	}
+	return sum
+}
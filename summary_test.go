@@ -0,0 +1,33 @@
+//go:build !windows
+
+package psi
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitSummaryString(t *testing.T) {
+	sum := ExitSummary{PID: 42, ExitCode: 3, Uptime: 90 * time.Second, UserCPU: 1500 * time.Millisecond, SystemCPU: 250 * time.Millisecond, MaxRSS: 45 << 20, OrphansReaped: 2}
+	if got, want := sum.String(), "child (pid 42) exited with code 3 after 1m30s (user 1.5s, sys 250ms, max RSS 45.0 MiB, 2 orphans reaped)"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	sum.ExitCode, sum.Signal = 137, syscall.SIGKILL
+	if got, want := sum.String(), "child (pid 42) was killed by SIGKILL (exit code 137) after 1m30s (user 1.5s, sys 250ms, max RSS 45.0 MiB, 2 orphans reaped)"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSupervisorExitSummary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-summary", helperCountEnv+"="+countFile)
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	waitForContent(t, countFile, "code=3 signal=0 uptime=true cpu=true rss=true last=true\n")
+}
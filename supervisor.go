@@ -0,0 +1,577 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// supervisor holds the init's state across child generations: the signal
+// subscription, the shutdown escalation and the currently running child.
+type supervisor struct {
+	cfg    *config
+	sigs   chan os.Signal
+	esc    *escalation
+	reaper *reaper
+
+	stopTimeout time.Duration
+	sidecars    []*sidecarProc
+
+	child    *procHandle
+	childPID int
+	// cgroup confines the child when cgroup mode is enabled and available.
+	cgroup *childCgroup
+	// reload yields when a watched file changed; nil when none are watched.
+	reload <-chan struct{}
+	// done yields the current child's exit code once reaped.
+	done <-chan int
+	// restarts counts child generations started after the first;
+	// generation counts all, including upgrades.
+	restarts   int
+	generation int
+	// started is when the current child generation was started.
+	started time.Time
+	// fastExits counts consecutive generations that exited before the
+	// minimum uptime.
+	fastExits int
+	// backoffs counts the restarts since a generation last ran healthily
+	// (see stableUptime), from which the restart delay grows.
+	backoffs int
+	// starting is set until the current child completes startup;
+	// startDeadline fires at the end of the startup window.
+	starting      bool
+	startDeadline <-chan time.Time
+	// readyc is closed when the current child reports readiness; ready is
+	// set once it has.
+	readyc <-chan struct{}
+	ready  bool
+	// readySidecars is set once the AfterReady sidecars have been started.
+	readySidecars bool
+	// probeDue fires when the next liveness check is due; results arrive on
+	// probeResults. probeFailures counts consecutive failures.
+	probeDue      <-chan time.Time
+	probeResults  chan probeResult
+	probeFailures int
+	// notifyAddr is the sd_notify socket passed to the child; messages
+	// arrive on notifyMsgs. watchdogDue fires when the child's watchdog
+	// deadline passes.
+	notifyAddr  string
+	notifyMsgs  <-chan notifyMsg
+	watchdogDue <-chan time.Time
+	// unhealthy is set once the child is being stopped by its liveness
+	// probe or watchdog; unhealthyKill fires when it is to be killed.
+	unhealthy     bool
+	unhealthyKill <-chan time.Time
+	// orphansAtStart is the reaper's orphan count when the child started.
+	orphansAtStart int
+	// tracer exports the lifecycle trace; nil when tracing is off.
+	tracer *tracer
+	// status is the child's state as served by the health endpoint.
+	status childStatus
+	// systemd reports to systemd when it supervises the init.
+	systemd *systemdNotifier
+	// listenFDs are passed to every child generation: those from a service
+	// manager, then those bound by the init.
+	listenFDs []listenFD
+	// retiring is the previous generation while an upgrade replaces it;
+	// retiringDone yields its exit code and retiringKill fires when it is
+	// to be killed.
+	retiring     *generation
+	retiringDone <-chan int
+	retiringKill <-chan time.Time
+	// upgrades carries Upgrade calls. upgradeResult receives the outcome
+	// of the pending one and upgradeCancel is its context's Done channel.
+	upgrades      chan upgradeRequest
+	upgradeResult chan<- error
+	upgradeCancel <-chan struct{}
+	// preStopDone is closed when the pre-stop phase started by the first
+	// terminate signal ends; closing preStopAbort cuts it short. The first
+	// stop step's preStopSignal is held back until then.
+	preStopDone   <-chan struct{}
+	preStopAbort  chan struct{}
+	preStopSignal syscall.Signal
+	// stopExtended is how much the child has extended the shutdown by.
+	stopExtended time.Duration
+	// stopSignals are the stop steps' signals sent to the child so far.
+	stopSignals []syscall.Signal
+	// stopTimedOut is set once a stop step's wait expired and the
+	// escalation went on to SIGKILL.
+	stopTimedOut bool
+	// cron runs the cron jobs; nil when there are none.
+	cron *cron
+	// stderrTail keeps the child's last stderr lines for the termination
+	// message; nil when none is written.
+	stderrTail *lineTail
+	// crashBuffer keeps the end of the current generation's stderr.
+	crashBuffer *ringBuffer
+	// journal receives the output and events if journald forwarding is on.
+	journal *journal
+	// fluent ships the output if Fluent forwarding is on.
+	fluent *fluentForwarder
+	// logExporter exports the output and events if OTLP log export is on.
+	logExporter *logExporter
+	// heldStdin is the child's stdin in StdinOpen mode, kept open by
+	// heldStdinWriter.
+	heldStdin, heldStdinWriter *os.File
+	// childTerminals are the terminals of the child's stdin and stdout
+	// when not on a pseudo-terminal of its own.
+	childTerminals []*os.File
+	// pty connects the init's terminal to the child's if it runs on one.
+	pty *ptyProxy
+	// output routes the child's stdout and stderr.
+	output childOutput
+	// control is the control socket's listener; commands affecting the
+	// child arrive on controls.
+	control  net.Listener
+	controls chan controlCall
+	// audit writes the signal audit log if configured.
+	audit *signalAudit
+	// restartRequested is set once the child is being stopped by a restart
+	// command; restartEsc walks the stop chain until it has exited.
+	restartRequested bool
+	restartEsc       *escalation
+	// savedPID is the PID last written to the PID file.
+	savedPID int
+}
+
+func newSupervisor(cfg *config) *supervisor {
+	// Parse stop timeout once and build the shutdown escalation chain.
+	stopTimeout := parseStopTimeout(defaultStopTimeout)
+	s := &supervisor{
+		cfg:          cfg,
+		sigs:         make(chan os.Signal, 64),
+		esc:          newEscalation(cfg.resolveStopChain(stopTimeout)),
+		reaper:       newReaper(),
+		probeResults: make(chan probeResult, 1),
+		upgrades:     make(chan upgradeRequest),
+		controls:     make(chan controlCall),
+		stopTimeout:  stopTimeout,
+	}
+	if cfg.terminationLog != "" {
+		s.stderrTail = &lineTail{}
+		s.output.stderr.sinks = append(s.output.stderr.sinks, s.stderrTail)
+	}
+	s.pty = cfg.newPTYProxy()
+	s.attachLogFiles()
+	s.journal = cfg.newJournal()
+	s.attachJournal(&s.output, cfg.mainChildName())
+	s.fluent = cfg.newFluentForwarder()
+	s.attachFluent(&s.output, cfg.mainChildName())
+	s.logExporter = newLogExporter()
+	s.attachLogExport(&s.output, cfg.mainChildName())
+	cfg.addLineFilters(&s.output, cfg.mainChildName())
+	if cfg.crashBuffer > 0 {
+		s.crashBuffer = newRingBuffer(cfg.crashBuffer)
+		s.output.stderr.sinks = append(s.output.stderr.sinks, s.crashBuffer)
+	}
+	return s
+}
+
+// run starts the sidecars and the child, supervises the child and restarts
+// it according to the restart policy. Sidecars are stopped once the child is
+// gone for good. The init tasks and pre-start hook run after the sidecars
+// start and the post-stop hook before they stop. It returns the exit code the init should
+// exit with.
+func (s *supervisor) run() (code int) {
+	s.cfg.setupLogging()
+	s.logBanner()
+	s.startTracing()
+	defer func() { s.stopTracing(code) }()
+	defer s.stopFluent()
+	defer s.stopLogExport()
+	defer s.stopPTY()
+	defer s.stopControl()
+	defer s.stopSignalAudit()
+	s.cfg.subscribeEvents()
+	s.startJournal()
+	s.startLogExport()
+	s.startSignalAudit()
+	relaySignals(s.sigs)
+	s.listenFDs = inheritListenFDs()
+	bound, err := s.cfg.bindListeners()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	s.listenFDs = append(s.listenFDs, bound...)
+	s.startSystemd()
+	s.cfg.applyToInit()
+	s.reaper.oomScoreAdj = s.cfg.spawnOOMScoreAdj
+	s.cfg.applyProcessTitle()
+	if s.cfg.cgroup {
+		cg, err := setupChildCgroup()
+		if err != nil {
+			log.Printf("psi: cgroup mode unavailable, using process-group signals: %v", err)
+		} else {
+			s.cgroup = cg
+		}
+	}
+	go s.reaper.loop()
+	initReaper.Store(s.reaper)
+	s.serveHealth()
+	s.serveMetrics()
+	s.serveDebug()
+	s.serveControl()
+	s.startNotify()
+	s.reload = s.cfg.startWatcher()
+	if err := s.startSidecars(); err != nil {
+		fatalf("failed to start sidecar: %v", err)
+	}
+	if !s.runInitTasks() || !s.preStartHook() {
+		s.markStopping()
+		s.stopSidecars()
+		s.writeTerminationLog(ExitPreStartFailed)
+		s.saveState()
+		return ExitPreStartFailed
+	}
+	s.startCron()
+	if s.cfg.onSupervise != nil {
+		s.cfg.onSupervise(&Supervisor{s: s})
+	}
+	code = s.superviseChild()
+	// Piped output may still be on its way to stdout and the sinks.
+	s.output.flush(time.Second)
+	s.writeTerminationLog(code)
+	s.removePidFile()
+	s.markStopping()
+	s.stopCron()
+	s.postStopHook()
+	s.stopSidecars()
+	return code
+}
+
+// superviseChild runs child generations until one exits without being
+// restarted and returns its exit code.
+func (s *supervisor) superviseChild() int {
+	for {
+		if err := s.startChild(); err != nil {
+			logMessage(LogError, fmt.Sprintf("failed to start child: %v", err))
+			return startFailureCode(err)
+		}
+		code := s.wait()
+		s.cancelPreStop()
+		s.awaitRetiring()
+		s.child.close()
+		sum := s.exitSummary(code, time.Now())
+		s.captureCrash(&sum)
+		s.status.childExited(sum)
+		s.saveState()
+		emit(Event{Type: EventChildExited, PID: s.childPID, Signal: sum.Signal, ExitCode: code, Exit: &sum})
+		code = s.startupExitCode(code)
+		code = s.unhealthyExitCode(code)
+		if s.takeRestart() {
+			s.restarts++
+			continue
+		}
+		if s.esc.started() || !s.shouldRestart(code) {
+			// Small grace to reap stragglers, then exit with the child's code.
+			time.Sleep(50 * time.Millisecond)
+			s.reaper.drain()
+			return s.stopTimeoutExitCode(s.cleanStopExitCode(code))
+		}
+		uptime := time.Since(s.started)
+		if s.crashLoop(uptime) {
+			log.Printf("psi: child exited %d times within %s of starting; giving up", s.fastExits, s.cfg.restart.minUptime)
+			s.reaper.drain()
+			return ExitCrashLoop
+		}
+		if !s.backoff(code, uptime) {
+			return code
+		}
+		s.restarts++
+	}
+}
+
+// childCommand builds the managed child: the external program in command
+// mode, otherwise this binary (see WithReexecPath) re-exec'd with PSI_CHILD=1
+// to run submain. The
+// re-exec'd binary needs the PSI_* variables to rebuild its configuration and
+// scrubs them itself.
+func (s *supervisor) childCommand() (*exec.Cmd, error) {
+	if len(s.cfg.command) > 0 {
+		env, err := s.cfg.childExtraEnv(s.cfg.childEnv(os.Environ()))
+		if err != nil {
+			return nil, err
+		}
+		argv := s.cfg.expandArgv(s.cfg.command, env)
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Env = env
+		return cmd, nil
+	}
+	env, err := s.cfg.childExtraEnv(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(s.cfg.selfExe(), os.Args[1:]...)
+	cmd.Args[0] = os.Args[0]
+	cmd.Env = append(env, fmt.Sprintf("%s=%s", childEnvKey, childEnvVal))
+	return cmd, nil
+}
+
+// startChild starts a new generation of the managed child.
+func (s *supervisor) startChild() error {
+	cred, err := s.cfg.credential()
+	if err != nil {
+		return err
+	}
+	cmd, err := s.childCommand()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if s.pty == nil {
+		// On a pseudo-terminal, the proxy applies the stdin mode.
+		stdin, closeStdin, err := s.childStdin()
+		if err != nil {
+			return err
+		}
+		defer closeStdin()
+		if stdin != nil {
+			cmd.Stdin = stdin
+		}
+	}
+	if s.crashBuffer != nil {
+		s.crashBuffer.reset()
+	}
+	var closeStderr func()
+	if s.pty != nil {
+		closeStderr, err = s.pty.attach(cmd, &s.output)
+	} else {
+		closeStderr, err = s.output.attach(cmd)
+	}
+	if err != nil {
+		return err
+	}
+	if s.pty == nil {
+		s.childTerminals = childTerminals(cmd)
+		s.syncWindowSize()
+	}
+	// Put child in its own process group so signals can be forwarded to the whole tree.
+	cmd.SysProcAttr = newSysProcAttr(cred)
+	if s.pty != nil {
+		setControllingTTY(cmd.SysProcAttr)
+	}
+	s.cfg.applyRoot(&cmd.Dir, cmd.SysProcAttr)
+	if s.cgroup != nil {
+		s.cgroup.attach(cmd)
+	}
+	// Listen sockets come first: they must be descriptors 3 and up.
+	if err := s.prepareListenFDs(cmd); err != nil {
+		return err
+	}
+	readyStarted, err := s.prepareReady(cmd)
+	if err != nil {
+		return err
+	}
+	s.prepareNotify(cmd)
+	rlimitsStarted, err := s.prepareRlimits(cmd)
+	if err != nil {
+		readyStarted(false)
+		closeStderr()
+		return err
+	}
+	var child *procHandle
+	var done <-chan int
+	restoreUmask := func() {}
+	if s.cfg.forkUmask() && !threadUmask {
+		// Without a per-thread umask, set the process's just for the fork.
+		restoreUmask = s.cfg.setUmask()
+	}
+	err = s.reaper.forkWithOOMScoreAdj(s.cfg.childOOMScoreAdj, func() error {
+		return s.cfg.startChildProcess(cmd.SysProcAttr, func() (err error) {
+			child, done, err = s.reaper.spawn(cmd)
+			return err
+		})
+	})
+	restoreUmask()
+	closeStderr()
+	readyStarted(err == nil)
+	if err == nil {
+		rlimitsStarted(child.pid)
+	} else {
+		rlimitsStarted(0)
+	}
+	if err != nil && s.cgroup != nil {
+		// CLONE_INTO_CGROUP needs Linux 5.7; carry on without the cgroup.
+		log.Printf("psi: starting child in cgroup failed, disabling cgroup mode: %v", err)
+		s.cgroup = nil
+		return s.startChild()
+	}
+	if err != nil {
+		return err
+	}
+	s.child = child
+	s.childPID = child.pid
+	s.done = done
+	s.generation++
+	s.orphansAtStart = s.reaper.orphansReaped()
+	s.started = time.Now()
+	if s.retiring == nil {
+		s.status.childStarted(s.childPID, s.restarts, s.generation, s.started)
+	} else {
+		// The previous generation stays the reported one until replaced.
+		s.status.upgrading(s.childPID)
+	}
+	s.saveState()
+	emit(Event{Type: EventChildStarted, PID: s.childPID})
+	s.beginStartup()
+	s.beginProbes()
+	s.beginWatchdog()
+	s.cfg.debugf(1, "started child %s (pid %d)", cmd.Path, s.childPID)
+	return nil
+}
+
+// wait runs the supervisor loop for the current child: forward signals, drive
+// the shutdown escalation and return the child's exit code once reaped.
+func (s *supervisor) wait() int {
+	for {
+		select {
+		case code := <-s.done:
+			if s.upgradeFailed(code) {
+				continue
+			}
+			return code
+		case code := <-s.retiringDone:
+			s.retired(code)
+		case <-s.retiringKill:
+			tracef("kill timer expired (pid %d)", s.retiring.pid)
+			s.retiringKillDue()
+		case req := <-s.upgrades:
+			s.requestUpgrade(req)
+		case <-s.upgradeCancel:
+			s.cancelUpgrade()
+		case sig := <-s.sigs:
+			s.handleSignal(sig)
+		case <-s.startDeadline:
+			tracef("startup timer expired")
+			s.startupDeadline()
+		case <-s.readyc:
+			s.childReady()
+		case <-s.probeDue:
+			s.runProbe()
+		case res := <-s.probeResults:
+			s.probeDone(res)
+		case msg := <-s.notifyMsgs:
+			s.handleNotify(msg)
+		case <-s.watchdogDue:
+			tracef("watchdog timer expired")
+			s.watchdogExpired()
+		case <-s.unhealthyKill:
+			tracef("kill timer expired (pid %d)", s.childPID)
+			s.unhealthyKillDue()
+		case <-s.restartKill():
+			tracef("kill timer expired (pid %d)", s.childPID)
+			s.advanceRestart()
+		case call := <-s.controls:
+			s.handleControl(call)
+		case <-s.reload:
+			if !s.esc.started() {
+				s.cfg.debugf(1, "watched file changed, sending %s to the child", signalName(s.cfg.reloadSignal()))
+				s.signalChild(s.cfg.reloadSignal())
+			}
+		case <-s.preStopDone:
+			s.endPreStop()
+		case <-s.esc.C():
+			tracef("stop timer expired")
+			// The pre-stop phase counts against the first step's wait.
+			s.endPreStop()
+			// Escalate: the previous step's wait expired, send the next signal.
+			if step, ok := s.advanceStop(); ok {
+				s.stopSignals = append(s.stopSignals, step.Signal)
+				s.stopTimedOut = s.stopTimedOut || step.Signal == syscall.SIGKILL
+				s.signalAll(step.Signal)
+			}
+		}
+	}
+}
+
+// handleSignal applies the forwarding policy to a signal received by the init.
+func (s *supervisor) handleSignal(received os.Signal) {
+	if sig, ok := toSyscallSignal(received); ok && sig != sigPreempt {
+		// Go's runtime preempts goroutines with SIGURG.
+		tracef("received signal %d (%s)", int(sig), signalName(sig))
+	}
+	// Never handle SIGCHLD here (the reaper loop collects children).
+	if received == sigChild {
+		return
+	}
+	sig, ok := toSyscallSignal(received)
+	if ok {
+		emit(Event{Type: EventSignalReceived, Signal: sig})
+	}
+	if !ok || s.cfg.isIgnored(sig) {
+		return
+	}
+	sig = s.cfg.translateSignal(sig)
+	if sig == s.cfg.upgradeSignal {
+		if err := s.upgrade(); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+	// On first terminate-like signal, start the escalation chain.
+	if s.cfg.isTerminate(sig) && !s.esc.started() {
+		s.beginStop(sig)
+		return
+	}
+	// A repeated terminate-like signal may force an immediate kill.
+	if s.cfg.isTerminate(sig) && s.cfg.forceOnSecond {
+		s.signalAll(syscall.SIGKILL)
+		return
+	}
+	// On its own terminal the child learns of the new size from the kernel;
+	// otherwise it gets the size along with the signal.
+	if sig == sigWindowChange {
+		if s.pty != nil {
+			s.pty.resize()
+			s.signalSidecars(sig)
+			return
+		}
+		s.syncWindowSize()
+	}
+	// Forward everything else to the child's process group,
+	// substituting the configured stop signal for terminate-like ones.
+	s.signalAll(s.cfg.forwardSignal(sig))
+	if !s.cfg.isTerminate(sig) {
+		s.signalSidecars(sig)
+	}
+}
+
+// beginStop starts the shutdown on the terminate-like signal sig: the first
+// stop step's signal, or sig as forwarded, goes to the child once the
+// pre-stop phase is over.
+func (s *supervisor) beginStop(sig syscall.Signal) {
+	s.markStopping()
+	step, _ := s.advanceStop()
+	if step.Signal == 0 {
+		step.Signal = s.cfg.forwardSignal(sig)
+	}
+	s.stopSignals = append(s.stopSignals, step.Signal)
+	if !s.beginPreStop(step.Signal) {
+		s.signalAll(step.Signal)
+	}
+}
+
+// signalChild sends sig to the child's process group.
+func (s *supervisor) signalChild(sig syscall.Signal) {
+	s.cfg.debugf(2, "forwarding %s to process group %d", signalName(sig), s.childPID)
+	emit(Event{Type: EventSignalForwarded, PID: s.childPID, Signal: sig})
+	if sig == syscall.SIGKILL {
+		emit(Event{Type: EventForcedKill, PID: s.childPID})
+		s.cfg.dumpTree()
+	}
+	if sig == syscall.SIGKILL && s.cgroup != nil {
+		// Kill the whole tree, including processes outside the group.
+		kill := s.cgroup.kill
+		if s.cfg.cgroupFreeze {
+			kill = s.cgroup.freezeKill
+		}
+		if err := kill(); err == nil {
+			return
+		}
+	}
+	_ = s.child.signalGroup(sig)
+}
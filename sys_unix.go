@@ -0,0 +1,99 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const (
+	// sigChild is never forwarded: the reaper collects the children.
+	sigChild = syscall.SIGCHLD
+	// sigPreempt is how Go's runtime preempts goroutines; it is left out
+	// of the logs and traces.
+	sigPreempt = syscall.SIGURG
+	// sigGoStackDump makes a Go submain child dump its stacks.
+	sigGoStackDump = syscall.SIGUSR1
+	// sigWindowChange tells of a new terminal window size.
+	sigWindowChange = syscall.SIGWINCH
+)
+
+// alwaysSupervise makes the init supervise even when it is not PID 1.
+const alwaysSupervise = false
+
+// credential is the user a child runs as.
+type credential = syscall.Credential
+
+// haveCredentials reports whether children can run as another user.
+const haveCredentials = true
+
+// newSysProcAttr starts a process as the leader of its own process group,
+// so that signals reach its whole tree, running as cred unless nil.
+func newSysProcAttr(cred *credential) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true, Credential: cred}
+}
+
+// setControllingTTY makes attr start a process as the leader of a new
+// session whose controlling terminal is its stdin.
+func setControllingTTY(attr *syscall.SysProcAttr) {
+	// A session leader is its process group's leader already; setpgid
+	// would fail.
+	attr.Setpgid = false
+	attr.Setsid, attr.Setctty, attr.Ctty = true, true, 0
+}
+
+// runsAsUser reports whether attr starts a process as a user other than
+// root.
+func runsAsUser(attr *syscall.SysProcAttr) bool {
+	return attr.Credential != nil && attr.Credential.Uid != 0
+}
+
+// setChroot makes attr start a process chrooted to dir, if not empty.
+func setChroot(attr *syscall.SysProcAttr, dir string) {
+	attr.Chroot = dir
+}
+
+// chroot changes the current process's root directory.
+func chroot(dir string) error {
+	return syscall.Chroot(dir)
+}
+
+// switchUser changes the current process's groups, group and user to cred.
+func switchUser(cred *credential) error {
+	groups := make([]int, len(cred.Groups))
+	for i, g := range cred.Groups {
+		groups[i] = int(g)
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(int(cred.Gid)); err != nil {
+		return fmt.Errorf("setgid %d: %w", cred.Gid, err)
+	}
+	if err := syscall.Setuid(int(cred.Uid)); err != nil {
+		return fmt.Errorf("setuid %d: %w", cred.Uid, err)
+	}
+	return nil
+}
+
+// umask sets the current process's umask and returns the previous one.
+func umask(mask int) int {
+	return syscall.Umask(mask)
+}
+
+// closeOnExec keeps an inherited descriptor from leaking into further
+// programs.
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
+
+// processGroup returns the process group of pid.
+func processGroup(pid int) (int, error) {
+	return syscall.Getpgid(pid)
+}
+
+// kill sends sig to pid.
+func kill(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
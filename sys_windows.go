@@ -0,0 +1,86 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows has none of these signals; the values are never received, nor
+// can they be sent.
+const (
+	sigChild        syscall.Signal = -1
+	sigPreempt      syscall.Signal = -2
+	sigGoStackDump  syscall.Signal = -3
+	sigWindowChange syscall.Signal = -4
+)
+
+// alwaysSupervise makes the init supervise even when it is not PID 1: a
+// Windows process has no PID 1 to be.
+const alwaysSupervise = true
+
+// credential is the user a child would run as; Windows cannot start one
+// that way.
+type credential struct {
+	Uid, Gid uint32
+	Groups   []uint32
+}
+
+// haveCredentials reports whether children can run as another user.
+const haveCredentials = false
+
+// newSysProcAttr starts a process in its own process group, which console
+// control events can then be sent to.
+func newSysProcAttr(*credential) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+func setControllingTTY(*syscall.SysProcAttr) {}
+
+func runsAsUser(*syscall.SysProcAttr) bool { return false }
+
+func setChroot(_ *syscall.SysProcAttr, dir string) {
+	if dir != "" {
+		log.Printf("psi: chroot is not supported on Windows; ignoring %s", dir)
+	}
+}
+
+func chroot(string) error {
+	return errors.New("chroot is not supported on Windows")
+}
+
+func switchUser(*credential) error {
+	return errors.New("running as another user is not supported on Windows")
+}
+
+func umask(int) int { return 0 }
+
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(syscall.Handle(fd))
+}
+
+func processGroup(int) (int, error) {
+	return 0, errors.New("process groups are not supported on Windows")
+}
+
+// kill emulates a signal: SIGKILL terminates pid, and the terminate-like
+// signals send CTRL_BREAK to the process group it leads, which a Go program
+// receives as os.Interrupt. Others cannot be sent.
+func kill(pid int, sig syscall.Signal) error {
+	switch sig {
+	case syscall.SIGKILL:
+	case syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP:
+		return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid))
+	default:
+		return fmt.Errorf("cannot send %s on Windows", signalName(sig))
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	return windows.TerminateProcess(h, 128+uint32(syscall.SIGKILL))
+}
@@ -0,0 +1,30 @@
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestWindowsSignals(t *testing.T) {
+	for _, name := range []string{"TERM", "int", "SIGKILL", "15"} {
+		if _, err := ParseSignal(name); err != nil {
+			t.Errorf("ParseSignal(%q): %v", name, err)
+		}
+	}
+	if _, err := ParseSignal("USR1"); err == nil {
+		t.Error("ParseSignal(USR1) succeeded on Windows")
+	}
+	if err := kill(1, sigGoStackDump); err == nil {
+		t.Error("kill with an unsupported signal succeeded")
+	}
+}
+
+func TestWindowsSysProcAttr(t *testing.T) {
+	attr := newSysProcAttr(nil)
+	if attr.CreationFlags&syscall.CREATE_NEW_PROCESS_GROUP == 0 {
+		t.Fatalf("CreationFlags=%#x, want CREATE_NEW_PROCESS_GROUP", attr.CreationFlags)
+	}
+	if !newConfig().supervise() {
+		t.Fatal("supervise()=false on Windows")
+	}
+}
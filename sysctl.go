@@ -0,0 +1,84 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const sysctlEnv = "PSI_SYSCTL"
+
+// WithSysctl writes value to the kernel parameter key (e.g.
+// "net.core.somaxconn") under /proc/sys during init startup, before the
+// child starts. Namespaced parameters such as net.* only affect the
+// container. Overridden by PSI_SYSCTL, a comma-separated list of key=value
+// pairs.
+func WithSysctl(key, value string) Option {
+	return func(c *config) {
+		c.sysctls = append(c.sysctls, sysctl{key: key, value: value})
+	}
+}
+
+// sysctl is one kernel parameter assignment.
+type sysctl struct {
+	key, value string
+}
+
+// parseSysctls parses "key=value,key=value".
+func parseSysctls(s string) ([]sysctl, error) {
+	var out []sysctl
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not key=value", part)
+		}
+		out = append(out, sysctl{key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+	}
+	return out, nil
+}
+
+// sysctlPath maps a sysctl key to its file under /proc/sys.
+func sysctlPath(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid sysctl key %q", key)
+	}
+	if !strings.Contains(key, "/") {
+		key = strings.ReplaceAll(key, ".", "/")
+	}
+	return filepath.Join("/proc/sys", filepath.Clean("/"+key)), nil
+}
+
+// loadSysctlEnv applies the PSI_SYSCTL override.
+func (c *config) loadSysctlEnv() {
+	val := strings.TrimSpace(os.Getenv(sysctlEnv))
+	if val == "" {
+		return
+	}
+	sysctls, err := parseSysctls(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", sysctlEnv, val, err)
+		return
+	}
+	c.sysctls = sysctls
+}
+
+// applySysctls writes the configured kernel parameters in order.
+func (c *config) applySysctls() {
+	for _, s := range c.sysctls {
+		path, err := sysctlPath(s.key)
+		if err == nil {
+			err = os.WriteFile(path, []byte(s.value), 0)
+		}
+		if err != nil {
+			log.Printf("psi: failed to set sysctl %s=%s: %v", s.key, s.value, err)
+			continue
+		}
+		c.debugf(1, "sysctl %s=%s", s.key, s.value)
+	}
+}
@@ -0,0 +1,39 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSysctls(t *testing.T) {
+	got, err := parseSysctls("net.core.somaxconn=4096, net.ipv4.ip_unprivileged_port_start = 80,")
+	if err != nil {
+		t.Fatalf("parseSysctls: %v", err)
+	}
+	want := []sysctl{{"net.core.somaxconn", "4096"}, {"net.ipv4.ip_unprivileged_port_start", "80"}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if _, err := parseSysctls("net.core.somaxconn"); err == nil {
+		t.Fatal("expected a missing value to fail")
+	}
+}
+
+func TestSysctlPath(t *testing.T) {
+	cases := map[string]string{
+		"net.core.somaxconn":    "/proc/sys/net/core/somaxconn",
+		"net/ipv4/conf/eth0.10": "/proc/sys/net/ipv4/conf/eth0.10",
+		"kernel.shmmax":         "/proc/sys/kernel/shmmax",
+	}
+	for key, want := range cases {
+		got, err := sysctlPath(key)
+		if err != nil || got != want {
+			t.Fatalf("sysctlPath(%q) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+	for _, key := range []string{"", "../etc/passwd", "net..core"} {
+		if _, err := sysctlPath(key); err == nil {
+			t.Fatalf("sysctlPath(%q) should fail", key)
+		}
+	}
+}
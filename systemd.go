@@ -0,0 +1,105 @@
+package psi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchdogPIDEnv names the process the service manager's watchdog applies
+// to.
+const watchdogPIDEnv = "WATCHDOG_PID"
+
+// systemdNotifier reports the init's state to systemd when the init itself
+// runs as a Type=notify service, e.g. in subreaper mode outside containers.
+type systemdNotifier struct {
+	addr string
+	// watchdog is the service's WatchdogSec, zero when disabled.
+	watchdog time.Duration
+	ready    bool
+	stopping bool
+}
+
+// newSystemdNotifier takes over the sd_notify variables systemd passed to
+// the init, removing them from the environment so the child and sidecars
+// cannot report to systemd on the init's behalf.
+func newSystemdNotifier(debugf func(int, string, ...any)) *systemdNotifier {
+	n := &systemdNotifier{addr: os.Getenv(notifySocketEnv)}
+	usec := os.Getenv(watchdogUsecEnv)
+	pid := os.Getenv(watchdogPIDEnv)
+	for _, key := range []string{notifySocketEnv, watchdogUsecEnv, watchdogPIDEnv} {
+		os.Unsetenv(key)
+	}
+	if n.addr == "" || usec == "" {
+		return n
+	}
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		debugf(1, "systemd watchdog is for pid %s; ignoring", pid)
+		return n
+	}
+	us, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || us <= 0 {
+		debugf(1, "invalid %s=%q; ignoring", watchdogUsecEnv, usec)
+		return n
+	}
+	n.watchdog = time.Duration(us) * time.Microsecond
+	return n
+}
+
+// notify sends state to systemd. Errors are ignored: if systemd cannot be
+// reached there is no one to report them to.
+func (n *systemdNotifier) notify(state string) {
+	_ = sdNotify(n.addr, state)
+}
+
+// startSystemd reports to systemd if it supervises the init and sends
+// watchdog keepalives at half the watchdog period while the child runs.
+func (s *supervisor) startSystemd() {
+	s.systemd = newSystemdNotifier(s.cfg.debugf)
+	if s.systemd.addr == "" {
+		return
+	}
+	s.cfg.debugf(1, "reporting to systemd on %s", s.systemd.addr)
+	if s.systemd.watchdog <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.systemd.watchdog / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			if s.status.alive() {
+				s.systemd.notify("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+// markReady records that the child is ready and tells systemd the first
+// time.
+func (s *supervisor) markReady() {
+	s.status.childReady()
+	emit(Event{Type: EventChildReady, PID: s.childPID})
+	if s.systemd != nil && !s.systemd.ready {
+		s.systemd.ready = true
+		s.systemd.notify(fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid()))
+	}
+	s.retire()
+	s.saveState()
+}
+
+// markStopping tells systemd the init is shutting down.
+func (s *supervisor) markStopping() {
+	if s.systemd != nil && !s.systemd.stopping {
+		s.systemd.stopping = true
+		s.systemd.notify("STOPPING=1")
+	}
+}
+
+// forwardStatus passes the child's sd_notify STATUS on to systemd.
+func (s *supervisor) forwardStatus(text string) {
+	if s.systemd != nil {
+		s.systemd.notify("STATUS=" + strings.ReplaceAll(text, "\n", " "))
+	}
+}
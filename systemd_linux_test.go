@@ -0,0 +1,76 @@
+package psi
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeSystemd listens like systemd's notify socket and exports its address.
+func fakeSystemd(t *testing.T, watchdog string) <-chan notifyMsg {
+	t.Helper()
+	addr, msgs, err := listenNotify()
+	if err != nil {
+		t.Fatalf("listenNotify: %v", err)
+	}
+	t.Setenv(notifySocketEnv, addr)
+	t.Setenv(watchdogUsecEnv, watchdog)
+	t.Setenv(watchdogPIDEnv, strconv.Itoa(os.Getpid()))
+	return msgs
+}
+
+func nextState(t *testing.T, msgs <-chan notifyMsg) string {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg.state
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing sent to systemd")
+		return ""
+	}
+}
+
+func TestSystemdNotifier(t *testing.T) {
+	msgs := fakeSystemd(t, "100000")
+	s := newSupervisor(newConfig())
+	s.startSystemd()
+	for _, key := range []string{notifySocketEnv, watchdogUsecEnv, watchdogPIDEnv} {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Fatalf("%s left in the environment for the child", key)
+		}
+	}
+	if s.systemd.watchdog != 100*time.Millisecond {
+		t.Fatalf("watchdog = %s", s.systemd.watchdog)
+	}
+
+	s.status.childStarted(1234, 0, 1, time.Now())
+	if got := nextState(t, msgs); got != "WATCHDOG=1" {
+		t.Fatalf("got %q, want a keepalive while the child runs", got)
+	}
+	s.markReady()
+	s.markReady()
+	s.forwardStatus("serving\nrequests")
+	s.markStopping()
+	s.status.childExited(ExitSummary{Exited: time.Now()})
+	var got []string
+	for len(got) < 3 {
+		if state := nextState(t, msgs); state != "WATCHDOG=1" {
+			got = append(got, state)
+		}
+	}
+	want := []string{"READY=1\nMAINPID=" + strconv.Itoa(os.Getpid()), "STATUS=serving requests", "STOPPING=1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSystemdWatchdogForOtherPID(t *testing.T) {
+	fakeSystemd(t, "100000")
+	t.Setenv(watchdogPIDEnv, "1")
+	if n := newSystemdNotifier(newConfig().debugf); n.addr == "" || n.watchdog != 0 {
+		t.Fatalf("notifier = %+v; the watchdog belongs to another process", n)
+	}
+}
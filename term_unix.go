@@ -0,0 +1,69 @@
+//go:build !windows
+
+package psi
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	return fdControl(f, func(fd int) error {
+		_, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+		return err
+	}) == nil
+}
+
+// makeRaw puts the terminal f in raw mode, as cfmakeraw does, and returns
+// the function restoring its previous mode.
+func makeRaw(f *os.File) (restore func(), err error) {
+	var old *unix.Termios
+	err = fdControl(f, func(fd int) error {
+		if old, err = unix.IoctlGetTermios(fd, ioctlGetTermios); err != nil {
+			return err
+		}
+		t := *old
+		t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+		t.Oflag &^= unix.OPOST
+		t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+		t.Cflag &^= unix.CSIZE | unix.PARENB
+		t.Cflag |= unix.CS8
+		t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+		return unix.IoctlSetTermios(fd, ioctlSetTermios, &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		fdControl(f, func(fd int) error { return unix.IoctlSetTermios(fd, ioctlSetTermios, old) })
+	}, nil
+}
+
+// copyWinsize sets the window size of the terminal to to that of from.
+func copyWinsize(from, to *os.File) error {
+	var ws *unix.Winsize
+	err := fdControl(from, func(fd int) (err error) {
+		ws, err = unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return fdControl(to, func(fd int) error { return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws) })
+}
+
+// fdControl runs fn on f's descriptor without, unlike Fd, switching it to
+// blocking mode.
+func fdControl(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := rc.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
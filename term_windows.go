@@ -0,0 +1,16 @@
+package psi
+
+import (
+	"errors"
+	"os"
+)
+
+func isTerminal(*os.File) bool { return false }
+
+func makeRaw(*os.File) (func(), error) {
+	return nil, errors.New("terminal modes are not supported on Windows")
+}
+
+func copyWinsize(from, to *os.File) error {
+	return errors.New("window sizes are not supported on Windows")
+}
@@ -0,0 +1,188 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const terminationLogEnv = "PSI_TERMINATION_LOG"
+
+const (
+	// defaultTerminationLog is Kubernetes' default terminationMessagePath.
+	defaultTerminationLog = "/dev/termination-log"
+	// terminationLogMax is the size Kubernetes truncates the message to.
+	terminationLogMax = 4096
+	// stderrTailLines and stderrTailLineMax bound the child's stderr kept
+	// for the termination message.
+	stderrTailLines   = 20
+	stderrTailLineMax = 512
+)
+
+// WithTerminationLog makes the init write a short report on how the child
+// ended (exit code, signal, reason and its last stderr lines) to path when
+// it exits, so that kubectl describe pod shows why the container
+// terminated. An empty path means /dev/termination-log. Overridden by
+// PSI_TERMINATION_LOG, set to a path or to 1 for the default.
+func WithTerminationLog(path string) Option {
+	return func(c *config) {
+		if path == "" {
+			path = defaultTerminationLog
+		}
+		c.terminationLog = path
+	}
+}
+
+// loadTerminationLogEnv applies the PSI_TERMINATION_LOG override.
+func (c *config) loadTerminationLogEnv() {
+	val := strings.TrimSpace(os.Getenv(terminationLogEnv))
+	switch strings.ToLower(val) {
+	case "":
+	case "1", "true", "yes", "on":
+		c.terminationLog = defaultTerminationLog
+	case "0", "false", "no", "off":
+		c.terminationLog = ""
+	default:
+		c.terminationLog = val
+	}
+}
+
+// lineTail keeps the last lines written to it.
+type lineTail struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+}
+
+func (t *lineTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(p)
+	for len(p) > 0 {
+		i := strings.IndexByte(string(p), '\n')
+		if i < 0 {
+			t.partial = append(t.partial, p...)
+			if len(t.partial) > stderrTailLineMax {
+				t.partial = t.partial[:stderrTailLineMax]
+			}
+			break
+		}
+		line := append(t.partial, p[:i]...)
+		t.partial = nil
+		p = p[i+1:]
+		if len(line) > stderrTailLineMax {
+			line = line[:stderrTailLineMax]
+		}
+		t.lines = append(t.lines, strings.TrimSuffix(string(line), "\r"))
+		if len(t.lines) > stderrTailLines {
+			t.lines = t.lines[len(t.lines)-stderrTailLines:]
+		}
+	}
+	return n, nil
+}
+
+// last returns the kept lines, including an unterminated last one.
+func (t *lineTail) last() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := append([]string(nil), t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+	}
+	return lines
+}
+
+// terminationReason explains the init's exit code; sum is the last child's
+// exit, if any.
+func (s *supervisor) terminationReason(code int, sum *ExitSummary) string {
+	if sum == nil || code != sum.ExitCode {
+		switch code {
+		case ExitCrashLoop:
+			return "crash loop: the child kept exiting soon after starting"
+		case ExitStartTimeout:
+			return "the child exited before completing startup"
+		case ExitLivenessFailed:
+			return "the child failed its liveness check"
+		case ExitPreStartFailed:
+			return "an init task or the pre-start hook failed"
+		case ExitStopTimeout:
+			return "stopped on request; killed after ignoring the stop signal"
+		case ExitNotFound:
+			return "the child's program was not found"
+		case ExitCannotExecute:
+			return "the child's program could not be executed"
+		case ExitInternalError:
+			return "the child could not be started"
+		}
+	}
+	switch {
+	case sum == nil:
+		return fmt.Sprintf("exited with code %d", code)
+	case s.esc.started() && sum.Signal == syscall.SIGKILL:
+		return "stopped on request; killed after ignoring the stop signal"
+	case s.esc.started():
+		return "stopped on request"
+	case sum.Signal != 0:
+		return "killed by " + signalName(sum.Signal)
+	case code == 0:
+		return "completed"
+	}
+	return fmt.Sprintf("failed with exit code %d", code)
+}
+
+// terminationMessage renders the report, truncating the stderr lines from
+// the front to fit Kubernetes' limit.
+func (s *supervisor) terminationMessage(code int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "exit code: %d\n", code)
+	sum, ok := s.status.lastExitSummary()
+	if ok {
+		if sum.Signal != 0 {
+			fmt.Fprintf(&b, "signal: %s\n", signalName(sum.Signal))
+		}
+		fmt.Fprintf(&b, "reason: %s\n", s.terminationReason(code, &sum))
+		fmt.Fprintf(&b, "%s\n", sum)
+	} else {
+		fmt.Fprintf(&b, "reason: %s\n", s.terminationReason(code, nil))
+	}
+	var lines []string
+	if ok && sum.Stderr != "" {
+		// The crash buffer reaches further back than the tail.
+		lines = strings.Split(strings.TrimSuffix(sum.Stderr, "\n"), "\n")
+	} else if s.stderrTail != nil {
+		s.output.flush(time.Second)
+		lines = s.stderrTail.last()
+	}
+	if len(lines) == 0 {
+		return b.String()
+	}
+	b.WriteString("last stderr lines:\n")
+	room := terminationLogMax - b.Len()
+	var tail []string
+	for i := len(lines) - 1; i >= 0; i-- {
+		room -= len(lines[i]) + 1
+		if room < 0 {
+			break
+		}
+		tail = append([]string{lines[i]}, tail...)
+	}
+	for _, line := range tail {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// writeTerminationLog writes the termination message if enabled. Failing to
+// write it is logged and otherwise ignored.
+func (s *supervisor) writeTerminationLog(code int) {
+	if s.cfg.terminationLog == "" {
+		return
+	}
+	if err := os.WriteFile(s.cfg.terminationLog, []byte(s.terminationMessage(code)), 0o644); err != nil {
+		log.Printf("psi: cannot write termination message: %v", err)
+	}
+}
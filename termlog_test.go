@@ -0,0 +1,104 @@
+//go:build !windows
+
+package psi
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestLineTail(t *testing.T) {
+	var tail lineTail
+	fmt.Fprint(&tail, "one\r\ntw")
+	fmt.Fprint(&tail, "o\n"+strings.Repeat("x", 600)+"\nthr")
+	want := []string{"one", "two", strings.Repeat("x", stderrTailLineMax), "thr"}
+	if got := tail.last(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("last = %q, want %q", got, want)
+	}
+	for i := range 30 {
+		fmt.Fprintf(&tail, "line %d\n", i)
+	}
+	got := tail.last()
+	if len(got) != stderrTailLines || got[0] != "line 10" || got[len(got)-1] != "line 29" {
+		t.Fatalf("last = %q", got)
+	}
+}
+
+func TestTerminationReason(t *testing.T) {
+	s := newSupervisor(newConfig())
+	for _, tc := range []struct {
+		code int
+		sum  *ExitSummary
+		want string
+	}{
+		{ExitPreStartFailed, nil, "an init task or the pre-start hook failed"},
+		{ExitCrashLoop, &ExitSummary{ExitCode: 1}, "crash loop: the child kept exiting soon after starting"},
+		{ExitCrashLoop, &ExitSummary{ExitCode: ExitCrashLoop}, "failed with exit code 120"},
+		{137, &ExitSummary{ExitCode: 137, Signal: syscall.SIGKILL}, "killed by SIGKILL"},
+		{0, &ExitSummary{}, "completed"},
+		{2, &ExitSummary{ExitCode: 2}, "failed with exit code 2"},
+	} {
+		if got := s.terminationReason(tc.code, tc.sum); got != tc.want {
+			t.Errorf("terminationReason(%d, %+v) = %q, want %q", tc.code, tc.sum, got, tc.want)
+		}
+	}
+	s.esc.advance()
+	if got := s.terminationReason(143, &ExitSummary{ExitCode: 143, Signal: syscall.SIGTERM}); got != "stopped on request" {
+		t.Errorf("terminationReason after stop = %q", got)
+	}
+}
+
+func TestTerminationMessageTruncates(t *testing.T) {
+	s := newSupervisor(newConfig(WithTerminationLog("")))
+	for i := range stderrTailLines {
+		fmt.Fprintf(s.stderrTail, "%d %s\n", i, strings.Repeat("y", 400))
+	}
+	msg := s.terminationMessage(1)
+	if len(msg) > terminationLogMax {
+		t.Fatalf("message is %d bytes", len(msg))
+	}
+	if !strings.HasPrefix(msg, "exit code: 1\nreason: exited with code 1\nlast stderr lines:\n") ||
+		!strings.HasSuffix(msg, "19 "+strings.Repeat("y", 400)+"\n") {
+		t.Fatalf("message = %q", msg)
+	}
+}
+
+func TestTerminationLogEnv(t *testing.T) {
+	for val, want := range map[string]string{"1": defaultTerminationLog, "/tmp/term": "/tmp/term", "off": ""} {
+		t.Setenv(terminationLogEnv, val)
+		if c := newConfig(WithTerminationLog("/x")); c.terminationLog != want {
+			t.Errorf("%s=%q: terminationLog = %q, want %q", terminationLogEnv, val, c.terminationLog, want)
+		}
+	}
+}
+
+func TestSupervisorTerminationLog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("supervisor not available on Windows")
+	}
+	path := t.TempDir() + "/termination-log"
+	cmd := helperCommand("init-stderr", terminationLogEnv+"="+path)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if exit := exitStatus(cmd.Run()); exit != 3 {
+		t.Fatalf("expected exit code 3, got %d", exit)
+	}
+	if !strings.Contains(stderr.String(), "panic: boom\n") {
+		t.Fatalf("child stderr not forwarded: %q", stderr.String())
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b)
+	for _, want := range []string{"exit code: 3\nreason: failed with exit code 3\nchild (pid ", "last stderr lines:\nstarting\npanic: boom\n"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("termination message lacks %q:\n%s", want, msg)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const processTitleEnv = "PSI_PROCESS_TITLE"
+
+// commMax is the length limit of a process's command name (TASK_COMM_LEN
+// less the terminating NUL).
+const commMax = 15
+
+// WithProcessTitle sets the title the init shows in ps and /proc in place of
+// the argv it shares with the child, "psi[init] <child argv>" by default;
+// the child's own argv is left untouched. The title is cut to the length of
+// the original argv and the command name to 15 bytes. An empty title keeps
+// the original argv. Linux only. Overridden by PSI_PROCESS_TITLE ("off"
+// keeps the argv).
+func WithProcessTitle(title string) Option {
+	return func(c *config) {
+		c.processTitle = &title
+	}
+}
+
+// loadProcessTitleEnv applies the PSI_PROCESS_TITLE override.
+func (c *config) loadProcessTitleEnv() {
+	val, ok := os.LookupEnv(processTitleEnv)
+	if !ok {
+		return
+	}
+	val = strings.TrimSpace(val)
+	switch strings.ToLower(val) {
+	case "off", "0", "false", "no", "none":
+		val = ""
+	}
+	c.processTitle = &val
+}
+
+// initTitle returns the init's process title, empty to keep its argv, and
+// its command name: the title cut to 15 bytes, or "psi[init] <program>" with
+// the program's base name by default.
+func (c *config) initTitle() (title, comm string) {
+	if c.processTitle != nil {
+		title, comm = *c.processTitle, *c.processTitle
+	} else {
+		argv := c.command
+		if len(argv) == 0 {
+			argv = os.Args
+		}
+		title = "psi[init] " + strings.Join(argv, " ")
+		comm = "psi[init] " + filepath.Base(argv[0])
+	}
+	if len(comm) > commMax {
+		comm = comm[:commMax]
+	}
+	return title, comm
+}
+
+// applyProcessTitle sets the init's process title.
+func (c *config) applyProcessTitle() {
+	title, comm := c.initTitle()
+	if title == "" {
+		return
+	}
+	if err := setProcessTitle(title, comm); err != nil {
+		c.debugf(1, "cannot set process title: %v", err)
+	}
+}
+
+// origArgs is the original os.Args, which alias the process's argv memory.
+// setProcessTitle overwrites it, so os.Args is replaced by a copy while the
+// package is initialized, before flags or options can keep parts of it.
+var origArgs = cloneArgs()
+
+// cloneArgs replaces os.Args with a copy and returns the original.
+func cloneArgs() []string {
+	orig := os.Args
+	args := make([]string, len(orig))
+	for i, a := range orig {
+		args[i] = strings.Clone(a)
+	}
+	os.Args = args
+	return orig
+}
@@ -0,0 +1,51 @@
+package psi
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// setProcessTitle rewrites the argv area the kernel shows as
+// /proc/self/cmdline and sets the command name. The name is written to /proc/self/comm rather than set with
+// PR_SET_NAME, which only names the calling thread and thus, from Go, an
+// arbitrary one.
+func setProcessTitle(title, comm string) error {
+	start, end, err := argvArea()
+	if err != nil {
+		return err
+	}
+	if len(origArgs) == 0 || uintptr(unsafe.Pointer(unsafe.StringData(origArgs[0]))) != start {
+		return errors.New("os.Args did not come from the process's argv")
+	}
+	area := unsafe.Slice(unsafe.StringData(origArgs[0]), end-start)
+	n := copy(area[:len(area)-1], title)
+	clear(area[n:])
+	return os.WriteFile("/proc/self/comm", []byte(comm), 0)
+}
+
+// argvArea reads the bounds of the argv area (arg_start and arg_end, fields
+// 48 and 49) from /proc/self/stat.
+func argvArea() (start, end uintptr, err error) {
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	i := strings.LastIndexByte(string(b), ')')
+	if i < 0 {
+		return 0, 0, errors.New("malformed /proc/self/stat")
+	}
+	// Fields from 3 (state) on follow the name.
+	fields := strings.Fields(string(b[i+1:]))
+	if len(fields) < 49-2 {
+		return 0, 0, errors.New("/proc/self/stat lacks the argv bounds")
+	}
+	s, err1 := strconv.ParseUint(fields[48-3], 10, 64)
+	e, err2 := strconv.ParseUint(fields[49-3], 10, 64)
+	if err1 != nil || err2 != nil || e <= s {
+		return 0, 0, errors.New("malformed argv bounds in /proc/self/stat")
+	}
+	return uintptr(s), uintptr(e), nil
+}
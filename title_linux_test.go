@@ -0,0 +1,31 @@
+package psi
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSupervisorProcessTitle(t *testing.T) {
+	countFile := t.TempDir() + "/count"
+	cmd := helperCommand("init-sleep", helperCountEnv+"="+countFile)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Signal(syscall.SIGTERM)
+	waitForContent(t, countFile, "running\n")
+	proc := "/proc/" + strconv.Itoa(cmd.Process.Pid)
+	cmdline, err := os.ReadFile(proc + "/cmdline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := strings.TrimRight(string(cmdline), "\x00"); !strings.HasPrefix(title, "psi[init] /bin/sh -c echo running") {
+		t.Fatalf("cmdline = %q", cmdline)
+	}
+	if comm, _ := os.ReadFile(proc + "/comm"); string(comm) != "psi[init] sh\n" {
+		t.Fatalf("comm = %q", comm)
+	}
+}
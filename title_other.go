@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func setProcessTitle(string, string) error {
+	return errors.New("process titles are only supported on Linux")
+}
@@ -0,0 +1,45 @@
+package psi
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestInitTitle(t *testing.T) {
+	c := newConfig()
+	c.command = []string{"/app/server", "-v"}
+	if title, comm := c.initTitle(); title != "psi[init] /app/server -v" || comm != "psi[init] serve" {
+		t.Fatalf("initTitle = %q, %q", title, comm)
+	}
+	if title, _ := newConfig().initTitle(); title != "psi[init] "+strings.Join(os.Args, " ") {
+		t.Fatalf("initTitle in submain mode = %q", title)
+	}
+	if title, comm := newConfig(WithProcessTitle("my very long init title")).initTitle(); title != "my very long init title" || comm != "my very long in" {
+		t.Fatalf("custom initTitle = %q, %q", title, comm)
+	}
+	if title, _ := newConfig(WithProcessTitle("")).initTitle(); title != "" {
+		t.Fatalf("initTitle with an empty title = %q", title)
+	}
+}
+
+func TestProcessTitleEnv(t *testing.T) {
+	for val, want := range map[string]string{"off": "", "my init": "my init"} {
+		t.Setenv(processTitleEnv, val)
+		if got, _ := newConfig(WithProcessTitle("x")).initTitle(); got != want {
+			t.Errorf("%s=%q: initTitle = %q, want %q", processTitleEnv, val, got, want)
+		}
+	}
+}
+
+func TestArgsCloned(t *testing.T) {
+	if len(origArgs) != len(os.Args) {
+		t.Fatalf("origArgs = %q, os.Args = %q", origArgs, os.Args)
+	}
+	for i := range os.Args {
+		if os.Args[i] != origArgs[i] || (os.Args[i] != "" && unsafe.StringData(os.Args[i]) == unsafe.StringData(origArgs[i])) {
+			t.Fatalf("os.Args[%d] is not a copy", i)
+		}
+	}
+}
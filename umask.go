@@ -0,0 +1,84 @@
+package psi
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const umaskEnv = "PSI_UMASK"
+
+// WithUmask sets the child's file mode creation mask, e.g. 0o027. It is
+// applied before submain runs, or before exec in command mode. Overridden by
+// PSI_UMASK (octal, e.g. "0027").
+func WithUmask(mask int) Option {
+	return func(c *config) {
+		c.umask = &mask
+	}
+}
+
+// parseUmask parses an octal umask.
+func parseUmask(s string) (int, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not an octal number")
+	}
+	if n > 0o777 {
+		return 0, fmt.Errorf("%#o out of range", n)
+	}
+	return int(n), nil
+}
+
+// loadUmaskEnv applies the PSI_UMASK override.
+func (c *config) loadUmaskEnv() {
+	val := strings.TrimSpace(os.Getenv(umaskEnv))
+	if val == "" {
+		return
+	}
+	mask, err := parseUmask(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", umaskEnv, val, err)
+		return
+	}
+	c.umask = &mask
+}
+
+// forkUmask reports whether the child's umask must be set for its fork: an
+// external program cannot set its own, a Go submain does.
+func (c *config) forkUmask() bool {
+	return c.umask != nil && len(c.command) > 0
+}
+
+// setForkUmask applies the configured umask to the calling thread, which
+// must be locked and about to fork the child, after giving the thread its
+// own copy of the process's filesystem attributes so the init's other
+// threads keep the init's umask.
+func (c *config) setForkUmask() error {
+	if err := unshareFS(); err != nil {
+		return fmt.Errorf("unsharing filesystem attributes: %w", err)
+	}
+	umask(*c.umask)
+	c.debugf(1, "umask %04o", *c.umask)
+	return nil
+}
+
+// umaskString formats the configured umask for the banner.
+func (c *config) umaskString() string {
+	if c.umask == nil {
+		return "inherited"
+	}
+	return fmt.Sprintf("%04o", *c.umask)
+}
+
+// setUmask applies the configured umask to the current process and returns
+// a function restoring the previous one.
+func (c *config) setUmask() func() {
+	if c.umask == nil {
+		return func() {}
+	}
+	old := umask(*c.umask)
+	c.debugf(1, "umask %04o", *c.umask)
+	return func() { umask(old) }
+}
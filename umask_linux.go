@@ -0,0 +1,12 @@
+package psi
+
+import "golang.org/x/sys/unix"
+
+// threadUmask reports whether a thread can have a umask of its own.
+const threadUmask = true
+
+// unshareFS gives the calling thread its own umask, root and working
+// directory.
+func unshareFS() error {
+	return unix.Unshare(unix.CLONE_FS)
+}
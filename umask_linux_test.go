@@ -0,0 +1,31 @@
+package psi
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestForkUmask(t *testing.T) {
+	old := syscall.Umask(0o002)
+	defer syscall.Umask(old)
+	mask := 0o077
+	c := &config{umask: &mask, command: []string{"sh"}}
+	cmd := exec.Command("sh", "-c", "umask")
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if err := c.startChildProcess(cmd.SysProcAttr, cmd.Start); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "0077" {
+		t.Fatalf("child umask = %s, want 0077", got)
+	}
+	if got := syscall.Umask(0o002); got != 0o002 {
+		t.Fatalf("init's umask changed to %#o", got)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+const threadUmask = false
+
+func unshareFS() error {
+	return errors.New("per-thread umask is only supported on Linux")
+}
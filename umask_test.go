@@ -0,0 +1,37 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseUmask(t *testing.T) {
+	cases := map[string]int{"0027": 0o027, "77": 0o077, " 0 ": 0, "0777": 0o777}
+	for input, want := range cases {
+		if got, err := parseUmask(input); err != nil || got != want {
+			t.Fatalf("parseUmask(%q) = %#o, %v; want %#o", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "8", "1000", "rwx"} {
+		if _, err := parseUmask(input); err == nil {
+			t.Fatalf("parseUmask(%q) should fail", input)
+		}
+	}
+}
+
+func TestSetUmask(t *testing.T) {
+	t.Setenv(umaskEnv, "0077")
+	cfg := newConfig(WithUmask(0o022))
+	old := syscall.Umask(0o002)
+	defer syscall.Umask(old)
+	restore := cfg.setUmask()
+	if got := syscall.Umask(0o077); got != 0o077 {
+		t.Fatalf("umask = %#o; want 0077 from the env", got)
+	}
+	restore()
+	if got := syscall.Umask(0o002); got != 0o002 {
+		t.Fatalf("restored umask = %#o; want 0002", got)
+	}
+}
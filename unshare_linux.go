@@ -0,0 +1,46 @@
+package psi
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// runInPIDNamespace re-execs the current program as PID 1 of a new PID
+// namespace and relays signals and the exit code between the caller and that
+// namespace's init. When creating the namespace is not permitted (rootless),
+// it retries inside a new user namespace mapping the caller to root. It never
+// returns.
+func runInPIDNamespace() {
+	cmd := exec.Command(procSelfExe, os.Args[1:]...)
+	cmd.Args[0] = os.Args[0]
+	cmd.Env = append(os.Environ(), unsharedEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWPID}
+	err := cmd.Start()
+	if errors.Is(err, syscall.EPERM) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  syscall.CLONE_NEWPID | syscall.CLONE_NEWUSER,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		}
+		err = cmd.Start()
+	}
+	if err != nil {
+		fatalf("failed to start in new PID namespace: %v", err)
+	}
+	sigs := make(chan os.Signal, 64)
+	signal.Notify(sigs)
+	go func() {
+		for s := range sigs {
+			if sig, ok := toSyscallSignal(s); ok && sig != sigChild {
+				_ = cmd.Process.Signal(sig)
+			}
+		}
+	}()
+	_ = cmd.Wait()
+	ws, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	os.Exit(exitCode(ws))
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package psi
+
+func runInPIDNamespace() {
+	fatalf("%s is only supported on Linux", unsharePIDEnv)
+}
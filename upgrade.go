@@ -0,0 +1,250 @@
+package psi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const upgradeSignalEnv = "PSI_UPGRADE_SIGNAL"
+
+// WithUpgradeSignal makes sig (e.g. SIGUSR2) upgrade the child without
+// downtime instead of being forwarded: the init starts a new child
+// generation alongside the running one, passing it the same listen sockets
+// (see WithListen), waits for it to become ready (see WithReadyNotify and
+// WithStartTimeout) and then stops the previous generation with the stop
+// signal, killing it if it outlives PSI_STOP_TIMEOUT. Both generations
+// accept connections on the shared sockets while they overlap. If the new
+// child exits before it is ready, the previous one keeps running. Overridden
+// by PSI_UPGRADE_SIGNAL.
+func WithUpgradeSignal(sig syscall.Signal) Option {
+	return func(c *config) {
+		c.upgradeSignal = sig
+	}
+}
+
+// loadUpgradeEnv applies the PSI_UPGRADE_SIGNAL override.
+func (c *config) loadUpgradeEnv() {
+	val := strings.TrimSpace(os.Getenv(upgradeSignalEnv))
+	if val == "" {
+		return
+	}
+	sig, err := ParseSignal(val)
+	if err != nil {
+		log.Printf("psi: invalid %s=%q: %v; ignoring", upgradeSignalEnv, val, err)
+		return
+	}
+	c.upgradeSignal = sig
+}
+
+// Supervisor controls a running init from within it. See WithSupervisor.
+type Supervisor struct {
+	s *supervisor
+}
+
+// WithSupervisor calls fn in the init, not in the child, with a handle on
+// the supervisor once the sidecars have been started and right before the
+// first child generation. fn must not block; start a goroutine to use the
+// handle later, e.g. to upgrade the child when a new release is deployed.
+func WithSupervisor(fn func(*Supervisor)) Option {
+	return func(c *config) {
+		c.onSupervise = fn
+	}
+}
+
+// upgradeRequest asks the supervisor loop to upgrade the child; the outcome
+// is sent on result.
+type upgradeRequest struct {
+	ctx    context.Context
+	result chan error
+}
+
+// Upgrade replaces the child like the upgrade signal (see
+// WithUpgradeSignal): it starts a new generation on the same listen
+// sockets, waits for it to become ready and stops the previous generation
+// within PSI_STOP_TIMEOUT. It returns once the previous generation has
+// exited, or an error if the upgrade could not start or the new child exited
+// before becoming ready, in which case the previous generation keeps
+// running. If ctx is done before the new child is ready, the new child is
+// killed and the previous generation kept; Upgrade returns ctx.Err() as soon
+// as ctx is done. The /status endpoint reports the active generation.
+func (sup *Supervisor) Upgrade(ctx context.Context) error {
+	req := upgradeRequest{ctx: ctx, result: make(chan error, 1)}
+	select {
+	case sup.s.upgrades <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// generation is a child generation being replaced by an upgrade, kept with
+// the state needed to fall back to it.
+type generation struct {
+	child   *procHandle
+	pid     int
+	done    <-chan int
+	started time.Time
+	ready   bool
+	// stopping is set once the generation has been sent the stop signal.
+	stopping bool
+}
+
+// requestUpgrade handles an Upgrade call.
+func (s *supervisor) requestUpgrade(req upgradeRequest) {
+	if err := s.upgrade(); err != nil {
+		req.result <- err
+		return
+	}
+	if s.retiring == nil {
+		// The previous generation was stopped and reaped already.
+		req.result <- nil
+		return
+	}
+	s.upgradeResult, s.upgradeCancel = req.result, req.ctx.Done()
+}
+
+// upgrade starts a new child generation next to the running one. The
+// previous generation is stopped once the new one is ready.
+func (s *supervisor) upgrade() error {
+	switch {
+	case s.esc.started():
+		return errors.New("psi: shutting down")
+	case s.retiring != nil:
+		return errors.New("psi: upgrade already in progress")
+	case s.starting || (s.cfg.readyNotify && !s.ready) || s.unhealthy:
+		return fmt.Errorf("psi: child (pid %d) is not ready", s.childPID)
+	}
+	log.Printf("psi: upgrading: starting a new child to replace pid %d", s.childPID)
+	s.retiring = &generation{child: s.child, pid: s.childPID, done: s.done, started: s.started, ready: s.ready}
+	s.retiringDone = s.done
+	if err := s.startChild(); err != nil {
+		s.restoreGeneration()
+		return fmt.Errorf("psi: upgrade failed: %w", err)
+	}
+	return nil
+}
+
+// finishUpgrade reports the outcome of the pending Upgrade call, if any.
+func (s *supervisor) finishUpgrade(err error) {
+	if s.upgradeResult != nil {
+		s.upgradeResult <- err
+	}
+	s.upgradeResult, s.upgradeCancel = nil, nil
+}
+
+// cancelUpgrade kills the new child of an upgrade whose caller gave up
+// before it became ready.
+func (s *supervisor) cancelUpgrade() {
+	s.upgradeCancel = nil
+	if s.retiring == nil || s.retiring.stopping {
+		return
+	}
+	log.Printf("psi: upgrade cancelled; killing child (pid %d)", s.childPID)
+	s.signalChild(syscall.SIGKILL)
+}
+
+// retire sends the previous generation, if any, the stop signal once its
+// replacement is ready and arms the timer that kills it.
+func (s *supervisor) retire() {
+	prev := s.retiring
+	if prev == nil || prev.stopping || s.done == prev.done {
+		return
+	}
+	prev.stopping = true
+	s.status.childStarted(s.childPID, s.restarts, s.generation, s.started)
+	s.status.childReady()
+	log.Printf("psi: child (pid %d) is ready; stopping previous generation (pid %d)", s.childPID, prev.pid)
+	_ = prev.child.signalGroup(s.cfg.forwardSignal(syscall.SIGTERM))
+	s.retiringKill = time.After(s.stopTimeout)
+	tracef("kill timer armed for %s (pid %d)", s.stopTimeout, prev.pid)
+}
+
+// retired handles the exit of the previous generation.
+func (s *supervisor) retired(code int) {
+	prev := s.retiring
+	prev.child.close()
+	s.retiring, s.retiringDone, s.retiringKill = nil, nil, nil
+	if prev.stopping {
+		s.cfg.debugf(1, "previous generation (pid %d) exited with code %d", prev.pid, code)
+		s.finishUpgrade(nil)
+		return
+	}
+	log.Printf("psi: previous generation (pid %d) exited with code %d during the upgrade", prev.pid, code)
+}
+
+// retiringKillDue kills a previous generation that outlived the stop timeout.
+func (s *supervisor) retiringKillDue() {
+	s.retiringKill = nil
+	log.Printf("psi: previous generation (pid %d) did not stop within %s; killing", s.retiring.pid, s.stopTimeout)
+	_ = s.retiring.child.signalGroup(syscall.SIGKILL)
+}
+
+// upgradeFailed falls back to the previous generation when the new child
+// exited with code before becoming ready. It reports whether it did.
+func (s *supervisor) upgradeFailed(code int) bool {
+	if s.retiring == nil || s.retiring.stopping || s.esc.started() {
+		return false
+	}
+	log.Printf("psi: upgrade failed: child (pid %d) exited with code %d before becoming ready; keeping pid %d", s.childPID, code, s.retiring.pid)
+	s.child.close()
+	s.restoreGeneration()
+	s.status.upgrading(0)
+	s.saveState()
+	s.beginProbes()
+	s.beginWatchdog()
+	s.finishUpgrade(fmt.Errorf("psi: upgrade failed: new child exited with code %d before becoming ready", code))
+	return true
+}
+
+// restoreGeneration makes the previous generation current again.
+func (s *supervisor) restoreGeneration() {
+	prev := s.retiring
+	s.child, s.childPID, s.done, s.started = prev.child, prev.pid, prev.done, prev.started
+	s.ready, s.readyc = prev.ready, nil
+	s.starting, s.startDeadline = false, nil
+	s.retiring, s.retiringDone, s.retiringKill = nil, nil, nil
+}
+
+// awaitRetiring stops and reaps a previous generation still running when
+// the current child exits.
+func (s *supervisor) awaitRetiring() {
+	if s.retiring == nil {
+		return
+	}
+	if !s.retiring.stopping {
+		s.finishUpgrade(errors.New("psi: upgrade failed: new child exited"))
+		s.retiring.stopping = true
+		_ = s.retiring.child.signalGroup(s.cfg.forwardSignal(syscall.SIGTERM))
+		s.retiringKill = time.After(s.stopTimeout)
+		tracef("kill timer armed for %s (pid %d)", s.stopTimeout, s.retiring.pid)
+	}
+	for s.retiring != nil {
+		select {
+		case code := <-s.retiringDone:
+			s.retired(code)
+		case <-s.retiringKill:
+			s.retiringKillDue()
+		}
+	}
+}
+
+// signalAll sends sig to the child's process group and to the previous
+// generation's during an upgrade.
+func (s *supervisor) signalAll(sig syscall.Signal) {
+	s.signalChild(sig)
+	if s.retiring != nil {
+		emit(Event{Type: EventSignalForwarded, PID: s.retiring.pid, Signal: sig})
+		_ = s.retiring.child.signalGroup(sig)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package psi
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestLoadUpgradeEnv(t *testing.T) {
+	t.Setenv(upgradeSignalEnv, "USR2")
+	c := &config{}
+	c.loadUpgradeEnv()
+	if c.upgradeSignal != syscall.SIGUSR2 {
+		t.Fatalf("expected SIGUSR2, got %v", c.upgradeSignal)
+	}
+	t.Setenv(upgradeSignalEnv, "bogus")
+	c = &config{upgradeSignal: syscall.SIGUSR1}
+	c.loadUpgradeEnv()
+	if c.upgradeSignal != syscall.SIGUSR1 {
+		t.Fatalf("expected invalid value to be ignored, got %v", c.upgradeSignal)
+	}
+}
+
+func TestWithUpgradeSignal(t *testing.T) {
+	c := newConfig(WithUpgradeSignal(syscall.SIGUSR2))
+	if c.upgradeSignal != syscall.SIGUSR2 {
+		t.Fatalf("expected SIGUSR2, got %v", c.upgradeSignal)
+	}
+}
@@ -0,0 +1,152 @@
+package psi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const (
+	userEnv = "PSI_USER"
+	uidEnv  = "PSI_UID"
+	gidEnv  = "PSI_GID"
+)
+
+// WithUser runs the child as spec, given as "user", "user:group", "uid" or
+// "uid:gid". Names are resolved through /etc/passwd and /etc/group, which
+// also supply the supplementary groups; numeric IDs missing from them (e.g.
+// in scratch images) are used as-is, with the group defaulting to the UID.
+// Overridden by PSI_USER, PSI_UID and PSI_GID.
+func WithUser(spec string) Option {
+	return func(c *config) {
+		c.user = spec
+	}
+}
+
+// loadUserEnv applies the PSI_USER, PSI_UID and PSI_GID overrides.
+func (c *config) loadUserEnv() {
+	if val := strings.TrimSpace(os.Getenv(userEnv)); val != "" {
+		c.user = val
+	}
+	for key, dst := range map[string]**uint32{uidEnv: &c.uid, gidEnv: &c.gid} {
+		val := strings.TrimSpace(os.Getenv(key))
+		if val == "" {
+			continue
+		}
+		id, err := parseID(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", key, val, err)
+			continue
+		}
+		*dst = &id
+	}
+}
+
+func parseID(s string) (uint32, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", s)
+	}
+	return uint32(n), nil
+}
+
+// credential resolves the configured user into the child's credentials. It
+// returns nil when no user is configured.
+func (c *config) credential() (*credential, error) {
+	if c.user == "" && c.uid == nil && c.gid == nil {
+		return nil, nil
+	}
+	if !haveCredentials {
+		return nil, errors.New("running as another user is not supported on this platform")
+	}
+	name, group, hasGroup := strings.Cut(c.user, ":")
+	if c.uid != nil {
+		name = strconv.FormatUint(uint64(*c.uid), 10)
+	}
+	cred := &credential{Groups: []uint32{}}
+	if name == "" {
+		cred.Uid = uint32(os.Getuid())
+		cred.Gid = uint32(os.Getgid())
+	} else if err := lookupUser(name, cred); err != nil {
+		return nil, err
+	}
+	if hasGroup && group != "" {
+		gid, err := lookupGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		cred.Gid = gid
+	}
+	if c.gid != nil {
+		cred.Gid = *c.gid
+	}
+	return cred, nil
+}
+
+// lookupUser fills cred from the passwd entry for name (a user name or UID),
+// falling back to a bare numeric UID with a matching GID.
+func lookupUser(name string, cred *credential) error {
+	var u *user.User
+	var err error
+	if _, numErr := parseID(name); numErr == nil {
+		u, err = user.LookupId(name)
+	} else {
+		u, err = user.Lookup(name)
+	}
+	if err != nil {
+		id, numErr := parseID(name)
+		if numErr != nil {
+			return fmt.Errorf("user %q: %w", name, err)
+		}
+		cred.Uid, cred.Gid = id, id
+		return nil
+	}
+	uid, err := parseID(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", name, err)
+	}
+	gid, err := parseID(u.Gid)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", name, err)
+	}
+	cred.Uid, cred.Gid = uid, gid
+	if ids, err := u.GroupIds(); err == nil {
+		for _, s := range ids {
+			if id, err := parseID(s); err == nil && id != gid {
+				cred.Groups = append(cred.Groups, id)
+			}
+		}
+	}
+	return nil
+}
+
+// lookupGroup resolves a group name or GID.
+func lookupGroup(name string) (uint32, error) {
+	if id, err := parseID(name); err == nil {
+		return id, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, fmt.Errorf("group %q: %w", name, err)
+	}
+	return parseID(g.Gid)
+}
+
+// dropPrivileges switches the current process to the configured user. It is
+// used when there is no separate child, i.e. right before exec or submain.
+func (c *config) dropPrivileges() {
+	cred, err := c.credential()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if cred == nil {
+		return
+	}
+	if err := switchUser(cred); err != nil {
+		fatalf("%v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package psi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCredentialNumericFallback(t *testing.T) {
+	cfg := &config{user: "54321"}
+	cred, err := cfg.credential()
+	if err != nil {
+		t.Fatalf("credential: %v", err)
+	}
+	if cred.Uid != 54321 || cred.Gid != 54321 || len(cred.Groups) != 0 {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+	cfg = &config{user: "54321:100"}
+	if cred, err = cfg.credential(); err != nil || cred.Gid != 100 {
+		t.Fatalf("expected gid 100, got %+v, %v", cred, err)
+	}
+}
+
+func TestCredentialLookup(t *testing.T) {
+	cfg := &config{user: "root"}
+	cred, err := cfg.credential()
+	if err != nil {
+		t.Skipf("no passwd entry for root: %v", err)
+	}
+	if cred.Uid != 0 || cred.Gid != 0 {
+		t.Fatalf("expected root to resolve to 0:0, got %+v", cred)
+	}
+	if _, err := (&config{user: "no-such-user-psi"}).credential(); err == nil {
+		t.Fatal("expected unknown user name to fail")
+	}
+	if _, err := (&config{user: "54321:no-such-group-psi"}).credential(); err == nil {
+		t.Fatal("expected unknown group name to fail")
+	}
+}
+
+func TestCredentialNone(t *testing.T) {
+	if cred, err := (&config{}).credential(); cred != nil || err != nil {
+		t.Fatalf("expected no credential, got %+v, %v", cred, err)
+	}
+}
+
+func TestUserEnv(t *testing.T) {
+	t.Setenv(userEnv, "54321:54321")
+	t.Setenv(gidEnv, "1000")
+	cfg := newConfig(WithUser("nobody"))
+	cred, err := cfg.credential()
+	if err != nil {
+		t.Fatalf("credential: %v", err)
+	}
+	if cred.Uid != 54321 || cred.Gid != 1000 || !slices.Equal(cred.Groups, []uint32{}) {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+}
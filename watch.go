@@ -0,0 +1,101 @@
+package psi
+
+import (
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	watchFilesEnv  = "PSI_WATCH_FILES"
+	watchSignalEnv = "PSI_WATCH_SIGNAL"
+)
+
+// watchQuiet is how long watched paths must stay unchanged before the reload
+// signal is sent, so a burst of events (an editor's save, a Kubernetes
+// secret's symlink swap) results in a single reload.
+const watchQuiet = 250 * time.Millisecond
+
+// WithWatchFiles makes the init watch paths and send sig (SIGHUP when 0) to
+// the child's process group when any of them changes, e.g. to pick up rotated
+// certificates. A directory is watched as a whole; a file is watched through
+// its parent directory, so it may be replaced or created later. Kubernetes'
+// atomic updates of mounted secrets and ConfigMaps are detected. Watching only
+// happens while supervising and only on Linux. Overridden by PSI_WATCH_FILES
+// (comma-separated paths) and PSI_WATCH_SIGNAL.
+func WithWatchFiles(sig syscall.Signal, paths ...string) Option {
+	return func(c *config) {
+		c.watchFiles = append([]string(nil), paths...)
+		c.watchSignal = sig
+	}
+}
+
+// loadWatchEnv applies the PSI_WATCH_FILES and PSI_WATCH_SIGNAL overrides.
+func (c *config) loadWatchEnv() {
+	if paths := splitPaths(os.Getenv(watchFilesEnv)); len(paths) > 0 {
+		c.watchFiles = paths
+	}
+	if val := strings.TrimSpace(os.Getenv(watchSignalEnv)); val != "" {
+		sig, err := ParseSignal(val)
+		if err != nil {
+			log.Printf("psi: invalid %s=%q: %v; ignoring", watchSignalEnv, val, err)
+		} else {
+			c.watchSignal = sig
+		}
+	}
+}
+
+// reloadSignal returns the signal sent to the child when a watched path
+// changes.
+func (c *config) reloadSignal() syscall.Signal {
+	if c.watchSignal == 0 {
+		return syscall.SIGHUP
+	}
+	return c.watchSignal
+}
+
+// startWatcher starts watching the configured paths. The returned channel
+// yields once per settled burst of changes; it is nil when nothing is watched
+// or watching failed.
+func (c *config) startWatcher() <-chan struct{} {
+	if len(c.watchFiles) == 0 {
+		return nil
+	}
+	events := make(chan struct{}, 1)
+	err := watchPaths(c.watchFiles, func() {
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Printf("psi: watching files disabled: %v", err)
+		return nil
+	}
+	c.debugf(1, "watching %s", strings.Join(c.watchFiles, ", "))
+	reload := make(chan struct{}, 1)
+	go debounce(events, reload, watchQuiet)
+	return reload
+}
+
+// debounce forwards a value to out once in has been quiet for the given
+// duration after a value arrived.
+func debounce(in <-chan struct{}, out chan<- struct{}, quiet time.Duration) {
+	for range in {
+		t := time.NewTimer(quiet)
+		for settled := false; !settled; {
+			select {
+			case <-in:
+				t.Reset(quiet)
+			case <-t.C:
+				settled = true
+			}
+		}
+		select {
+		case out <- struct{}{}:
+		default:
+		}
+	}
+}
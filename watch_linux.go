@@ -0,0 +1,84 @@
+package psi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchMask selects the inotify events that mean a path's content changed.
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CREATE |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// k8sDataLink is the symlink Kubernetes swaps atomically when it updates a
+// mounted secret or ConfigMap; the visible files point through it.
+const k8sDataLink = "..data"
+
+// watchPaths watches paths with inotify and calls changed from a background
+// goroutine whenever one of them may have changed.
+func watchPaths(paths []string, changed func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify_init1: %w", err)
+	}
+	// names maps a watch to the entry names it cares about; "" means any.
+	names := make(map[int32][]string)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		dir, name := path, ""
+		if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+			dir, name = filepath.Dir(path), filepath.Base(path)
+		}
+		wd, err := unix.InotifyAddWatch(fd, dir, watchMask)
+		if err != nil {
+			unix.Close(fd)
+			return fmt.Errorf("watching %s: %w", dir, err)
+		}
+		names[int32(wd)] = append(names[int32(wd)], name)
+		if name != "" {
+			names[int32(wd)] = append(names[int32(wd)], k8sDataLink)
+		}
+	}
+	go readInotify(fd, names, changed)
+	return nil
+}
+
+// readInotify reads events from fd and calls changed for those matching
+// names.
+func readInotify(fd int, names map[int32][]string, changed func()) {
+	defer unix.Close(fd)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := unix.Read(fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(ev.Len)], "\x00"))
+			off = nameStart + int(ev.Len)
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 || watchMatches(names[ev.Wd], name) {
+				changed()
+			}
+		}
+	}
+}
+
+// watchMatches reports whether an event for the entry name is of interest to
+// a watch caring about wanted.
+func watchMatches(wanted []string, name string) bool {
+	for _, w := range wanted {
+		if w == "" || w == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package psi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitChanged(t *testing.T, changed <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no change reported after %s", what)
+	}
+	// Drain the rest of the burst.
+	for {
+		select {
+		case <-changed:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestWatchPathsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	changed := make(chan struct{}, 16)
+	if err := watchPaths([]string{path}, func() { changed <- struct{}{} }); err != nil {
+		t.Fatalf("watchPaths: %v", err)
+	}
+	// Unrelated files in the same directory are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatal("change reported for an unwatched file")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// The watched file may be created after the watch starts.
+	if err := os.WriteFile(path, []byte("a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitChanged(t, changed, "creating the file")
+	// Editors often save by renaming a temporary file over the original.
+	tmp := filepath.Join(dir, ".config.yaml.swp")
+	if err := os.WriteFile(tmp, []byte("b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitChanged(t, changed, "replacing the file")
+}
+
+func TestWatchPathsKubernetesSecret(t *testing.T) {
+	// A projected volume: tls.crt -> ..data/tls.crt, ..data -> ..<timestamp>.
+	dir := t.TempDir()
+	writeGen := func(gen, content string) {
+		if err := os.Mkdir(filepath.Join(dir, gen), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, gen, "tls.crt"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		tmp := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(gen, tmp); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeGen("..1", "old")
+	if err := os.Symlink(filepath.Join("..data", "tls.crt"), filepath.Join(dir, "tls.crt")); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "tls.crt")} {
+		changed := make(chan struct{}, 16)
+		if err := watchPaths([]string{path}, func() { changed <- struct{}{} }); err != nil {
+			t.Fatalf("watchPaths: %v", err)
+		}
+		writeGen("..2"+filepath.Base(path), "new")
+		waitChanged(t, changed, "swapping ..data while watching "+path)
+	}
+}
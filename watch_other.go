@@ -0,0 +1,9 @@
+//go:build !linux
+
+package psi
+
+import "errors"
+
+func watchPaths([]string, func()) error {
+	return errors.New("file watching is only supported on Linux")
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+
+package psi
+
+import (
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchEnv(t *testing.T) {
+	cfg := newConfig()
+	if cfg.startWatcher() != nil {
+		t.Fatal("watcher started without watched files")
+	}
+	if cfg.reloadSignal() != syscall.SIGHUP {
+		t.Fatalf("default reload signal = %v, want SIGHUP", cfg.reloadSignal())
+	}
+	t.Setenv(watchFilesEnv, "/etc/app/config.yaml, /run/secrets/tls")
+	t.Setenv(watchSignalEnv, "USR1")
+	cfg = newConfig(WithWatchFiles(syscall.SIGUSR2, "/other"))
+	if want := []string{"/etc/app/config.yaml", "/run/secrets/tls"}; !slices.Equal(cfg.watchFiles, want) {
+		t.Fatalf("watchFiles = %q, want %q", cfg.watchFiles, want)
+	}
+	if cfg.reloadSignal() != syscall.SIGUSR1 {
+		t.Fatalf("reload signal = %v, want SIGUSR1", cfg.reloadSignal())
+	}
+	t.Setenv(watchSignalEnv, "bogus")
+	if cfg = newConfig(WithWatchFiles(syscall.SIGUSR2)); cfg.reloadSignal() != syscall.SIGUSR2 {
+		t.Fatalf("invalid PSI_WATCH_SIGNAL should be ignored, got %v", cfg.reloadSignal())
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	in := make(chan struct{})
+	out := make(chan struct{}, 1)
+	go debounce(in, out, 50*time.Millisecond)
+	defer close(in)
+	for range 5 {
+		in <- struct{}{}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-out:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event after the burst settled")
+	}
+	select {
+	case <-out:
+		t.Fatal("a single burst produced more than one event")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
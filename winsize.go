@@ -0,0 +1,48 @@
+package psi
+
+import (
+	"os"
+	"os/exec"
+)
+
+// initTerminal returns the first of the init's stdin, stdout and stderr
+// that is a terminal, nil if none is.
+func initTerminal() *os.File {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if isTerminal(f) {
+			return f
+		}
+	}
+	return nil
+}
+
+// childTerminals returns those of cmd's stdin and stdout that are
+// terminals.
+func childTerminals(cmd *exec.Cmd) []*os.File {
+	var ttys []*os.File
+	for _, s := range []any{cmd.Stdin, cmd.Stdout} {
+		if f, ok := s.(*os.File); ok && isTerminal(f) {
+			ttys = append(ttys, f)
+		}
+	}
+	return ttys
+}
+
+// syncWindowSize copies the window size of the init's terminal to the
+// child's, which, in a process group of its own, is not sent SIGWINCH by
+// the kernel and may not even share the init's terminal: a full-screen
+// program then finds the size it is told of by the forwarded SIGWINCH.
+func (s *supervisor) syncWindowSize() {
+	if len(s.childTerminals) == 0 {
+		return
+	}
+	from := initTerminal()
+	if from == nil {
+		return
+	}
+	for _, to := range s.childTerminals {
+		if err := copyWinsize(from, to); err != nil {
+			tracef("copying the window size: %v", err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package psi
+
+import (
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSupervisorSyncsWindowSize(t *testing.T) {
+	masterIn, stdin := openTestPTY(t, 30, 100)
+	_, stdout := openTestPTY(t, 0, 0)
+	countFile := t.TempDir() + "/size"
+	cmd := helperCommand("init-winsize", helperCountEnv+"="+countFile)
+	cmd.Stdin, cmd.Stdout = stdin, stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	waitForContent(t, countFile, "30 100\n")
+
+	err := fdControl(masterIn, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: 40, Col: 120})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Process.Signal(syscall.SIGWINCH); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, countFile+".winch", "40 120\n")
+	cmd.Process.Signal(syscall.SIGTERM)
+	cmd.Wait()
+}
@@ -0,0 +1,251 @@
+package psi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a significant line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseYAML parses the subset of YAML used by configuration files: block
+// mappings and sequences, flow sequences of scalars, plain and quoted scalars
+// and comments. Scalars are returned as strings, mappings as map[string]any
+// and sequences as []any.
+func parseYAML(data string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(data, "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		indent := len(raw) - len(text)
+		text = stripYAMLComment(text)
+		if text == "" || text == "---" || text == "..." {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: indent, text: text})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a trailing comment outside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence starting at the current line, whose
+// entries are indented by indent.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isYAMLItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if isYAMLItem(line.text) {
+			return nil, fmt.Errorf("line %d: sequence item inside a mapping", line.num)
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		var err error
+		if m[key], err = p.value(line, indent, rest); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	var seq []any
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isYAMLItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		item := strings.TrimLeft(line.text[1:], " ")
+		if _, _, ok := splitYAMLKey(item); ok && !strings.HasPrefix(item, "[") {
+			// "- key: value" starts a mapping indented like its first key.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: line.indent + len(line.text) - len(item), text: item}
+			m, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, m)
+			continue
+		}
+		p.pos++
+		v, err := p.value(line, indent, item)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+	return seq, nil
+}
+
+// value parses the value following a key or sequence dash on line: rest when
+// it is inline, otherwise the nested block on the following lines.
+func (p *yamlParser) value(line yamlLine, indent int, rest string) (any, error) {
+	if rest != "" {
+		v, err := parseYAMLFlow(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.num, err)
+		}
+		return v, nil
+	}
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		// A sequence may sit at its key's indentation.
+		if next.indent > indent || (next.indent == indent && isYAMLItem(next.text) && !isYAMLItem(line.text)) {
+			return p.block(next.indent)
+		}
+	}
+	return "", nil
+}
+
+func isYAMLItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// splitYAMLKey splits "key: value" or "key:".
+func splitYAMLKey(s string) (key, rest string, ok bool) {
+	if s == "" || s[0] == '"' || s[0] == '\'' {
+		return "", "", false
+	}
+	if strings.HasSuffix(s, ":") {
+		key = s[:len(s)-1]
+	} else if i := strings.Index(s, ": "); i >= 0 {
+		key, rest = s[:i], strings.TrimSpace(s[i+2:])
+	} else {
+		return "", "", false
+	}
+	key = strings.TrimSpace(key)
+	return key, rest, key != "" && !strings.ContainsAny(key, " ")
+}
+
+// parseYAMLFlow parses an inline value: a scalar or a flow sequence.
+func parseYAMLFlow(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported")
+	case strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">"):
+		return nil, fmt.Errorf("block scalars are not supported")
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		seq := []any{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return seq, nil
+		}
+		for _, item := range splitYAMLFlow(inner) {
+			v, err := parseYAMLScalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+		}
+		return seq, nil
+	}
+	return parseYAMLScalar(s)
+}
+
+// splitYAMLFlow splits a flow sequence's contents on commas outside quotes.
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseYAMLScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s == "~" || s == "null":
+		return "", nil
+	}
+	return s, nil
+}
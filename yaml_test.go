@@ -0,0 +1,69 @@
+package psi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# comment
+plain: value   # trailing
+quoted: "a # not a comment\tx"
+single: 'it''s'
+empty:
+nothing: ~
+flow: [a, "b, c", 'd']
+nested:
+  key: 1
+  deeper:
+    k: v
+list:
+- one
+- two
+items:
+  - name: a
+    args: [x]
+  - name: b
+    env:
+      - K=V
+  - plain
+`
+	got, err := parseYAML(doc)
+	if err != nil {
+		t.Fatalf("parseYAML: %v", err)
+	}
+	want := map[string]any{
+		"plain":   "value",
+		"quoted":  "a # not a comment\tx",
+		"single":  "it's",
+		"empty":   "",
+		"nothing": "",
+		"flow":    []any{"a", "b, c", "d"},
+		"nested":  map[string]any{"key": "1", "deeper": map[string]any{"k": "v"}},
+		"list":    []any{"one", "two"},
+		"items": []any{
+			map[string]any{"name": "a", "args": []any{"x"}},
+			map[string]any{"name": "b", "env": []any{"K=V"}},
+			"plain",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %#v\nwant %#v", got, want)
+	}
+	for _, bad := range []string{
+		"a: 1\n  b: 2",
+		"a: 1\na: 2",
+		"a: {b: 1}",
+		"a: |\n  text",
+		"a: [1, 2",
+		"a: \"open",
+		"\ta: 1",
+		"just a string",
+		"a:\n  - x\n  y: 1",
+	} {
+		if _, err := parseYAML(bad); err == nil {
+			t.Errorf("parseYAML(%q) should fail", bad)
+		}
+	}
+}